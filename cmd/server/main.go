@@ -2,15 +2,17 @@ package main
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"math"
-	"net"
 	"net/http"
-	"net/http/httptrace"
+	"net/mail"
 	"net/url"
+	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -19,30 +21,32 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/harveywai/zenstack/pkg/auth"
 	"github.com/harveywai/zenstack/pkg/catalog"
+	"github.com/harveywai/zenstack/pkg/content"
 	"github.com/harveywai/zenstack/pkg/database"
+	"github.com/harveywai/zenstack/pkg/incident"
 	"github.com/harveywai/zenstack/pkg/infra"
+	"github.com/harveywai/zenstack/pkg/leader"
+	"github.com/harveywai/zenstack/pkg/metrics"
 	"github.com/harveywai/zenstack/pkg/middleware"
+	"github.com/harveywai/zenstack/pkg/monitor"
 	"github.com/harveywai/zenstack/pkg/notify"
+	"github.com/harveywai/zenstack/pkg/oncall"
 	"github.com/harveywai/zenstack/pkg/providers/domain"
 	"github.com/harveywai/zenstack/pkg/scaffolder"
+	"github.com/harveywai/zenstack/pkg/secrets"
+	"github.com/harveywai/zenstack/pkg/statuspage"
+	"github.com/harveywai/zenstack/pkg/synthetic"
+	"github.com/harveywai/zenstack/pkg/telegram"
+	"gorm.io/gorm"
 )
 
 const (
 	// workerPoolSize defines the number of concurrent workers
 	workerPoolSize = 5
-	// criticalThreshold defines the days remaining threshold for critical status
-	criticalThreshold = 7
-	// warningThreshold defines the days remaining threshold for warning status
-	warningThreshold = 30
 )
 
-// SSLScanResult represents the result of a deep SSL certificate scan
-type SSLScanResult struct {
-	DomainName    string
-	ExpiryDate    time.Time
-	DaysRemaining int
-	IsReachable   bool
-}
+// monitorEngine runs all background checks and feeds their events into notifications
+var monitorEngine *monitor.Engine
 
 // ScanResultWithStatus extends ScanResult with security status information
 type ScanResultWithStatus struct {
@@ -68,23 +72,79 @@ func main() {
 		log.Fatalf("failed to initialize database: %v", err)
 	}
 
+	// Load the master keys and encrypt the secrets stored in plaintext or under an
+	// older master key.
+	if err := secrets.Load(); err != nil {
+		log.Fatalf("failed to load master keys: %v", err)
+	}
+	if n, err := notify.RewrapChannelSecrets(); err != nil {
+		log.Printf("warning: failed to encrypt channel secrets: %v", err)
+	} else {
+		if n > 0 {
+			log.Printf("Encrypted the secrets of %d channels with master key %s", n, secrets.Keys().Primary())
+		}
+		// The legacy tables kept as a backup lose their plaintext secrets once the
+		// channels hold them encrypted
+		n, err := database.ScrubLegacySecrets(notify.SealedSecrets)
+		if n > 0 {
+			log.Printf("Cleared %d plaintext secrets from the legacy channel tables", n)
+		}
+		if err != nil {
+			log.Printf("warning: %v", err)
+		}
+	}
+
 	// Seed default admin user if no users exist.
 	if err := database.SeedAdmin(); err != nil {
 		log.Fatalf("failed to seed default admin user: %v", err)
 	}
 
+	// Count database errors for the Prometheus exporter.
+	if err := metrics.InstrumentDB(database.DB); err != nil {
+		log.Printf("warning: failed to instrument database metrics: %v", err)
+	}
+
 	// Initialize Gin router
 	r := gin.Default()
 
 	// Serve HTML dashboard at root
 	r.GET("/", handleDashboard)
 
+	// Prometheus metrics endpoint (optionally protected by ZENSTACK_METRICS_TOKEN)
+	r.GET("/metrics", handleMetrics)
+
 	// Public authentication routes (no AuthMiddleware applied)
 	authPublic := r.Group("/v1/auth")
 	{
 		authPublic.POST("/login", handleLogin)
 	}
 
+	// Public acknowledgement links of on-call pages, authorized by their signed token
+	oncallPublic := r.Group("/v1/oncall")
+	{
+		oncallPublic.GET("/ack/:token", handleAckLink)
+		oncallPublic.POST("/ack/:token", handleAckLink)
+	}
+
+	// Telegram bot webhooks, authorized by the secret registered with Telegram
+	r.POST("/v1/telegram/webhook/:id", handleTelegramWebhook)
+
+	// Public snooze links of notifications, authorized by their signed token
+	silencePublic := r.Group("/v1/silences")
+	{
+		silencePublic.GET("/snooze/:token", handleSnoozeLink)
+		silencePublic.POST("/snooze/:token", handleSnoozeLink)
+	}
+
+	// Public status pages (no AuthMiddleware applied)
+	statusPublic := r.Group("/status")
+	{
+		statusPublic.GET("/:slug", handleStatusPage)
+		statusPublic.GET("/:slug/summary.json", handleStatusPageJSON)
+		statusPublic.GET("/:slug/feed.atom", handleStatusPageAtom)
+		statusPublic.GET("/:slug/feed.rss", handleStatusPageRSS)
+	}
+
 	// Protected API routes
 	v1 := r.Group("/v1")
 	v1.Use(middleware.AuthMiddleware())
@@ -100,6 +160,7 @@ func main() {
 		v1.GET("/infra/status", handleInfraStatus)
 		v1.GET("/infra", handleInfraList)
 		v1.GET("/catalog/:serviceId/docs", handleCatalogDocs)
+		v1.POST("/escalations/:id/ack", handleAcknowledgeEscalation)
 	}
 
 	// Admin-only management routes
@@ -111,15 +172,36 @@ func main() {
 		v1Admin.POST("/users", handleCreateUser)
 		v1Admin.POST("/users/:id/approve", handleApproveUser)
 		v1Admin.POST("/users/:id/reject", handleRejectUser)
+		v1Admin.PUT("/users/:id/contact", handleUpdateUserContact)
 		v1Admin.GET("/dashboard/stats", handleDashboardStats)
 
 		// Admin domain management routes
 		v1Admin.PATCH("/domains/:id", handleUpdateDomain)
 		v1Admin.PUT("/domains/:id", handleUpdateDomain) // Support PUT for compatibility
 		v1Admin.GET("/domains/:id/heartbeats", handleGetDomainHeartbeats)
+		v1Admin.GET("/domains/:id/content/baseline", handleGetContentBaseline)
+		v1Admin.POST("/domains/:id/content/baseline", handleRebaselineContent)
+		v1Admin.GET("/domains/:id/content/changes", handleListContentChanges)
+		v1Admin.GET("/domains/:id/diagnostics", handleListDiagnostics)
+		v1Admin.GET("/diagnostics/:id", handleGetDiagnostic)
+		v1Admin.GET("/domains/:id/latency-baselines", handleListLatencyBaselines)
 		v1Admin.DELETE("/domains/:id", handleDeleteDomain)
 
-		// Notification configuration endpoints
+		// Notification channel endpoints
+		v1Admin.GET("/notifications/channels", handleListChannels)
+		v1Admin.GET("/notifications/channels/types", handleListChannelTypes)
+		v1Admin.GET("/notifications/channels/:id", handleGetChannel)
+		v1Admin.POST("/notifications/channels", handleCreateChannel)
+		v1Admin.PUT("/notifications/channels/:id", handleUpdateChannel)
+		v1Admin.DELETE("/notifications/channels/:id", handleDeleteChannel)
+		v1Admin.POST("/notifications/channels/:id/test", handleTestChannelByID)
+		v1Admin.PUT("/notifications/channels/:id/bot", handleConfigureTelegramBot)
+
+		// Encryption of stored secrets
+		v1Admin.GET("/secrets", handleSecretsStatus)
+		v1Admin.POST("/secrets/rewrap", handleRewrapSecrets)
+
+		// Legacy webhook config endpoints, managing webhook channels
 		v1Admin.GET("/notifications/configs", handleListNotificationConfigs)
 		v1Admin.POST("/notifications/configs", handleCreateNotificationConfig)
 		v1Admin.PUT("/notifications/configs/:id", handleUpdateNotificationConfig)
@@ -130,16 +212,105 @@ func main() {
 		v1Admin.POST("/notifications/templates", handleCreateMessageTemplate)
 		v1Admin.PUT("/notifications/templates/:id", handleUpdateMessageTemplate)
 		v1Admin.DELETE("/notifications/templates/:id", handleDeleteMessageTemplate)
+		v1Admin.PUT("/notifications/templates/:id/variants", handleSaveTemplateVariant)
+		v1Admin.DELETE("/notifications/templates/:id/variants/:variantId", handleDeleteTemplateVariant)
+		v1Admin.POST("/notifications/templates/:id/preview", handlePreviewMessageTemplate)
+		v1Admin.POST("/notifications/templates/:id/test", handleTestMessageTemplate)
+		v1Admin.POST("/notifications/channels/test", handleTestChannel)
 
-		// Telegram notification config endpoints
+		// Legacy Telegram config endpoints, managing Telegram channels
 		v1Admin.GET("/notifications/telegram", handleListTelegramConfigs)
 		v1Admin.POST("/notifications/telegram", handleCreateTelegramConfig)
 		v1Admin.PUT("/notifications/telegram/:id", handleUpdateTelegramConfig)
 		v1Admin.DELETE("/notifications/telegram/:id", handleDeleteTelegramConfig)
 		v1Admin.POST("/notifications/telegram/:id/test", handleTestTelegramConnection)
+		v1Admin.PUT("/notifications/telegram/:id/bot", handleConfigureTelegramBot)
+
+		// Legacy email config endpoints, managing email channels
+		v1Admin.GET("/notifications/email", handleListEmailConfigs)
+		v1Admin.POST("/notifications/email", handleCreateEmailConfig)
+		v1Admin.PUT("/notifications/email/:id", handleUpdateEmailConfig)
+		v1Admin.DELETE("/notifications/email/:id", handleDeleteEmailConfig)
+		v1Admin.POST("/notifications/email/:id/test", handleTestEmailConfig)
+
+		// Notification Routing Rule Routes
+		v1Admin.GET("/notifications/routes", handleListRoutingRules)
+		v1Admin.POST("/notifications/routes", handleCreateRoutingRule)
+		v1Admin.PUT("/notifications/routes/:id", handleUpdateRoutingRule)
+		v1Admin.DELETE("/notifications/routes/:id", handleDeleteRoutingRule)
+		v1Admin.POST("/notifications/routes/test", handleTestRouting)
+
+		// Notification outbox and dead-letter endpoints
+		v1Admin.GET("/notifications/outbox", handleListOutbox)
+		v1Admin.POST("/notifications/outbox/replay", handleReplayDeadLetters)
+		v1Admin.POST("/notifications/outbox/:id/replay", handleReplayOutboxMessage)
+		v1Admin.DELETE("/notifications/outbox/:id", handleDeleteOutboxMessage)
+		v1Admin.GET("/notifications/history", handleListDeliveryHistory)
+		v1Admin.GET("/notifications/groups", handleListNotificationGroups)
+
+		// Scheduled digest endpoints
+		v1Admin.GET("/notifications/digests", handleListDigests)
+		v1Admin.POST("/notifications/digests", handleCreateDigest)
+		v1Admin.PUT("/notifications/digests/:id", handleUpdateDigest)
+		v1Admin.DELETE("/notifications/digests/:id", handleDeleteDigest)
+		v1Admin.POST("/notifications/digests/:id/send", handleSendDigest)
+		v1Admin.GET("/notifications/digests/:id/preview", handlePreviewDigest)
+
+		// Silences suppressing notifications and pages of matching events
+		v1Admin.GET("/silences", handleListSilences)
+		v1Admin.POST("/silences", handleCreateSilence)
+		v1Admin.PUT("/silences/:id", handleUpdateSilence)
+		v1Admin.POST("/silences/:id/expire", handleExpireSilence)
+		v1Admin.GET("/silences/suppressed", handleListSuppressedEvents)
+		v1Admin.POST("/domains/:id/snooze", handleSnoozeDomain)
+
+		// Certificate reminder schedules, globally and per domain tag
+		v1Admin.GET("/reminders/schedules", handleListReminderSchedules)
+		v1Admin.POST("/reminders/schedules", handleCreateReminderSchedule)
+		v1Admin.PUT("/reminders/schedules/:id", handleUpdateReminderSchedule)
+		v1Admin.DELETE("/reminders/schedules/:id", handleDeleteReminderSchedule)
+		v1Admin.GET("/domains/:id/reminders", handleGetDomainReminders)
+
+		// On-call schedule and escalation policy endpoints
+		v1Admin.GET("/oncall/schedules", handleListSchedules)
+		v1Admin.POST("/oncall/schedules", handleCreateSchedule)
+		v1Admin.PUT("/oncall/schedules/:id", handleUpdateSchedule)
+		v1Admin.DELETE("/oncall/schedules/:id", handleDeleteSchedule)
+		v1Admin.GET("/oncall/schedules/:id/on-call", handleGetOnCall)
+		v1Admin.POST("/oncall/schedules/:id/overrides", handleCreateScheduleOverride)
+		v1Admin.DELETE("/oncall/schedules/:id/overrides/:overrideId", handleDeleteScheduleOverride)
+		v1Admin.GET("/oncall/policies", handleListEscalationPolicies)
+		v1Admin.POST("/oncall/policies", handleCreateEscalationPolicy)
+		v1Admin.PUT("/oncall/policies/:id", handleUpdateEscalationPolicy)
+		v1Admin.DELETE("/oncall/policies/:id", handleDeleteEscalationPolicy)
+		v1Admin.GET("/escalations", handleListEscalations)
+		v1Admin.POST("/escalations/:id/resolve", handleResolveEscalation)
 
 		// Settings endpoints (simplified API for Telegram configuration)
 		v1Admin.POST("/settings/telegram", handleSaveTelegramSettings)
+
+		// Status page management endpoints
+		v1Admin.GET("/status-pages", handleListStatusPages)
+		v1Admin.POST("/status-pages", handleCreateStatusPage)
+		v1Admin.PUT("/status-pages/:id", handleUpdateStatusPage)
+		v1Admin.DELETE("/status-pages/:id", handleDeleteStatusPage)
+		v1Admin.POST("/status-pages/:id/components", handleCreateStatusComponent)
+		v1Admin.PUT("/status-pages/:id/components/:componentId", handleUpdateStatusComponent)
+		v1Admin.DELETE("/status-pages/:id/components/:componentId", handleDeleteStatusComponent)
+
+		// Synthetic transaction check endpoints
+		v1Admin.GET("/synthetics", handleListSyntheticChecks)
+		v1Admin.POST("/synthetics", handleCreateSyntheticCheck)
+		v1Admin.PUT("/synthetics/:id", handleUpdateSyntheticCheck)
+		v1Admin.DELETE("/synthetics/:id", handleDeleteSyntheticCheck)
+		v1Admin.POST("/synthetics/:id/run", handleRunSyntheticCheck)
+		v1Admin.GET("/synthetics/:id/runs", handleListSyntheticRuns)
+
+		// Incident management endpoints
+		v1Admin.GET("/incidents", handleListIncidents)
+		v1Admin.POST("/incidents", handleCreateIncident)
+		v1Admin.PUT("/incidents/:id", handleUpdateIncident)
+		v1Admin.POST("/incidents/:id/updates", handleCreateIncidentUpdate)
 	}
 
 	// Dashboard stats endpoint (Admin only) - legacy endpoint for backward compatibility
@@ -149,11 +320,18 @@ func main() {
 		v1Dashboard.GET("/stats", handleDashboardStats)
 	}
 
-	// Start background SSL monitoring task (new version with deep scan)
-	go startSSLScanner()
-
-	// Start background HTTP health monitoring worker (new version)
-	go startLiveMonitor()
+	// Start the monitoring engine (HTTP, SSL, content and synthetic checks). With several
+	// replicas only the elected leader runs it; every replica keeps serving the API.
+	monitorEngine = monitor.NewDefault(monitor.Options{Workers: workerPoolSize})
+	// Bots in polling mode fetch their commands on the leader only, since Telegram
+	// allows one poller per bot
+	monitorEngine.AddTask("telegram-bot", time.Second, telegram.Poll)
+	telegram.Scanner = scanForBot
+	elector, err := leader.New(leader.ConfigFromEnv())
+	if err != nil {
+		log.Fatalf("failed to set up leader election: %v", err)
+	}
+	go elector.Run(context.Background(), monitorEngine.Run)
 
 	// Start server
 	r.Run(":8080")
@@ -1473,6 +1651,11 @@ func handleDashboard(c *gin.Context) {
                         <option value="DingTalk">DingTalk</option>
                         <option value="Feishu">Feishu</option>
                         <option value="Slack">Slack</option>
+                        <option value="WeCom">WeCom</option>
+                        <option value="Discord">Discord</option>
+                        <option value="Teams">Microsoft Teams</option>
+                        <option value="PagerDuty">PagerDuty</option>
+                        <option value="Opsgenie">Opsgenie</option>
                         <option value="Webhook">Generic Webhook</option>
                     </select>
                 </div>
@@ -5249,8 +5432,22 @@ func handleDashboard(c *gin.Context) {
                         if (listResp.ok) {
                             const listData = await listResp.json();
                             const existingConfig = listData.configs && listData.configs[0];
-                            if (existingConfig && existingConfig.tg_token === token && existingConfig.tg_chat_id === chatID) {
+                            // The token isn't returned, so match by chat ID and save the token entered
+                            if (existingConfig && existingConfig.tg_chat_id === chatID) {
                                 configId = existingConfig.id;
+                                const saveResp = await apiFetch("/v1/admin/notifications/telegram/" + configId, {
+                                    method: "PUT",
+                                    headers: { "Content-Type": "application/json" },
+                                    body: JSON.stringify({
+                                        tg_token: token,
+                                        tg_chat_id: chatID,
+                                        is_active: existingConfig.is_active,
+                                    }),
+                                });
+                                if (!saveResp.ok) {
+                                    const data = await saveResp.json();
+                                    throw new Error(data.error || "Failed to save Telegram config");
+                                }
                             }
                         }
 
@@ -6388,8 +6585,12 @@ func handleUpdateDomain(c *gin.Context) {
 	}
 
 	var body struct {
-		Tags         *string `json:"tags"`
-		CustomStatus *string `json:"custom_status"`
+		Tags             *string  `json:"tags"`
+		CustomStatus     *string  `json:"custom_status"`
+		ContentWatch     *bool    `json:"content_watch"`
+		ContentSelector  *string  `json:"content_selector"`
+		ContentThreshold *float64 `json:"content_threshold"`
+		AnomalyAlerts    *bool    `json:"anomaly_alerts"`
 	}
 
 	if err := c.ShouldBindJSON(&body); err != nil {
@@ -6404,9 +6605,34 @@ func handleUpdateDomain(c *gin.Context) {
 	if body.CustomStatus != nil {
 		updateData["custom_status"] = *body.CustomStatus
 	}
+	if body.ContentWatch != nil {
+		updateData["content_watch"] = *body.ContentWatch
+	}
+	if body.ContentSelector != nil {
+		selector := strings.TrimSpace(*body.ContentSelector)
+		if selector != "" {
+			if err := content.ValidateSelector(selector); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid content_selector", "details": err.Error()})
+				return
+			}
+		}
+		updateData["content_selector"] = selector
+	}
+	if body.ContentThreshold != nil {
+		// 0 resets the domain to the default threshold, so alerting on any change takes a
+		// small threshold such as 0.1
+		if *body.ContentThreshold < 0 || *body.ContentThreshold > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("content_threshold must be between 0 (the default of %g) and 100", content.DefaultThreshold)})
+			return
+		}
+		updateData["content_threshold"] = *body.ContentThreshold
+	}
+	if body.AnomalyAlerts != nil {
+		updateData["anomaly_alerts"] = *body.AnomalyAlerts
+	}
 
 	if len(updateData) == 0 {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one field (tags or custom_status) must be provided"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "at least one field (tags, custom_status, content watch or anomaly alert settings) must be provided"})
 		return
 	}
 
@@ -6419,10 +6645,14 @@ func handleUpdateDomain(c *gin.Context) {
 	database.DB.First(&domain, domainID)
 
 	c.JSON(http.StatusOK, gin.H{
-		"id":            domain.ID,
-		"domain_name":   domain.DomainName,
-		"tags":          domain.Tags,
-		"custom_status": domain.CustomStatus,
+		"id":                domain.ID,
+		"domain_name":       domain.DomainName,
+		"tags":              domain.Tags,
+		"custom_status":     domain.CustomStatus,
+		"content_watch":     domain.ContentWatch,
+		"content_selector":  domain.ContentSelector,
+		"content_threshold": domain.ContentThreshold,
+		"anomaly_alerts":    domain.AnomalyAlerts,
 	})
 }
 
@@ -6468,6 +6698,37 @@ func handleDeleteDomain(c *gin.Context) {
 		return
 	}
 
+	// Delete content watch state of the domain
+	if err := tx.Where("domain_id = ?", domainID).Delete(&database.ContentBaseline{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete content baseline"})
+		return
+	}
+	if err := tx.Where("domain_id = ?", domainID).Delete(&database.ContentChange{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete content changes"})
+		return
+	}
+
+	// Delete diagnostic snapshots of the domain
+	if err := tx.Where("domain_id = ?", domainID).Delete(&database.DiagnosticSnapshot{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete diagnostic snapshots"})
+		return
+	}
+
+	// Delete latency baselines and anomaly markers of the domain
+	if err := tx.Where("domain_id = ?", domainID).Delete(&database.LatencyBaseline{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete latency baselines"})
+		return
+	}
+	if err := tx.Where("domain_id = ?", domainID).Delete(&database.LatencyAnomaly{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete latency anomalies"})
+		return
+	}
+
 	// Delete the domain itself (physical delete)
 	if err := tx.Delete(&domain).Error; err != nil {
 		tx.Rollback()
@@ -6480,6 +6741,9 @@ func handleDeleteDomain(c *gin.Context) {
 		return
 	}
 
+	metrics.ForgetDomain(domain.DomainName)
+	monitorEngine.Forget(monitor.DomainKey(domain.ID))
+
 	c.JSON(http.StatusOK, gin.H{
 		"message": "domain and associated heartbeats deleted successfully",
 		"id":      domainID,
@@ -6531,6 +6795,15 @@ func handleGetDomainHeartbeats(c *gin.Context) {
 		return
 	}
 
+	// Latency anomalies of the same period, shown as markers on the latency chart
+	var anomalies []database.LatencyAnomaly
+	if err := database.DB.Where("domain_id = ? AND created_at >= ?", domainID, since).
+		Order("created_at asc").
+		Find(&anomalies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch latency anomalies"})
+		return
+	}
+
 	// Calculate uptime percentage from last 50 checks
 	successCount := 0
 	for _, hb := range recentHeartbeats {
@@ -6545,6 +6818,7 @@ func handleGetDomainHeartbeats(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"heartbeats_24h": heartbeats,
+		"anomalies_24h":  anomalies,
 		"recent_50":      recentHeartbeats,
 		"uptime_percent": uptimePercent,
 		"total_checks":   len(recentHeartbeats),
@@ -6590,7 +6864,10 @@ func handleScan(c *gin.Context) {
 
 		// Save or update domain in database
 		if database.DB != nil {
-			sslStatus := getSSLStatus(result.DaysRemaining)
+			sslStatus := monitor.DefaultThresholds.SSLStatus(result.DaysRemaining)
+			if !result.IsReachable {
+				sslStatus = monitor.SSLOffline
+			}
 			now := time.Now()
 
 			var existingDomain database.MonitoredDomain
@@ -6744,11 +7021,11 @@ func getStatus(result domain.ScanResult) string {
 	}
 
 	// Apply security thresholds for valid certificates
-	if result.DaysRemaining < criticalThreshold {
+	if result.DaysRemaining < monitor.DefaultThresholds.CriticalDays {
 		return "Critical"
 	}
 
-	if result.DaysRemaining < warningThreshold {
+	if result.DaysRemaining < monitor.DefaultThresholds.WarningDays {
 		return "Warning"
 	}
 
@@ -6795,718 +7072,626 @@ func printSummary(totalScanned, atRisk int) {
 	fmt.Println(strings.Repeat("=", 60))
 }
 
-// deepScanSSL performs a deep SSL certificate scan using tls.DialWithDialer with 5s timeout
-func deepScanSSL(domainName string) SSLScanResult {
-	// Set a connection timeout to 5 seconds
-	dialer := &net.Dialer{
-		Timeout: 5 * time.Second,
+// findDomainByParam resolves a domain from a path parameter holding either its ID or its name.
+func findDomainByParam(idParam string) (database.MonitoredDomain, error) {
+	var domain database.MonitoredDomain
+	var domainID uint
+	if _, err := fmt.Sscanf(idParam, "%d", &domainID); err == nil {
+		err := database.DB.First(&domain, domainID).Error
+		return domain, err
 	}
+	err := database.DB.Where("domain_name = ?", idParam).First(&domain).Error
+	return domain, err
+}
 
-	// Connect to port 443 (HTTPS)
-	// InsecureSkipVerify is set to true to fetch info even if the cert is expired
-	conn, err := tls.DialWithDialer(dialer, "tcp", domainName+":443", &tls.Config{
-		InsecureSkipVerify: true,
-	})
+// handleGetContentBaseline returns the current content baseline of a domain
+func handleGetContentBaseline(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
 
+	domain, err := findDomainByParam(c.Param("id"))
 	if err != nil {
-		return SSLScanResult{
-			DomainName:    domainName,
-			IsReachable:   false,
-			DaysRemaining: -1,
-		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "domain not found"})
+		return
 	}
-	defer conn.Close()
 
-	// Get the first certificate in the peer chain
-	if len(conn.ConnectionState().PeerCertificates) == 0 {
-		return SSLScanResult{
-			DomainName:    domainName,
-			IsReachable:   false,
-			DaysRemaining: -1,
-		}
+	var baseline database.ContentBaseline
+	if err := database.DB.Where("domain_id = ?", domain.ID).First(&baseline).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no content baseline recorded for this domain"})
+		return
 	}
 
-	cert := conn.ConnectionState().PeerCertificates[0]
-
-	// Parse PeerCertificates[0].NotAfter to get SSL expiry
-	expiryUTC := cert.NotAfter
-	expiryLocal := expiryUTC.In(time.Local)
-
-	// Calculate days remaining using math.Ceil for more intuitive countdown
-	daysRemaining := int(math.Ceil(time.Until(expiryLocal).Hours() / 24))
-
-	return SSLScanResult{
-		DomainName:    domainName,
-		ExpiryDate:    expiryLocal,
-		DaysRemaining: daysRemaining,
-		IsReachable:   true,
-	}
+	c.JSON(http.StatusOK, baseline)
 }
 
-// getSSLStatus determines the SSL status based on days remaining
-func getSSLStatus(daysRemaining int) string {
-	if daysRemaining < 0 {
-		return "Expired"
-	}
-	if daysRemaining < 7 {
-		return "Critical"
-	}
-	if daysRemaining < 15 {
-		return "Warning"
+// handleRebaselineContent fetches the current content of a domain and accepts it as the new baseline
+func handleRebaselineContent(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
 	}
-	return "Valid"
-}
-
-// startSSLScanner runs in the background and scans all domains every 6 hours
-func startSSLScanner() {
-	// Run immediately on startup, then every 6 hours
-	ticker := time.NewTicker(6 * time.Hour)
-	defer ticker.Stop()
 
-	// Initial scan
-	scanAllDomainsSSL()
+	domain, err := findDomainByParam(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "domain not found"})
+		return
+	}
 
-	// Periodic scans
-	for range ticker.C {
-		scanAllDomainsSSL()
+	baseline, err := content.Rebaseline(domain)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error":   "failed to capture content baseline",
+			"details": err.Error(),
+		})
+		return
 	}
-}
 
-// scanSSLTask is an alias for backward compatibility
-func scanSSLTask() {
-	startSSLScanner()
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "content baseline updated",
+		"baseline": baseline,
+	})
 }
 
-// scanAllDomainsSSL scans all domains in the database and updates SSL information
-func scanAllDomainsSSL() {
+// handleListContentChanges returns the detected content changes of a domain, newest first
+func handleListContentChanges(c *gin.Context) {
 	if database.DB == nil {
-		log.Println("Database not initialized, skipping SSL scan")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
 		return
 	}
 
-	log.Println("Starting background SSL certificate scan...")
-
-	var domains []database.MonitoredDomain
-	if err := database.DB.Find(&domains).Error; err != nil {
-		log.Printf("Error fetching domains for SSL scan: %v", err)
+	domain, err := findDomainByParam(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "domain not found"})
 		return
 	}
 
-	if len(domains) == 0 {
-		log.Println("No domains to scan")
+	var changes []database.ContentChange
+	if err := database.DB.Where("domain_id = ?", domain.ID).
+		Order("created_at desc").
+		Limit(50).
+		Find(&changes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch content changes"})
 		return
 	}
 
-	log.Printf("Scanning %d domains for SSL certificates...", len(domains))
-
-	// Scan domains with a worker pool
-	jobs := make(chan database.MonitoredDomain, len(domains))
-	results := make(chan struct {
-		domain database.MonitoredDomain
-		result SSLScanResult
-	}, len(domains))
+	c.JSON(http.StatusOK, gin.H{"changes": changes})
+}
 
-	var wg sync.WaitGroup
+// handleListDiagnostics returns the diagnostic snapshots of a domain, newest first.
+// Optional filters: incident_id, heartbeat_id and trigger (failure or transition).
+func handleListDiagnostics(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
 
-	// Start workers
-	for i := 0; i < workerPoolSize; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for d := range jobs {
-				result := deepScanSSL(d.DomainName)
-				results <- struct {
-					domain database.MonitoredDomain
-					result SSLScanResult
-				}{domain: d, result: result}
-			}
-		}()
+	domain, err := findDomainByParam(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "domain not found"})
+		return
 	}
 
-	// Send jobs
-	for _, d := range domains {
-		jobs <- d
+	query := database.DB.Where("domain_id = ?", domain.ID)
+	if incidentID := c.Query("incident_id"); incidentID != "" {
+		query = query.Where("incident_id = ?", incidentID)
+	}
+	if heartbeatID := c.Query("heartbeat_id"); heartbeatID != "" {
+		query = query.Where("heartbeat_id = ?", heartbeatID)
+	}
+	if trigger := c.Query("trigger"); trigger != "" {
+		query = query.Where("`trigger` = ?", trigger)
 	}
-	close(jobs)
 
-	// Wait for completion
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
-
-	// Process results
-	updated := 0
-	for res := range results {
-		sslStatus := getSSLStatus(res.result.DaysRemaining)
-		now := time.Now()
-
-		updateData := map[string]interface{}{
-			"ssl_expiry":      res.result.ExpiryDate,
-			"ssl_status":      sslStatus,
-			"last_check_time": now,
-		}
+	var snapshots []database.DiagnosticSnapshot
+	if err := query.Order("created_at desc").Limit(50).Find(&snapshots).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch diagnostic snapshots"})
+		return
+	}
 
-		// If domain is not reachable, set status to "Offline"
-		if !res.result.IsReachable {
-			updateData["ssl_status"] = "Offline"
-		}
+	c.JSON(http.StatusOK, gin.H{"diagnostics": snapshots})
+}
 
-		if err := database.DB.Model(&res.domain).Updates(updateData).Error; err != nil {
-			log.Printf("Error updating domain %s: %v", res.domain.DomainName, err)
-		} else {
-			updated++
-
-			// Check if we need to send a notification for SSL Expiring (days remaining < 7)
-			if res.result.DaysRemaining < 7 && res.result.DaysRemaining >= 0 {
-				// Reload domain to get updated SSL status
-				var updatedDomain database.MonitoredDomain
-				if err := database.DB.First(&updatedDomain, res.domain.ID).Error; err == nil {
-					// Check if notification was sent in the last 24 hours
-					shouldNotify := false
-					if updatedDomain.LastNotificationSent.IsZero() {
-						// Never sent a notification before
-						shouldNotify = true
-					} else {
-						// Check if 24 hours have passed since last notification
-						timeSinceLastNotification := now.Sub(updatedDomain.LastNotificationSent)
-						if timeSinceLastNotification >= 24*time.Hour {
-							shouldNotify = true
-						}
-					}
-
-					if shouldNotify {
-						// Get message template for SSL Risk event
-						var template database.MessageTemplate
-						if err := database.DB.Where("event_name = ? OR name = ?", "SSL_CRITICAL", "SSLExpired").First(&template).Error; err == nil {
-							// Prepare data for template
-							data := map[string]string{
-								"domain":         updatedDomain.DomainName,
-								"days":           fmt.Sprintf("%d", res.result.DaysRemaining),
-								"days_remaining": fmt.Sprintf("%d", res.result.DaysRemaining),
-								"expiry":         updatedDomain.SSLExpiry.Format("2006-01-02 15:04:05"),
-								"expiry_date":    updatedDomain.SSLExpiry.Format("2006-01-02"),
-							}
-
-							// Format template text
-							telegramText := notify.ParseTemplate(template.TemplateText, data)
-							if telegramText == "" {
-								// Fallback message if template is empty
-								telegramText = fmt.Sprintf("🔒 证书预警：域名 %s 的 SSL 证书将在 %d 天后过期。", updatedDomain.DomainName, res.result.DaysRemaining)
-							}
-
-							// Send to Telegram using sendTelegramAlert function
-							if err := notify.SendTelegramAlert(telegramText); err != nil {
-								log.Printf("Failed to send Telegram notification for domain %s: %v", updatedDomain.DomainName, err)
-							} else {
-								log.Printf("Telegram notification sent for SSL Risk domain: %s (Days: %d)", updatedDomain.DomainName, res.result.DaysRemaining)
-								// Update last_notification_sent timestamp
-								database.DB.Model(&updatedDomain).Update("last_notification_sent", now)
-							}
-						} else {
-							log.Printf("No template found for SSL Risk event, using default message")
-							// Send default message if no template found
-							defaultMessage := fmt.Sprintf("🔒 证书预警：域名 %s 的 SSL 证书将在 %d 天后过期。", updatedDomain.DomainName, res.result.DaysRemaining)
-							if err := notify.SendTelegramAlert(defaultMessage); err != nil {
-								log.Printf("Failed to send Telegram notification for domain %s: %v", updatedDomain.DomainName, err)
-							}
-						}
-					}
-				}
-			}
-		}
+// handleGetDiagnostic returns a single diagnostic snapshot
+func handleGetDiagnostic(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
 	}
 
-	log.Printf("SSL scan completed. Updated %d/%d domains", updated, len(domains))
-}
+	var snapshot database.DiagnosticSnapshot
+	if err := database.DB.First(&snapshot, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "diagnostic snapshot not found"})
+		return
+	}
 
-// HealthCheckResult represents the result of an HTTP health check
-type HealthCheckResult struct {
-	DomainName    string
-	IsLive        bool
-	StatusCode    int
-	ResponseTime  int // Total response time in milliseconds
-	DNSLookup     int // DNS lookup time in milliseconds
-	TCPConnection int // TCP connection time in milliseconds
-	TLSHandshake  int // TLS handshake time in milliseconds (0 for HTTP)
-	TTFB          int // Time to First Byte in milliseconds
+	c.JSON(http.StatusOK, snapshot)
 }
 
-// checkDomainHealth performs an HTTP health check on a domain using httptrace
-// Captures detailed timing metrics: DNS lookup, TCP connection, TLS handshake, TTFB
-func checkDomainHealth(domainName string) HealthCheckResult {
-	// Try HTTPS first, then HTTP
-	urls := []string{
-		"https://" + domainName,
-		"http://" + domainName,
+// handleListLatencyBaselines returns the latency baselines of a domain per probe phase,
+// over all hours (hour -1) and per hour of the day.
+func handleListLatencyBaselines(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
 	}
 
-	client := &http.Client{
-		Timeout: 5 * time.Second,
+	domain, err := findDomainByParam(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "domain not found"})
+		return
 	}
 
-	for _, urlStr := range urls {
-		result := checkDomainHealthWithTrace(client, urlStr, domainName)
-		if result.IsLive {
-			return result
-		}
+	var baselines []database.LatencyBaseline
+	if err := database.DB.Where("domain_id = ?", domain.ID).Order("hour asc, phase asc").Find(&baselines).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch latency baselines"})
+		return
 	}
 
-	// If both HTTPS and HTTP failed, domain is not live
-	return HealthCheckResult{
-		DomainName:    domainName,
-		IsLive:        false,
-		StatusCode:    0,
-		ResponseTime:  0,
-		DNSLookup:     0,
-		TCPConnection: 0,
-		TLSHandshake:  0,
-		TTFB:          0,
-	}
+	c.JSON(http.StatusOK, gin.H{"baselines": baselines})
 }
 
-// checkDomainHealthWithTrace performs HTTP request with detailed timing using httptrace
-func checkDomainHealthWithTrace(client *http.Client, urlStr, domainName string) HealthCheckResult {
-	var dnsStart, dnsDone, connectStart, connectDone, tlsStart, tlsDone, gotFirstByte time.Time
-	var dnsLookup, tcpConnection, tlsHandshake, ttfb int
-
-	startTime := time.Now()
+// Notification Channel Handlers
+
+// channelRequest is the body of channel create and update requests. Settings are
+// merged into the current settings of the channel, so omitted keys, such as secrets,
+// keep their value.
+type channelRequest struct {
+	Type          *string         `json:"type"`
+	Name          *string         `json:"name"`
+	Locale        *string         `json:"locale"`
+	IsActive      *bool           `json:"is_active"`
+	Settings      json.RawMessage `json:"settings"`
+	QuietStart    *string         `json:"quiet_start"`
+	QuietEnd      *string         `json:"quiet_end"`
+	TimeZone      *string         `json:"time_zone"`
+	QuietSeverity *string         `json:"quiet_severity"`
+}
 
-	// Create request with context
-	req, err := http.NewRequest("GET", urlStr, nil)
-	if err != nil {
-		return HealthCheckResult{
-			DomainName:    domainName,
-			IsLive:        false,
-			StatusCode:    0,
-			ResponseTime:  int(time.Since(startTime).Milliseconds()),
-			DNSLookup:     0,
-			TCPConnection: 0,
-			TLSHandshake:  0,
-			TTFB:          0,
+// apply copies the provided fields onto the channel and validates its settings and
+// quiet hours.
+func (r channelRequest) apply(ch *database.Channel) error {
+	if r.Type != nil {
+		typ := strings.ToLower(strings.TrimSpace(*r.Type))
+		if ch.ID != 0 && typ != ch.Type {
+			return fmt.Errorf("the type of a channel can't be changed")
 		}
+		ch.Type = typ
 	}
-
-	// Create trace context
-	trace := &httptrace.ClientTrace{
-		DNSStart: func(dsi httptrace.DNSStartInfo) {
-			dnsStart = time.Now()
-		},
-		DNSDone: func(ddi httptrace.DNSDoneInfo) {
-			dnsDone = time.Now()
-			if dnsDone.After(dnsStart) {
-				dnsLookup = int(dnsDone.Sub(dnsStart).Milliseconds())
-			}
-		},
-		ConnectStart: func(network, addr string) {
-			connectStart = time.Now()
-		},
-		ConnectDone: func(network, addr string, err error) {
-			connectDone = time.Now()
-			if connectDone.After(connectStart) {
-				// TCP connection time is the duration between ConnectStart and ConnectDone
-				tcpConnection = int(connectDone.Sub(connectStart).Milliseconds())
-			}
-		},
-		TLSHandshakeStart: func() {
-			tlsStart = time.Now()
-		},
-		TLSHandshakeDone: func(cs tls.ConnectionState, err error) {
-			tlsDone = time.Now()
-			if tlsDone.After(tlsStart) {
-				tlsHandshake = int(tlsDone.Sub(tlsStart).Milliseconds())
-			}
-		},
-		GotFirstResponseByte: func() {
-			gotFirstByte = time.Now()
-			// Calculate TTFB from connection done (or TLS done if HTTPS)
-			if !tlsDone.IsZero() {
-				ttfb = int(gotFirstByte.Sub(tlsDone).Milliseconds())
-			} else if !connectDone.IsZero() {
-				ttfb = int(gotFirstByte.Sub(connectDone).Milliseconds())
-			} else {
-				ttfb = int(gotFirstByte.Sub(startTime).Milliseconds())
-			}
-		},
+	if ch.Type == "" {
+		return fmt.Errorf("type is required")
 	}
-
-	// Add trace to request context
-	ctx := httptrace.WithClientTrace(req.Context(), trace)
-	req = req.WithContext(ctx)
-
-	// Perform request
-	resp, err := client.Do(req)
-	if err != nil {
-		// Calculate total time even on error
-		totalTime := int(time.Since(startTime).Milliseconds())
-		return HealthCheckResult{
-			DomainName:    domainName,
-			IsLive:        false,
-			StatusCode:    0,
-			ResponseTime:  totalTime,
-			DNSLookup:     dnsLookup,
-			TCPConnection: tcpConnection,
-			TLSHandshake:  tlsHandshake,
-			TTFB:          ttfb,
-		}
+	if r.Name != nil {
+		ch.Name = strings.TrimSpace(*r.Name)
 	}
-	defer resp.Body.Close()
-
-	// Calculate total response time
-	responseTime := int(time.Since(startTime).Milliseconds())
-
-	// Consider 2xx and 3xx status codes as "live"
-	isLive := resp.StatusCode >= 200 && resp.StatusCode < 400
-
-	// If some metrics are still 0, try to estimate from total time
-	if dnsLookup == 0 && tcpConnection == 0 && tlsHandshake == 0 && ttfb == 0 {
-		// Fallback: estimate proportions (rough approximation)
-		if tlsHandshake > 0 {
-			// HTTPS: assume DNS 10%, TCP 20%, TLS 40%, TTFB 30%
-			dnsLookup = responseTime * 10 / 100
-			tcpConnection = responseTime * 20 / 100
-			ttfb = responseTime * 30 / 100
-		} else {
-			// HTTP: assume DNS 20%, TCP 30%, TTFB 50%
-			dnsLookup = responseTime * 20 / 100
-			tcpConnection = responseTime * 30 / 100
-			ttfb = responseTime * 50 / 100
-		}
+	if r.Locale != nil {
+		ch.Locale = strings.TrimSpace(*r.Locale)
 	}
-
-	return HealthCheckResult{
-		DomainName:    domainName,
-		IsLive:        isLive,
-		StatusCode:    resp.StatusCode,
-		ResponseTime:  responseTime,
-		DNSLookup:     dnsLookup,
-		TCPConnection: tcpConnection,
-		TLSHandshake:  tlsHandshake,
-		TTFB:          ttfb,
-	}
-}
-
-// startLiveMonitor runs in the background and checks domain health every 2 minutes
-// It performs HTTP GET requests to check if domains are live and updates IsLive and LastStatusCode
-func startLiveMonitor() {
-	// Run immediately on startup
-	checkAllDomainsHealth()
-	cleanupOldHeartbeats()
-
-	// Use a ticker that runs every 2 minutes
-	ticker := time.NewTicker(2 * time.Minute)
-	defer ticker.Stop()
-
-	// Cleanup ticker - runs every 6 hours to keep only last 24 hours of data
-	cleanupTicker := time.NewTicker(6 * time.Hour)
-	defer cleanupTicker.Stop()
-
-	// Periodic checks
-	for {
-		select {
-		case <-ticker.C:
-			checkAllDomainsHealth()
-		case <-cleanupTicker.C:
-			cleanupOldHeartbeats()
-		}
+	if r.IsActive != nil {
+		ch.IsActive = *r.IsActive
+	}
+	if r.QuietStart != nil {
+		ch.QuietStart = *r.QuietStart
+	}
+	if r.QuietEnd != nil {
+		ch.QuietEnd = *r.QuietEnd
+	}
+	if r.TimeZone != nil {
+		ch.TimeZone = *r.TimeZone
+	}
+	if r.QuietSeverity != nil {
+		ch.QuietSeverity = *r.QuietSeverity
 	}
+	if err := notify.ValidateQuietHours(ch); err != nil {
+		return err
+	}
+	return notify.ApplySettings(ch, r.Settings)
 }
 
-// cleanupOldHeartbeats removes heartbeats older than 24 hours to ensure performance
-func cleanupOldHeartbeats() {
-	if database.DB == nil {
-		return
+// saveChannel creates or updates a channel. It responds with the error and returns
+// false if that fails.
+func saveChannel(c *gin.Context, ch *database.Channel) bool {
+	var err error
+	if ch.ID == 0 {
+		err = notify.CreateChannel(database.DB, ch)
+	} else {
+		// Select all columns so that false and empty values are saved too; the cursor
+		// is advanced by the Telegram bot in the background
+		err = database.DB.Model(ch).Select("*").Omit("created_at", "cursor").Updates(ch).Error
 	}
-
-	cutoff := time.Now().Add(-24 * time.Hour) // Keep only last 24 hours
-	result := database.DB.Where("created_at < ?", cutoff).Delete(&database.Heartbeat{})
-	if result.Error != nil {
-		log.Printf("Error cleaning up old heartbeats: %v", result.Error)
-	} else if result.RowsAffected > 0 {
-		log.Printf("Cleaned up %d old heartbeats (older than 24 hours)", result.RowsAffected)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save channel"})
+		return false
 	}
+	return true
 }
 
-// checkSiteLive is an alias for startLiveMonitor (backward compatibility)
-func checkSiteLive() {
-	startLiveMonitor()
+// respondChannel responds with the API view of a channel.
+func respondChannel(c *gin.Context, status int, ch database.Channel) {
+	view, err := notify.ViewChannel(ch)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(status, view)
 }
 
-// startHealthCheck is an alias for checkSiteLive (backward compatibility)
-func startHealthCheck() {
-	checkSiteLive()
+// findChannelByParam loads the channel of the :id parameter, optionally of a type. It
+// responds with 404 and returns false if there is none.
+func findChannelByParam(c *gin.Context, typ, what string) (database.Channel, bool) {
+	var ch database.Channel
+	query := database.DB.Where("id = ?", uintParam(c, "id"))
+	if typ != "" {
+		query = query.Where("type = ?", typ)
+	}
+	if err := query.Limit(1).Find(&ch).Error; err != nil || ch.ID == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": what + " not found"})
+		return ch, false
+	}
+	return ch, true
 }
 
-// startHealthChecker is an alias for startHealthCheck (backward compatibility)
-func startHealthChecker() {
-	startHealthCheck()
+// handleListChannelTypes returns the channel types and the schemas of their settings
+func handleListChannelTypes(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"types": notify.ChannelTypes()})
 }
 
-// checkAllDomainsHealth checks the HTTP health of all domains in the database
-func checkAllDomainsHealth() {
+// handleListChannels returns all notification channels, optionally of one type
+func handleListChannels(c *gin.Context) {
 	if database.DB == nil {
-		log.Println("Database not initialized, skipping health check")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
 		return
 	}
 
-	log.Println("Starting background HTTP health check...")
-
-	var domains []database.MonitoredDomain
-	if err := database.DB.Find(&domains).Error; err != nil {
-		log.Printf("Error fetching domains for health check: %v", err)
-		return
+	query := database.DB.Order("id")
+	if typ := c.Query("type"); typ != "" {
+		query = query.Where("type = ?", strings.ToLower(typ))
 	}
-
-	if len(domains) == 0 {
-		log.Println("No domains to check")
+	var channels []database.Channel
+	if err := query.Find(&channels).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list channels"})
 		return
 	}
 
-	log.Printf("Checking HTTP health for %d domains...", len(domains))
-
-	// Check domains with a worker pool
-	jobs := make(chan database.MonitoredDomain, len(domains))
-	results := make(chan struct {
-		domain database.MonitoredDomain
-		result HealthCheckResult
-	}, len(domains))
-
-	var wg sync.WaitGroup
-
-	// Start workers
-	for i := 0; i < workerPoolSize; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for d := range jobs {
-				result := checkDomainHealth(d.DomainName)
-				results <- struct {
-					domain database.MonitoredDomain
-					result HealthCheckResult
-				}{domain: d, result: result}
-			}
-		}()
-	}
-
-	// Send jobs
-	for _, d := range domains {
-		jobs <- d
-	}
-	close(jobs)
-
-	// Wait for completion
-	go func() {
-		wg.Wait()
-		close(results)
-	}()
-
-	// Process results
-	updated := 0
-	for res := range results {
-		// Track previous state to detect transitions from Live to Down
-		wasLive := res.domain.IsLive
-		nowLive := res.result.IsLive
-
-		updateData := map[string]interface{}{
-			"is_live":          res.result.IsLive,
-			"status_code":      res.result.StatusCode,
-			"last_status_code": res.result.StatusCode, // Update LastStatusCode
-			"response_time":    res.result.ResponseTime,
-		}
-
-		if err := database.DB.Model(&res.domain).Updates(updateData).Error; err != nil {
-			log.Printf("Error updating domain health %s: %v", res.domain.DomainName, err)
-		} else {
-			updated++
-
-			// Record heartbeat for charting with detailed metrics
-			heartbeat := database.Heartbeat{
-				DomainID:      res.domain.ID,
-				Latency:       res.result.ResponseTime,
-				StatusCode:    res.result.StatusCode,
-				DNSLookup:     res.result.DNSLookup,
-				TCPConnection: res.result.TCPConnection,
-				TLSHandshake:  res.result.TLSHandshake,
-				TTFB:          res.result.TTFB,
-				NodeLocation:  "Japan-Nagoya", // Default monitoring node location
-				CreatedAt:     time.Now(),
-			}
-			if err := database.DB.Create(&heartbeat).Error; err != nil {
-				log.Printf("Error creating heartbeat for domain %s: %v", res.domain.DomainName, err)
-			}
-
-			// Trigger Telegram notification if site transitions from Live to Down
-			if wasLive && !nowLive {
-				// Site went from Live to Down - immediately send Telegram notification
-				log.Printf("Domain %s transitioned from Live to Down, sending Telegram notification", res.domain.DomainName)
-
-				// Get message template for SITE_DOWN event
-				var template database.MessageTemplate
-				if err := database.DB.Where("event_name = ? OR name = ?", "SITE_DOWN", "SiteDown").First(&template).Error; err == nil {
-					// Prepare data for template
-					data := map[string]string{
-						"domain":      res.domain.DomainName,
-						"status":      fmt.Sprintf("%d", res.result.StatusCode),
-						"status_code": fmt.Sprintf("%d", res.result.StatusCode),
-						"code":        fmt.Sprintf("%d", res.result.StatusCode), // Alias for code variable
-					}
-
-					// Format template text
-					telegramText := notify.ParseTemplate(template.TemplateText, data)
-					if telegramText == "" {
-						// Fallback message if template is empty
-						telegramText = fmt.Sprintf("🚨 告警：站点 %s 无法访问！状态码：%d", res.domain.DomainName, res.result.StatusCode)
-					}
-
-					// Send to Telegram using sendTelegramAlert function
-					// sendTelegramAlert automatically reads config from database
-					if err := notify.SendTelegramAlert(telegramText); err != nil {
-						log.Printf("Failed to send Telegram notification for domain %s: %v", res.domain.DomainName, err)
-					} else {
-						log.Printf("Telegram notification sent for domain %s (Live -> Down)", res.domain.DomainName)
-					}
-				} else {
-					log.Printf("No template found for SITE_DOWN event, using default message")
-					// Send default message if no template found
-					defaultMessage := fmt.Sprintf("🚨 告警：站点 %s 无法访问！状态码：%d", res.domain.DomainName, res.result.StatusCode)
-					if err := notify.SendTelegramAlert(defaultMessage); err != nil {
-						log.Printf("Failed to send Telegram notification for domain %s: %v", res.domain.DomainName, err)
-					}
-				}
-			}
+	views := make([]notify.ChannelView, 0, len(channels))
+	for _, ch := range channels {
+		view, err := notify.ViewChannel(ch)
+		if err != nil {
+			log.Printf("Error viewing channel %d: %v", ch.ID, err)
+			continue
 		}
+		views = append(views, view)
 	}
 
-	log.Printf("Health check completed. Updated %d/%d domains", updated, len(domains))
+	c.JSON(http.StatusOK, gin.H{"channels": views})
 }
 
-// Notification Configuration Handlers
-
-// handleListNotificationConfigs returns all notification configurations
-func handleListNotificationConfigs(c *gin.Context) {
+// handleGetChannel returns a notification channel
+func handleGetChannel(c *gin.Context) {
 	if database.DB == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
 		return
 	}
 
-	var configs []database.NotificationConfig
-	if err := database.DB.Order("created_at desc").Find(&configs).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list notification configs"})
+	ch, ok := findChannelByParam(c, "", "channel")
+	if !ok {
 		return
 	}
-
-	c.JSON(http.StatusOK, gin.H{"configs": configs})
+	respondChannel(c, http.StatusOK, ch)
 }
 
-// handleCreateNotificationConfig creates a new notification configuration
-func handleCreateNotificationConfig(c *gin.Context) {
+// handleCreateChannel creates a notification channel, active unless is_active is false
+func handleCreateChannel(c *gin.Context) {
 	if database.DB == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
 		return
 	}
 
-	var body struct {
-		WebhookURL string `json:"webhook_url"`
-		SecretKey  string `json:"secret_key"`
-		Platform   string `json:"platform"`
-		IsActive   bool   `json:"is_active"`
-	}
-
+	var body channelRequest
 	if err := c.ShouldBindJSON(&body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
 		return
 	}
 
-	if body.WebhookURL == "" || body.Platform == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "webhook_url and platform are required"})
+	ch := database.Channel{IsActive: true}
+	if err := body.apply(&ch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	config := database.NotificationConfig{
-		WebhookURL: body.WebhookURL,
-		SecretKey:  body.SecretKey,
-		Platform:   body.Platform,
-		IsActive:   body.IsActive,
-	}
-
-	if err := database.DB.Create(&config).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create notification config"})
+	if !saveChannel(c, &ch) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, config)
+	respondChannel(c, http.StatusCreated, ch)
 }
 
-// handleUpdateNotificationConfig updates an existing notification configuration
-func handleUpdateNotificationConfig(c *gin.Context) {
+// handleUpdateChannel updates a notification channel. Omitted fields and settings
+// keep their value.
+func handleUpdateChannel(c *gin.Context) {
 	if database.DB == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
 		return
 	}
 
-	id := c.Param("id")
-	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "config ID is required"})
-		return
-	}
-
-	var config database.NotificationConfig
-	if err := database.DB.First(&config, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "notification config not found"})
+	ch, ok := findChannelByParam(c, "", "channel")
+	if !ok {
 		return
 	}
 
-	var body struct {
-		WebhookURL string `json:"webhook_url"`
-		SecretKey  string `json:"secret_key"`
-		Platform   string `json:"platform"`
-		IsActive   bool   `json:"is_active"`
-	}
-
+	var body channelRequest
 	if err := c.ShouldBindJSON(&body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
 		return
 	}
 
-	updateData := map[string]interface{}{
-		"platform":  body.Platform,
-		"is_active": body.IsActive,
-	}
-
-	if body.WebhookURL != "" {
-		updateData["webhook_url"] = body.WebhookURL
-	}
-	if body.SecretKey != "" {
-		updateData["secret_key"] = body.SecretKey
+	if err := body.apply(&ch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
-
-	if err := database.DB.Model(&config).Updates(updateData).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update notification config"})
+	if !saveChannel(c, &ch) {
 		return
 	}
 
-	c.JSON(http.StatusOK, config)
+	respondChannel(c, http.StatusOK, ch)
 }
 
-// handleDeleteNotificationConfig deletes a notification configuration
-func handleDeleteNotificationConfig(c *gin.Context) {
+// handleDeleteChannel deletes a notification channel
+func handleDeleteChannel(c *gin.Context) {
 	if database.DB == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
 		return
 	}
 
-	id := c.Param("id")
-	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "config ID is required"})
+	if err := database.DB.Delete(&database.Channel{}, uintParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete channel"})
 		return
 	}
 
-	if err := database.DB.Delete(&database.NotificationConfig{}, id).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete notification config"})
+	c.JSON(http.StatusOK, gin.H{"message": "channel deleted"})
+}
+
+// handleTestChannelByID sends a test message to a notification channel
+func handleTestChannelByID(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "notification config deleted"})
+	model, ok := findChannelByParam(c, "", "channel")
+	if !ok {
+		return
+	}
+	ch, err := notify.LoadChannel(notify.ChannelRef(model.Type, model.ID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := notify.SendTest(c.Request.Context(), ch, notify.TestMessage()); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to send test message", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Test message sent successfully", "channel": ch})
+}
+
+// Secret Encryption Handlers
+
+// handleSecretsStatus returns the master keys and how many stored secrets are sealed
+// under each of them
+func handleSecretsStatus(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	keys := secrets.Keys()
+	if keys == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "master keys not loaded"})
+		return
+	}
+	counts, err := notify.ChannelSecretKeys()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to count secrets"})
+		return
+	}
+
+	plaintext := counts[""]
+	delete(counts, "")
+	c.JSON(http.StatusOK, gin.H{
+		"primary_key": keys.Primary(),
+		"keys":        keys.IDs(),
+		"sealed":      counts,    // Secrets by the ID of the master key sealing them
+		"plaintext":   plaintext, // Secrets not encrypted yet
+	})
+}
+
+// handleRewrapSecrets encrypts the secrets stored in plaintext or under an older
+// master key with the primary key, which the server also does on startup
+func handleRewrapSecrets(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	n, err := notify.RewrapChannelSecrets()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to rewrap secrets", "details": err.Error(), "channels": n})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"channels": n, "primary_key": secrets.Keys().Primary()})
+}
+
+// Legacy Notification Config Handlers
+//
+// The webhook, Telegram and email config endpoints predate channels and are kept for
+// existing clients and the dashboard. They manage the channels of one type in the
+// response shapes of the former config tables, without the secrets.
+
+// legacyPatch encodes the settings given to a legacy endpoint. Empty values are left
+// out, so they keep their current value.
+func legacyPatch(settings map[string]interface{}) json.RawMessage {
+	for k, v := range settings {
+		if s, ok := v.(string); ok && s == "" {
+			delete(settings, k)
+		}
+	}
+	patch, _ := json.Marshal(settings)
+	return patch
+}
+
+// listLegacyChannels responds with the channels of a type in their legacy shape.
+func listLegacyChannels(c *gin.Context, typ string, shape func(database.Channel) interface{}) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var channels []database.Channel
+	if err := database.DB.Where("type = ?", typ).Order("created_at desc").Find(&channels).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list " + typ + " configs"})
+		return
+	}
+
+	configs := make([]interface{}, 0, len(channels))
+	for _, ch := range channels {
+		configs = append(configs, shape(ch))
+	}
+	c.JSON(http.StatusOK, gin.H{"configs": configs})
+}
+
+// deleteLegacyChannel deletes the channel of the :id parameter if it has the type.
+func deleteLegacyChannel(c *gin.Context, typ, what string) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	if err := database.DB.Where("type = ?", typ).Delete(&database.Channel{}, uintParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete " + what})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": what + " deleted"})
+}
+
+// webhookConfig returns a webhook channel in the shape of the former webhook configs.
+// The secret key is left empty, like the secrets of the other shapes, and the URL is
+// redacted to its host since it often carries a token.
+func webhookConfig(ch database.Channel) database.NotificationConfig {
+	config := database.NotificationConfig{ID: ch.ID, Locale: ch.Locale, IsActive: ch.IsActive, CreatedAt: ch.CreatedAt, UpdatedAt: ch.UpdatedAt}
+	if s, err := notify.DecodeSettings(ch); err == nil {
+		settings := s.(*notify.WebhookSettings)
+		config.Platform, config.WebhookURL = settings.Platform, notify.RedactURL(settings.URL)
+	}
+	return config
+}
+
+// telegramConfig returns a Telegram channel in the shape of the former Telegram configs.
+func telegramConfig(ch database.Channel) database.NotifyConfig {
+	config := database.NotifyConfig{ID: ch.ID, Locale: ch.Locale, IsActive: ch.IsActive, CreatedAt: ch.CreatedAt, UpdatedAt: ch.UpdatedAt}
+	if s, err := notify.DecodeSettings(ch); err == nil {
+		settings := s.(*notify.TelegramSettings)
+		config.TGChatID, config.BotMode = settings.ChatID, settings.BotMode
+	}
+	return config
+}
+
+// emailConfig returns an email channel in the shape of the former email configs.
+func emailConfig(ch database.Channel) database.EmailConfig {
+	config := database.EmailConfig{ID: ch.ID, Name: ch.Name, Locale: ch.Locale, IsActive: ch.IsActive, CreatedAt: ch.CreatedAt, UpdatedAt: ch.UpdatedAt}
+	if s, err := notify.DecodeSettings(ch); err == nil {
+		settings := s.(*notify.EmailSettings)
+		config.Host, config.Port, config.Security = settings.Host, settings.Port, settings.Security
+		config.Username, config.From, config.Recipients = settings.Username, settings.From, settings.Recipients
+	}
+	return config
+}
+
+// handleListNotificationConfigs returns all webhook channels
+func handleListNotificationConfigs(c *gin.Context) {
+	listLegacyChannels(c, notify.ChannelWebhook, func(ch database.Channel) interface{} { return webhookConfig(ch) })
+}
+
+// handleCreateNotificationConfig creates a webhook channel
+func handleCreateNotificationConfig(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var body struct {
+		WebhookURL string `json:"webhook_url"`
+		SecretKey  string `json:"secret_key"`
+		Platform   string `json:"platform"`
+		Locale     string `json:"locale"`
+		IsActive   bool   `json:"is_active"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	ch := database.Channel{Type: notify.ChannelWebhook, Locale: strings.TrimSpace(body.Locale), IsActive: body.IsActive}
+	patch := legacyPatch(map[string]interface{}{"platform": body.Platform, "url": body.WebhookURL, "secret": body.SecretKey})
+	if err := notify.ApplySettings(&ch, patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !saveChannel(c, &ch) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, webhookConfig(ch))
+}
+
+// handleUpdateNotificationConfig updates a webhook channel. An empty or redacted webhook
+// URL or an empty secret key keeps the current one.
+func handleUpdateNotificationConfig(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	ch, ok := findChannelByParam(c, notify.ChannelWebhook, "notification config")
+	if !ok {
+		return
+	}
+
+	var body struct {
+		WebhookURL string  `json:"webhook_url"`
+		SecretKey  string  `json:"secret_key"`
+		Platform   string  `json:"platform"`
+		Locale     *string `json:"locale"`
+		IsActive   bool    `json:"is_active"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	ch.IsActive = body.IsActive
+	if body.Locale != nil {
+		ch.Locale = strings.TrimSpace(*body.Locale)
+	}
+	patch := legacyPatch(map[string]interface{}{"platform": body.Platform, "url": body.WebhookURL, "secret": body.SecretKey})
+	if err := notify.ApplySettings(&ch, patch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !saveChannel(c, &ch) {
+		return
+	}
+
+	c.JSON(http.StatusOK, webhookConfig(ch))
+}
+
+// handleDeleteNotificationConfig deletes a webhook channel
+func handleDeleteNotificationConfig(c *gin.Context) {
+	deleteLegacyChannel(c, notify.ChannelWebhook, "notification config")
 }
 
 // Message Template Handlers
@@ -7519,7 +7704,9 @@ func handleListMessageTemplates(c *gin.Context) {
 	}
 
 	var templates []database.MessageTemplate
-	if err := database.DB.Order("created_at desc").Find(&templates).Error; err != nil {
+	if err := database.DB.Preload("Variants", func(db *gorm.DB) *gorm.DB {
+		return db.Order("locale asc, format asc")
+	}).Order("created_at desc").Find(&templates).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list message templates"})
 		return
 	}
@@ -7538,6 +7725,7 @@ func handleCreateMessageTemplate(c *gin.Context) {
 		EventName     string `json:"event_name"`
 		TitleTemplate string `json:"title_template"`
 		BodyTemplate  string `json:"body_template"`
+		HTMLTemplate  string `json:"html_template"`
 	}
 
 	if err := c.ShouldBindJSON(&body); err != nil {
@@ -7554,6 +7742,11 @@ func handleCreateMessageTemplate(c *gin.Context) {
 		EventName:     body.EventName,
 		TitleTemplate: body.TitleTemplate,
 		BodyTemplate:  body.BodyTemplate,
+		HTMLTemplate:  body.HTMLTemplate,
+	}
+	if err := validateLegacyTemplates(template); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	if err := database.DB.Create(&template).Error; err != nil {
@@ -7592,6 +7785,7 @@ func handleUpdateMessageTemplate(c *gin.Context) {
 		TitleTemplate string `json:"title_template"`
 		BodyTemplate  string `json:"body_template"`
 		TemplateText  string `json:"template_text"`
+		HTMLTemplate  string `json:"html_template"`
 	}
 
 	if err := c.ShouldBindJSON(&body); err != nil {
@@ -7612,15 +7806,76 @@ func handleUpdateMessageTemplate(c *gin.Context) {
 	if body.TemplateText != "" {
 		updateData["template_text"] = body.TemplateText
 	}
+	if body.HTMLTemplate != "" {
+		updateData["html_template"] = body.HTMLTemplate
+	}
+	if err := validateLegacyTemplates(database.MessageTemplate{
+		TitleTemplate: body.TitleTemplate,
+		BodyTemplate:  body.BodyTemplate,
+		TemplateText:  body.TemplateText,
+		HTMLTemplate:  body.HTMLTemplate,
+	}); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&template).Updates(updateData).Error; err != nil {
+			return err
+		}
 
-	if err := database.DB.Model(&template).Updates(updateData).Error; err != nil {
+		// Variants take precedence over the legacy fields, so edits made through them
+		// (e.g. on the settings page) go to the plain variant of the default locale
+		text := body.TemplateText
+		if text == "" {
+			text = body.BodyTemplate
+		}
+		if text == "" && body.TitleTemplate == "" {
+			return nil
+		}
+		var variant database.TemplateVariant
+		err := tx.Where("template_id = ? AND locale = ? AND format = ?", template.ID, notify.DefaultLocale(), notify.FormatPlain).
+			First(&variant).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if text != "" {
+			variant.Body = text
+		}
+		if body.TitleTemplate != "" {
+			variant.Title = body.TitleTemplate
+		}
+		return tx.Save(&variant).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update message template"})
 		return
 	}
 
+	database.DB.Preload("Variants").First(&template, template.ID)
 	c.JSON(http.StatusOK, template)
 }
 
+// validateLegacyTemplates parses the template fields of a message template.
+func validateLegacyTemplates(t database.MessageTemplate) error {
+	for _, f := range []struct {
+		name, src, format string
+	}{
+		{"title_template", t.TitleTemplate, notify.FormatPlain},
+		{"body_template", t.BodyTemplate, notify.FormatPlain},
+		{"template_text", t.TemplateText, notify.FormatPlain},
+		{"html_template", t.HTMLTemplate, notify.FormatHTML},
+	} {
+		if err := notify.ValidateTemplate(f.src, f.format); err != nil {
+			return fmt.Errorf("invalid %s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
 // handleDeleteMessageTemplate deletes a message template
 func handleDeleteMessageTemplate(c *gin.Context) {
 	if database.DB == nil {
@@ -7634,7 +7889,13 @@ func handleDeleteMessageTemplate(c *gin.Context) {
 		return
 	}
 
-	if err := database.DB.Delete(&database.MessageTemplate{}, id).Error; err != nil {
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("template_id = ?", id).Delete(&database.TemplateVariant{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&database.MessageTemplate{}, id).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete message template"})
 		return
 	}
@@ -7642,418 +7903,3090 @@ func handleDeleteMessageTemplate(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "message template deleted"})
 }
 
-// Telegram Notification Config Handlers
-
-// handleListTelegramConfigs returns all Telegram notification configurations
-func handleListTelegramConfigs(c *gin.Context) {
+// handleSaveTemplateVariant creates or replaces the variant of a message template
+// for a locale and format
+func handleSaveTemplateVariant(c *gin.Context) {
 	if database.DB == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
 		return
 	}
 
-	var configs []database.NotifyConfig
-	if err := database.DB.Order("created_at desc").Find(&configs).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list telegram configs"})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{"configs": configs})
-}
-
-// handleCreateTelegramConfig creates a new Telegram notification configuration
-func handleCreateTelegramConfig(c *gin.Context) {
-	if database.DB == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+	var template database.MessageTemplate
+	if err := database.DB.First(&template, uintParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "message template not found"})
 		return
 	}
 
 	var body struct {
-		TGToken  string `json:"tg_token"`
-		TGChatID string `json:"tg_chat_id"`
-		IsActive bool   `json:"is_active"`
+		Locale string `json:"locale"`
+		Format string `json:"format"`
+		Title  string `json:"title"`
+		Body   string `json:"body"`
 	}
-
 	if err := c.ShouldBindJSON(&body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
 		return
 	}
 
-	if body.TGToken == "" || body.TGChatID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "tg_token and tg_chat_id are required"})
+	variant := database.TemplateVariant{
+		TemplateID: template.ID,
+		Locale:     strings.TrimSpace(body.Locale),
+		Format:     strings.ToLower(strings.TrimSpace(body.Format)),
+		Title:      body.Title,
+		Body:       body.Body,
+	}
+	if variant.Format == "" {
+		variant.Format = notify.FormatPlain
+	}
+	if err := notify.ValidateVariant(variant); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	config := database.NotifyConfig{
-		TGToken:  body.TGToken,
-		TGChatID: body.TGChatID,
-		IsActive: body.IsActive,
+	var existing database.TemplateVariant
+	err := database.DB.Where("template_id = ? AND locale = ? AND format = ?", template.ID, variant.Locale, variant.Format).First(&existing).Error
+	switch {
+	case err == nil:
+		variant.ID = existing.ID
+		variant.CreatedAt = existing.CreatedAt
+		err = database.DB.Save(&variant).Error
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		err = database.DB.Create(&variant).Error
 	}
-
-	if err := database.DB.Create(&config).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create telegram config"})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save template variant"})
 		return
 	}
 
-	c.JSON(http.StatusCreated, config)
+	c.JSON(http.StatusOK, variant)
 }
 
-// handleUpdateTelegramConfig updates an existing Telegram notification configuration
-func handleUpdateTelegramConfig(c *gin.Context) {
+// handleDeleteTemplateVariant deletes a variant of a message template
+func handleDeleteTemplateVariant(c *gin.Context) {
 	if database.DB == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
 		return
 	}
 
-	id := c.Param("id")
-	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "config ID is required"})
+	result := database.DB.Where("id = ? AND template_id = ?", uintParam(c, "variantId"), uintParam(c, "id")).
+		Delete(&database.TemplateVariant{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete template variant"})
 		return
 	}
-
-	var config database.NotifyConfig
-	if err := database.DB.First(&config, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "telegram config not found"})
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "template variant not found"})
 		return
 	}
 
-	var body struct {
-		TGToken  string `json:"tg_token"`
-		TGChatID string `json:"tg_chat_id"`
-		IsActive bool   `json:"is_active"`
+	c.JSON(http.StatusOK, gin.H{"message": "template variant deleted"})
+}
+
+// templatePreviewRequest is the body of template preview and test requests. Without
+// a domain the message is rendered for a sample domain. A variant previews unsaved
+// changes: it replaces the saved variant of its locale and format.
+type templatePreviewRequest struct {
+	Domain  string                    `json:"domain"` // Domain ID or name
+	Data    map[string]string         `json:"data"`   // Overrides the sample event data
+	Locale  string                    `json:"locale"`
+	Format  string                    `json:"format"`
+	Channel string                    `json:"channel"` // Channel ref; renders for its locale and format
+	Variant *database.TemplateVariant `json:"variant"`
+}
+
+// renderTemplatePreview loads the template of the request and renders it. It writes
+// the error response and returns false if the request is invalid.
+func renderTemplatePreview(c *gin.Context, body templatePreviewRequest) (notify.Message, notify.Preview, notify.Channel, bool) {
+	var ch notify.Channel
+	var template database.MessageTemplate
+	if err := database.DB.Preload("Variants").First(&template, uintParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "message template not found"})
+		return notify.Message{}, notify.Preview{}, ch, false
+	}
+	if template.EventName == notify.DigestEvent {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "digest templates are previewed with /v1/admin/notifications/digests/:id/preview"})
+		return notify.Message{}, notify.Preview{}, ch, false
 	}
 
-	if err := c.ShouldBindJSON(&body); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
-		return
+	if v := body.Variant; v != nil {
+		v.Locale = strings.TrimSpace(v.Locale)
+		v.Format = strings.ToLower(strings.TrimSpace(v.Format))
+		if v.Format == "" {
+			v.Format = notify.FormatPlain
+		}
+		if err := notify.ValidateVariant(*v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid variant: " + err.Error()})
+			return notify.Message{}, notify.Preview{}, ch, false
+		}
+		replaced := false
+		for i, saved := range template.Variants {
+			if strings.EqualFold(saved.Locale, v.Locale) && saved.Format == v.Format {
+				template.Variants[i] = *v
+				replaced = true
+			}
+		}
+		if !replaced {
+			template.Variants = append(template.Variants, *v)
+		}
 	}
 
-	updateData := map[string]interface{}{
-		"is_active": body.IsActive,
+	var domain database.MonitoredDomain
+	if body.Domain != "" {
+		var err error
+		if domain, err = findDomainByParam(body.Domain); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "domain not found"})
+			return notify.Message{}, notify.Preview{}, ch, false
+		}
 	}
 
-	if body.TGToken != "" {
-		updateData["tg_token"] = body.TGToken
+	locale := strings.TrimSpace(body.Locale)
+	format := strings.ToLower(strings.TrimSpace(body.Format))
+	if body.Channel != "" {
+		var err error
+		if ch, err = notify.LoadChannel(body.Channel); err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return notify.Message{}, notify.Preview{}, ch, false
+		}
+		if locale == "" {
+			locale = ch.Locale
+		}
+		if format == "" {
+			format = notify.FormatFor(ch.Platform)
+		}
 	}
-	if body.TGChatID != "" {
-		updateData["tg_chat_id"] = body.TGChatID
+	valid := format == ""
+	for _, f := range notify.Formats {
+		valid = valid || f == format
 	}
-
-	if err := database.DB.Model(&config).Updates(updateData).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update telegram config"})
-		return
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("invalid format %q (expected one of %s)", format, strings.Join(notify.Formats, ", "))})
+		return notify.Message{}, notify.Preview{}, ch, false
 	}
 
-	c.JSON(http.StatusOK, config)
+	msg, preview := notify.PreviewTemplate(template, domain, body.Data, locale, format)
+	return msg, preview, ch, true
 }
 
-// handleDeleteTelegramConfig deletes a Telegram notification configuration
-func handleDeleteTelegramConfig(c *gin.Context) {
+// handlePreviewMessageTemplate renders a message template with sample or real domain
+// data and lists its unknown and unused variables, without sending anything
+func handlePreviewMessageTemplate(c *gin.Context) {
 	if database.DB == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
 		return
 	}
 
-	id := c.Param("id")
-	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "config ID is required"})
+	var body templatePreviewRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
 		return
 	}
 
-	if err := database.DB.Delete(&database.NotifyConfig{}, id).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete telegram config"})
+	_, preview, _, ok := renderTemplatePreview(c, body)
+	if !ok {
 		return
 	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "telegram config deleted"})
+	c.JSON(http.StatusOK, preview)
 }
 
-// handleSaveTelegramSettings saves or updates Telegram bot token and chat ID
-// This is a simplified endpoint that creates or updates the first active Telegram config
-func handleSaveTelegramSettings(c *gin.Context) {
+// handleTestMessageTemplate renders a message template like the preview and sends it
+// to a channel right away, bypassing routing, silences and the outbox
+func handleTestMessageTemplate(c *gin.Context) {
 	if database.DB == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
 		return
 	}
 
-	var body struct {
-		Token  string `json:"token"`
-		ChatID string `json:"chat_id"`
-	}
-
+	var body templatePreviewRequest
 	if err := c.ShouldBindJSON(&body); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
 		return
 	}
-
-	if body.Token == "" || body.ChatID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "token and chat_id are required"})
+	if body.Channel == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "channel is required"})
 		return
 	}
 
-	// Check if a Telegram config already exists
-	var existingConfig database.NotifyConfig
-	err := database.DB.Where("is_active = ?", true).First(&existingConfig).Error
-
-	if err != nil {
-		// No active config exists, create a new one
-		config := database.NotifyConfig{
-			TGToken:  body.Token,
-			TGChatID: body.ChatID,
-			IsActive: true,
-		}
-		if err := database.DB.Create(&config).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create telegram config"})
-			return
-		}
-		c.JSON(http.StatusCreated, gin.H{
-			"message": "Telegram settings saved successfully",
-			"config":  config,
-		})
-	} else {
-		// Update existing config
-		updateData := map[string]interface{}{
-			"tg_token":   body.Token,
-			"tg_chat_id": body.ChatID,
-			"is_active":  true,
-		}
-		if err := database.DB.Model(&existingConfig).Updates(updateData).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update telegram config"})
-			return
-		}
-		c.JSON(http.StatusOK, gin.H{
-			"message": "Telegram settings updated successfully",
-			"config":  existingConfig,
-		})
+	msg, preview, ch, ok := renderTemplatePreview(c, body)
+	if !ok {
+		return
+	}
+	if err := notify.SendTest(c.Request.Context(), ch, msg); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to send test message", "details": err.Error(), "preview": preview})
+		return
 	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Test message sent successfully", "channel": ch, "preview": preview})
 }
 
-// handleTestTelegramConnection tests the Telegram bot connection by sending a test message
-func handleTestTelegramConnection(c *gin.Context) {
+// handleTestChannel sends a test message to any channel, given by its ref
+func handleTestChannel(c *gin.Context) {
 	if database.DB == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
 		return
 	}
 
-	id := c.Param("id")
-	if id == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "config ID is required"})
+	var body struct {
+		Channel string `json:"channel"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.Channel == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "channel is required"})
 		return
 	}
 
-	var config database.NotifyConfig
-	if err := database.DB.First(&config, id).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "telegram config not found"})
+	ch, err := notify.LoadChannel(body.Channel)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	if err := notify.SendTest(c.Request.Context(), ch, notify.TestMessage()); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to send test message", "details": err.Error()})
 		return
 	}
 
-	// Send test message
-	testMessage := "Hello from ZenStack"
-	if err := notify.SendTelegramMessage(config.TGToken, config.TGChatID, testMessage); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":   "failed to send test message",
+	c.JSON(http.StatusOK, gin.H{"message": "Test message sent successfully", "channel": ch})
+}
+
+// Telegram Notification Config Handlers
+
+// handleListTelegramConfigs returns all Telegram channels
+func handleListTelegramConfigs(c *gin.Context) {
+	listLegacyChannels(c, notify.ChannelTelegram, func(ch database.Channel) interface{} { return telegramConfig(ch) })
+}
+
+// handleCreateTelegramConfig creates a Telegram channel
+func handleCreateTelegramConfig(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var body struct {
+		TGToken  string `json:"tg_token"`
+		TGChatID string `json:"tg_chat_id"`
+		Locale   string `json:"locale"`
+		IsActive bool   `json:"is_active"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	if body.TGToken == "" || body.TGChatID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tg_token and tg_chat_id are required"})
+		return
+	}
+
+	ch := database.Channel{Type: notify.ChannelTelegram, Locale: strings.TrimSpace(body.Locale), IsActive: body.IsActive}
+	if err := notify.ApplySettings(&ch, legacyPatch(map[string]interface{}{"token": body.TGToken, "chat_id": body.TGChatID})); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !saveChannel(c, &ch) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, telegramConfig(ch))
+}
+
+// handleUpdateTelegramConfig updates a Telegram channel. An empty token or chat ID
+// keeps the current one.
+func handleUpdateTelegramConfig(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	ch, ok := findChannelByParam(c, notify.ChannelTelegram, "telegram config")
+	if !ok {
+		return
+	}
+
+	var body struct {
+		TGToken  string  `json:"tg_token"`
+		TGChatID string  `json:"tg_chat_id"`
+		Locale   *string `json:"locale"`
+		IsActive bool    `json:"is_active"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	ch.IsActive = body.IsActive
+	if body.Locale != nil {
+		ch.Locale = strings.TrimSpace(*body.Locale)
+	}
+	if err := notify.ApplySettings(&ch, legacyPatch(map[string]interface{}{"token": body.TGToken, "chat_id": body.TGChatID})); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !saveChannel(c, &ch) {
+		return
+	}
+
+	c.JSON(http.StatusOK, telegramConfig(ch))
+}
+
+// handleDeleteTelegramConfig deletes a Telegram channel
+func handleDeleteTelegramConfig(c *gin.Context) {
+	deleteLegacyChannel(c, notify.ChannelTelegram, "telegram config")
+}
+
+// handleSaveTelegramSettings saves or updates Telegram bot token and chat ID
+// This is a simplified endpoint that creates or updates the first active Telegram channel
+func handleSaveTelegramSettings(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var body struct {
+		Token  string `json:"token"`
+		ChatID string `json:"chat_id"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	if body.Token == "" || body.ChatID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token and chat_id are required"})
+		return
+	}
+
+	// Update the first active Telegram channel, or create one
+	var ch database.Channel
+	if err := database.DB.Where("type = ? AND is_active = ?", notify.ChannelTelegram, true).Order("id").Limit(1).Find(&ch).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load telegram config"})
+		return
+	}
+	created := ch.ID == 0
+	ch.Type, ch.IsActive = notify.ChannelTelegram, true
+	if err := notify.ApplySettings(&ch, legacyPatch(map[string]interface{}{"token": body.Token, "chat_id": body.ChatID})); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !saveChannel(c, &ch) {
+		return
+	}
+
+	if created {
+		c.JSON(http.StatusCreated, gin.H{
+			"message": "Telegram settings saved successfully",
+			"config":  telegramConfig(ch),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Telegram settings updated successfully",
+		"config":  telegramConfig(ch),
+	})
+}
+
+// handleTestTelegramConnection tests the Telegram bot connection by sending a test message.
+// It is kept for the settings page; /notifications/channels/:id/test works for any channel.
+func handleTestTelegramConnection(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	model, ok := findChannelByParam(c, notify.ChannelTelegram, "telegram config")
+	if !ok {
+		return
+	}
+
+	ch, err := notify.LoadChannel(notify.ChannelRef(notify.ChannelTelegram, model.ID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "telegram config not found"})
+		return
+	}
+	if err := notify.SendTest(c.Request.Context(), ch, notify.TestMessage()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "failed to send test message",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Test message sent successfully",
+		"chat_id": telegramConfig(model).TGChatID,
+	})
+}
+
+// handleConfigureTelegramBot sets how the bot of a Telegram channel receives commands:
+// "polling", "webhook" (registered at ZENSTACK_PUBLIC_URL) or "" to only send
+func handleConfigureTelegramBot(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	ch, ok := findChannelByParam(c, notify.ChannelTelegram, "telegram config")
+	if !ok {
+		return
+	}
+
+	var body struct {
+		Mode string `json:"mode"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	mode := strings.ToLower(strings.TrimSpace(body.Mode))
+	if mode != "" && mode != telegram.ModePolling && mode != telegram.ModeWebhook {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be polling, webhook or empty"})
+		return
+	}
+	if err := telegram.Configure(c.Request.Context(), &ch, mode); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to configure telegram bot", "details": err.Error()})
+		return
+	}
+
+	resp := gin.H{"config": telegramConfig(ch)}
+	if mode == telegram.ModeWebhook {
+		resp["webhook_url"] = telegram.WebhookURL(ch.ID)
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// handleTelegramWebhook receives the updates of a Telegram bot in webhook mode
+func handleTelegramWebhook(c *gin.Context) {
+	var update telegram.Update
+	if err := c.ShouldBindJSON(&update); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid update"})
+		return
+	}
+
+	err := telegram.HandleWebhook(c.Request.Context(), uintParam(c, "id"), c.GetHeader("X-Telegram-Bot-Api-Secret-Token"), update)
+	if errors.Is(err, telegram.ErrUnauthorized) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+		return
+	}
+	if err != nil {
+		// Telegram retries failed updates, so only report errors worth retrying
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to handle update"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"ok": true})
+}
+
+// scanForBot runs the HTTP and SSL checks of a domain for the /scan bot command.
+// On the leader, their outcomes go through the event pipeline like scheduled checks;
+// followers only probe the domain, see RunNow.
+func scanForBot(ctx context.Context, d database.MonitoredDomain) (string, error) {
+	var b strings.Builder
+	b.WriteString("Scanned " + d.DomainName)
+	for _, kind := range []string{monitor.KindHTTP, monitor.KindSSL} {
+		out, err := monitorEngine.RunNow(ctx, kind, monitor.DomainTarget(d))
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "\n%s: %s", strings.ToUpper(kind), out.Status)
+		if out.Message != "" {
+			fmt.Fprintf(&b, " (%s)", out.Message)
+		}
+	}
+	return b.String(), nil
+}
+
+// Email Notification Config Handlers
+
+// emailConfigRequest is the body of email config create and update requests.
+// The password is write-only and never returned.
+type emailConfigRequest struct {
+	Name       *string `json:"name"`
+	Host       *string `json:"host"`
+	Port       *int    `json:"port"`
+	Security   *string `json:"security"`
+	Username   *string `json:"username"`
+	Password   *string `json:"password"`
+	From       *string `json:"from"`
+	Recipients *string `json:"recipients"`
+	Locale     *string `json:"locale"`
+	IsActive   *bool   `json:"is_active"`
+}
+
+// apply copies the provided fields onto an email channel and validates its settings.
+func (r emailConfigRequest) apply(ch *database.Channel) error {
+	if r.Name != nil {
+		ch.Name = strings.TrimSpace(*r.Name)
+	}
+	if r.Locale != nil {
+		ch.Locale = strings.TrimSpace(*r.Locale)
+	}
+	if r.IsActive != nil {
+		ch.IsActive = *r.IsActive
+	}
+	settings := map[string]interface{}{}
+	for key, value := range map[string]*string{
+		"host": r.Host, "security": r.Security, "username": r.Username,
+		"password": r.Password, "from": r.From, "recipients": r.Recipients,
+	} {
+		if value != nil {
+			settings[key] = *value
+		}
+	}
+	if r.Port != nil {
+		settings["port"] = *r.Port
+	}
+	patch, _ := json.Marshal(settings)
+	return notify.ApplySettings(ch, patch)
+}
+
+// handleListEmailConfigs returns all email channels
+func handleListEmailConfigs(c *gin.Context) {
+	listLegacyChannels(c, notify.ChannelEmail, func(ch database.Channel) interface{} { return emailConfig(ch) })
+}
+
+// handleCreateEmailConfig creates an email channel
+func handleCreateEmailConfig(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var body emailConfigRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	ch := database.Channel{Type: notify.ChannelEmail, IsActive: true}
+	if err := body.apply(&ch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !saveChannel(c, &ch) {
+		return
+	}
+
+	c.JSON(http.StatusCreated, emailConfig(ch))
+}
+
+// handleUpdateEmailConfig updates an email channel.
+// Omitted fields keep their value.
+func handleUpdateEmailConfig(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	ch, ok := findChannelByParam(c, notify.ChannelEmail, "email config")
+	if !ok {
+		return
+	}
+
+	var body emailConfigRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	if err := body.apply(&ch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !saveChannel(c, &ch) {
+		return
+	}
+
+	c.JSON(http.StatusOK, emailConfig(ch))
+}
+
+// handleDeleteEmailConfig deletes an email channel
+func handleDeleteEmailConfig(c *gin.Context) {
+	deleteLegacyChannel(c, notify.ChannelEmail, "email config")
+}
+
+// handleTestEmailConfig sends a test email to the recipients of an email channel
+func handleTestEmailConfig(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	ch, ok := findChannelByParam(c, notify.ChannelEmail, "email config")
+	if !ok {
+		return
+	}
+	s, err := notify.DecodeSettings(ch)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	settings := s.(*notify.EmailSettings)
+
+	notifier := notify.NewEmailNotifier(*settings)
+	msg := notify.Message{
+		Event:    "TEST",
+		Severity: notify.SeverityInfo,
+		Title:    "Test email from ZenStack",
+		Body:     "Hello from ZenStack. Email notifications are configured correctly.",
+		Fields:   []notify.Field{{Name: "SMTP Server", Value: settings.Host}},
+		Time:     time.Now(),
+	}
+	info := notify.DeliveryInfo{Channel: notify.ChannelRef(notify.ChannelEmail, ch.ID), Recipient: settings.Recipients}
+	if err := notify.Deliver(c.Request.Context(), notifier, msg, info); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "failed to send test email",
 			"details": err.Error(),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Test message sent successfully",
-		"chat_id": config.TGChatID,
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "Test email sent successfully",
+		"recipients": notifier.To,
+	})
+}
+
+// Notification Routing Rule Handlers
+
+// routingRuleRequest is the body of routing rule create and update requests.
+type routingRuleRequest struct {
+	Name         *string `json:"name"`
+	Position     *int    `json:"position"`
+	Events       *string `json:"events"`
+	Tags         *string `json:"tags"`
+	CustomStatus *string `json:"custom_status"`
+	Severities   *string `json:"severities"`
+	Channels     *string `json:"channels"`
+	Continue     *bool   `json:"continue"`
+	IsActive     *bool   `json:"is_active"`
+
+	EscalationPolicyID *uint   `json:"escalation_policy_id"`
+	GroupWindow        *int    `json:"group_window"`
+	GroupBy            *string `json:"group_by"`
+}
+
+// apply copies the provided fields onto the rule.
+func (r routingRuleRequest) apply(rule *database.RoutingRule) {
+	if r.Name != nil {
+		rule.Name = strings.TrimSpace(*r.Name)
+	}
+	if r.Position != nil {
+		rule.Position = *r.Position
+	}
+	if r.Events != nil {
+		rule.Events = strings.ToUpper(strings.ReplaceAll(*r.Events, " ", ""))
+	}
+	if r.Tags != nil {
+		rule.Tags = strings.TrimSpace(*r.Tags)
+	}
+	if r.CustomStatus != nil {
+		rule.CustomStatus = strings.TrimSpace(*r.CustomStatus)
+	}
+	if r.Severities != nil {
+		rule.Severities = strings.ToLower(strings.ReplaceAll(*r.Severities, " ", ""))
+	}
+	if r.Channels != nil {
+		rule.Channels = strings.ToLower(strings.ReplaceAll(*r.Channels, " ", ""))
+	}
+	if r.Continue != nil {
+		rule.Continue = *r.Continue
+	}
+	if r.IsActive != nil {
+		rule.IsActive = *r.IsActive
+	}
+	if r.EscalationPolicyID != nil {
+		rule.EscalationPolicyID = *r.EscalationPolicyID
+	}
+	if r.GroupWindow != nil {
+		rule.GroupWindow = *r.GroupWindow
+	}
+	if r.GroupBy != nil {
+		rule.GroupBy = strings.ToLower(strings.ReplaceAll(*r.GroupBy, " ", ""))
+	}
+}
+
+// handleListRoutingRules returns all routing rules in evaluation order
+func handleListRoutingRules(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var rules []database.RoutingRule
+	if err := database.DB.Order("position asc, id asc").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list routing rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+// handleCreateRoutingRule creates a new routing rule
+func handleCreateRoutingRule(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var body routingRuleRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	rule := database.RoutingRule{IsActive: true}
+	body.apply(&rule)
+	if err := notify.ValidateRoutingRule(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create routing rule"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// handleUpdateRoutingRule updates an existing routing rule. Omitted fields keep their value.
+func handleUpdateRoutingRule(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var rule database.RoutingRule
+	if err := database.DB.First(&rule, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "routing rule not found"})
+		return
+	}
+
+	var body routingRuleRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	body.apply(&rule)
+	if err := notify.ValidateRoutingRule(rule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Select all columns so that false and empty values are saved too
+	if err := database.DB.Model(&rule).Select("*").Omit("created_at").Updates(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update routing rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// handleDeleteRoutingRule deletes a routing rule
+func handleDeleteRoutingRule(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	if err := database.DB.Delete(&database.RoutingRule{}, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete routing rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "routing rule deleted"})
+}
+
+// handleTestRouting shows which rules and channels a hypothetical event would reach,
+// and which channels would hold it for quiet hours now, without sending anything. The
+// domain, if given, provides tags and custom status; explicit tags, custom_status and
+// severity override it.
+func handleTestRouting(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var body struct {
+		EventName    string  `json:"event_name"`
+		Domain       string  `json:"domain"`
+		Tags         *string `json:"tags"`
+		CustomStatus *string `json:"custom_status"`
+		SSLStatus    string  `json:"ssl_status"`
+		Severity     string  `json:"severity"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	if body.EventName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "event_name is required"})
+		return
+	}
+
+	in := notify.RouteInput{Event: strings.ToUpper(body.EventName)}
+	if body.Domain != "" {
+		domain, err := findDomainByParam(body.Domain)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "domain not found"})
+			return
+		}
+		in.Tags = domain.Tags
+		in.CustomStatus = domain.CustomStatus
+		if body.SSLStatus == "" {
+			body.SSLStatus = domain.SSLStatus
+		}
+	}
+	if body.Tags != nil {
+		in.Tags = *body.Tags
+	}
+	if body.CustomStatus != nil {
+		in.CustomStatus = *body.CustomStatus
+	}
+	in.Severity = notify.Urgency(in.Event, body.SSLStatus)
+	if body.Severity != "" {
+		in.Severity = strings.ToLower(body.Severity)
+	}
+	_, in.Resolve = notify.AlertKey(in.Event, "")
+
+	route, err := notify.RouteEvent(in)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to route event", "details": err.Error()})
+		return
+	}
+	held := gin.H{}
+	now := time.Now()
+	for _, ch := range route.Channels {
+		if ch.Quiet == nil {
+			continue
+		}
+		if until, ok := ch.Quiet.Holds(notify.Message{Event: in.Event, Urgency: in.Severity, Resolve: in.Resolve}, now); ok {
+			held[ch.Ref] = until
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"event":               in.Event,
+		"tags":                in.Tags,
+		"custom_status":       in.CustomStatus,
+		"severity":            in.Severity,
+		"resolve":             in.Resolve,
+		"rules":               route.Rules,
+		"channels":            route.Channels,
+		"held_until":          held,
+		"default_route":       route.Default,
+		"escalation_policies": route.Policies(),
+	})
+}
+
+// handleListOutbox returns queued, delivered and dead-lettered notifications, newest
+// first, with the number of messages per status. Filter with ?status= and ?channel=.
+func handleListOutbox(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	query := database.DB.Order("id desc").Limit(200)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if channel := c.Query("channel"); channel != "" {
+		query = query.Where("channel = ?", strings.ToLower(channel))
+	}
+
+	var messages []database.OutboxMessage
+	if err := query.Find(&messages).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list notification outbox"})
+		return
+	}
+
+	var counts []struct {
+		Status string
+		Count  int64
+	}
+	database.DB.Model(&database.OutboxMessage{}).Select("status, count(*) as count").Group("status").Scan(&counts)
+	byStatus := gin.H{
+		notify.OutboxPending:   0,
+		notify.OutboxHeld:      0,
+		notify.OutboxSending:   0,
+		notify.OutboxDelivered: 0,
+		notify.OutboxDead:      0,
+	}
+	for _, row := range counts {
+		byStatus[row.Status] = row.Count
+	}
+
+	c.JSON(http.StatusOK, gin.H{"messages": messages, "counts": byStatus})
+}
+
+// handleReplayOutboxMessage queues a dead-lettered notification again
+func handleReplayOutboxMessage(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	m, err := notify.ReplayOutbox(uintParam(c, "id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "notification not found"})
+			return
+		}
+		if m != nil {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to replay notification"})
+		return
+	}
+
+	c.JSON(http.StatusOK, m)
+}
+
+// handleReplayDeadLetters queues all dead-lettered notifications again
+func handleReplayDeadLetters(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	n, err := notify.ReplayDeadLetters()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to replay notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"replayed": n})
+}
+
+// handleDeleteOutboxMessage discards a notification from the outbox
+func handleDeleteOutboxMessage(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	if err := database.DB.Delete(&database.OutboxMessage{}, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete notification"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "notification deleted"})
+}
+
+// handleListDeliveryHistory returns delivery attempts, newest first. Filters: event,
+// domain, channel, platform, status (success or failed), dedup_key, escalation_id,
+// user_id, outbox_id, since and until (RFC 3339), and incident_id, which selects the
+// attempts for the incident's domain while it was open.
+func handleListDeliveryHistory(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	query := database.DB.Model(&database.DeliveryAttempt{})
+	for _, f := range []struct{ param, column string }{
+		{"event", "event"},
+		{"domain", "domain"},
+		{"channel", "channel"},
+		{"platform", "platform"},
+		{"dedup_key", "dedup_key"},
+		{"escalation_id", "escalation_id"},
+		{"user_id", "user_id"},
+		{"outbox_id", "outbox_id"},
+	} {
+		if v := c.Query(f.param); v != "" {
+			query = query.Where(f.column+" = ?", v)
+		}
+	}
+	switch c.Query("status") {
+	case "":
+	case "success":
+		query = query.Where("success = ?", true)
+	case "failed":
+		query = query.Where("success = ?", false)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status (expected success or failed)"})
+		return
+	}
+
+	for _, f := range []struct{ param, op string }{{"since", ">="}, {"until", "<="}} {
+		if v := c.Query(f.param); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + f.param + " (expected RFC 3339)"})
+				return
+			}
+			query = query.Where("created_at "+f.op+" ?", t)
+		}
+	}
+
+	if v := c.Query("incident_id"); v != "" {
+		var inc database.Incident
+		if err := database.DB.First(&inc, v).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "incident not found"})
+			return
+		}
+		var domain database.MonitoredDomain
+		if err := database.DB.Unscoped().First(&domain, inc.DomainID).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "incident is not tied to a domain"})
+			return
+		}
+		end := time.Now()
+		if inc.ResolvedAt != nil {
+			end = *inc.ResolvedAt
+		}
+		// Recovery notifications are sent right after the incident is resolved
+		query = query.Where("domain = ? AND created_at BETWEEN ? AND ?", domain.DomainName, inc.StartedAt.Add(-time.Minute), end.Add(5*time.Minute))
+	}
+
+	limit := 100
+	if v, err := strconv.Atoi(c.Query("limit")); err == nil && v > 0 {
+		limit = v
+	}
+	if limit > 1000 {
+		limit = 1000
+	}
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	var summary struct {
+		Total      int64 `json:"total"`
+		Succeeded  int64 `json:"succeeded"`
+		Failed     int64 `json:"failed"`
+		UsersPaged int64 `json:"users_paged"` // Distinct users reached by an on-call page
+	}
+	query.Session(&gorm.Session{}).Count(&summary.Total)
+	query.Session(&gorm.Session{}).Where("success = ?", true).Count(&summary.Succeeded)
+	summary.Failed = summary.Total - summary.Succeeded
+	query.Session(&gorm.Session{}).Where("success = ? AND user_id <> 0", true).Distinct("user_id").Count(&summary.UsersPaged)
+
+	var deliveries []database.DeliveryAttempt
+	if err := query.Order("created_at desc, id desc").Limit(limit).Offset(offset).Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list delivery history"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"deliveries": deliveries,
+		"summary":    summary,
+		"limit":      limit,
+		"offset":     offset,
+	})
+}
+
+// handleListNotificationGroups returns the notification groups waiting for their
+// window to pass
+func handleListNotificationGroups(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var groups []database.NotificationGroup
+	if err := database.DB.Preload("Items").Order("flush_at asc").Find(&groups).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list notification groups"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"groups": groups})
+}
+
+// Digest Handlers
+
+// digestRequest is the body of digest create and update requests. Omitted fields
+// keep their value.
+type digestRequest struct {
+	Name          *string `json:"name"`
+	Channels      *string `json:"channels"`
+	Days          *string `json:"days"`
+	At            *string `json:"at"`
+	TimeZone      *string `json:"time_zone"`
+	Horizon       *int    `json:"horizon"`
+	Certificates  *bool   `json:"certificates"`
+	Registrations *bool   `json:"registrations"`
+	Incidents     *bool   `json:"incidents"`
+	Tags          *string `json:"tags"`
+	IsActive      *bool   `json:"is_active"`
+}
+
+// apply copies the provided fields onto the digest.
+func (r digestRequest) apply(d *database.Digest) {
+	if r.Name != nil {
+		d.Name = strings.TrimSpace(*r.Name)
+	}
+	if r.Channels != nil {
+		d.Channels = strings.ToLower(strings.ReplaceAll(*r.Channels, " ", ""))
+	}
+	if r.Days != nil {
+		d.Days = strings.ToLower(strings.ReplaceAll(*r.Days, " ", ""))
+	}
+	if r.At != nil {
+		d.At = strings.TrimSpace(*r.At)
+	}
+	if r.TimeZone != nil {
+		d.TimeZone = strings.TrimSpace(*r.TimeZone)
+	}
+	if r.Horizon != nil {
+		d.Horizon = *r.Horizon
+	}
+	if r.Certificates != nil {
+		d.Certificates = *r.Certificates
+	}
+	if r.Registrations != nil {
+		d.Registrations = *r.Registrations
+	}
+	if r.Incidents != nil {
+		d.Incidents = *r.Incidents
+	}
+	if r.Tags != nil {
+		d.Tags = strings.TrimSpace(*r.Tags)
+	}
+	if r.IsActive != nil {
+		d.IsActive = *r.IsActive
+	}
+}
+
+// handleListDigests returns all scheduled digests
+func handleListDigests(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var digests []database.Digest
+	if err := database.DB.Order("name asc").Find(&digests).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list digests"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"digests": digests})
+}
+
+// handleCreateDigest creates a scheduled digest
+func handleCreateDigest(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var body digestRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	digest := database.Digest{
+		At:            notify.DefaultDigestAt,
+		TimeZone:      "UTC",
+		Horizon:       notify.DefaultDigestHorizon,
+		Certificates:  true,
+		Registrations: true,
+		Incidents:     true,
+		IsActive:      true,
+	}
+	body.apply(&digest)
+	if err := notify.ValidateDigest(digest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	digest.NextRunAt, _ = notify.NextDigestRun(digest, time.Now())
+
+	if err := database.DB.Create(&digest).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create digest"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, digest)
+}
+
+// handleUpdateDigest updates a scheduled digest and reschedules its next run
+func handleUpdateDigest(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var digest database.Digest
+	if err := database.DB.First(&digest, uintParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "digest not found"})
+		return
+	}
+
+	var body digestRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	body.apply(&digest)
+	if err := notify.ValidateDigest(digest); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	digest.NextRunAt, _ = notify.NextDigestRun(digest, time.Now())
+
+	// Select all columns so that false and empty values are saved too
+	if err := database.DB.Model(&digest).Select("*").Omit("created_at").Updates(&digest).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update digest"})
+		return
+	}
+
+	c.JSON(http.StatusOK, digest)
+}
+
+// handleDeleteDigest deletes a scheduled digest
+func handleDeleteDigest(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	if err := database.DB.Delete(&database.Digest{}, uintParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete digest"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "digest deleted"})
+}
+
+// handleSendDigest queues a digest to its channels now. The period of the next
+// digest starts now as well.
+func handleSendDigest(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var digest database.Digest
+	if err := database.DB.First(&digest, uintParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "digest not found"})
+		return
+	}
+
+	if err := notify.SendDigest(digest, false); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	database.DB.First(&digest, digest.ID)
+	c.JSON(http.StatusOK, gin.H{"message": "digest queued", "digest": digest})
+}
+
+// handlePreviewDigest renders a digest without sending it
+func handlePreviewDigest(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var digest database.Digest
+	if err := database.DB.First(&digest, uintParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "digest not found"})
+		return
+	}
+
+	msg, err := notify.BuildDigest(digest, time.Now())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	msg = msg.Render(c.Query("locale"), notify.FormatPlain)
+
+	c.JSON(http.StatusOK, gin.H{"title": msg.Title, "body": msg.Text, "fields": msg.Fields})
+}
+
+// Silence Handlers
+
+// silenceRequest is the body of silence create and update requests. Omitted fields
+// keep their value.
+type silenceRequest struct {
+	DomainIDs *string    `json:"domain_ids"`
+	Tags      *string    `json:"tags"`
+	Events    *string    `json:"events"`
+	StartsAt  *time.Time `json:"starts_at"`
+	EndsAt    *time.Time `json:"ends_at"`
+	Comment   *string    `json:"comment"`
+}
+
+// apply copies the provided fields onto the silence.
+func (r silenceRequest) apply(s *database.Silence) {
+	if r.DomainIDs != nil {
+		s.DomainIDs = strings.ReplaceAll(*r.DomainIDs, " ", "")
+	}
+	if r.Tags != nil {
+		s.Tags = strings.TrimSpace(*r.Tags)
+	}
+	if r.Events != nil {
+		s.Events = strings.ToUpper(strings.ReplaceAll(*r.Events, " ", ""))
+	}
+	if r.StartsAt != nil {
+		s.StartsAt = *r.StartsAt
+	}
+	if r.EndsAt != nil {
+		s.EndsAt = *r.EndsAt
+	}
+	if r.Comment != nil {
+		s.Comment = strings.TrimSpace(*r.Comment)
+	}
+}
+
+// silenceView is a silence with its current state.
+type silenceView struct {
+	database.Silence
+	State string `json:"state"`
+}
+
+// currentUsername returns the name of the authenticated user, for audit fields.
+func currentUsername(c *gin.Context) string {
+	var user database.User
+	database.DB.Select("username").Where("id = ?", c.GetUint("userID")).Limit(1).Find(&user)
+	if user.Username == "" {
+		return fmt.Sprintf("user %d", c.GetUint("userID"))
+	}
+	return user.Username
+}
+
+// handleListSilences returns the silences, optionally filtered by ?state=pending,
+// active or expired
+func handleListSilences(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var silences []database.Silence
+	if err := database.DB.Order("ends_at desc").Find(&silences).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list silences"})
+		return
+	}
+
+	now := time.Now()
+	state := c.Query("state")
+	views := make([]silenceView, 0, len(silences))
+	for _, s := range silences {
+		v := silenceView{Silence: s, State: notify.SilenceState(s, now)}
+		if state == "" || v.State == state {
+			views = append(views, v)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"silences": views})
+}
+
+// handleCreateSilence creates a silence. It starts now unless starts_at is given.
+func handleCreateSilence(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var body silenceRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	silence := database.Silence{StartsAt: time.Now(), CreatedBy: currentUsername(c)}
+	body.apply(&silence)
+	if err := notify.ValidateSilence(silence); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Create(&silence).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create silence"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, silenceView{Silence: silence, State: notify.SilenceState(silence, time.Now())})
+}
+
+// handleUpdateSilence changes the matchers, time range or comment of a silence
+func handleUpdateSilence(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var silence database.Silence
+	if err := database.DB.First(&silence, uintParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "silence not found"})
+		return
+	}
+
+	var body silenceRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	body.apply(&silence)
+	if err := notify.ValidateSilence(silence); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Select all columns so that cleared matchers are saved too
+	if err := database.DB.Model(&silence).Select("*").Omit("created_at").Updates(&silence).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update silence"})
+		return
+	}
+
+	c.JSON(http.StatusOK, silenceView{Silence: silence, State: notify.SilenceState(silence, time.Now())})
+}
+
+// handleExpireSilence ends a silence now. Expired silences are kept for reference.
+func handleExpireSilence(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	silence, err := notify.ExpireSilence(uintParam(c, "id"))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "silence not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to expire silence"})
+		return
+	}
+
+	c.JSON(http.StatusOK, silenceView{Silence: *silence, State: notify.SilenceExpired})
+}
+
+// handleListSuppressedEvents returns the latest events suppressed by silences,
+// optionally filtered by ?silence_id= and ?domain_id=
+func handleListSuppressedEvents(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit < 1 || limit > 1000 {
+		limit = 100
+	}
+	query := database.DB.Order("created_at desc").Limit(limit)
+	if id := c.Query("silence_id"); id != "" {
+		query = query.Where("silence_id = ?", id)
+	}
+	if id := c.Query("domain_id"); id != "" {
+		query = query.Where("domain_id = ?", id)
+	}
+
+	var events []database.SuppressedEvent
+	if err := query.Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list suppressed events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"events": events})
+}
+
+// handleSnoozeDomain silences all events of a domain for a duration such as "4h" or "7d"
+func handleSnoozeDomain(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var domain database.MonitoredDomain
+	if err := database.DB.First(&domain, uintParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "domain not found"})
+		return
+	}
+
+	var body struct {
+		Duration string `json:"duration"`
+		Comment  string `json:"comment"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	d, err := notify.ParseSnoozeDuration(body.Duration)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	comment := strings.TrimSpace(body.Comment)
+	if comment == "" {
+		comment = "Snoozed " + domain.DomainName
+	}
+	silence, err := notify.Snooze(domain.ID, d, currentUsername(c), comment)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, silenceView{Silence: *silence, State: notify.SilenceActive})
+}
+
+// handleSnoozeLink serves the signed snooze link of a notification. GET shows the
+// durations to choose from; POST silences the domain for the chosen one.
+func handleSnoozeLink(c *gin.Context) {
+	renderSnooze := func(status int, page notify.SnoozePage) {
+		var buf bytes.Buffer
+		if err := notify.RenderSnoozePage(&buf, page); err != nil {
+			log.Printf("Error rendering snooze page: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to render page"})
+			return
+		}
+		c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+	}
+
+	if database.DB == nil {
+		renderSnooze(http.StatusInternalServerError, notify.SnoozePage{Error: "Database not initialized"})
+		return
+	}
+
+	claims, err := auth.VerifyAction(c.Param("token"), notify.ActionSnooze)
+	if err != nil {
+		renderSnooze(http.StatusForbidden, notify.SnoozePage{Error: "This link is invalid or has expired"})
+		return
+	}
+
+	var domain database.MonitoredDomain
+	if err := database.DB.First(&domain, claims.ObjectID).Error; err != nil {
+		renderSnooze(http.StatusNotFound, notify.SnoozePage{Error: "Domain not found"})
+		return
+	}
+	if c.Request.Method != http.MethodPost {
+		renderSnooze(http.StatusOK, notify.SnoozePage{Domain: &domain})
+		return
+	}
+
+	// Links only snooze for one of the offered durations
+	d, _ := time.ParseDuration(c.PostForm("duration"))
+	offered := false
+	for _, o := range notify.SnoozeDurations {
+		offered = offered || d == o
+	}
+	if !offered {
+		renderSnooze(http.StatusBadRequest, notify.SnoozePage{Error: "Invalid snooze duration"})
+		return
+	}
+
+	silence, err := notify.Snooze(domain.ID, d, "snooze link", "Snoozed from a notification")
+	if err != nil {
+		renderSnooze(http.StatusInternalServerError, notify.SnoozePage{Error: "Failed to snooze domain"})
+		return
+	}
+	renderSnooze(http.StatusOK, notify.SnoozePage{Domain: &domain, Silence: silence})
+}
+
+// Certificate Reminder Handlers
+
+// reminderScheduleRequest is the body of reminder schedule create and update requests.
+// Omitted fields keep their value.
+type reminderScheduleRequest struct {
+	Tag    *string `json:"tag"`
+	Stages *string `json:"stages"`
+}
+
+// apply validates the provided fields and copies them onto the schedule. Stages are
+// stored normalized, earliest first.
+func (r reminderScheduleRequest) apply(s *database.ReminderSchedule) error {
+	if r.Tag != nil {
+		s.Tag = strings.TrimSpace(*r.Tag)
+	}
+	if r.Stages != nil {
+		s.Stages = *r.Stages
+	}
+	stages, err := monitor.ParseReminderStages(s.Stages)
+	if err != nil {
+		return err
+	}
+	s.Stages = monitor.FormatReminderStages(stages)
+	return nil
+}
+
+// reminderTagTaken reports whether another schedule exists for the tag, ignoring case.
+func reminderTagTaken(s database.ReminderSchedule) bool {
+	var count int64
+	database.DB.Model(&database.ReminderSchedule{}).Where("LOWER(tag) = LOWER(?) AND id <> ?", s.Tag, s.ID).Count(&count)
+	return count > 0
+}
+
+// handleListReminderSchedules returns the reminder schedules and the stages used when
+// no global schedule exists
+func handleListReminderSchedules(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var schedules []database.ReminderSchedule
+	if err := database.DB.Order("tag").Find(&schedules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list reminder schedules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"schedules":      schedules,
+		"default_stages": monitor.FormatReminderStages(monitor.DefaultReminderStages),
+	})
+}
+
+// handleCreateReminderSchedule creates the global reminder schedule (empty tag) or the
+// schedule of a tag
+func handleCreateReminderSchedule(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var body reminderScheduleRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	var schedule database.ReminderSchedule
+	if err := body.apply(&schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if reminderTagTaken(schedule) {
+		c.JSON(http.StatusConflict, gin.H{"error": "a reminder schedule for this tag already exists"})
+		return
+	}
+
+	if err := database.DB.Create(&schedule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create reminder schedule"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, schedule)
+}
+
+// handleUpdateReminderSchedule changes the tag or stages of a reminder schedule.
+// Stages already sent for a certificate are not sent again.
+func handleUpdateReminderSchedule(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var schedule database.ReminderSchedule
+	if err := database.DB.First(&schedule, uintParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "reminder schedule not found"})
+		return
+	}
+
+	var body reminderScheduleRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	if err := body.apply(&schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if reminderTagTaken(schedule) {
+		c.JSON(http.StatusConflict, gin.H{"error": "a reminder schedule for this tag already exists"})
+		return
+	}
+
+	if err := database.DB.Model(&schedule).Select("*").Omit("created_at").Updates(&schedule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update reminder schedule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// handleDeleteReminderSchedule deletes a reminder schedule. Without a global schedule
+// the default stages apply.
+func handleDeleteReminderSchedule(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	if err := database.DB.Delete(&database.ReminderSchedule{}, uintParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete reminder schedule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "reminder schedule deleted"})
+}
+
+// handleGetDomainReminders returns the reminder stages of a domain and the reminders
+// sent for its current certificate
+func handleGetDomainReminders(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var domain database.MonitoredDomain
+	if err := database.DB.First(&domain, uintParam(c, "id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "domain not found"})
+		return
+	}
+
+	stages, tags, err := monitor.ReminderStages(domain)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load reminder schedules"})
+		return
+	}
+
+	sent := []database.CertificateReminder{}
+	if domain.SSLSerial != "" {
+		err := database.DB.Where("domain_id = ? AND serial = ?", domain.ID, domain.SSLSerial).
+			Order("stage desc").Find(&sent).Error
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load sent reminders"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"domain_id":     domain.ID,
+		"serial":        domain.SSLSerial,
+		"ssl_expiry":    domain.SSLExpiry,
+		"stages":        stages,
+		"schedule_tags": tags, // Empty when the global or default stages apply
+		"sent":          sent,
+	})
+}
+
+// On-call Handlers
+
+// uintParam parses a numeric path parameter, returning 0 if it is invalid.
+func uintParam(c *gin.Context, name string) uint {
+	id, err := strconv.ParseUint(c.Param(name), 10, 64)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
+}
+
+// handleUpdateUserContact sets the email address and Telegram chat a user is paged at
+func handleUpdateUserContact(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var user database.User
+	if err := database.DB.First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	var body struct {
+		Email          *string `json:"email"`
+		TelegramChatID *string `json:"telegram_chat_id"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	if body.Email != nil {
+		user.Email = strings.TrimSpace(*body.Email)
+		if user.Email != "" {
+			if _, err := mail.ParseAddress(user.Email); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid email address"})
+				return
+			}
+		}
+	}
+	if body.TelegramChatID != nil {
+		user.TelegramChatID = strings.TrimSpace(*body.TelegramChatID)
+	}
+
+	if err := database.DB.Model(&user).Select("email", "telegram_chat_id").Updates(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update user contact"})
+		return
+	}
+
+	user.Password = ""
+	c.JSON(http.StatusOK, user)
+}
+
+// handleListSchedules returns all on-call schedules with the user currently on call
+func handleListSchedules(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var schedules []database.OnCallSchedule
+	if err := database.DB.Preload("Layers").Preload("Overrides").Order("name").Find(&schedules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list schedules"})
+		return
+	}
+
+	now := time.Now()
+	result := make([]gin.H, 0, len(schedules))
+	for _, s := range schedules {
+		userID, _ := oncall.OnCall(s, now)
+		result = append(result, gin.H{"schedule": s, "on_call_user_id": userID})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"schedules": result})
+}
+
+// handleCreateSchedule creates an on-call schedule with its layers and overrides
+func handleCreateSchedule(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var schedule database.OnCallSchedule
+	if err := c.ShouldBindJSON(&schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	schedule.ID = 0
+	if schedule.TimeZone == "" {
+		schedule.TimeZone = "UTC"
+	}
+	for i := range schedule.Layers {
+		schedule.Layers[i].ID = 0
+	}
+	for i := range schedule.Overrides {
+		schedule.Overrides[i].ID = 0
+	}
+	if err := oncall.ValidateSchedule(schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Create(&schedule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create schedule"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, schedule)
+}
+
+// handleUpdateSchedule replaces the settings and layers of a schedule. Overrides are
+// managed through their own endpoints and kept.
+func handleUpdateSchedule(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	existing, err := oncall.LoadSchedule(uintParam(c, "id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "schedule not found"})
+		return
+	}
+
+	var body database.OnCallSchedule
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	schedule := *existing
+	schedule.Name = body.Name
+	schedule.Description = body.Description
+	schedule.TimeZone = body.TimeZone
+	if schedule.TimeZone == "" {
+		schedule.TimeZone = "UTC"
+	}
+	schedule.Layers = body.Layers
+	for i := range schedule.Layers {
+		schedule.Layers[i].ID = 0
+		schedule.Layers[i].ScheduleID = schedule.ID
+	}
+	if err := oncall.ValidateSchedule(schedule); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&schedule).Select("name", "description", "time_zone").Updates(&schedule).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("schedule_id = ?", schedule.ID).Delete(&database.ScheduleLayer{}).Error; err != nil {
+			return err
+		}
+		if len(schedule.Layers) > 0 {
+			return tx.Create(&schedule.Layers).Error
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update schedule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// handleDeleteSchedule deletes a schedule with its layers and overrides
+func handleDeleteSchedule(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	id := uintParam(c, "id")
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("schedule_id = ?", id).Delete(&database.ScheduleLayer{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("schedule_id = ?", id).Delete(&database.ScheduleOverride{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&database.OnCallSchedule{}, id).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete schedule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "schedule deleted"})
+}
+
+// handleGetOnCall returns who is on call for a schedule, now or at the RFC 3339 time
+// given in the "at" query parameter
+func handleGetOnCall(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	schedule, err := oncall.LoadSchedule(uintParam(c, "id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "schedule not found"})
+		return
+	}
+
+	at := time.Now()
+	if v := c.Query("at"); v != "" {
+		if at, err = time.Parse(time.RFC3339, v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid at (expected RFC 3339)"})
+			return
+		}
+	}
+
+	userID, ok := oncall.OnCall(*schedule, at)
+	if !ok {
+		c.JSON(http.StatusOK, gin.H{"schedule_id": schedule.ID, "at": at, "user": nil})
+		return
+	}
+
+	var user database.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusOK, gin.H{"schedule_id": schedule.ID, "at": at, "user": nil, "user_id": userID})
+		return
+	}
+	user.Password = ""
+
+	c.JSON(http.StatusOK, gin.H{"schedule_id": schedule.ID, "at": at, "user": user, "user_id": userID})
+}
+
+// handleCreateScheduleOverride puts a user on call for a time range
+func handleCreateScheduleOverride(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var schedule database.OnCallSchedule
+	if err := database.DB.First(&schedule, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "schedule not found"})
+		return
+	}
+
+	var override database.ScheduleOverride
+	if err := c.ShouldBindJSON(&override); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	override.ID = 0
+	override.ScheduleID = schedule.ID
+	if err := oncall.ValidateOverride(override); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Create(&override).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create override"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, override)
+}
+
+// handleDeleteScheduleOverride removes an override from a schedule
+func handleDeleteScheduleOverride(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	result := database.DB.Where("id = ? AND schedule_id = ?", c.Param("overrideId"), c.Param("id")).Delete(&database.ScheduleOverride{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete override"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "override not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "override deleted"})
+}
+
+// handleListEscalationPolicies returns all escalation policies with their steps
+func handleListEscalationPolicies(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var policies []database.EscalationPolicy
+	err := database.DB.
+		Preload("Steps", func(db *gorm.DB) *gorm.DB { return db.Order("position asc, id asc") }).
+		Order("name").Find(&policies).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list escalation policies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"policies": policies})
+}
+
+// normalizePolicySteps orders the steps as given and cleans up their targets.
+func normalizePolicySteps(policy *database.EscalationPolicy) {
+	for i := range policy.Steps {
+		policy.Steps[i].ID = 0
+		policy.Steps[i].PolicyID = policy.ID
+		policy.Steps[i].Position = i
+		policy.Steps[i].Targets = strings.ToLower(strings.ReplaceAll(policy.Steps[i].Targets, " ", ""))
+		if policy.Steps[i].DelayMinutes == 0 {
+			policy.Steps[i].DelayMinutes = 10
+		}
+	}
+}
+
+// handleCreateEscalationPolicy creates an escalation policy with its steps
+func handleCreateEscalationPolicy(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var policy database.EscalationPolicy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	policy.ID = 0
+	normalizePolicySteps(&policy)
+	if err := oncall.ValidatePolicy(policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := database.DB.Create(&policy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create escalation policy"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, policy)
+}
+
+// handleUpdateEscalationPolicy replaces the settings and steps of a policy. Running
+// escalations continue with the new steps.
+func handleUpdateEscalationPolicy(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var existing database.EscalationPolicy
+	if err := database.DB.First(&existing, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "escalation policy not found"})
+		return
+	}
+
+	var policy database.EscalationPolicy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+	policy.ID = existing.ID
+	policy.CreatedAt = existing.CreatedAt
+	normalizePolicySteps(&policy)
+	if err := oncall.ValidatePolicy(policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&policy).Select("name", "description", "repeat").Updates(&policy).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("policy_id = ?", policy.ID).Delete(&database.EscalationStep{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&policy.Steps).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update escalation policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, policy)
+}
+
+// handleDeleteEscalationPolicy deletes a policy that no routing rule uses
+func handleDeleteEscalationPolicy(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	id := uintParam(c, "id")
+	var rules int64
+	database.DB.Model(&database.RoutingRule{}).Where("escalation_policy_id = ?", id).Count(&rules)
+	if rules > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("escalation policy is used by %d routing rule(s)", rules)})
+		return
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("policy_id = ?", id).Delete(&database.EscalationStep{}).Error; err != nil {
+			return err
+		}
+		// Stop paging for open escalations of the policy
+		if err := tx.Model(&database.Escalation{}).
+			Where("policy_id = ? AND status = ?", id, oncall.StatusTriggered).
+			Update("status", oncall.StatusExhausted).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&database.EscalationPolicy{}, id).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete escalation policy"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "escalation policy deleted"})
+}
+
+// handleListEscalations returns recent escalations, optionally filtered by status
+func handleListEscalations(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	query := database.DB.Order("created_at desc").Limit(100)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var escalations []database.Escalation
+	if err := query.Find(&escalations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list escalations"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"escalations": escalations})
+}
+
+// handleAcknowledgeEscalation acknowledges an escalation as the logged-in user
+func handleAcknowledgeEscalation(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	esc, err := oncall.Acknowledge(uintParam(c, "id"), c.GetUint("userID"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "escalation not found"})
+			return
+		}
+		if errors.Is(err, oncall.ErrNotOpen) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "escalation": esc})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to acknowledge escalation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, esc)
+}
+
+// handleResolveEscalation resolves an escalation by hand
+func handleResolveEscalation(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	esc, err := oncall.Resolve(uintParam(c, "id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "escalation not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to resolve escalation"})
+		return
+	}
+
+	c.JSON(http.StatusOK, esc)
+}
+
+// handleAckLink serves the signed acknowledgement link of a page. GET shows the alert
+// with a confirmation button; POST acknowledges it on behalf of the paged user.
+func handleAckLink(c *gin.Context) {
+	renderAck := func(status int, page oncall.AckPage) {
+		var buf bytes.Buffer
+		if err := oncall.RenderAckPage(&buf, page); err != nil {
+			log.Printf("Error rendering acknowledgement page: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to render page"})
+			return
+		}
+		c.Data(status, "text/html; charset=utf-8", buf.Bytes())
+	}
+
+	if database.DB == nil {
+		renderAck(http.StatusInternalServerError, oncall.AckPage{Error: "Database not initialized"})
+		return
+	}
+
+	claims, err := auth.VerifyAction(c.Param("token"), oncall.ActionAcknowledge)
+	if err != nil {
+		renderAck(http.StatusForbidden, oncall.AckPage{Error: "This link is invalid or has expired"})
+		return
+	}
+
+	if c.Request.Method != http.MethodPost {
+		var esc database.Escalation
+		if err := database.DB.First(&esc, claims.ObjectID).Error; err != nil {
+			renderAck(http.StatusNotFound, oncall.AckPage{Error: "Escalation not found"})
+			return
+		}
+		renderAck(http.StatusOK, oncall.AckPage{Escalation: &esc})
+		return
+	}
+
+	esc, err := oncall.Acknowledge(claims.ObjectID, claims.UserID)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		renderAck(http.StatusNotFound, oncall.AckPage{Error: "Escalation not found"})
+	case errors.Is(err, oncall.ErrNotOpen):
+		renderAck(http.StatusOK, oncall.AckPage{Escalation: esc})
+	case err != nil:
+		renderAck(http.StatusInternalServerError, oncall.AckPage{Error: "Failed to acknowledge escalation"})
+	default:
+		renderAck(http.StatusOK, oncall.AckPage{Escalation: esc, Done: true})
+	}
+}
+
+// Status Page Handlers
+
+// loadPublicStatusPage resolves a public status page by slug, writing a 404 if it doesn't exist.
+func loadPublicStatusPage(c *gin.Context) (*statuspage.Summary, bool) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return nil, false
+	}
+
+	var page database.StatusPage
+	if err := database.DB.Where("slug = ? AND is_public = ?", c.Param("slug"), true).First(&page).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "status page not found"})
+		return nil, false
+	}
+
+	summary, err := statuspage.Build(page)
+	if err != nil {
+		log.Printf("Error building status page %s: %v", page.Slug, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build status page"})
+		return nil, false
+	}
+
+	return summary, true
+}
+
+// publicBaseURL returns the externally visible base URL of the server.
+// ZENSTACK_PUBLIC_URL takes precedence over the request host.
+func publicBaseURL(c *gin.Context) string {
+	if base := os.Getenv("ZENSTACK_PUBLIC_URL"); base != "" {
+		return strings.TrimRight(base, "/")
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// handleStatusPage serves the public HTML status page
+func handleStatusPage(c *gin.Context) {
+	summary, ok := loadPublicStatusPage(c)
+	if !ok {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := statuspage.RenderHTML(&buf, summary); err != nil {
+		log.Printf("Error rendering status page %s: %v", summary.Slug, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to render status page"})
+		return
+	}
+
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// handleStatusPageJSON serves the public status page summary as JSON
+func handleStatusPageJSON(c *gin.Context) {
+	summary, ok := loadPublicStatusPage(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// handleStatusPageAtom serves the incidents of a status page as an Atom feed
+func handleStatusPageAtom(c *gin.Context) {
+	summary, ok := loadPublicStatusPage(c)
+	if !ok {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := statuspage.RenderAtom(&buf, summary, publicBaseURL(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to render feed"})
+		return
+	}
+
+	c.Data(http.StatusOK, "application/atom+xml; charset=utf-8", buf.Bytes())
+}
+
+// handleStatusPageRSS serves the incidents of a status page as an RSS feed
+func handleStatusPageRSS(c *gin.Context) {
+	summary, ok := loadPublicStatusPage(c)
+	if !ok {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := statuspage.RenderRSS(&buf, summary, publicBaseURL(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to render feed"})
+		return
+	}
+
+	c.Data(http.StatusOK, "application/rss+xml; charset=utf-8", buf.Bytes())
+}
+
+// handleListStatusPages returns all status pages with their components
+func handleListStatusPages(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var pages []database.StatusPage
+	if err := database.DB.Preload("Components", func(db *gorm.DB) *gorm.DB {
+		return db.Order("sort_order asc, id asc")
+	}).Order("created_at desc").Find(&pages).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list status pages"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"pages": pages})
+}
+
+// handleCreateStatusPage creates a new status page
+func handleCreateStatusPage(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var body struct {
+		Slug        string `json:"slug"`
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		IsPublic    *bool  `json:"is_public"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	body.Slug = strings.ToLower(strings.TrimSpace(body.Slug))
+	if body.Slug == "" || body.Title == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "slug and title are required"})
+		return
+	}
+
+	page := database.StatusPage{
+		Slug:        body.Slug,
+		Title:       body.Title,
+		Description: body.Description,
+		IsPublic:    true,
+	}
+	if body.IsPublic != nil {
+		page.IsPublic = *body.IsPublic
+	}
+
+	if err := database.DB.Create(&page).Error; err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			c.JSON(http.StatusConflict, gin.H{"error": "status page with this slug already exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create status page"})
+		return
+	}
+
+	// GORM skips false booleans on create when the column has a default
+	if !page.IsPublic {
+		database.DB.Model(&page).Update("is_public", false)
+	}
+
+	c.JSON(http.StatusCreated, page)
+}
+
+// handleUpdateStatusPage updates an existing status page
+func handleUpdateStatusPage(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var page database.StatusPage
+	if err := database.DB.First(&page, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "status page not found"})
+		return
+	}
+
+	var body struct {
+		Slug        *string `json:"slug"`
+		Title       *string `json:"title"`
+		Description *string `json:"description"`
+		IsPublic    *bool   `json:"is_public"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	updateData := map[string]interface{}{}
+	if body.Slug != nil && strings.TrimSpace(*body.Slug) != "" {
+		updateData["slug"] = strings.ToLower(strings.TrimSpace(*body.Slug))
+	}
+	if body.Title != nil {
+		updateData["title"] = *body.Title
+	}
+	if body.Description != nil {
+		updateData["description"] = *body.Description
+	}
+	if body.IsPublic != nil {
+		updateData["is_public"] = *body.IsPublic
+	}
+
+	if err := database.DB.Model(&page).Updates(updateData).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update status page"})
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// handleDeleteStatusPage deletes a status page and its components
+func handleDeleteStatusPage(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	id := c.Param("id")
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("page_id = ?", id).Delete(&database.StatusComponent{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&database.StatusPage{}, id).Error
 	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete status page"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "status page deleted"})
+}
+
+// statusComponentBody is the request body for creating and updating status components.
+// DomainIDs is accepted as a list of IDs and stored comma-separated.
+type statusComponentBody struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	DomainIDs   []uint  `json:"domain_ids"`
+	SortOrder   *int    `json:"sort_order"`
+}
+
+// joinDomainIDs formats domain IDs as the comma-separated list stored on a component.
+func joinDomainIDs(ids []uint) string {
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, fmt.Sprintf("%d", id))
+	}
+	return strings.Join(parts, ",")
 }
 
-// sendTelegram sends a notification message to Telegram using configured bot token and chat ID.
-// It retrieves the configuration from the database and handles network timeouts appropriately.
-// This is a legacy function kept for backward compatibility.
-func sendTelegram(message string) error {
+// handleCreateStatusComponent adds a component to a status page
+func handleCreateStatusComponent(c *gin.Context) {
 	if database.DB == nil {
-		return fmt.Errorf("database not initialized")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
 	}
 
-	var config database.TelegramConfig
-	if err := database.DB.Where("enabled = ?", true).First(&config).Error; err != nil {
-		// No Telegram config found or disabled - not an error, just skip notification
-		log.Printf("Telegram notification skipped: %v", err)
-		return nil
+	var page database.StatusPage
+	if err := database.DB.First(&page, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "status page not found"})
+		return
 	}
 
-	if config.BotToken == "" || config.ChatID == "" {
-		log.Printf("Telegram notification skipped: bot token or chat ID not configured")
-		return nil
+	var body statusComponentBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
 	}
 
-	// Create HTTP client with timeout for Japan-to-Global requests
-	client := &http.Client{
-		Timeout: 15 * time.Second, // Important: timeout for network requests
+	if body.Name == nil || *body.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
 	}
 
-	// Telegram Bot API endpoint
-	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", config.BotToken)
+	component := database.StatusComponent{
+		PageID:    page.ID,
+		Name:      *body.Name,
+		DomainIDs: joinDomainIDs(body.DomainIDs),
+	}
+	if body.Description != nil {
+		component.Description = *body.Description
+	}
+	if body.SortOrder != nil {
+		component.SortOrder = *body.SortOrder
+	}
 
-	// Prepare request payload
-	payload := map[string]string{
-		"chat_id": config.ChatID,
-		"text":    message,
+	if err := database.DB.Create(&component).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create status component"})
+		return
 	}
 
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal Telegram payload: %w", err)
+	c.JSON(http.StatusCreated, component)
+}
+
+// handleUpdateStatusComponent updates a component of a status page
+func handleUpdateStatusComponent(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
 	}
 
-	// Send request with timeout handling
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return fmt.Errorf("failed to create Telegram request: %w", err)
+	var component database.StatusComponent
+	if err := database.DB.Where("page_id = ?", c.Param("id")).First(&component, c.Param("componentId")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "status component not found"})
+		return
 	}
-	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := client.Do(req)
-	if err != nil {
-		// Network timeout or connection error
-		log.Printf("Telegram notification failed (network error): %v", err)
-		return fmt.Errorf("telegram network error: %w", err)
+	var body statusComponentBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Telegram notification failed with status %d", resp.StatusCode)
-		return fmt.Errorf("telegram API returned status %d", resp.StatusCode)
+	updateData := map[string]interface{}{}
+	if body.Name != nil && *body.Name != "" {
+		updateData["name"] = *body.Name
+	}
+	if body.Description != nil {
+		updateData["description"] = *body.Description
+	}
+	if body.DomainIDs != nil {
+		updateData["domain_ids"] = joinDomainIDs(body.DomainIDs)
+	}
+	if body.SortOrder != nil {
+		updateData["sort_order"] = *body.SortOrder
 	}
 
-	log.Printf("Telegram notification sent successfully")
-	return nil
+	if err := database.DB.Model(&component).Updates(updateData).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update status component"})
+		return
+	}
+
+	c.JSON(http.StatusOK, component)
+}
+
+// handleDeleteStatusComponent removes a component from a status page
+func handleDeleteStatusComponent(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	if err := database.DB.Where("page_id = ?", c.Param("id")).
+		Delete(&database.StatusComponent{}, c.Param("componentId")).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete status component"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "status component deleted"})
 }
 
-// startSSLMonitoring starts a background goroutine that periodically scans SSL certificates
-// for all monitored domains using a non-blocking time.Ticker.
-func startSSLMonitoring() {
-	ticker := time.NewTicker(1 * time.Hour) // Check every hour
-	defer ticker.Stop()
+// Incident Handlers
 
-	log.Println("SSL monitoring goroutine started")
+// handleListIncidents returns incidents, optionally filtered by status or domain
+func handleListIncidents(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
 
-	// Run initial scan immediately
-	go performSSLScan()
+	query := database.DB.Preload("Updates", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at desc")
+	})
+	if status := c.Query("status"); status == "active" {
+		query = query.Where("status <> ?", incident.StatusResolved)
+	} else if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if domainID := c.Query("domain_id"); domainID != "" {
+		query = query.Where("domain_id = ?", domainID)
+	}
 
-	// Periodic scans
-	for range ticker.C {
-		go performSSLScan()
+	var incidents []database.Incident
+	if err := query.Order("started_at desc").Limit(200).Find(&incidents).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list incidents"})
+		return
 	}
+
+	c.JSON(http.StatusOK, gin.H{"incidents": incidents})
 }
 
-// performSSLScan scans all monitored domains for SSL certificate expiry.
-func performSSLScan() {
+// handleCreateIncident creates a manual incident
+func handleCreateIncident(c *gin.Context) {
 	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
 		return
 	}
 
-	var domains []database.MonitoredDomain
-	if err := database.DB.Find(&domains).Error; err != nil {
-		log.Printf("Failed to load monitored domains for SSL scan: %v", err)
+	var body struct {
+		Title        string `json:"title"`
+		Message      string `json:"message"`
+		Status       string `json:"status"`
+		Impact       string `json:"impact"`
+		DomainID     uint   `json:"domain_id"`
+		StatusPageID uint   `json:"status_page_id"`
+		IsPublic     bool   `json:"is_public"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
 		return
 	}
 
-	for _, monitoredDomain := range domains {
-		result := domain.CheckCertificate(monitoredDomain.DomainName)
+	if body.Title == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "title is required"})
+		return
+	}
+	if body.Status == "" {
+		body.Status = incident.StatusInvestigating
+	}
+	if body.Impact == "" {
+		body.Impact = incident.ImpactMinor
+	}
+	if !incident.ValidStatus(body.Status) || !incident.ValidImpact(body.Impact) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid status or impact"})
+		return
+	}
 
-		// Update domain record with SSL expiry information
-		updates := map[string]interface{}{
-			"status": getStatus(result),
+	now := time.Now()
+	inc := database.Incident{
+		Title:        body.Title,
+		Status:       body.Status,
+		Impact:       body.Impact,
+		DomainID:     body.DomainID,
+		StatusPageID: body.StatusPageID,
+		Source:       "manual",
+		IsPublic:     body.IsPublic,
+		StartedAt:    now,
+	}
+	if body.Status == incident.StatusResolved {
+		inc.ResolvedAt = &now
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&inc).Error; err != nil {
+			return err
 		}
-
-		// Only persist ssl_expiry if we actually got a real expiry date (avoid writing 0001-01-01)
-		if !result.ExpiryDate.IsZero() {
-			updates["ssl_expiry"] = result.ExpiryDate
-			updates["last_expiry_date"] = result.ExpiryDate
+		if body.Message == "" {
+			return nil
 		}
+		return tx.Create(&database.IncidentUpdate{
+			IncidentID: inc.ID,
+			Status:     inc.Status,
+			Message:    body.Message,
+			IsPublic:   body.IsPublic,
+		}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create incident"})
+		return
+	}
 
-		if err := database.DB.Model(&monitoredDomain).Updates(updates).Error; err != nil {
-			log.Printf("Failed to update SSL expiry for domain %s: %v", monitoredDomain.DomainName, err)
-			continue
-		}
+	c.JSON(http.StatusCreated, inc)
+}
 
-		// Send notification if certificate is critical or expired
-		if result.DaysRemaining < criticalThreshold || result.Status == "Expired" {
-			message := fmt.Sprintf("⚠️ SSL Alert: %s - %s (Days remaining: %d)",
-				monitoredDomain.DomainName, result.Status, result.DaysRemaining)
-			if err := sendTelegram(message); err != nil {
-				log.Printf("Failed to send Telegram notification: %v", err)
-			}
+// handleUpdateIncident updates incident metadata such as title, impact and visibility
+func handleUpdateIncident(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var inc database.Incident
+	if err := database.DB.First(&inc, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "incident not found"})
+		return
+	}
+
+	var body struct {
+		Title        *string `json:"title"`
+		Impact       *string `json:"impact"`
+		IsPublic     *bool   `json:"is_public"`
+		StatusPageID *uint   `json:"status_page_id"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	updateData := map[string]interface{}{}
+	if body.Title != nil && *body.Title != "" {
+		updateData["title"] = *body.Title
+	}
+	if body.Impact != nil {
+		if !incident.ValidImpact(*body.Impact) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid impact"})
+			return
 		}
+		updateData["impact"] = *body.Impact
+	}
+	if body.IsPublic != nil {
+		updateData["is_public"] = *body.IsPublic
 	}
+	if body.StatusPageID != nil {
+		updateData["status_page_id"] = *body.StatusPageID
+	}
+
+	if err := database.DB.Model(&inc).Updates(updateData).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update incident"})
+		return
+	}
+
+	c.JSON(http.StatusOK, inc)
 }
 
-// startHTTPHealthChecks starts a background goroutine that periodically performs HTTP health checks
-// for all monitored domains using a non-blocking time.Ticker.
-func startHTTPHealthChecks() {
-	ticker := time.NewTicker(5 * time.Minute) // Check every 5 minutes
-	defer ticker.Stop()
+// handleCreateIncidentUpdate posts a timeline update to an incident and changes its status
+func handleCreateIncidentUpdate(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var inc database.Incident
+	if err := database.DB.First(&inc, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "incident not found"})
+		return
+	}
+
+	var body struct {
+		Status   string `json:"status"`
+		Message  string `json:"message"`
+		IsPublic *bool  `json:"is_public"`
+	}
+
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	if body.Message == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "message is required"})
+		return
+	}
+	if body.Status == "" {
+		body.Status = inc.Status
+	}
+
+	public := inc.IsPublic
+	if body.IsPublic != nil {
+		public = *body.IsPublic
+	}
 
-	log.Println("HTTP health check goroutine started")
+	if err := incident.AddUpdate(&inc, body.Status, body.Message, public); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	// Run initial check immediately
-	go performHTTPHealthChecks()
+	c.JSON(http.StatusCreated, inc)
+}
 
-	// Periodic checks
-	for range ticker.C {
-		go performHTTPHealthChecks()
+// handleMetrics serves Prometheus metrics. When ZENSTACK_METRICS_TOKEN is set,
+// scrapers must send it as a Bearer token.
+func handleMetrics(c *gin.Context) {
+	if token := os.Getenv("ZENSTACK_METRICS_TOKEN"); token != "" {
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), []byte("Bearer "+token)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid metrics token"})
+			return
+		}
 	}
+
+	metrics.Handler().ServeHTTP(c.Writer, c.Request)
 }
 
-// performHTTPHealthChecks performs HTTP health checks for all monitored domains.
-func performHTTPHealthChecks() {
+// Synthetic Check Handlers
+
+// handleListSyntheticChecks returns all synthetic checks
+func handleListSyntheticChecks(c *gin.Context) {
 	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
 		return
 	}
 
-	var domains []database.MonitoredDomain
-	if err := database.DB.Find(&domains).Error; err != nil {
-		log.Printf("Failed to load monitored domains for health check: %v", err)
+	var checks []database.SyntheticCheck
+	if err := database.DB.Order("created_at desc").Find(&checks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list synthetic checks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"checks": checks})
+}
+
+// syntheticCheckBody is the request body for creating and updating synthetic checks.
+// Definition holds the scenario as a YAML or JSON string.
+type syntheticCheckBody struct {
+	Name            *string `json:"name"`
+	DomainID        *uint   `json:"domain_id"`
+	Definition      *string `json:"definition"`
+	IntervalSeconds *int    `json:"interval_seconds"`
+	IsActive        *bool   `json:"is_active"`
+}
+
+// handleCreateSyntheticCheck creates a new synthetic check after validating its scenario
+func handleCreateSyntheticCheck(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
 		return
 	}
 
-	client := &http.Client{
-		Timeout: 10 * time.Second, // Timeout for health checks
+	var body syntheticCheckBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
 	}
 
-	for _, monitoredDomain := range domains {
-		// Try HTTPS first, then HTTP
-		urls := []string{
-			fmt.Sprintf("https://%s", monitoredDomain.DomainName),
-			fmt.Sprintf("http://%s", monitoredDomain.DomainName),
-		}
+	if body.Definition == nil || *body.Definition == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "definition is required"})
+		return
+	}
 
-		var isLive bool
-		var statusCode int
+	sc, err := synthetic.Parse([]byte(*body.Definition))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid scenario", "details": err.Error()})
+		return
+	}
 
-		for _, urlStr := range urls {
-			resp, err := client.Get(urlStr)
-			if err == nil {
-				resp.Body.Close()
-				isLive = true
-				statusCode = resp.StatusCode
-				break
-			}
+	check := database.SyntheticCheck{
+		Name:            sc.Name,
+		Definition:      *body.Definition,
+		IntervalSeconds: int(synthetic.DefaultInterval.Seconds()),
+		IsActive:        true,
+	}
+	if body.Name != nil && *body.Name != "" {
+		check.Name = *body.Name
+	}
+	if body.DomainID != nil {
+		check.DomainID = *body.DomainID
+	}
+	if body.IntervalSeconds != nil {
+		if err := synthetic.ValidateInterval(*body.IntervalSeconds); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
+		check.IntervalSeconds = *body.IntervalSeconds
+	}
+	if check.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required (in the body or the scenario)"})
+		return
+	}
 
-		// Update domain record
-		updates := map[string]interface{}{
-			"is_live":          isLive,
-			"last_status_code": statusCode,
+	if err := database.DB.Create(&check).Error; err != nil {
+		if strings.Contains(err.Error(), "UNIQUE constraint failed") {
+			c.JSON(http.StatusConflict, gin.H{"error": "synthetic check with this name already exists"})
+			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create synthetic check"})
+		return
+	}
 
-		if err := database.DB.Model(&monitoredDomain).Updates(updates).Error; err != nil {
-			log.Printf("Failed to update health status for domain %s: %v", monitoredDomain.DomainName, err)
-			continue
+	// GORM skips false booleans on create when the column has a default
+	if body.IsActive != nil && !*body.IsActive {
+		database.DB.Model(&check).Update("is_active", false)
+	}
+
+	c.JSON(http.StatusCreated, check)
+}
+
+// handleUpdateSyntheticCheck updates a synthetic check
+func handleUpdateSyntheticCheck(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var check database.SyntheticCheck
+	if err := database.DB.First(&check, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "synthetic check not found"})
+		return
+	}
+
+	var body syntheticCheckBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+		return
+	}
+
+	updateData := map[string]interface{}{}
+	if body.Definition != nil {
+		if _, err := synthetic.Parse([]byte(*body.Definition)); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid scenario", "details": err.Error()})
+			return
+		}
+		updateData["definition"] = *body.Definition
+	}
+	if body.Name != nil && *body.Name != "" {
+		updateData["name"] = *body.Name
+	}
+	if body.DomainID != nil {
+		updateData["domain_id"] = *body.DomainID
+	}
+	if body.IntervalSeconds != nil {
+		if err := synthetic.ValidateInterval(*body.IntervalSeconds); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
 		}
+		updateData["interval_seconds"] = *body.IntervalSeconds
+	}
+	if body.IsActive != nil {
+		updateData["is_active"] = *body.IsActive
+	}
 
-		// Send notification if domain goes down
-		if !isLive {
-			message := fmt.Sprintf("🔴 Domain Down: %s is not reachable", monitoredDomain.DomainName)
-			if err := sendTelegram(message); err != nil {
-				log.Printf("Failed to send Telegram notification: %v", err)
-			}
+	if err := database.DB.Model(&check).Updates(updateData).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update synthetic check"})
+		return
+	}
+
+	c.JSON(http.StatusOK, check)
+}
+
+// handleDeleteSyntheticCheck deletes a synthetic check and its run history
+func handleDeleteSyntheticCheck(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	id := c.Param("id")
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("check_id = ?", id).Delete(&database.SyntheticStepResult{}).Error; err != nil {
+			return err
 		}
+		if err := tx.Where("check_id = ?", id).Delete(&database.SyntheticRun{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&database.SyntheticCheck{}, id).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete synthetic check"})
+		return
+	}
+
+	var checkID uint
+	if _, err := fmt.Sscanf(id, "%d", &checkID); err == nil {
+		monitorEngine.Forget(monitor.SyntheticKey(checkID))
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "synthetic check deleted"})
+}
+
+// handleRunSyntheticCheck runs a synthetic check immediately and returns the result.
+// Only the leader stores the run, updates the check's status and sends notifications,
+// see RunNow.
+func handleRunSyntheticCheck(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var check database.SyntheticCheck
+	if err := database.DB.First(&check, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "synthetic check not found"})
+		return
 	}
+
+	out, err := monitorEngine.RunNow(c.Request.Context(), monitor.KindSynthetic, monitor.SyntheticTarget(check))
+	run, ok := out.Detail.(*database.SyntheticRun)
+	if err != nil || !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to run synthetic check"})
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+// handleListSyntheticRuns returns the recent runs of a synthetic check with per-step timings
+func handleListSyntheticRuns(c *gin.Context) {
+	if database.DB == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "database not initialized"})
+		return
+	}
+
+	var runs []database.SyntheticRun
+	if err := database.DB.Preload("Steps", func(db *gorm.DB) *gorm.DB {
+		return db.Order("step_index asc")
+	}).Where("check_id = ?", c.Param("id")).
+		Order("created_at desc").
+		Limit(50).
+		Find(&runs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch synthetic runs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"runs": runs})
 }
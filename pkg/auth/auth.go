@@ -58,7 +58,7 @@ func ValidateToken(tokenString string) (*Claims, error) {
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+	if claims, ok := token.Claims.(*Claims); ok && token.Valid && len(claims.Audience) == 0 {
 		return claims, nil
 	}
 
@@ -74,3 +74,49 @@ func getJWTSecret() string {
 	}
 	return secret
 }
+
+// actionAudience marks action tokens so they are never accepted as login tokens.
+const actionAudience = "zenstack-action"
+
+// ActionClaims authorize a single action on an object without logging in, e.g. the
+// acknowledgement link in an on-call page.
+type ActionClaims struct {
+	Action   string `json:"action"`
+	ObjectID uint   `json:"object_id"`
+	UserID   uint   `json:"recipient_id,omitempty"` // User the link was sent to
+	jwt.RegisteredClaims
+}
+
+// SignAction returns a signed token allowing action on the object until ttl passes.
+func SignAction(action string, objectID, userID uint, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := ActionClaims{
+		Action:   action,
+		ObjectID: objectID,
+		UserID:   userID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{actionAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(getJWTSecret()))
+}
+
+// VerifyAction validates a token created by SignAction for the given action.
+func VerifyAction(tokenString, action string) (*ActionClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &ActionClaims{}, func(token *jwt.Token) (interface{}, error) {
+		return []byte(getJWTSecret()), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithAudience(actionAudience))
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*ActionClaims)
+	if !ok || !token.Valid || claims.Action != action {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+	return claims, nil
+}
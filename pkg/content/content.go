@@ -0,0 +1,284 @@
+package content
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"github.com/sergi/go-diff/diffmatchpatch"
+	"golang.org/x/net/html"
+	"gorm.io/gorm"
+)
+
+const (
+	// DefaultThreshold is the change percentage that raises an alert when a domain has none configured.
+	DefaultThreshold = 5.0
+	// maxBodySize caps how much of a page is downloaded for comparison.
+	maxBodySize = 2 << 20
+	// maxDiffSize caps the stored diff so a full-page rewrite doesn't bloat the database.
+	maxDiffSize = 64 << 10
+)
+
+var httpClient = &http.Client{
+	Timeout: 15 * time.Second,
+}
+
+// Snapshot is the normalized, hashed content of a page or page region.
+type Snapshot struct {
+	Text string
+	Hash string
+}
+
+// Comparison describes how a snapshot differs from its baseline.
+type Comparison struct {
+	Changed       bool
+	ChangePercent float64
+	Diff          string
+}
+
+// Fetch downloads a page and normalizes it. HTTPS is tried before HTTP.
+func Fetch(domainName, selector string) (*Snapshot, error) {
+	var lastErr error
+	for _, scheme := range []string{"https://", "http://"} {
+		resp, err := httpClient.Get(scheme + domainName)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		body, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+		resp.Body.Close()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+			lastErr = fmt.Errorf("unexpected status code %d", resp.StatusCode)
+			continue
+		}
+		return Normalize(string(body), selector)
+	}
+	return nil, fmt.Errorf("failed to fetch %s: %w", domainName, lastErr)
+}
+
+// Normalize extracts the visible text of an HTML document, or of the region matched
+// by selector, and hashes it. Scripts, styles and whitespace differences are ignored
+// so that cache-busting tokens and reformatting don't count as changes.
+func Normalize(body, selector string) (*Snapshot, error) {
+	doc, err := html.Parse(strings.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML: %w", err)
+	}
+
+	roots := []*html.Node{doc}
+	if strings.TrimSpace(selector) != "" {
+		steps, err := parseSelector(selector)
+		if err != nil {
+			return nil, err
+		}
+		roots = selectNodes(doc, steps)
+		if len(roots) == 0 {
+			return nil, fmt.Errorf("selector %q matched no elements", selector)
+		}
+	}
+
+	var lines []string
+	for _, root := range roots {
+		var b strings.Builder
+		extractText(root, &b)
+		for _, line := range strings.Split(b.String(), "\n") {
+			if line = strings.Join(strings.Fields(line), " "); line != "" {
+				lines = append(lines, line)
+			}
+		}
+	}
+
+	text := strings.Join(lines, "\n")
+	sum := sha256.Sum256([]byte(text))
+	return &Snapshot{Text: text, Hash: hex.EncodeToString(sum[:])}, nil
+}
+
+// blockElements start a new line in the extracted text.
+var blockElements = map[string]bool{
+	"p": true, "div": true, "section": true, "article": true, "header": true, "footer": true,
+	"li": true, "tr": true, "h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true,
+	"br": true, "title": true, "main": true, "nav": true, "aside": true, "table": true, "form": true,
+}
+
+func extractText(n *html.Node, b *strings.Builder) {
+	switch n.Type {
+	case html.TextNode:
+		b.WriteString(n.Data)
+		b.WriteString(" ")
+		return
+	case html.CommentNode:
+		return
+	case html.ElementNode:
+		switch n.Data {
+		case "script", "style", "noscript", "template", "svg":
+			return
+		}
+		if blockElements[n.Data] {
+			b.WriteString("\n")
+		}
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		extractText(c, b)
+	}
+	if n.Type == html.ElementNode && blockElements[n.Data] {
+		b.WriteString("\n")
+	}
+}
+
+// Compare diffs a snapshot against the baseline text line by line.
+// ChangePercent is the share of characters inserted or deleted relative to the larger text.
+func Compare(baselineText string, current *Snapshot) Comparison {
+	dmp := diffmatchpatch.New()
+	a, b, lineArray := dmp.DiffLinesToChars(terminate(baselineText), terminate(current.Text))
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(a, b, false), lineArray)
+
+	changed := 0
+	var out strings.Builder
+	for _, d := range diffs {
+		var prefix string
+		switch d.Type {
+		case diffmatchpatch.DiffInsert:
+			prefix = "+ "
+			changed += len(d.Text)
+		case diffmatchpatch.DiffDelete:
+			prefix = "- "
+			changed += len(d.Text)
+		default:
+			continue
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(d.Text, "\n"), "\n") {
+			out.WriteString(prefix + line + "\n")
+		}
+	}
+
+	total := len(baselineText)
+	if len(current.Text) > total {
+		total = len(current.Text)
+	}
+	pct := 0.0
+	if total > 0 {
+		pct = float64(changed) / float64(total) * 100.0
+		if pct > 100 {
+			pct = 100
+		}
+	}
+
+	diff := out.String()
+	if len(diff) > maxDiffSize {
+		diff = diff[:maxDiffSize] + "\n... (diff truncated)\n"
+	}
+
+	return Comparison{
+		Changed:       changed > 0,
+		ChangePercent: pct,
+		Diff:          diff,
+	}
+}
+
+// terminate ends the last line of a non-empty text, so that a line added after it
+// doesn't count the last line as changed too.
+func terminate(text string) string {
+	if text == "" {
+		return text
+	}
+	return text + "\n"
+}
+
+// SetBaseline stores snap as the new baseline for a domain and clears its alert state.
+func SetBaseline(domain database.MonitoredDomain, snap *Snapshot) (*database.ContentBaseline, error) {
+	if database.DB == nil {
+		return nil, database.ErrDatabaseNotInitialized
+	}
+
+	var baseline database.ContentBaseline
+	err := database.DB.Where("domain_id = ?", domain.ID).First(&baseline).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	baseline.DomainID = domain.ID
+	baseline.Selector = domain.ContentSelector
+	baseline.Hash = snap.Hash
+	baseline.Content = snap.Text
+	baseline.LastAlertHash = ""
+	if err := database.DB.Save(&baseline).Error; err != nil {
+		return nil, err
+	}
+	return &baseline, nil
+}
+
+// Rebaseline fetches the current content of a domain and makes it the new baseline.
+func Rebaseline(domain database.MonitoredDomain) (*database.ContentBaseline, error) {
+	snap, err := Fetch(domain.DomainName, domain.ContentSelector)
+	if err != nil {
+		return nil, err
+	}
+	return SetBaseline(domain, snap)
+}
+
+// Check compares the current content of a domain with its baseline. The first check,
+// or a check after the selector was changed, records a baseline instead of comparing.
+// A ContentChange is returned (and stored) only when the change exceeds the domain
+// threshold and differs from the last change already alerted on.
+func Check(domain database.MonitoredDomain) (*database.ContentChange, error) {
+	if database.DB == nil {
+		return nil, database.ErrDatabaseNotInitialized
+	}
+
+	snap, err := Fetch(domain.DomainName, domain.ContentSelector)
+	if err != nil {
+		return nil, err
+	}
+
+	var baseline database.ContentBaseline
+	err = database.DB.Where("domain_id = ?", domain.ID).First(&baseline).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && baseline.Selector != domain.ContentSelector) {
+		_, err = SetBaseline(domain, snap)
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if snap.Hash == baseline.Hash || snap.Hash == baseline.LastAlertHash {
+		return nil, nil
+	}
+
+	threshold := domain.ContentThreshold
+	if threshold <= 0 {
+		threshold = DefaultThreshold
+	}
+
+	cmp := Compare(baseline.Content, snap)
+	if !cmp.Changed || cmp.ChangePercent < threshold {
+		return nil, nil
+	}
+
+	change := database.ContentChange{
+		DomainID:      domain.ID,
+		BaselineHash:  baseline.Hash,
+		NewHash:       snap.Hash,
+		ChangePercent: cmp.ChangePercent,
+		Diff:          cmp.Diff,
+	}
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&change).Error; err != nil {
+			return err
+		}
+		return tx.Model(&baseline).Update("last_alert_hash", snap.Hash).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &change, nil
+}
@@ -0,0 +1,158 @@
+package content
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestNormalize(t *testing.T) {
+	page := `<html><head><title>Shop</title><style>p{color:red}</style>
+<script>var token = "abc123";</script></head>
+<body>
+  <nav>Home   |  Pricing</nav>
+  <div id="price">
+    <p>Price: 	 <b>$10</b></p><!-- cache 42 --><noscript>Enable JS</noscript>
+  </div>
+  <p>Footer<br>line</p>
+</body></html>`
+
+	tests := []struct {
+		name     string
+		selector string
+		want     string
+		error    bool
+	}{
+		{name: "whole page", want: "Shop\nHome | Pricing\nPrice: $10\nFooter\nline"},
+		{name: "region", selector: "#price", want: "Price: $10"},
+		{name: "several regions", selector: "//p", want: "Price: $10\nFooter\nline"},
+		{name: "no match", selector: ".missing", error: true},
+		{name: "invalid selector", selector: "//div[", error: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snap, err := Normalize(page, tt.selector)
+			if tt.error {
+				if err == nil {
+					t.Errorf("normalized as %q", snap.Text)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if snap.Text != tt.want {
+				t.Errorf("text = %q, want %q", snap.Text, tt.want)
+			}
+		})
+	}
+
+	// Reformatting, scripts and comments don't change the hash
+	a, _ := Normalize(`<p>Hello <b>world</b></p><script>x=1</script>`, "")
+	b, _ := Normalize("\n<p>  Hello\t<b>world</b>  </p>\n<!-- built 2026 --><script>x=2</script>", "")
+	c, _ := Normalize(`<p>Hello <b>there</b></p>`, "")
+	if a.Hash != b.Hash || a.Hash == c.Hash || len(a.Hash) != 64 {
+		t.Errorf("hashes %s, %s and %s", a.Hash, b.Hash, c.Hash)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name     string
+		baseline string
+		current  string
+		percent  float64
+		diff     string
+	}{
+		{"unchanged", "a\nb", "a\nb", 0, ""},
+		{"changed line", "aaaa\nbbbb\ncccc", "aaaa\nbbbX\ncccc", 10.0 / 14 * 100, "- bbbb\n+ bbbX\n"},
+		{"added line", "aaaa", "aaaa\nbbbb", 5.0 / 9 * 100, "+ bbbb\n"},
+		{"removed line", "aaaa\nbbbb", "aaaa", 5.0 / 9 * 100, "- bbbb\n"},
+		{"everything replaced", "old", "new", 100, "- old\n+ new\n"},
+		{"from nothing", "", "new", 100, "+ new\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmp := Compare(tt.baseline, &Snapshot{Text: tt.current})
+			if cmp.Changed != (tt.percent > 0) || math.Abs(cmp.ChangePercent-tt.percent) > 1e-9 || cmp.Diff != tt.diff {
+				t.Errorf("Compare() = %v, %.2f%%, diff %q; want %.2f%%, diff %q", cmp.Changed, cmp.ChangePercent, cmp.Diff, tt.percent, tt.diff)
+			}
+		})
+	}
+
+	long := strings.Repeat("line\n", maxDiffSize/4)
+	if cmp := Compare("", &Snapshot{Text: long}); len(cmp.Diff) > maxDiffSize+100 || !strings.HasSuffix(cmp.Diff, "(diff truncated)\n") {
+		t.Errorf("diff of %d bytes was not truncated", len(cmp.Diff))
+	}
+}
+
+func TestCheckThreshold(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&database.ContentBaseline{}, &database.ContentChange{}); err != nil {
+		t.Fatal(err)
+	}
+	prev := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = prev })
+
+	// 100 lines of 9 characters, the given number of them changed
+	changed := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 100; i++ {
+			line := fmt.Sprintf("line %04d", i)
+			if i < changed {
+				line = fmt.Sprintf("LINE %04d", i)
+			}
+			fmt.Fprintf(w, "<p>%s</p>", line)
+		}
+	}))
+	defer srv.Close()
+	domain := database.MonitoredDomain{DomainName: strings.TrimPrefix(srv.URL, "http://")}
+	domain.ID = 1
+
+	steps := []struct {
+		name      string
+		changed   int
+		threshold float64
+		alert     bool
+	}{
+		{"the first check records the baseline", 0, 0, false},
+		// A changed line counts as deleted and inserted: 2 changed lines are 4%
+		{"below the default threshold", 2, 0, false},
+		{"above the default threshold", 3, 0, true},
+		{"the same change again", 3, 0, false},
+		{"below a configured threshold", 10, 25, false},
+		{"above a configured threshold", 15, 25, true},
+		{"any change with a small threshold", 1, 0.1, true},
+		{"back to the baseline", 0, 0.1, false},
+	}
+	for _, s := range steps {
+		changed = s.changed
+		domain.ContentThreshold = s.threshold
+		change, err := Check(domain)
+		if err != nil {
+			t.Fatalf("%s: %v", s.name, err)
+		}
+		if (change != nil) != s.alert {
+			t.Errorf("%s: got change %+v, want alert %v", s.name, change, s.alert)
+		}
+	}
+
+	var n int64
+	db.Model(&database.ContentChange{}).Count(&n)
+	if n != 3 {
+		t.Errorf("stored %d changes, want 3", n)
+	}
+}
@@ -0,0 +1,273 @@
+package content
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+
+	"golang.org/x/net/html"
+)
+
+// step is one compound selector, e.g. div#main.hero[role=banner] or //section[@id='x'].
+type step struct {
+	tag        string            // "" or "*" matches any element
+	id         string            // Required id attribute
+	classes    []string          // Required classes
+	attrs      map[string]string // Required attribute values
+	descendant bool              // Match at any depth below the previous step (otherwise direct child)
+}
+
+// parseSelector parses the supported subset of CSS selectors and XPath expressions.
+//
+// CSS: compound selectors of the form tag#id.class[attr=value] combined with the
+// descendant (space) and child (>) combinators, e.g. "main .hero > h1".
+// XPath: location paths of element steps with an optional attribute predicate,
+// e.g. "//div[@id='content']/p" or "//*[@class='price']".
+func parseSelector(sel string) ([]step, error) {
+	sel = strings.TrimSpace(sel)
+	if sel == "" {
+		return nil, fmt.Errorf("empty selector")
+	}
+	if strings.HasPrefix(sel, "/") {
+		return parseXPath(sel)
+	}
+	return parseCSS(sel)
+}
+
+// ValidateSelector reports whether sel is a supported CSS selector or XPath expression.
+func ValidateSelector(sel string) error {
+	_, err := parseSelector(sel)
+	return err
+}
+
+func parseCSS(sel string) ([]step, error) {
+	var steps []step
+	descendant := true
+	for _, tok := range cssTokens(sel) {
+		if tok == ">" {
+			descendant = false
+			continue
+		}
+
+		st := step{descendant: descendant, attrs: map[string]string{}}
+		descendant = true
+
+		// Attribute selectors: [name=value]
+		for {
+			open := strings.Index(tok, "[")
+			if open < 0 {
+				break
+			}
+			end := strings.Index(tok[open:], "]")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated attribute selector in %q", tok)
+			}
+			name, value := splitAttr(tok[open+1 : open+end])
+			st.attrs[name] = value
+			tok = tok[:open] + tok[open+end+1:]
+		}
+
+		// Split on # and . while keeping the marker
+		cur, kind := "", byte(0)
+		flush := func() {
+			switch kind {
+			case 0:
+				st.tag = strings.ToLower(cur)
+			case '#':
+				st.id = cur
+			case '.':
+				st.classes = append(st.classes, cur)
+			}
+		}
+		for i := 0; i < len(tok); i++ {
+			if tok[i] == '#' || tok[i] == '.' {
+				flush()
+				cur, kind = "", tok[i]
+				continue
+			}
+			cur += string(tok[i])
+		}
+		flush()
+
+		steps = append(steps, st)
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("invalid selector %q", sel)
+	}
+	return steps, nil
+}
+
+// cssTokens splits a CSS selector into compound selectors and ">" combinators.
+// Whitespace and ">" within attribute selectors are kept, e.g. in [title="a > b"].
+func cssTokens(sel string) []string {
+	var tokens []string
+	var cur strings.Builder
+	flush := func() {
+		if cur.Len() > 0 {
+			tokens = append(tokens, cur.String())
+			cur.Reset()
+		}
+	}
+	depth := 0
+	for _, r := range sel {
+		switch {
+		case r == '[':
+			depth++
+		case r == ']' && depth > 0:
+			depth--
+		case depth > 0:
+		case r == '>':
+			flush()
+			tokens = append(tokens, ">")
+			continue
+		case unicode.IsSpace(r):
+			flush()
+			continue
+		}
+		cur.WriteRune(r)
+	}
+	flush()
+	return tokens
+}
+
+func parseXPath(sel string) ([]step, error) {
+	var steps []step
+	rest := sel
+	for rest != "" {
+		descendant := false
+		switch {
+		case strings.HasPrefix(rest, "//"):
+			descendant = true
+			rest = rest[2:]
+		case strings.HasPrefix(rest, "/"):
+			rest = rest[1:]
+		default:
+			return nil, fmt.Errorf("invalid XPath %q", sel)
+		}
+
+		// A step ends at the next "/" outside of a predicate
+		end, depth := len(rest), 0
+		for i := 0; i < len(rest); i++ {
+			switch rest[i] {
+			case '[':
+				depth++
+			case ']':
+				depth--
+			case '/':
+				if depth == 0 && end == len(rest) {
+					end = i
+				}
+			}
+		}
+		tok := rest[:end]
+		rest = rest[end:]
+
+		st := step{descendant: descendant, attrs: map[string]string{}}
+		if open := strings.Index(tok, "["); open >= 0 {
+			if !strings.HasSuffix(tok, "]") {
+				return nil, fmt.Errorf("unterminated predicate in %q", tok)
+			}
+			pred := strings.TrimPrefix(tok[open+1:len(tok)-1], "@")
+			name, value := splitAttr(pred)
+			switch name {
+			case "id":
+				st.id = value
+			case "class":
+				st.classes = strings.Fields(value)
+			default:
+				st.attrs[name] = value
+			}
+			tok = tok[:open]
+		}
+		st.tag = strings.ToLower(tok)
+		if st.tag == "" {
+			return nil, fmt.Errorf("invalid XPath step in %q", sel)
+		}
+		steps = append(steps, st)
+	}
+	if len(steps) == 0 {
+		return nil, fmt.Errorf("invalid XPath %q", sel)
+	}
+	return steps, nil
+}
+
+// splitAttr splits name=value (value optionally quoted). A bare name matches any value.
+func splitAttr(s string) (string, string) {
+	name, value, ok := strings.Cut(s, "=")
+	if !ok {
+		return strings.TrimSpace(s), ""
+	}
+	value = strings.Trim(strings.TrimSpace(value), `"'`)
+	return strings.TrimSpace(name), value
+}
+
+// matches reports whether an element node satisfies a single step.
+func (st step) matches(n *html.Node) bool {
+	if n.Type != html.ElementNode {
+		return false
+	}
+	if st.tag != "" && st.tag != "*" && n.Data != st.tag {
+		return false
+	}
+
+	attrs := make(map[string]string, len(n.Attr))
+	for _, a := range n.Attr {
+		attrs[a.Key] = a.Val
+	}
+
+	if st.id != "" && attrs["id"] != st.id {
+		return false
+	}
+	if len(st.classes) > 0 {
+		have := map[string]bool{}
+		for _, c := range strings.Fields(attrs["class"]) {
+			have[c] = true
+		}
+		for _, c := range st.classes {
+			if !have[c] {
+				return false
+			}
+		}
+	}
+	for name, value := range st.attrs {
+		got, ok := attrs[name]
+		if !ok || (value != "" && got != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// selectNodes returns all nodes under root matching the selector steps, in document order.
+func selectNodes(root *html.Node, steps []step) []*html.Node {
+	current := []*html.Node{root}
+	for _, st := range steps {
+		var next []*html.Node
+		seen := map[*html.Node]bool{}
+		for _, n := range current {
+			collect(n, st, func(m *html.Node) {
+				if !seen[m] {
+					seen[m] = true
+					next = append(next, m)
+				}
+			})
+		}
+		current = next
+		if len(current) == 0 {
+			break
+		}
+	}
+	return current
+}
+
+// collect calls fn for every child (or descendant, for descendant steps) of n matching st.
+func collect(n *html.Node, st step, fn func(*html.Node)) {
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if st.matches(c) {
+			fn(c)
+		}
+		if st.descendant {
+			collect(c, st, fn)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package content
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/html"
+)
+
+func TestParseSelector(t *testing.T) {
+	tests := []struct {
+		sel   string
+		want  []step
+		error bool
+	}{
+		{sel: "h1", want: []step{{tag: "h1", descendant: true}}},
+		{sel: "  DIV#main.hero.wide ", want: []step{{tag: "div", id: "main", classes: []string{"hero", "wide"}, descendant: true}}},
+		{sel: ".price", want: []step{{classes: []string{"price"}, descendant: true}}},
+		{
+			sel:  "main .hero > h1",
+			want: []step{{tag: "main", descendant: true}, {classes: []string{"hero"}, descendant: true}, {tag: "h1"}},
+		},
+		{sel: "ul>li", want: []step{{tag: "ul", descendant: true}, {tag: "li"}}},
+		{
+			sel:  `a[href="/pricing"][data-track]`,
+			want: []step{{tag: "a", attrs: map[string]string{"href": "/pricing", "data-track": ""}, descendant: true}},
+		},
+		{sel: "[role = 'banner']", want: []step{{attrs: map[string]string{"role": "banner"}, descendant: true}}},
+		{
+			sel:  `p > [title="a > b c"]`,
+			want: []step{{tag: "p", descendant: true}, {attrs: map[string]string{"title": "a > b c"}}},
+		},
+		{sel: "//div[@id='content']/p", want: []step{{tag: "div", id: "content", descendant: true}, {tag: "p"}}},
+		{sel: `//*[@class="price sale"]`, want: []step{{tag: "*", classes: []string{"price", "sale"}, descendant: true}}},
+		{sel: "/html/body//SPAN[@data-sku]", want: []step{{tag: "html"}, {tag: "body"}, {tag: "span", attrs: map[string]string{"data-sku": ""}, descendant: true}}},
+		{sel: "//a[@href='/a/b']", want: []step{{tag: "a", attrs: map[string]string{"href": "/a/b"}, descendant: true}}},
+
+		{sel: "", error: true},
+		{sel: "  ", error: true},
+		{sel: ">", error: true},
+		{sel: "a[href", error: true},
+		{sel: "//div[@id='x'", error: true},
+		{sel: "//div[@id='x']/", error: true},
+		{sel: "//[@id='x']", error: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sel, func(t *testing.T) {
+			got, err := parseSelector(tt.sel)
+			if tt.error {
+				if err == nil {
+					t.Errorf("parsed as %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := range tt.want {
+				if tt.want[i].attrs == nil {
+					tt.want[i].attrs = map[string]string{}
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+const selectorPage = `<html><body>
+<main id="main">
+  <section class="hero wide"><h1>Title</h1><div><h1>Nested</h1></div></section>
+  <ul><li class="item">One</li><li class="item sale">Two</li></ul>
+  <p data-sku="a1">First</p><p data-sku="b2">Second</p><p>Third</p>
+  <a href="/pricing" data-track>Pricing</a>
+  <abbr title="a > b">Compare</abbr>
+</main>
+<footer><p>Footer</p></footer>
+</body></html>`
+
+func TestSelectNodes(t *testing.T) {
+	doc, err := html.Parse(strings.NewReader(selectorPage))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		sel  string
+		want string // Text of the matched nodes
+	}{
+		{"h1", "Title|Nested"},
+		{".hero > h1", "Title"},
+		{"section h1", "Title|Nested"},
+		{"section > div > h1", "Nested"},
+		{"li.item", "One|Two"},
+		{"li.item.sale", "Two"},
+		{".sale.missing", ""},
+		{"p[data-sku]", "First|Second"},
+		{"p[data-sku=b2]", "Second"},
+		{"#main p", "First|Second|Third"},
+		{"main > p", "First|Second|Third"},
+		{"body > p", ""},
+		{"a[href='/pricing'][data-track]", "Pricing"},
+		{`main > [title="a > b"]`, "Compare"},
+		{"//p", "First|Second|Third|Footer"},
+		{"//footer/p", "Footer"},
+		{"/html/body/main/p", "First|Second|Third"},
+		{"/body", ""},
+		{"//*[@id='main']/section//h1", "Title|Nested"},
+		{"//li[@class='sale item']", "Two"},
+		{"//p[@data-sku='a1']", "First"},
+		{"//*[@data-track]", "Pricing"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sel, func(t *testing.T) {
+			steps, err := parseSelector(tt.sel)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var texts []string
+			for _, n := range selectNodes(doc, steps) {
+				var b strings.Builder
+				extractText(n, &b)
+				texts = append(texts, strings.TrimSpace(b.String()))
+			}
+			if got := strings.Join(texts, "|"); got != tt.want {
+				t.Errorf("selected %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
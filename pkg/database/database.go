@@ -1,6 +1,7 @@
 package database
 
 import (
+	"fmt"
 	"log"
 	"sync"
 	"time"
@@ -48,15 +49,20 @@ type MonitoredDomain struct {
 	Status               string    `json:"status"`
 	SSLExpiry            time.Time `json:"ssl_expiry"`
 	SSLStatus            string    `json:"ssl_status"`
+	SSLSerial            string    `json:"ssl_serial"` // Serial number of the last seen certificate, in hex
 	LastCheckTime        time.Time `json:"last_check_time"`
 	AutoRenew            bool      `json:"auto_renew" gorm:"default:true"` // 续费提醒开关
 	LastNotificationSent time.Time `json:"last_notification_sent" gorm:"column:last_notification_sent"`
 	IsLive               bool      `json:"is_live" gorm:"default:false"`
 	StatusCode           int       `json:"status_code" gorm:"default:0"`
-	LastStatusCode       int       `json:"last_status_code" gorm:"default:0"` // Last HTTP status code received
-	ResponseTime         int       `json:"response_time" gorm:"default:0"`    // Response time in milliseconds
-	Tags                 string    `json:"tags" gorm:"type:text"`            // Comma-separated tags for categorization
-	CustomStatus         string    `json:"custom_status"`                     // User-defined status (e.g., "Testing", "Production", "Pending Migration")
+	LastStatusCode       int       `json:"last_status_code" gorm:"default:0"`   // Last HTTP status code received
+	ResponseTime         int       `json:"response_time" gorm:"default:0"`      // Response time in milliseconds
+	Tags                 string    `json:"tags" gorm:"type:text"`               // Comma-separated tags for categorization
+	CustomStatus         string    `json:"custom_status"`                       // User-defined status (e.g., "Testing", "Production", "Pending Migration")
+	ContentWatch         bool      `json:"content_watch" gorm:"default:false"`  // Enable content change / defacement detection
+	ContentSelector      string    `json:"content_selector"`                    // Optional CSS or XPath region to watch (whole page if empty)
+	ContentThreshold     float64   `json:"content_threshold" gorm:"default:0"`  // Percent of changed content that raises an alert, above 0 (0 = default)
+	AnomalyAlerts        bool      `json:"anomaly_alerts" gorm:"default:false"` // Notify when latency deviates from the baseline
 }
 
 // Heartbeat represents a single health check result for a monitored domain
@@ -67,68 +73,507 @@ type Heartbeat struct {
 	StatusCode    int       `json:"status_code"`                                // HTTP status code (0 if unreachable)
 	DNSLookup     int       `json:"dns_lookup"`                                 // DNS lookup time in milliseconds
 	TCPConnection int       `json:"tcp_connection"`                             // TCP connection time in milliseconds
-	TLSHandshake  int       `json:"tls_handshake"`                               // TLS handshake time in milliseconds (0 for HTTP)
+	TLSHandshake  int       `json:"tls_handshake"`                              // TLS handshake time in milliseconds (0 for HTTP)
 	TTFB          int       `json:"ttfb"`                                       // Time to First Byte in milliseconds
 	NodeLocation  string    `json:"node_location" gorm:"default:'Japan-Tokyo'"` // Monitoring node location
+	DiagnosticID  uint      `json:"diagnostic_id,omitempty"`                    // Diagnostic snapshot captured for a failed check
+	Anomaly       bool      `json:"anomaly,omitempty"`                          // Latency of a phase deviated from the baseline
 	CreatedAt     time.Time `gorm:"index" json:"created_at"`                    // Timestamp of the check
 }
 
+// LatencyBaseline is the rolling latency baseline (EWMA of mean and variance) of one
+// probe phase of a domain, over all checks (Hour -1) or over one hour of the day.
+type LatencyBaseline struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	DomainID  uint      `gorm:"uniqueIndex:idx_latency_baseline" json:"domain_id"`
+	Phase     string    `gorm:"uniqueIndex:idx_latency_baseline" json:"phase"` // dns, tcp, tls, ttfb or total
+	Hour      int       `gorm:"uniqueIndex:idx_latency_baseline" json:"hour"`  // Hour of the day (0-23), -1 for all hours
+	Mean      float64   `json:"mean"`                                          // Milliseconds
+	Variance  float64   `json:"variance"`
+	Samples   int       `json:"samples"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// LatencyAnomaly marks a heartbeat whose latency of one phase deviated significantly
+// from the baseline.
+type LatencyAnomaly struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	DomainID    uint      `gorm:"index" json:"domain_id"`
+	HeartbeatID uint      `gorm:"index" json:"heartbeat_id"`
+	Phase       string    `json:"phase"`
+	Value       int       `json:"value"`    // Observed latency in milliseconds
+	Baseline    float64   `json:"baseline"` // Baseline mean in milliseconds
+	StdDev      float64   `json:"std_dev"`
+	Score       float64   `json:"score"`  // Deviation in standard deviations
+	Hourly      bool      `json:"hourly"` // Compared against the hour-of-day baseline
+	CreatedAt   time.Time `gorm:"index" json:"created_at"`
+}
+
+// DiagnosticSnapshot records what happened during a health check request, captured
+// on every failed check and on every up/down transition.
+type DiagnosticSnapshot struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	DomainID    uint      `gorm:"index" json:"domain_id"`
+	HeartbeatID uint      `gorm:"index" json:"heartbeat_id,omitempty"`
+	IncidentID  uint      `gorm:"index" json:"incident_id,omitempty"`
+	Trigger     string    `json:"trigger"` // failure or transition
+	URL         string    `json:"url"`
+	ErrorClass  string    `json:"error_class"`            // e.g. dns_nxdomain, connection_refused, timeout_tls, http_status
+	Error       string    `gorm:"type:text" json:"error"` // Raw transport error, if any
+	Phase       string    `json:"phase"`                  // Request phase reached: dns, connect, tls, request, response, body
+	ResolvedIPs string    `json:"resolved_ips"`           // Comma-separated addresses returned by DNS
+	RemoteAddr  string    `json:"remote_addr"`            // Address actually connected to
+	TLSVersion  string    `json:"tls_version"`
+	TLSError    string    `gorm:"type:text" json:"tls_error"`
+	StatusCode  int       `json:"status_code"`
+	Headers     string    `gorm:"type:text" json:"headers"` // Response headers as JSON
+	Body        string    `gorm:"type:text" json:"body"`    // First few KB of the response body
+	CreatedAt   time.Time `gorm:"index" json:"created_at"`
+}
+
 // User represents an authenticated platform user.
 type User struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Username  string    `gorm:"uniqueIndex" json:"username"`
-	Password  string    `json:"-"` // Hashed password, never exposed in JSON responses.
-	Role      string    `json:"role"`
-	Status    string    `gorm:"default:'pending'" json:"status"`
-	CreatedAt time.Time `json:"created_at"`
+	ID             uint      `gorm:"primaryKey" json:"id"`
+	Username       string    `gorm:"uniqueIndex" json:"username"`
+	Password       string    `json:"-"` // Hashed password, never exposed in JSON responses.
+	Role           string    `json:"role"`
+	Status         string    `gorm:"default:'pending'" json:"status"`
+	Email          string    `json:"email"`            // Contact address for on-call pages
+	TelegramChatID string    `json:"telegram_chat_id"` // Contact chat for on-call pages
+	CreatedAt      time.Time `json:"created_at"`
 }
 
-// NotificationConfig stores webhook configuration for notification platforms
-type NotificationConfig struct {
-	ID         uint      `gorm:"primaryKey" json:"id"`
-	WebhookURL string    `json:"webhook_url" gorm:"column:webhook_url"`
-	SecretKey  string    `json:"secret_key" gorm:"column:secret_key"`
-	Platform   string    `json:"platform"` // e.g., DingTalk, Feishu, Slack
-	IsActive   bool      `json:"is_active" gorm:"default:true"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
+// Channel is a notification channel. Type selects the schema of Settings, a JSON
+// object validated by the notify package: a webhook platform, an SMTP server and its
+// recipients, or a Telegram bot and chat. During quiet hours, notifications below
+// QuietSeverity are held back until the hours end or a digest includes them.
+type Channel struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	Type          string    `gorm:"index" json:"type"` // webhook, email or telegram
+	Name          string    `json:"name"`
+	Settings      string    `gorm:"type:text" json:"-"`
+	Locale        string    `json:"locale"` // Language of the messages, e.g. en or zh-CN; empty uses the server default
+	IsActive      bool      `json:"is_active"`
+	Cursor        int64     `json:"-"`              // Next update ID of Telegram bots polling for commands
+	QuietStart    string    `json:"quiet_start"`    // Local start of quiet hours "HH:MM", empty for none
+	QuietEnd      string    `json:"quiet_end"`      // Local end of quiet hours "HH:MM"; before the start for windows spanning midnight
+	TimeZone      string    `json:"time_zone"`      // Time zone of the quiet hours, empty for UTC
+	QuietSeverity string    `json:"quiet_severity"` // Lowest urgency delivered during quiet hours; empty for critical
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
 }
 
 // MessageTemplate stores notification message templates for different events
 // Also known as NotificationTemplate with fields: Type (Name/EventName), Content (TemplateText)
 type MessageTemplate struct {
+	ID            uint   `gorm:"primaryKey" json:"id"`
+	EventName     string `json:"event_name" gorm:"uniqueIndex"` // e.g., "SSL_EXPIRED", "SITE_DOWN"
+	TitleTemplate string `json:"title_template" gorm:"column:title_template"`
+	BodyTemplate  string `json:"body_template" gorm:"column:body_template"`
+	Name          string `json:"name" gorm:"column:name"`                   // Type: "SiteDown", "SSLExpired"
+	TemplateText  string `json:"template_text" gorm:"column:template_text"` // Content: Template text for Telegram
+	Template      string `gorm:"type:text" json:"template"`                 // Alias for TemplateText (backward compatibility)
+	HTMLTemplate  string `gorm:"type:text" json:"html_template"`            // HTML body for email; generated from BodyTemplate if empty
+	// Variants take precedence over the fields above, which are used only for
+	// templates without variants.
+	Variants  []TemplateVariant `json:"variants" gorm:"foreignKey:TemplateID"`
+	CreatedAt time.Time         `json:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// TemplateVariant is the text of a message template for one locale and channel
+// format. Title and body are Go text/template templates; html variants are rendered
+// with html/template.
+type TemplateVariant struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	TemplateID uint      `gorm:"uniqueIndex:idx_template_variant" json:"template_id"`
+	Locale     string    `gorm:"uniqueIndex:idx_template_variant" json:"locale"` // e.g. en, zh-CN; empty matches any locale
+	Format     string    `gorm:"uniqueIndex:idx_template_variant" json:"format"` // plain, markdown, html or slack (Block Kit JSON)
+	Title      string    `gorm:"type:text" json:"title"`
+	Body       string    `gorm:"type:text" json:"body"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// RoutingRule routes matching notification events to specific channels. Rules are
+// evaluated by ascending position; empty conditions match everything.
+type RoutingRule struct {
+	ID                 uint      `gorm:"primaryKey" json:"id"`
+	Name               string    `json:"name"`
+	Position           int       `json:"position" gorm:"default:0"`             // Evaluation order, lowest first
+	Events             string    `json:"events" gorm:"type:text"`               // Comma-separated event names, "*" wildcards allowed (e.g. SSL_*)
+	Tags               string    `json:"tags" gorm:"type:text"`                 // Comma-separated domain tags, any of which must be present
+	CustomStatus       string    `json:"custom_status" gorm:"type:text"`        // Comma-separated custom statuses of the domain
+	Severities         string    `json:"severities"`                            // Comma-separated severities: critical, error, warning, info
+	Channels           string    `json:"channels" gorm:"type:text"`             // Comma-separated channel refs, e.g. webhook:1,email:2,telegram:3
+	Continue           bool      `json:"continue" gorm:"default:false"`         // Keep evaluating later rules after a match
+	EscalationPolicyID uint      `json:"escalation_policy_id" gorm:"default:0"` // Policy started for trigger events, 0 for none
+	GroupWindow        int       `json:"group_window" gorm:"default:0"`         // Seconds events are batched into one message, 0 sends them at once
+	GroupBy            string    `json:"group_by"`                              // Comma-separated event data keys grouping events besides the event type, e.g. issuer,expiry_date
+	IsActive           bool      `json:"is_active" gorm:"default:true"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// OnCallSchedule is a rotation of users who are on call. Layers are stacked with
+// higher positions taking precedence, and overrides take precedence over all layers.
+type OnCallSchedule struct {
+	ID          uint               `gorm:"primaryKey" json:"id"`
+	Name        string             `json:"name"`
+	Description string             `json:"description" gorm:"type:text"`
+	TimeZone    string             `json:"time_zone" gorm:"default:'UTC'"` // IANA zone of the layer restrictions
+	Layers      []ScheduleLayer    `json:"layers" gorm:"foreignKey:ScheduleID"`
+	Overrides   []ScheduleOverride `json:"overrides" gorm:"foreignKey:ScheduleID"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+}
+
+// ScheduleLayer rotates its users, handing over every RotationHours from Start.
+type ScheduleLayer struct {
 	ID            uint      `gorm:"primaryKey" json:"id"`
-	EventName     string    `json:"event_name" gorm:"uniqueIndex"` // e.g., "SSL_EXPIRED", "SITE_DOWN"
-	TitleTemplate string    `json:"title_template" gorm:"column:title_template"`
-	BodyTemplate  string    `json:"body_template" gorm:"column:body_template"`
-	Name          string    `json:"name" gorm:"column:name"`                   // Type: "SiteDown", "SSLExpired"
-	TemplateText  string    `json:"template_text" gorm:"column:template_text"` // Content: Template text for Telegram
-	Template      string    `gorm:"type:text" json:"template"`                 // Alias for TemplateText (backward compatibility)
-	CreatedAt     time.Time `json:"created_at"`
-	UpdatedAt     time.Time `json:"updated_at"`
+	ScheduleID    uint      `gorm:"index" json:"schedule_id"`
+	Name          string    `json:"name"`
+	Position      int       `json:"position" gorm:"default:0"` // Higher layers take precedence
+	Users         string    `json:"users"`                     // Comma-separated user IDs in rotation order
+	Start         time.Time `json:"start"`                     // Handover time of the first user
+	RotationHours int       `json:"rotation_hours" gorm:"default:168"`
+	RestrictStart string    `json:"restrict_start"` // Optional daily window "HH:MM", e.g. business hours
+	RestrictEnd   string    `json:"restrict_end"`
+}
+
+// ScheduleOverride puts a user on call for a time range, e.g. to cover a shift.
+type ScheduleOverride struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	ScheduleID uint      `gorm:"index" json:"schedule_id"`
+	UserID     uint      `json:"user_id"`
+	Start      time.Time `json:"start"`
+	End        time.Time `json:"end"`
+	Reason     string    `json:"reason"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// EscalationPolicy pages its steps in order until an alert is acknowledged.
+type EscalationPolicy struct {
+	ID          uint             `gorm:"primaryKey" json:"id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description" gorm:"type:text"`
+	Repeat      int              `json:"repeat" gorm:"default:0"` // Times the steps are repeated after the last one
+	Steps       []EscalationStep `json:"steps" gorm:"foreignKey:PolicyID"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// EscalationStep pages its targets and waits DelayMinutes for an acknowledgement
+// before the next step.
+type EscalationStep struct {
+	ID           uint   `gorm:"primaryKey" json:"id"`
+	PolicyID     uint   `gorm:"index" json:"policy_id"`
+	Position     int    `json:"position" gorm:"default:0"`
+	Targets      string `json:"targets" gorm:"type:text"` // Comma-separated: schedule:1, user:2 or channel refs such as webhook:3
+	DelayMinutes int    `json:"delay_minutes" gorm:"default:10"`
+}
+
+// Escalation tracks an alert paged through an escalation policy.
+type Escalation struct {
+	ID         uint       `gorm:"primaryKey" json:"id"`
+	PolicyID   uint       `gorm:"index;uniqueIndex:idx_open_escalation,where:status IN ('triggered'\\,'acknowledged')" json:"policy_id"` // One open escalation per alert and policy
+	DedupKey   string     `gorm:"index;uniqueIndex:idx_open_escalation" json:"dedup_key"`                                                // Alert key shared by trigger and resolve events
+	Event      string     `json:"event"`
+	Domain     string     `json:"domain"`
+	Title      string     `json:"title"`
+	Body       string     `json:"body" gorm:"type:text"`
+	Urgency    string     `json:"urgency"`
+	Status     string     `gorm:"index" json:"status"` // triggered, acknowledged, resolved or exhausted
+	Step       int        `json:"step"`                // Next step to page
+	Round      int        `json:"round"`               // Completed repetitions of the policy
+	NextAt     time.Time  `gorm:"index" json:"next_at"`
+	Paged      string     `json:"paged" gorm:"type:text"` // Comma-separated user IDs paged so far
+	AckedBy    uint       `json:"acked_by"`
+	AckedAt    *time.Time `json:"acked_at"`
+	ResolvedAt *time.Time `json:"resolved_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// OutboxMessage is a notification waiting for delivery to one channel. Messages are
+// retried with backoff and end up in the dead-letter list when retries run out.
+type OutboxMessage struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	Channel       string     `gorm:"index" json:"channel"` // Channel ref, e.g. webhook:1
+	Platform      string     `json:"platform"`
+	Event         string     `gorm:"index" json:"event"`
+	Domain        string     `json:"domain"`
+	DedupKey      string     `json:"dedup_key"`
+	DeliveryID    string     `gorm:"index" json:"delivery_id"` // Sent to receivers, the same for all attempts
+	Payload       string     `json:"payload" gorm:"type:text"` // JSON-encoded message
+	Status        string     `gorm:"index" json:"status"`      // pending, sending, delivered or dead
+	Attempts      int        `json:"attempts"`
+	MaxAttempts   int        `json:"max_attempts"`
+	NextAttemptAt time.Time  `gorm:"index" json:"next_attempt_at"` // Also the lease expiry while sending
+	LastError     string     `json:"last_error" gorm:"type:text"`
+	DeliveredAt   *time.Time `json:"delivered_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// NotificationGroup batches events of one type and group key for a channel until
+// FlushAt, when they are queued as a single message.
+type NotificationGroup struct {
+	ID        uint                    `gorm:"primaryKey" json:"id"`
+	Channel   string                  `gorm:"uniqueIndex:idx_notification_group" json:"channel"`
+	Platform  string                  `json:"platform"`
+	Event     string                  `gorm:"uniqueIndex:idx_notification_group" json:"event"`
+	GroupKey  string                  `gorm:"uniqueIndex:idx_notification_group" json:"group_key"`
+	Size      int                     `json:"size"` // Number of events in the group
+	FlushAt   time.Time               `gorm:"index" json:"flush_at"`
+	Items     []NotificationGroupItem `gorm:"foreignKey:GroupID" json:"items,omitempty"`
+	CreatedAt time.Time               `json:"created_at"`
+}
+
+// NotificationGroupItem is an event waiting in a notification group.
+type NotificationGroupItem struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	GroupID   uint      `gorm:"index" json:"group_id"`
+	Domain    string    `json:"domain"`
+	Payload   string    `json:"payload" gorm:"type:text"` // JSON-encoded message, rendered for the channel
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Digest is a scheduled summary of upcoming certificate and registration expirations
+// and recent incidents, sent to its channels.
+type Digest struct {
+	ID            uint       `gorm:"primaryKey" json:"id"`
+	Name          string     `json:"name"`
+	Channels      string     `json:"channels" gorm:"type:text"` // Comma-separated channel refs, e.g. webhook:1,email:2
+	Days          string     `json:"days"`                      // Comma-separated weekdays (mon, tue, ...), empty for every day
+	At            string     `json:"at"`                        // Local send time "HH:MM"
+	TimeZone      string     `json:"time_zone" gorm:"default:'UTC'"`
+	Horizon       int        `json:"horizon"` // Days ahead of expirations included
+	Certificates  bool       `json:"certificates"`
+	Registrations bool       `json:"registrations"`
+	Incidents     bool       `json:"incidents"` // Incidents since the previous digest
+	Tags          string     `json:"tags"`      // Comma-separated domain tags, any of which must be present; empty for all domains
+	IsActive      bool       `json:"is_active" gorm:"default:true"`
+	LastSentAt    *time.Time `json:"last_sent_at"`
+	NextRunAt     time.Time  `gorm:"index" json:"next_run_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
 }
 
-// NotifyConfig stores Telegram bot configuration for notifications
-// Also known as TelegramConfig with fields: BotToken (TGToken), ChatID (TGChatID), IsEnabled (IsActive)
-type NotifyConfig struct {
+// Silence suppresses the notifications and pages of matching events between StartsAt
+// and EndsAt. All non-empty matchers must match; within a matcher any value does.
+type Silence struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
-	TGToken   string    `json:"tg_token" gorm:"column:tg_token"`     // Telegram bot token (BotToken)
-	TGChatID  string    `json:"tg_chat_id" gorm:"column:tg_chat_id"` // Telegram chat ID (ChatID)
-	IsActive  bool      `json:"is_active" gorm:"default:true"`       // IsEnabled flag
+	DomainIDs string    `json:"domain_ids"`              // Comma-separated domain IDs
+	Tags      string    `json:"tags" gorm:"type:text"`   // Comma-separated domain tags
+	Events    string    `json:"events" gorm:"type:text"` // Comma-separated event names, "*" wildcards allowed
+	StartsAt  time.Time `gorm:"index" json:"starts_at"`
+	EndsAt    time.Time `gorm:"index" json:"ends_at"`
+	CreatedBy string    `json:"created_by"`
+	Comment   string    `json:"comment" gorm:"type:text"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
-// TelegramConfig stores Telegram bot configuration for notifications (backward compatibility alias)
-type TelegramConfig struct {
+// SuppressedEvent records an event a silence kept from being notified or paged.
+type SuppressedEvent struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
-	BotToken  string    `gorm:"uniqueIndex" json:"bot_token"`
-	ChatID    string    `json:"chat_id"`
-	Enabled   bool      `gorm:"default:true" json:"enabled"`
+	SilenceID uint      `gorm:"index" json:"silence_id"`
+	Event     string    `gorm:"index" json:"event"`
+	DomainID  uint      `gorm:"index" json:"domain_id"`
+	Domain    string    `json:"domain"`
+	Path      string    `json:"path"` // notification, escalation or digest
+	CreatedAt time.Time `gorm:"index" json:"created_at"`
+}
+
+// ReminderSchedule sets the days before expiry at which certificate reminders are
+// sent. The schedule without a tag is the global default; tagged schedules apply to
+// domains with that tag instead.
+type ReminderSchedule struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Tag       string    `gorm:"uniqueIndex" json:"tag"`
+	Stages    string    `json:"stages"` // Comma-separated days, e.g. "60,30,14,7,3,1"
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// CertificateReminder records a reminder stage sent for a certificate. Stages are
+// tracked per serial number, so a renewed certificate starts its schedule over.
+type CertificateReminder struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	DomainID  uint      `gorm:"uniqueIndex:idx_certificate_reminder" json:"domain_id"`
+	Serial    string    `gorm:"uniqueIndex:idx_certificate_reminder" json:"serial"`
+	Stage     int       `gorm:"uniqueIndex:idx_certificate_reminder" json:"stage"` // Days before expiry
+	ExpiresAt time.Time `json:"expires_at"`
+	SentAt    time.Time `gorm:"index" json:"sent_at"`
+}
+
+// DeliveryAttempt records one attempt to deliver a notification or on-call page.
+type DeliveryAttempt struct {
+	ID           uint      `gorm:"primaryKey" json:"id"`
+	OutboxID     uint      `gorm:"index" json:"outbox_id"`     // 0 for pages sent by escalations
+	EscalationID uint      `gorm:"index" json:"escalation_id"` // Set for on-call pages
+	UserID       uint      `gorm:"index" json:"user_id"`       // Paged user, 0 for channels
+	Channel      string    `gorm:"index" json:"channel"`       // Channel ref, or the contact kind of a paged user
+	Platform     string    `json:"platform"`
+	Recipient    string    `json:"recipient"`
+	Event        string    `gorm:"index" json:"event"`
+	Domain       string    `gorm:"index" json:"domain"`
+	DedupKey     string    `gorm:"index" json:"dedup_key"`
+	DeliveryID   string    `gorm:"index" json:"delivery_id"`
+	Title        string    `json:"title"`
+	Message      string    `json:"message" gorm:"type:text"` // Rendered plain text
+	Attempt      int       `json:"attempt"`
+	Success      bool      `gorm:"index" json:"success"`
+	StatusCode   int       `json:"status_code"` // HTTP or SMTP status of the last response, 0 if there was none
+	Error        string    `json:"error" gorm:"type:text"`
+	LatencyMs    int64     `json:"latency_ms"`
+	CreatedAt    time.Time `gorm:"index" json:"created_at"`
+}
+
+// DailyUptime aggregates health check results per domain and calendar day (UTC).
+// Heartbeats are pruned after 24 hours, so long-range uptime is derived from these rows.
+type DailyUptime struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	DomainID      uint      `gorm:"uniqueIndex:idx_daily_uptime_domain_day" json:"domain_id"`
+	Day           string    `gorm:"uniqueIndex:idx_daily_uptime_domain_day;size:10" json:"day"` // YYYY-MM-DD in UTC
+	TotalChecks   int       `json:"total_checks"`
+	SuccessChecks int       `json:"success_checks"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// ContentBaseline is the approved content of a watched domain that later checks are compared against.
+type ContentBaseline struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	DomainID      uint      `gorm:"uniqueIndex" json:"domain_id"`
+	Selector      string    `json:"selector"`                 // Selector the baseline was captured with
+	Hash          string    `json:"hash"`                     // SHA-256 of the normalized content
+	Content       string    `gorm:"type:text" json:"content"` // Normalized text content
+	LastAlertHash string    `json:"last_alert_hash"`          // Hash of the last change alerted on, to avoid repeats
+	CreatedAt     time.Time `json:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// ContentChange records a detected content change beyond the domain's threshold.
+type ContentChange struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	DomainID      uint      `gorm:"index" json:"domain_id"`
+	BaselineHash  string    `json:"baseline_hash"`
+	NewHash       string    `json:"new_hash"`
+	ChangePercent float64   `json:"change_percent"`
+	Diff          string    `gorm:"type:text" json:"diff"` // Line diff against the baseline (+ added, - removed)
+	CreatedAt     time.Time `gorm:"index" json:"created_at"`
+}
+
+// SyntheticCheck is a scripted multi-step HTTP scenario run on a schedule.
+type SyntheticCheck struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	Name            string    `gorm:"uniqueIndex" json:"name"`
+	DomainID        uint      `gorm:"index" json:"domain_id"`      // Optional: domain the scenario belongs to, used for notifications
+	Definition      string    `gorm:"type:text" json:"definition"` // Scenario definition in YAML or JSON
+	IntervalSeconds int       `json:"interval_seconds" gorm:"default:300"`
+	IsActive        bool      `json:"is_active" gorm:"default:true"`
+	LastRunAt       time.Time `json:"last_run_at"`
+	LastSuccess     bool      `json:"last_success"`
+	LastFailedStep  string    `json:"last_failed_step"`
+	LastError       string    `json:"last_error"`
+	CreatedAt       time.Time `json:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// SyntheticRun is the outcome of one execution of a synthetic check.
+type SyntheticRun struct {
+	ID              uint                  `gorm:"primaryKey" json:"id"`
+	CheckID         uint                  `gorm:"index" json:"check_id"`
+	Success         bool                  `json:"success"`
+	FailedStepIndex int                   `json:"failed_step_index"` // -1 when the run succeeded
+	FailedStep      string                `json:"failed_step"`
+	Error           string                `json:"error"`
+	Duration        int                   `json:"duration"` // Total run time in milliseconds
+	Steps           []SyntheticStepResult `gorm:"foreignKey:RunID" json:"steps,omitempty"`
+	CreatedAt       time.Time             `gorm:"index" json:"created_at"`
+}
+
+// SyntheticStepResult records the timings of a single step of a synthetic run, like a Heartbeat.
+type SyntheticStepResult struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	RunID         uint      `gorm:"index" json:"run_id"`
+	CheckID       uint      `gorm:"index" json:"check_id"`
+	StepIndex     int       `json:"step_index"`
+	StepName      string    `json:"step_name"`
+	StatusCode    int       `json:"status_code"`
+	Latency       int       `json:"latency"`        // Total step time in milliseconds
+	DNSLookup     int       `json:"dns_lookup"`     // DNS lookup time in milliseconds
+	TCPConnection int       `json:"tcp_connection"` // TCP connection time in milliseconds
+	TLSHandshake  int       `json:"tls_handshake"`  // TLS handshake time in milliseconds
+	TTFB          int       `json:"ttfb"`           // Time to First Byte in milliseconds
+	Success       bool      `json:"success"`
+	Error         string    `json:"error"`
+	CreatedAt     time.Time `gorm:"index" json:"created_at"`
+}
+
+// StatusPage is a public, unauthenticated status page that groups monitored domains into components.
+type StatusPage struct {
+	ID          uint              `gorm:"primaryKey" json:"id"`
+	Slug        string            `gorm:"uniqueIndex" json:"slug"` // Used in the public URL: /status/<slug>
+	Title       string            `json:"title"`
+	Description string            `json:"description"`
+	IsPublic    bool              `json:"is_public" gorm:"default:true"`
+	Components  []StatusComponent `gorm:"foreignKey:PageID" json:"components,omitempty"`
+	CreatedAt   time.Time         `json:"created_at"`
+	UpdatedAt   time.Time         `json:"updated_at"`
+}
+
+// StatusComponent is a named group of domains shown as a single row on a status page.
+type StatusComponent struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	PageID      uint      `gorm:"index" json:"page_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	DomainIDs   string    `json:"domain_ids"` // Comma-separated MonitoredDomain IDs
+	SortOrder   int       `json:"sort_order" gorm:"default:0"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Incident tracks an outage or degradation, either opened automatically by the
+// health monitor or created manually by an admin.
+type Incident struct {
+	ID           uint             `gorm:"primaryKey" json:"id"`
+	DomainID     uint             `gorm:"index" json:"domain_id"`      // 0 for incidents not tied to a single domain
+	StatusPageID uint             `gorm:"index" json:"status_page_id"` // Optional: show on this page regardless of domain
+	Title        string           `json:"title"`
+	Status       string           `json:"status"` // investigating, identified, monitoring, resolved
+	Impact       string           `json:"impact"` // none, minor, major, critical
+	Source       string           `json:"source"` // auto, manual
+	IsPublic     bool             `json:"is_public" gorm:"default:false"`
+	StartedAt    time.Time        `json:"started_at"`
+	ResolvedAt   *time.Time       `json:"resolved_at"`
+	Updates      []IncidentUpdate `gorm:"foreignKey:IncidentID" json:"updates,omitempty"`
+	CreatedAt    time.Time        `json:"created_at"`
+	UpdatedAt    time.Time        `json:"updated_at"`
+}
+
+// IncidentUpdate is a timeline entry for an incident. Only public updates are shown on status pages.
+type IncidentUpdate struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	IncidentID uint      `gorm:"index" json:"incident_id"`
+	Status     string    `json:"status"`
+	Message    string    `gorm:"type:text" json:"message"`
+	IsPublic   bool      `json:"is_public"` // No column default: GORM would leave false out of inserts
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// LeaderLease is a named lease held by one server replica at a time. The holder
+// renews it periodically; once it expires any replica may take it over.
+type LeaderLease struct {
+	Name           string    `gorm:"primaryKey" json:"name"`
+	HolderIdentity string    `json:"holder_identity"`
+	AcquiredAt     time.Time `json:"acquired_at"`
+	RenewedAt      time.Time `json:"renewed_at"`
+	ExpiresAt      time.Time `gorm:"index" json:"expires_at"`
+}
+
 // Init initializes the global SQLite database connection and runs migrations.
 // It is safe to call Init multiple times; initialization will only happen once.
 func Init() error {
@@ -139,6 +584,11 @@ func Init() error {
 			return
 		}
 
+		if err := resolveDuplicateEscalations(db); err != nil {
+			initErr = err
+			return
+		}
+
 		// Perform automatic schema migration for core models.
 		if err := db.AutoMigrate(
 			&Project{},
@@ -146,10 +596,39 @@ func Init() error {
 			&MonitoredDomain{},
 			&Heartbeat{},
 			&User{},
-			&NotificationConfig{},
+			&Channel{},
 			&MessageTemplate{},
-			&NotifyConfig{},
-			&TelegramConfig{}, // Backward compatibility
+			&TemplateVariant{},
+			&RoutingRule{},
+			&OnCallSchedule{},
+			&ScheduleLayer{},
+			&ScheduleOverride{},
+			&EscalationPolicy{},
+			&EscalationStep{},
+			&Escalation{},
+			&OutboxMessage{},
+			&NotificationGroup{},
+			&NotificationGroupItem{},
+			&Digest{},
+			&Silence{},
+			&SuppressedEvent{},
+			&ReminderSchedule{},
+			&CertificateReminder{},
+			&DeliveryAttempt{},
+			&DailyUptime{},
+			&ContentBaseline{},
+			&ContentChange{},
+			&SyntheticCheck{},
+			&SyntheticRun{},
+			&SyntheticStepResult{},
+			&StatusPage{},
+			&StatusComponent{},
+			&Incident{},
+			&IncidentUpdate{},
+			&LeaderLease{},
+			&DiagnosticSnapshot{},
+			&LatencyBaseline{},
+			&LatencyAnomaly{},
 		); err != nil {
 			initErr = err
 			return
@@ -163,6 +642,11 @@ func Init() error {
 			log.Println("admin user status ensured to be active")
 		}
 
+		if err := migrateLegacyChannels(db); err != nil {
+			initErr = err
+			return
+		}
+
 		// Seed default message templates
 		seedMessageTemplates(db)
 
@@ -173,41 +657,245 @@ func Init() error {
 	return initErr
 }
 
-// seedMessageTemplates seeds default message templates if they don't exist
+// resolveDuplicateEscalations resolves all but the first open escalation of an alert
+// and policy, which concurrent triggers could create before idx_open_escalation
+// existed and which would keep the index from being created.
+func resolveDuplicateEscalations(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&Escalation{}) || db.Migrator().HasIndex(&Escalation{}, "idx_open_escalation") {
+		return nil
+	}
+	open := []string{"triggered", "acknowledged"}
+	res := db.Model(&Escalation{}).
+		Where("status IN ? AND id NOT IN (?)", open,
+			db.Model(&Escalation{}).Select("MIN(id)").Where("status IN ?", open).Group("policy_id, dedup_key")).
+		Updates(map[string]interface{}{"status": "resolved", "resolved_at": time.Now()})
+	if res.Error != nil {
+		return fmt.Errorf("failed to resolve duplicate escalations: %w", res.Error)
+	}
+	if res.RowsAffected > 0 {
+		log.Printf("Resolved %d duplicate open escalation(s)", res.RowsAffected)
+	}
+	return nil
+}
+
+// defaultMessageTemplates are created on startup when no template for their event exists.
+var defaultMessageTemplates = []MessageTemplate{
+	{
+		Name:          "SiteDown",
+		EventName:     "SITE_DOWN",
+		TemplateText:  "🚨 Site {{domain}} is down! Status code: {{status_code}}",
+		TitleTemplate: "Site Down Alert",
+		BodyTemplate:  "Site {{domain}} is down. Status code: {{status_code}}",
+	},
+	{
+		Name:          "SiteUp",
+		EventName:     "SITE_UP",
+		TemplateText:  "✅ Site {{domain}} is reachable again. Status code: {{status_code}}",
+		TitleTemplate: "Site Recovered",
+		BodyTemplate:  "Site {{domain}} is reachable again. Status code: {{status_code}}",
+	},
+	{
+		Name:          "SSLExpired",
+		EventName:     "SSL_CRITICAL",
+		TemplateText:  "🔒 The SSL certificate of {{domain}} expires in {{days}} days.",
+		TitleTemplate: "SSL Certificate Warning",
+		BodyTemplate:  "SSL certificate for {{domain}} will expire in {{days_remaining}} days.",
+	},
+	{
+		Name:          "SSLRenewed",
+		EventName:     "SSL_RENEWED",
+		TemplateText:  "🔓 The SSL certificate of {{domain}} was renewed and is valid until {{expiry_date}}.",
+		TitleTemplate: "SSL Certificate Renewed",
+		BodyTemplate:  "SSL certificate for {{domain}} was renewed and is valid until {{expiry_date}}.",
+	},
+	{
+		Name:          "ContentChanged",
+		EventName:     "CONTENT_CHANGED",
+		TemplateText:  "⚠️ Content change: {{change_percent}}% of the content of {{domain}} changed since the baseline.",
+		TitleTemplate: "Content Changed",
+		BodyTemplate:  "Content of {{domain}} changed by {{change_percent}}% compared to the baseline.",
+	},
+	{
+		Name:          "SyntheticFailed",
+		EventName:     "SYNTHETIC_FAILED",
+		TemplateText:  "🧪 Synthetic check {{check}} failed at step \"{{step}}\": {{error}}",
+		TitleTemplate: "Synthetic Check Failed",
+		BodyTemplate:  "Synthetic check {{check}} failed at step {{step}}: {{error}}",
+	},
+	{
+		Name:          "SyntheticRecovered",
+		EventName:     "SYNTHETIC_RECOVERED",
+		TemplateText:  "✅ Synthetic check {{check}} is passing again.",
+		TitleTemplate: "Synthetic Check Recovered",
+		BodyTemplate:  "Synthetic check {{check}} is passing again.",
+	},
+	{
+		Name:          "LatencyAnomaly",
+		EventName:     "LATENCY_ANOMALY",
+		TemplateText:  "🐢 Latency anomaly on {{domain}}: {{details}}",
+		TitleTemplate: "Latency Anomaly",
+		BodyTemplate:  "Latency of {{domain}} deviates from its baseline: {{details}}",
+	},
+	{
+		Name:          "Digest",
+		EventName:     "DIGEST",
+		TitleTemplate: `{{.name}} - {{date "2006-01-02" .time}}`,
+		BodyTemplate:  digestBody,
+	},
+}
+
+// digestBody lists the expirations and incidents of a digest.
+const digestBody = `{{- if .show_certificates}}Certificates expiring in the next {{.horizon}} days:
+{{range .certificates}}• {{.Domain}}: {{date "2006-01-02" .Expiry}} ({{if .Expired}}expired {{humanize (since .Expiry)}} ago{{else}}in {{humanize (until .Expiry)}}{{end}}){{with .Detail}}, {{.}}{{end}}
+{{else}}• None
+{{end}}
+{{end -}}
+{{- if .show_registrations}}Registrations expiring in the next {{.horizon}} days:
+{{range .registrations}}• {{.Domain}}: {{date "2006-01-02" .Expiry}} ({{if .Expired}}expired {{humanize (since .Expiry)}} ago{{else}}in {{humanize (until .Expiry)}}{{end}}){{with .Detail}}, {{.}}{{end}}
+{{else}}• None
+{{end}}
+{{end -}}
+{{- if .show_incidents}}Incidents since {{date "2006-01-02" .since}}:
+{{range .incidents}}• {{.Title}} [{{.Status}}], {{date "2006-01-02 15:04" .StartedAt}}, {{if .ResolvedAt}}lasted {{humanize .Duration}}{{else}}ongoing{{end}}
+{{else}}• None
+{{end}}{{end -}}`
+
+// seededTemplateText is the Telegram text of templates seeded by earlier versions,
+// used to tell untouched templates from edited ones.
+var seededTemplateText = map[string]string{
+	"SITE_DOWN":    "🚨 告警：站点 {{domain}} 无法访问！状态码：{{status}}",
+	"SITE_UP":      "✅ 恢复：站点 {{domain}} 已恢复访问。状态码：{{status}}",
+	"SSL_CRITICAL": "🔒 证书预警：域名 {{domain}} 的 SSL 证书将在 {{days}} 天后过期。",
+	"SSL_RENEWED":  "🔓 证书已更新：域名 {{domain}} 的 SSL 证书有效期至 {{expiry_date}}。",
+}
+
+// defaultTemplateVariants are the localized variants of the default templates, by event.
+var defaultTemplateVariants = map[string][]TemplateVariant{
+	"SITE_DOWN": {
+		{Locale: "en", Format: "plain",
+			Title: "Site down: {{.domain}}",
+			Body:  `🚨 {{.domain}} is not reachable{{with .status_code}}{{if ne . "0"}} (status code {{.}}){{end}}{{end}}{{with .error_class}}: {{.}}{{end}}.`},
+		{Locale: "zh-CN", Format: "plain",
+			Title: "站点故障：{{.domain}}",
+			Body:  `🚨 站点 {{.domain}} 无法访问{{with .status_code}}{{if ne . "0"}}，状态码 {{.}}{{end}}{{end}}{{with .error_class}}（{{.}}）{{end}}。`},
+	},
+	"SITE_UP": {
+		{Locale: "en", Format: "plain",
+			Title: "Site recovered: {{.domain}}",
+			Body:  `✅ {{.domain}} is reachable again{{with .status_code}} (status code {{.}}){{end}}.`},
+		{Locale: "zh-CN", Format: "plain",
+			Title: "站点恢复：{{.domain}}",
+			Body:  `✅ 站点 {{.domain}} 已恢复访问{{with .status_code}}，状态码 {{.}}{{end}}。`},
+	},
+	"SSL_CRITICAL": {
+		{Locale: "en", Format: "plain",
+			Title: "SSL certificate of {{.domain}} expires soon",
+			Body:  `🔒 The SSL certificate of {{.domain}} expires in {{humanize (until .ssl_expiry)}} ({{date "2006-01-02" .ssl_expiry}}).`},
+		{Locale: "zh-CN", Format: "plain",
+			Title: "SSL 证书即将过期：{{.domain}}",
+			Body:  `🔒 域名 {{.domain}} 的 SSL 证书将在 {{humanize (until .ssl_expiry)}}后过期（{{date "2006-01-02" .ssl_expiry}}）。`},
+	},
+	"SSL_RENEWED": {
+		{Locale: "en", Format: "plain",
+			Title: "SSL certificate of {{.domain}} renewed",
+			Body:  `🔓 The SSL certificate of {{.domain}} was renewed and is valid until {{date "2006-01-02" .ssl_expiry}}.`},
+		{Locale: "zh-CN", Format: "plain",
+			Title: "SSL 证书已更新：{{.domain}}",
+			Body:  `🔓 域名 {{.domain}} 的 SSL 证书已更新，有效期至 {{date "2006-01-02" .ssl_expiry}}。`},
+	},
+	"CONTENT_CHANGED": {
+		{Locale: "en", Format: "plain",
+			Title: "Content of {{.domain}} changed",
+			Body:  `⚠️ {{.change_percent}}% of the content of {{.domain}} changed since the baseline.`},
+		{Locale: "zh-CN", Format: "plain",
+			Title: "页面内容变更：{{.domain}}",
+			Body:  `⚠️ 站点 {{.domain}} 的内容与基线相比变化了 {{.change_percent}}%。`},
+	},
+	"SYNTHETIC_FAILED": {
+		{Locale: "en", Format: "plain",
+			Title: "Synthetic check {{.check}} failed",
+			Body:  `🧪 Synthetic check {{.check}} failed{{with .step}} at step "{{.}}"{{end}}{{with .error}}: {{.}}{{end}}`},
+		{Locale: "zh-CN", Format: "plain",
+			Title: "拨测失败：{{.check}}",
+			Body:  `🧪 拨测 {{.check}} 失败{{with .step}}，步骤“{{.}}”{{end}}{{with .error}}：{{.}}{{end}}`},
+	},
+	"SYNTHETIC_RECOVERED": {
+		{Locale: "en", Format: "plain",
+			Title: "Synthetic check {{.check}} recovered",
+			Body:  `✅ Synthetic check {{.check}} is passing again.`},
+		{Locale: "zh-CN", Format: "plain",
+			Title: "拨测恢复：{{.check}}",
+			Body:  `✅ 拨测 {{.check}} 已恢复正常。`},
+	},
+	"DIGEST": {
+		{Locale: "en", Format: "plain",
+			Title: `{{.name}} - {{date "2006-01-02" .time}}`,
+			Body:  digestBody},
+		{Locale: "zh-CN", Format: "plain",
+			Title: `{{.name}} - {{date "2006-01-02" .time}}`,
+			Body: `{{- if .show_certificates}}未来 {{.horizon}} 天内到期的证书：
+{{range .certificates}}• {{.Domain}}：{{date "2006-01-02" .Expiry}}（{{if .Expired}}已过期{{humanize (since .Expiry)}}{{else}}{{humanize (until .Expiry)}}后到期{{end}}）{{with .Detail}}，{{.}}{{end}}
+{{else}}• 无
+{{end}}
+{{end -}}
+{{- if .show_registrations}}未来 {{.horizon}} 天内到期的域名注册：
+{{range .registrations}}• {{.Domain}}：{{date "2006-01-02" .Expiry}}（{{if .Expired}}已过期{{humanize (since .Expiry)}}{{else}}{{humanize (until .Expiry)}}后到期{{end}}）{{with .Detail}}，{{.}}{{end}}
+{{else}}• 无
+{{end}}
+{{end -}}
+{{- if .show_incidents}}自 {{date "2006-01-02" .since}} 以来的故障：
+{{range .incidents}}• {{.Title}} [{{.Status}}]，{{date "2006-01-02 15:04" .StartedAt}}，{{if .ResolvedAt}}持续{{humanize .Duration}}{{else}}仍在进行{{end}}
+{{else}}• 无
+{{end}}{{end -}}`},
+	},
+	"LATENCY_ANOMALY": {
+		{Locale: "en", Format: "plain",
+			Title: "Latency anomaly on {{.domain}}",
+			Body:  `🐢 Latency of {{.domain}} deviates from its baseline{{with .details}}: {{.}}{{end}}`},
+		{Locale: "zh-CN", Format: "plain",
+			Title: "响应延迟异常：{{.domain}}",
+			Body:  `🐢 站点 {{.domain}} 的响应延迟偏离基线{{with .details}}：{{.}}{{end}}`},
+	},
+}
+
+// seedMessageTemplates seeds default message templates if they don't exist, and
+// adds the default variants to seeded templates nobody has edited.
 func seedMessageTemplates(db *gorm.DB) {
-	// Seed SiteDown template
-	var siteDownTemplate MessageTemplate
-	if err := db.Where("name = ? OR event_name = ?", "SiteDown", "SITE_DOWN").First(&siteDownTemplate).Error; err != nil {
-		// Template doesn't exist, create it
-		siteDownTemplate = MessageTemplate{
-			Name:          "SiteDown",
-			EventName:     "SITE_DOWN",
-			TemplateText:  "🚨 告警：站点 {{domain}} 无法访问！状态码：{{status}}",
-			TitleTemplate: "Site Down Alert",
-			BodyTemplate:  "Site {{domain}} is down. Status code: {{status_code}}",
+	for _, def := range defaultMessageTemplates {
+		var existing MessageTemplate
+		if err := db.Preload("Variants").Where("name = ? OR event_name = ?", def.Name, def.EventName).First(&existing).Error; err == nil {
+			if len(existing.Variants) == 0 && isSeededTemplate(existing, def) {
+				seedTemplateVariants(db, existing)
+			}
+			continue
 		}
-		if err := db.Create(&siteDownTemplate).Error; err != nil {
-			log.Printf("warning: failed to create SiteDown template: %v", err)
+
+		// Template doesn't exist, create it
+		tmpl := def
+		if err := db.Create(&tmpl).Error; err != nil {
+			log.Printf("warning: failed to create %s template: %v", def.Name, err)
 		} else {
-			log.Println("SiteDown message template seeded")
+			log.Printf("%s message template seeded", def.Name)
+			seedTemplateVariants(db, tmpl)
 		}
 	}
+}
 
-	// Seed SSLExpired template
-	var sslExpiredTemplate MessageTemplate
-	if err := db.Where("name = ? OR event_name = ?", "SSLExpired", "SSL_CRITICAL").First(&sslExpiredTemplate).Error; err != nil {
-		// Template doesn't exist, create it
-		sslExpiredTemplate = MessageTemplate{
-			Name:          "SSLExpired",
-			EventName:     "SSL_CRITICAL",
-			TemplateText:  "🔒 证书预警：域名 {{domain}} 的 SSL 证书将在 {{days}} 天后过期。",
-			TitleTemplate: "SSL Certificate Warning",
-			BodyTemplate:  "SSL certificate for {{domain}} will expire in {{days_remaining}} days.",
-		}
-		if err := db.Create(&sslExpiredTemplate).Error; err != nil {
-			log.Printf("warning: failed to create SSLExpired template: %v", err)
-		} else {
-			log.Println("SSLExpired message template seeded")
+// isSeededTemplate reports whether a template still has the seeded texts, so that
+// variants, which take precedence, don't hide edits.
+func isSeededTemplate(t MessageTemplate, def MessageTemplate) bool {
+	if t.EventName != def.EventName || t.TitleTemplate != def.TitleTemplate || t.BodyTemplate != def.BodyTemplate || t.HTMLTemplate != "" {
+		return false
+	}
+	return t.TemplateText == def.TemplateText || t.TemplateText == seededTemplateText[def.EventName]
+}
+
+func seedTemplateVariants(db *gorm.DB, t MessageTemplate) {
+	for _, def := range defaultTemplateVariants[t.EventName] {
+		v := def
+		v.TemplateID = t.ID
+		if err := db.Create(&v).Error; err != nil {
+			log.Printf("warning: failed to create %s/%s variant of %s: %v", v.Locale, v.Format, t.Name, err)
 		}
 	}
 }
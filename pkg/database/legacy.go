@@ -0,0 +1,355 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// The channel configs below were replaced by Channel. They're no longer migrated:
+// Init moves their rows into channels once, see migrateLegacyChannels. The legacy
+// notification endpoints still use them as response shapes.
+
+// NotificationConfig stores webhook configuration for notification platforms
+type NotificationConfig struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	WebhookURL string    `json:"webhook_url" gorm:"column:webhook_url"`
+	SecretKey  string    `json:"secret_key" gorm:"column:secret_key"`
+	Platform   string    `json:"platform"` // e.g., DingTalk, Feishu, Slack
+	Locale     string    `json:"locale"`   // Language of the messages, e.g. en or zh-CN; empty uses the server default
+	IsActive   bool      `json:"is_active" gorm:"default:true"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// NotifyConfig stores Telegram bot configuration for notifications
+type NotifyConfig struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	TGToken   string    `json:"tg_token" gorm:"column:tg_token"`     // Telegram bot token
+	TGChatID  string    `json:"tg_chat_id" gorm:"column:tg_chat_id"` // Telegram chat ID
+	Locale    string    `json:"locale"`                              // Language of the messages; empty uses the server default
+	BotMode   string    `json:"bot_mode"`                            // How the bot receives commands: polling, webhook or empty for none
+	BotSecret string    `json:"-"`                                   // Secret token Telegram sends with webhook updates
+	BotOffset int64     `json:"-"`                                   // Next update ID to fetch when polling
+	IsActive  bool      `json:"is_active" gorm:"default:true"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// EmailConfig stores an SMTP server and the recipients of email notifications
+type EmailConfig struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Name       string    `json:"name"`
+	Host       string    `json:"host"`
+	Port       int       `json:"port" gorm:"default:587"`
+	Security   string    `json:"security"` // none, starttls or tls; empty uses STARTTLS when the server offers it
+	Username   string    `json:"username"`
+	Password   string    `json:"-"`
+	From       string    `json:"from"`
+	Recipients string    `json:"recipients" gorm:"type:text"` // Comma-separated email addresses
+	Locale     string    `json:"locale"`                      // Language of the messages; empty uses the server default
+	IsActive   bool      `json:"is_active" gorm:"default:true"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// TelegramConfig stores a Telegram bot. It was never read by the notifiers, which
+// used NotifyConfig.
+type TelegramConfig struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	BotToken  string    `gorm:"uniqueIndex" json:"bot_token"`
+	ChatID    string    `json:"chat_id"`
+	Enabled   bool      `gorm:"default:true" json:"enabled"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// legacyTablePrefix is prepended to the names of migrated legacy tables, which are
+// kept as a backup.
+const legacyTablePrefix = "legacy_"
+
+// migrateLegacyChannels moves the rows of the legacy channel tables into channels,
+// rewrites the channel refs stored by routing rules, digests, escalation steps and
+// pending notifications, and renames the legacy tables so it runs only once.
+// TelegramConfig rows duplicating a NotifyConfig are dropped.
+func migrateLegacyChannels(db *gorm.DB) error {
+	m := db.Migrator()
+	legacy := []interface{}{&NotificationConfig{}, &EmailConfig{}, &NotifyConfig{}, &TelegramConfig{}}
+	var found []interface{}
+	for _, model := range legacy {
+		if m.HasTable(model) {
+			found = append(found, model)
+		}
+	}
+	if len(found) == 0 {
+		return nil
+	}
+	// Databases of older versions may lack recent columns
+	if err := db.AutoMigrate(found...); err != nil {
+		return fmt.Errorf("failed to migrate legacy channel tables: %w", err)
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		refs := make(map[string]string)
+		migrated := 0
+		add := func(oldRef string, ch Channel, settings map[string]interface{}) error {
+			encoded, err := json.Marshal(settings)
+			if err != nil {
+				return err
+			}
+			ch.Settings = string(encoded)
+			if err := tx.Create(&ch).Error; err != nil {
+				return fmt.Errorf("failed to migrate channel %s: %w", oldRef, err)
+			}
+			migrated++
+			if oldRef != "" {
+				refs[oldRef] = ch.Type + ":" + strconv.FormatUint(uint64(ch.ID), 10)
+			}
+			return nil
+		}
+		base := func(typ, name, locale string, active bool, created time.Time) Channel {
+			return Channel{Type: typ, Name: name, Locale: locale, IsActive: active, CreatedAt: created}
+		}
+
+		if m.HasTable(&NotificationConfig{}) {
+			var configs []NotificationConfig
+			if err := tx.Order("id").Find(&configs).Error; err != nil {
+				return err
+			}
+			for _, c := range configs {
+				err := add("webhook:"+strconv.FormatUint(uint64(c.ID), 10),
+					base("webhook", "", c.Locale, c.IsActive, c.CreatedAt),
+					map[string]interface{}{"platform": c.Platform, "url": c.WebhookURL, "secret": c.SecretKey})
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if m.HasTable(&EmailConfig{}) {
+			var configs []EmailConfig
+			if err := tx.Order("id").Find(&configs).Error; err != nil {
+				return err
+			}
+			for _, c := range configs {
+				err := add("email:"+strconv.FormatUint(uint64(c.ID), 10),
+					base("email", c.Name, c.Locale, c.IsActive, c.CreatedAt),
+					map[string]interface{}{"host": c.Host, "port": c.Port, "security": c.Security, "username": c.Username,
+						"password": c.Password, "from": c.From, "recipients": c.Recipients})
+				if err != nil {
+					return err
+				}
+			}
+		}
+		bots := make(map[string]bool)
+		if m.HasTable(&NotifyConfig{}) {
+			var configs []NotifyConfig
+			if err := tx.Order("id").Find(&configs).Error; err != nil {
+				return err
+			}
+			for _, c := range configs {
+				bots[c.TGToken+" "+c.TGChatID] = true
+				ch := base("telegram", "", c.Locale, c.IsActive, c.CreatedAt)
+				ch.Cursor = c.BotOffset
+				err := add("telegram:"+strconv.FormatUint(uint64(c.ID), 10), ch,
+					map[string]interface{}{"token": c.TGToken, "chat_id": c.TGChatID, "bot_mode": c.BotMode, "bot_secret": c.BotSecret})
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if m.HasTable(&TelegramConfig{}) {
+			var configs []TelegramConfig
+			if err := tx.Order("id").Find(&configs).Error; err != nil {
+				return err
+			}
+			for _, c := range configs {
+				if c.BotToken == "" || bots[c.BotToken+" "+c.ChatID] {
+					continue
+				}
+				bots[c.BotToken+" "+c.ChatID] = true
+				err := add("", base("telegram", "", "", c.Enabled, c.CreatedAt),
+					map[string]interface{}{"token": c.BotToken, "chat_id": c.ChatID})
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		rewrites := []struct {
+			table, column string
+			list          bool
+		}{
+			{"routing_rules", "channels", true},
+			{"digests", "channels", true},
+			{"escalation_steps", "targets", true},
+			{"outbox_messages", "channel", false},
+			{"notification_groups", "channel", false},
+		}
+		for _, r := range rewrites {
+			if err := rewriteChannelRefs(tx, r.table, r.column, r.list, refs); err != nil {
+				return fmt.Errorf("failed to rewrite channel refs of %s: %w", r.table, err)
+			}
+		}
+
+		for _, model := range found {
+			stmt := &gorm.Statement{DB: tx}
+			if err := stmt.Parse(model); err != nil {
+				return err
+			}
+			if err := tx.Migrator().RenameTable(stmt.Table, legacyTablePrefix+stmt.Table); err != nil {
+				return fmt.Errorf("failed to rename table %s: %w", stmt.Table, err)
+			}
+		}
+		log.Printf("Migrated %d legacy notification configs to channels", migrated)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to migrate legacy channels: %w", err)
+	}
+	return nil
+}
+
+// rewriteChannelRefs replaces the legacy channel refs of a column, which holds either
+// one ref or a comma-separated list. Values are first moved out of the way, so that
+// unique indexes including the column don't conflict while refs are swapped.
+func rewriteChannelRefs(tx *gorm.DB, table, column string, list bool, refs map[string]string) error {
+	var rows []struct {
+		ID    uint
+		Value string
+	}
+	if err := tx.Table(table).Select("id, " + column + " AS value").Where(column + " <> ''").Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	changed := make(map[uint]string)
+	for _, row := range rows {
+		items := []string{row.Value}
+		if list {
+			items = strings.Split(row.Value, ",")
+		}
+		rewritten := false
+		for i, item := range items {
+			if ref, ok := refs[strings.ToLower(strings.TrimSpace(item))]; ok {
+				items[i], rewritten = ref, true
+			}
+		}
+		if rewritten {
+			changed[row.ID] = strings.Join(items, ",")
+		}
+	}
+
+	for id := range changed {
+		if err := tx.Table(table).Where("id = ?", id).Update(column, fmt.Sprintf("migrating:%d", id)).Error; err != nil {
+			return err
+		}
+	}
+	for id, value := range changed {
+		if err := tx.Table(table).Where("id = ?", id).Update(column, value).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// legacySecrets are the secret columns of the legacy channel tables, and the type and
+// settings of the channels holding them after the migration.
+var legacySecrets = []struct {
+	table       string
+	channelType string
+	columns     map[string]string // Column to setting key
+}{
+	{"notification_configs", "webhook", map[string]string{"secret_key": "secret"}},
+	{"email_configs", "email", map[string]string{"password": "password"}},
+	{"notify_configs", "telegram", map[string]string{"tg_token": "token", "bot_secret": "bot_secret"}},
+	{"telegram_configs", "telegram", map[string]string{"bot_token": "token"}},
+}
+
+// ScrubLegacySecrets clears the secrets kept in plaintext by the backups of the
+// legacy channel tables once the migrated channels hold them encrypted. sealed
+// returns the secret settings a channel stores encrypted, decrypted. Secrets no
+// channel of their type holds are kept and reported in the error. It returns the
+// number of secrets cleared; once all are, it does nothing.
+func ScrubLegacySecrets(sealed func(Channel) (map[string]string, error)) (int64, error) {
+	if DB == nil {
+		return 0, ErrDatabaseNotInitialized
+	}
+
+	var cleared int64
+	var errs []error
+	for _, l := range legacySecrets {
+		table := legacyTablePrefix + l.table
+		if !DB.Migrator().HasTable(table) {
+			continue
+		}
+
+		var held map[string]map[string]bool // Setting key to the values channels hold
+		for column, key := range l.columns {
+			var rows []struct {
+				ID    uint
+				Value string
+			}
+			if err := DB.Table(table).Select("id, " + column + " AS value").Where(column + " <> ''").Scan(&rows).Error; err != nil {
+				return cleared, fmt.Errorf("failed to read %s.%s: %w", table, column, err)
+			}
+			if len(rows) == 0 {
+				continue
+			}
+			if held == nil {
+				var err error
+				if held, err = sealedSettings(l.channelType, sealed); err != nil {
+					return cleared, err
+				}
+			}
+
+			var verified []uint
+			for _, row := range rows {
+				if held[key][row.Value] {
+					verified = append(verified, row.ID)
+				}
+			}
+			if len(verified) < len(rows) {
+				errs = append(errs, fmt.Errorf("kept %d value(s) of %s.%s that no %s channel holds encrypted", len(rows)-len(verified), table, column, l.channelType))
+			}
+			if len(verified) == 0 {
+				continue
+			}
+			// NULL rather than empty, since bot tokens are unique
+			res := DB.Table(table).Where("id IN ?", verified).Update(column, gorm.Expr("NULL"))
+			if res.Error != nil {
+				return cleared, fmt.Errorf("failed to clear %s.%s: %w", table, column, res.Error)
+			}
+			cleared += res.RowsAffected
+		}
+	}
+	return cleared, errors.Join(errs...)
+}
+
+// sealedSettings returns the secret settings the channels of a type hold encrypted.
+func sealedSettings(typ string, sealed func(Channel) (map[string]string, error)) (map[string]map[string]bool, error) {
+	var channels []Channel
+	if err := DB.Where("type = ?", typ).Find(&channels).Error; err != nil {
+		return nil, fmt.Errorf("failed to load %s channels: %w", typ, err)
+	}
+	held := make(map[string]map[string]bool)
+	for _, ch := range channels {
+		values, err := sealed(ch)
+		if err != nil {
+			// The secrets of the channel count as missing
+			log.Printf("Error reading the secrets of channel %d: %v", ch.ID, err)
+			continue
+		}
+		for key, v := range values {
+			if held[key] == nil {
+				held[key] = make(map[string]bool)
+			}
+			held[key][v] = true
+		}
+	}
+	return held, nil
+}
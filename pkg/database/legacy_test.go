@@ -0,0 +1,176 @@
+package database_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"github.com/harveywai/zenstack/pkg/notify"
+	"github.com/harveywai/zenstack/pkg/secrets"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// seedLegacy creates the database of a version with the legacy channel tables.
+func seedLegacy(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("zenstack.db"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.AutoMigrate(&database.NotificationConfig{}, &database.EmailConfig{}, &database.NotifyConfig{}, &database.TelegramConfig{},
+		&database.RoutingRule{}, &database.Digest{}, &database.EscalationStep{}, &database.OutboxMessage{}, &database.NotificationGroup{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	rows := []interface{}{
+		// Webhooks 2 and 7 become webhook:1 and webhook:2, so webhook:2 changes meaning
+		&database.NotificationConfig{ID: 2, Platform: "slack", WebhookURL: "https://hooks.slack.com/services/A", IsActive: true, CreatedAt: created},
+		&database.NotificationConfig{ID: 7, Platform: "dingtalk", WebhookURL: "https://oapi.dingtalk.com/robot/send?access_token=B", SecretKey: "SEC-B", Locale: "zh-CN", IsActive: true},
+		&database.EmailConfig{ID: 1, Name: "ops", Host: "smtp.example.com", Port: 465, Security: "tls", Username: "alerts", Password: "smtp-pass", From: "alerts@example.com", Recipients: "ops@example.com", IsActive: true},
+		&database.NotifyConfig{ID: 1, TGToken: "111:AAA", TGChatID: "10", BotMode: "webhook", BotSecret: "bot-secret", BotOffset: 42, IsActive: true},
+		// The same bot as the NotifyConfig, and another one
+		&database.TelegramConfig{ID: 1, BotToken: "111:AAA", ChatID: "10", Enabled: true},
+		&database.TelegramConfig{ID: 2, BotToken: "222:BBB", ChatID: "20", Enabled: false},
+
+		&database.RoutingRule{ID: 1, Name: "all", Channels: "webhook:7,email:1, telegram:1,webhook:9", IsActive: true},
+		&database.Digest{ID: 1, Name: "weekly", Channels: "email:1,webhook:2", IsActive: true},
+		&database.EscalationStep{ID: 1, PolicyID: 1, Targets: "user:1,schedule:2,telegram:1"},
+		&database.OutboxMessage{ID: 1, Channel: "webhook:7", Status: "pending"},
+		// Rewriting these one by one would break the unique index of groups
+		&database.NotificationGroup{ID: 1, Channel: "webhook:2", Event: "SITE_DOWN", GroupKey: "k"},
+		&database.NotificationGroup{ID: 2, Channel: "webhook:7", Event: "SITE_DOWN", GroupKey: "k"},
+	}
+	for _, row := range rows {
+		if err := db.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Create skips false over the column default
+	db.Model(&database.TelegramConfig{}).Where("id = 2").Update("enabled", false)
+	sqlDB, _ := db.DB()
+	sqlDB.Close()
+}
+
+func TestMigrateLegacyChannels(t *testing.T) {
+	t.Chdir(t.TempDir())
+	key, err := secrets.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ZENSTACK_MASTER_KEY", key)
+	if err := secrets.Load(); err != nil {
+		t.Fatal(err)
+	}
+	seedLegacy(t)
+
+	if err := database.Init(); err != nil {
+		t.Fatal(err)
+	}
+	db := database.DB
+
+	var channels []database.Channel
+	db.Order("id").Find(&channels)
+	want := []struct {
+		ref, name, locale string
+		active            bool
+		settings          notify.Settings
+	}{
+		{"webhook:1", "", "", true, &notify.WebhookSettings{Platform: "slack", URL: "https://hooks.slack.com/services/A"}},
+		{"webhook:2", "", "zh-CN", true, &notify.WebhookSettings{Platform: "dingtalk", URL: "https://oapi.dingtalk.com/robot/send?access_token=B", Secret: "SEC-B"}},
+		{"email:3", "ops", "", true, &notify.EmailSettings{Host: "smtp.example.com", Port: 465, Security: "tls", Username: "alerts", Password: "smtp-pass", From: "alerts@example.com", Recipients: "ops@example.com"}},
+		{"telegram:4", "", "", true, &notify.TelegramSettings{Token: "111:AAA", ChatID: "10", BotMode: "webhook", BotSecret: "bot-secret"}},
+		{"telegram:5", "", "", false, &notify.TelegramSettings{Token: "222:BBB", ChatID: "20"}},
+	}
+	if len(channels) != len(want) {
+		t.Fatalf("migrated %d channels, want %d", len(channels), len(want))
+	}
+	for i, w := range want {
+		ch := channels[i]
+		if ref := notify.ChannelRef(ch.Type, ch.ID); ref != w.ref || ch.Name != w.name || ch.Locale != w.locale || ch.IsActive != w.active {
+			t.Errorf("channel %d: %s %q locale %q active %v", i, ref, ch.Name, ch.Locale, ch.IsActive)
+		}
+		s, err := notify.DecodeSettings(ch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(s, w.settings) {
+			t.Errorf("%s settings:\n got %+v\nwant %+v", w.ref, s, w.settings)
+		}
+	}
+	if channels[3].Cursor != 42 {
+		t.Errorf("telegram cursor = %d, want the bot offset 42", channels[3].Cursor)
+	}
+	if !channels[0].CreatedAt.Equal(time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("created at %s, want the time of the legacy config", channels[0].CreatedAt)
+	}
+
+	refs := []struct {
+		table, column, want string
+	}{
+		{"routing_rules", "channels", "webhook:2,email:3,telegram:4,webhook:9"},
+		{"digests", "channels", "email:3,webhook:1"},
+		{"escalation_steps", "targets", "user:1,schedule:2,telegram:4"},
+		{"outbox_messages", "channel", "webhook:2"},
+	}
+	for _, r := range refs {
+		var got string
+		db.Table(r.table).Select(r.column).Where("id = 1").Scan(&got)
+		if got != r.want {
+			t.Errorf("%s.%s = %q, want %q", r.table, r.column, got, r.want)
+		}
+	}
+	var groups []string
+	db.Table("notification_groups").Order("id").Pluck("channel", &groups)
+	if fmt.Sprint(groups) != "[webhook:1 webhook:2]" {
+		t.Errorf("notification groups %v, want [webhook:1 webhook:2]", groups)
+	}
+
+	m := db.Migrator()
+	for _, table := range []string{"notification_configs", "email_configs", "notify_configs", "telegram_configs"} {
+		if m.HasTable(table) || !m.HasTable("legacy_"+table) {
+			t.Errorf("table %s was not renamed", table)
+		}
+	}
+
+	// The backups keep their secrets until the channels hold them encrypted
+	n, err := database.ScrubLegacySecrets(notify.SealedSecrets)
+	if n != 0 || err == nil {
+		t.Errorf("scrubbed %d secrets of plaintext channels, error %v", n, err)
+	}
+	if _, err := notify.RewrapChannelSecrets(); err != nil {
+		t.Fatal(err)
+	}
+	n, err = database.ScrubLegacySecrets(notify.SealedSecrets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// secret_key of webhook 7, the password, the token and bot secret of the
+	// NotifyConfig and both TelegramConfig tokens
+	if n != 6 {
+		t.Errorf("scrubbed %d secrets, want 6", n)
+	}
+	for table, column := range map[string]string{
+		"legacy_notification_configs": "secret_key", "legacy_email_configs": "password",
+		"legacy_notify_configs": "tg_token", "legacy_telegram_configs": "bot_token",
+	} {
+		var left int64
+		db.Table(table).Where(column + " <> ''").Count(&left)
+		if left != 0 {
+			t.Errorf("%s.%s still holds %d secrets", table, column, left)
+		}
+	}
+	var url string
+	db.Table("legacy_notification_configs").Select("webhook_url").Where("id = 2").Scan(&url)
+	if url == "" {
+		t.Error("scrubbed a column that isn't secret")
+	}
+	if n, err := database.ScrubLegacySecrets(notify.SealedSecrets); n != 0 || err != nil {
+		t.Errorf("scrubbing again: %d, %v", n, err)
+	}
+}
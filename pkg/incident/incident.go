@@ -0,0 +1,149 @@
+package incident
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"gorm.io/gorm"
+)
+
+// Incident lifecycle states, following the common status page vocabulary.
+const (
+	StatusInvestigating = "investigating"
+	StatusIdentified    = "identified"
+	StatusMonitoring    = "monitoring"
+	StatusResolved      = "resolved"
+)
+
+// Impact levels for incidents.
+const (
+	ImpactNone     = "none"
+	ImpactMinor    = "minor"
+	ImpactMajor    = "major"
+	ImpactCritical = "critical"
+)
+
+// ValidStatus reports whether s is a known incident status.
+func ValidStatus(s string) bool {
+	switch s {
+	case StatusInvestigating, StatusIdentified, StatusMonitoring, StatusResolved:
+		return true
+	}
+	return false
+}
+
+// ValidImpact reports whether s is a known impact level.
+func ValidImpact(s string) bool {
+	switch s {
+	case ImpactNone, ImpactMinor, ImpactMajor, ImpactCritical:
+		return true
+	}
+	return false
+}
+
+// FindOpen returns the unresolved automatic incident for a domain, if any.
+func FindOpen(domainID uint) (*database.Incident, error) {
+	if database.DB == nil {
+		return nil, database.ErrDatabaseNotInitialized
+	}
+
+	var inc database.Incident
+	err := database.DB.
+		Where("domain_id = ? AND status <> ?", domainID, StatusResolved).
+		Order("started_at desc").
+		First(&inc).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &inc, nil
+}
+
+// OpenForDomain opens an automatic incident for a domain that went down.
+// If an unresolved incident already exists for the domain it is returned unchanged.
+// Automatic incidents are private until an admin publishes them.
+func OpenForDomain(domain database.MonitoredDomain, reason string) (*database.Incident, error) {
+	existing, err := FindOpen(domain.ID)
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return existing, nil
+	}
+
+	now := time.Now()
+	inc := database.Incident{
+		DomainID:  domain.ID,
+		Title:     fmt.Sprintf("%s is unreachable", domain.DomainName),
+		Status:    StatusInvestigating,
+		Impact:    ImpactMajor,
+		Source:    "auto",
+		IsPublic:  false,
+		StartedAt: now,
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&inc).Error; err != nil {
+			return err
+		}
+		update := database.IncidentUpdate{
+			IncidentID: inc.ID,
+			Status:     StatusInvestigating,
+			Message:    reason,
+			IsPublic:   false,
+		}
+		return tx.Create(&update).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &inc, nil
+}
+
+// ResolveForDomain resolves the open automatic incident for a domain, if any.
+func ResolveForDomain(domainID uint, message string) (*database.Incident, error) {
+	inc, err := FindOpen(domainID)
+	if err != nil || inc == nil {
+		return inc, err
+	}
+	if err := AddUpdate(inc, StatusResolved, message, inc.IsPublic); err != nil {
+		return nil, err
+	}
+	return inc, nil
+}
+
+// AddUpdate appends a timeline entry to an incident and moves it to the given status.
+// Resolving an incident stamps ResolvedAt.
+func AddUpdate(inc *database.Incident, status, message string, public bool) error {
+	if database.DB == nil {
+		return database.ErrDatabaseNotInitialized
+	}
+	if !ValidStatus(status) {
+		return fmt.Errorf("invalid incident status: %s", status)
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		update := database.IncidentUpdate{
+			IncidentID: inc.ID,
+			Status:     status,
+			Message:    message,
+			IsPublic:   public,
+		}
+		if err := tx.Create(&update).Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{"status": status}
+		if status == StatusResolved {
+			now := time.Now()
+			updates["resolved_at"] = now
+			inc.ResolvedAt = &now
+		}
+		inc.Status = status
+		return tx.Model(inc).Updates(updates).Error
+	})
+}
@@ -0,0 +1,56 @@
+package incident
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openTestDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&database.Incident{}, &database.IncidentUpdate{}); err != nil {
+		t.Fatal(err)
+	}
+	prev := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = prev })
+}
+
+func TestPrivateUpdatesStayPrivate(t *testing.T) {
+	openTestDB(t)
+
+	domain := database.MonitoredDomain{DomainName: "example.com"}
+	domain.ID = 1
+	inc, err := OpenForDomain(domain, "connection refused")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := AddUpdate(inc, StatusIdentified, "internal note", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := AddUpdate(inc, StatusMonitoring, "fix deployed", true); err != nil {
+		t.Fatal(err)
+	}
+
+	var updates []database.IncidentUpdate
+	if err := database.DB.Where("incident_id = ?", inc.ID).Order("id").Find(&updates).Error; err != nil {
+		t.Fatal(err)
+	}
+	want := []bool{false, false, true}
+	if len(updates) != len(want) {
+		t.Fatalf("got %d updates, want %d", len(updates), len(want))
+	}
+	for i, u := range updates {
+		if u.IsPublic != want[i] {
+			t.Errorf("update %d (%q): is_public = %v, want %v", i, u.Message, u.IsPublic, want[i])
+		}
+	}
+}
@@ -89,7 +89,7 @@ func GetResourceStatus(projectName string) (ResourceStatus, error) {
 		return ResourceStatus{State: "Unknown", Color: "grey"}, nil
 	}
 
-	cfg, err := loadKubeConfig()
+	cfg, err := LoadKubeConfig()
 	if err != nil {
 		// Do not fail hard if the cluster is not reachable.
 		return ResourceStatus{State: "Unknown", Color: "grey"}, nil
@@ -156,8 +156,8 @@ func GetResourceStatus(projectName string) (ResourceStatus, error) {
 	}
 }
 
-// loadKubeConfig attempts to load in-cluster configuration, falling back to KUBECONFIG or default kubeconfig path.
-func loadKubeConfig() (*rest.Config, error) {
+// LoadKubeConfig attempts to load in-cluster configuration, falling back to KUBECONFIG or default kubeconfig path.
+func LoadKubeConfig() (*rest.Config, error) {
 	// Try in-cluster config first.
 	if cfg, err := rest.InClusterConfig(); err == nil {
 		return cfg, nil
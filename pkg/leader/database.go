@@ -0,0 +1,164 @@
+package leader
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+// databaseElector holds a LeaderLease row in the shared database. Replicas compare
+// lease expiry against their own clocks, so they should be NTP-synchronized.
+type databaseElector struct {
+	state
+	cfg Config
+	now func() time.Time // Clock, overridable in tests
+}
+
+func newDatabaseElector(cfg Config) *databaseElector {
+	return &databaseElector{cfg: cfg, now: time.Now}
+}
+
+// Identity implements Elector.
+func (e *databaseElector) Identity() string { return e.cfg.Identity }
+
+// Run implements Elector.
+func (e *databaseElector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	log.Printf("Leader election using database lease %q as %s", e.cfg.LeaseName, e.cfg.Identity)
+
+	for {
+		if !e.acquire(ctx) {
+			return
+		}
+
+		e.set(e.cfg.Identity, true)
+		leadCtx, cancel := context.WithCancel(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			lead(leadCtx)
+		}()
+
+		e.renew(leadCtx, done)
+		cancel()
+		<-done
+		e.set(e.cfg.Identity, false)
+
+		if ctx.Err() != nil {
+			e.release()
+			return
+		}
+	}
+}
+
+// acquire retries until the lease is taken or ctx is cancelled.
+func (e *databaseElector) acquire(ctx context.Context) bool {
+	ticker := time.NewTicker(e.cfg.RetryPeriod)
+	defer ticker.Stop()
+	for {
+		if ok, err := e.tryAcquireOrRenew(e.now()); err != nil {
+			log.Printf("Error acquiring leader lease %q: %v", e.cfg.LeaseName, err)
+		} else if ok {
+			return true
+		}
+
+		select {
+		case <-ctx.Done():
+			return false
+		case <-ticker.C:
+		}
+	}
+}
+
+// renew keeps the lease until it is taken over, cannot be renewed within the renew
+// deadline, the lead function returns or ctx is cancelled.
+func (e *databaseElector) renew(ctx context.Context, done <-chan struct{}) {
+	ticker := time.NewTicker(e.cfg.RetryPeriod)
+	defer ticker.Stop()
+	lastRenew := e.now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		now := e.now()
+		ok, err := e.tryAcquireOrRenew(now)
+		switch {
+		case err != nil:
+			log.Printf("Error renewing leader lease %q: %v", e.cfg.LeaseName, err)
+			if now.Sub(lastRenew) > e.cfg.RenewDeadline {
+				return
+			}
+		case !ok:
+			// Another replica took over, e.g. after this one stalled past the lease duration
+			return
+		default:
+			lastRenew = now
+		}
+	}
+}
+
+// db returns a session that doesn't log every lease query.
+func (e *databaseElector) db() *gorm.DB {
+	return database.DB.Session(&gorm.Session{Logger: database.DB.Logger.LogMode(logger.Warn)})
+}
+
+// tryAcquireOrRenew extends the lease if this replica holds it or it has expired,
+// and creates it if it doesn't exist yet. It reports whether this replica holds it.
+func (e *databaseElector) tryAcquireOrRenew(now time.Time) (bool, error) {
+	if database.DB == nil {
+		return false, database.ErrDatabaseNotInitialized
+	}
+
+	id := e.cfg.Identity
+	expires := now.Add(e.cfg.LeaseDuration)
+
+	res := e.db().Model(&database.LeaderLease{}).
+		Where("name = ? AND (holder_identity = ? OR expires_at < ?)", e.cfg.LeaseName, id, now).
+		Updates(map[string]interface{}{
+			// SET expressions see the old row, so acquired_at only moves on takeover
+			"acquired_at":     gorm.Expr("CASE WHEN holder_identity = ? THEN acquired_at ELSE ? END", id, now),
+			"holder_identity": id,
+			"renewed_at":      now,
+			"expires_at":      expires,
+		})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	if res.RowsAffected > 0 {
+		return true, nil
+	}
+
+	res = e.db().Clauses(clause.OnConflict{DoNothing: true}).Create(&database.LeaderLease{
+		Name:           e.cfg.LeaseName,
+		HolderIdentity: id,
+		AcquiredAt:     now,
+		RenewedAt:      now,
+		ExpiresAt:      expires,
+	})
+	if res.Error != nil {
+		return false, res.Error
+	}
+	return res.RowsAffected > 0, nil
+}
+
+// release expires the lease on shutdown so another replica can take over immediately.
+func (e *databaseElector) release() {
+	if database.DB == nil {
+		return
+	}
+	err := e.db().Model(&database.LeaderLease{}).
+		Where("name = ? AND holder_identity = ?", e.cfg.LeaseName, e.cfg.Identity).
+		Update("expires_at", e.now()).Error
+	if err != nil {
+		log.Printf("Error releasing leader lease %q: %v", e.cfg.LeaseName, err)
+	}
+}
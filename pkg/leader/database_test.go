@@ -0,0 +1,165 @@
+package leader
+
+import (
+	"context"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// clock is a settable time source shared by the electors of a test.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Set(t time.Time) {
+	c.mu.Lock()
+	c.now = t
+	c.mu.Unlock()
+}
+
+func openTestDB(t *testing.T) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&database.LeaderLease{}); err != nil {
+		t.Fatal(err)
+	}
+	prev := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = prev })
+}
+
+func newTestElector(identity string, c *clock) *databaseElector {
+	e := newDatabaseElector(Config{
+		LeaseName:     "test",
+		Identity:      identity,
+		LeaseDuration: 15 * time.Second,
+		RenewDeadline: 10 * time.Second,
+		RetryPeriod:   time.Millisecond,
+	})
+	e.now = c.Now
+	return e
+}
+
+func loadLease(t *testing.T) database.LeaderLease {
+	t.Helper()
+	var lease database.LeaderLease
+	if err := database.DB.First(&lease, "name = ?", "test").Error; err != nil {
+		t.Fatal(err)
+	}
+	return lease
+}
+
+func TestDatabaseLease(t *testing.T) {
+	openTestDB(t)
+	c := &clock{}
+	a, b := newTestElector("a", c), newTestElector("b", c)
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	sec := func(n int) time.Time { return t0.Add(time.Duration(n) * time.Second) }
+
+	steps := []struct {
+		name     string
+		elector  *databaseElector
+		at       time.Time
+		ok       bool
+		holder   string
+		acquired time.Time
+		expires  time.Time
+	}{
+		{"first acquire creates the lease", a, sec(0), true, "a", sec(0), sec(15)},
+		{"refused while the lease is live", b, sec(5), false, "a", sec(0), sec(15)},
+		{"renewal by the holder", a, sec(10), true, "a", sec(0), sec(25)},
+		{"refused until the renewed lease expires", b, sec(24), false, "a", sec(0), sec(25)},
+		{"takeover after expiry", b, sec(26), true, "b", sec(26), sec(41)},
+		{"the previous holder can't renew", a, sec(27), false, "b", sec(26), sec(41)},
+		{"renewal by the new holder", b, sec(30), true, "b", sec(26), sec(45)},
+	}
+	for _, s := range steps {
+		ok, err := s.elector.tryAcquireOrRenew(s.at)
+		if err != nil {
+			t.Fatalf("%s: %v", s.name, err)
+		}
+		lease := loadLease(t)
+		if ok != s.ok || lease.HolderIdentity != s.holder || !lease.AcquiredAt.Equal(s.acquired) || !lease.ExpiresAt.Equal(s.expires) {
+			t.Errorf("%s: got %v, lease held by %s acquired %s expiring %s", s.name, ok, lease.HolderIdentity,
+				lease.AcquiredAt.Format(time.TimeOnly), lease.ExpiresAt.Format(time.TimeOnly))
+		}
+	}
+
+	// Only the holder releases the lease, which another replica can take right away
+	c.Set(sec(31))
+	a.release()
+	if lease := loadLease(t); !lease.ExpiresAt.Equal(sec(45)) {
+		t.Errorf("a replica not holding the lease released it: expires %s", lease.ExpiresAt.Format(time.TimeOnly))
+	}
+	b.release()
+	if lease := loadLease(t); lease.HolderIdentity != "b" || !lease.ExpiresAt.Equal(sec(31)) {
+		t.Errorf("released lease held by %s expiring %s", lease.HolderIdentity, lease.ExpiresAt.Format(time.TimeOnly))
+	}
+	if ok, err := a.tryAcquireOrRenew(sec(32)); !ok || err != nil {
+		t.Errorf("taking a released lease: %v, %v", ok, err)
+	}
+}
+
+func TestDatabaseAcquireAndRenew(t *testing.T) {
+	openTestDB(t)
+	c := &clock{now: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)}
+	a, b := newTestElector("a", c), newTestElector("b", c)
+
+	if !a.acquire(context.Background()) {
+		t.Fatal("acquire failed on a free lease")
+	}
+
+	// acquire retries while another replica holds the lease, until ctx is done
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if b.acquire(ctx) {
+		t.Fatal("acquired a live lease")
+	}
+
+	// The holder keeps renewing until the lead function returns
+	done := make(chan struct{})
+	renewed := make(chan struct{})
+	go func() {
+		a.renew(context.Background(), done)
+		close(renewed)
+	}()
+	c.Set(c.Now().Add(10 * time.Second))
+	for !loadLease(t).ExpiresAt.Equal(c.Now().Add(15 * time.Second)) {
+		time.Sleep(time.Millisecond)
+	}
+	close(done)
+	<-renewed
+
+	// and stops once another replica took the lease over while it stalled
+	c.Set(c.Now().Add(time.Minute))
+	if !b.acquire(context.Background()) {
+		t.Fatal("acquire failed on an expired lease")
+	}
+	renewed = make(chan struct{})
+	go func() {
+		a.renew(context.Background(), make(chan struct{}))
+		close(renewed)
+	}()
+	select {
+	case <-renewed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("renew kept running after the lease was taken over")
+	}
+}
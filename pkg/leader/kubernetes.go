@@ -0,0 +1,94 @@
+package leader
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/harveywai/zenstack/pkg/infra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	coordinationv1 "k8s.io/client-go/kubernetes/typed/coordination/v1"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+// serviceAccountNamespace holds the namespace of the pod when running in-cluster.
+const serviceAccountNamespace = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+
+// kubernetesElector holds a coordination.k8s.io Lease through client-go. The
+// service account needs get, create and update permissions on leases.
+type kubernetesElector struct {
+	state
+	cfg     Config
+	lock    resourcelock.Interface
+	running sync.Mutex // client-go doesn't wait for the lead callback before re-campaigning
+}
+
+func newKubernetesElector(cfg Config) (*kubernetesElector, error) {
+	restCfg, err := infra.LoadKubeConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubernetes config: %w", err)
+	}
+	client, err := coordinationv1.NewForConfig(restCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+
+	if cfg.Namespace == "" {
+		if data, err := os.ReadFile(serviceAccountNamespace); err == nil {
+			cfg.Namespace = strings.TrimSpace(string(data))
+		}
+	}
+	if cfg.Namespace == "" {
+		cfg.Namespace = "default"
+	}
+
+	return &kubernetesElector{
+		cfg: cfg,
+		lock: &resourcelock.LeaseLock{
+			LeaseMeta:  metav1.ObjectMeta{Name: cfg.LeaseName, Namespace: cfg.Namespace},
+			Client:     client,
+			LockConfig: resourcelock.ResourceLockConfig{Identity: cfg.Identity},
+		},
+	}, nil
+}
+
+// Identity implements Elector.
+func (e *kubernetesElector) Identity() string { return e.cfg.Identity }
+
+// Run implements Elector.
+func (e *kubernetesElector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	log.Printf("Leader election using Kubernetes lease %s/%s as %s", e.cfg.Namespace, e.cfg.LeaseName, e.cfg.Identity)
+
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:            e.lock,
+		LeaseDuration:   e.cfg.LeaseDuration,
+		RenewDeadline:   e.cfg.RenewDeadline,
+		RetryPeriod:     e.cfg.RetryPeriod,
+		ReleaseOnCancel: true,
+		Name:            e.cfg.LeaseName,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(leadCtx context.Context) {
+				e.running.Lock()
+				defer e.running.Unlock()
+				e.set(e.cfg.Identity, true)
+				lead(leadCtx)
+			},
+			OnStoppedLeading: func() {
+				e.set(e.cfg.Identity, false)
+			},
+		},
+	})
+	if err != nil {
+		log.Printf("Invalid leader election config: %v", err)
+		return
+	}
+
+	// Run returns when leadership is lost; campaign again until shutdown
+	for ctx.Err() == nil {
+		elector.Run(ctx)
+	}
+}
@@ -0,0 +1,136 @@
+// Package leader elects a single replica to run the background schedulers when
+// several server instances share one database. The API is served by every replica.
+package leader
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/metrics"
+)
+
+// Election backends, selected with ZENSTACK_LEADER_ELECTION.
+const (
+	BackendNone       = "none"       // Single replica; always leader (default)
+	BackendDatabase   = "database"   // Lease row in the shared database
+	BackendKubernetes = "kubernetes" // coordination.k8s.io Lease object
+)
+
+// Default lease timings. A new leader takes over at most LeaseDuration after the
+// previous one stopped renewing.
+const (
+	DefaultLeaseName     = "zenstack-scheduler"
+	DefaultLeaseDuration = 15 * time.Second
+	DefaultRenewDeadline = 10 * time.Second
+	DefaultRetryPeriod   = 2 * time.Second
+)
+
+// Elector campaigns for leadership and runs work only while it is the leader.
+type Elector interface {
+	// Run blocks until ctx is cancelled. Whenever leadership is acquired, lead is
+	// called with a context that is cancelled as soon as leadership is lost; lead
+	// must return promptly after that.
+	Run(ctx context.Context, lead func(ctx context.Context))
+	// IsLeader reports whether this replica currently holds the lease.
+	IsLeader() bool
+	// Identity is the unique name of this replica.
+	Identity() string
+}
+
+// Config configures an elector.
+type Config struct {
+	Backend       string
+	LeaseName     string
+	Namespace     string // Kubernetes namespace of the Lease
+	Identity      string
+	LeaseDuration time.Duration
+	RenewDeadline time.Duration
+	RetryPeriod   time.Duration
+}
+
+// ConfigFromEnv reads the election settings:
+//
+//	ZENSTACK_LEADER_ELECTION  none (default), database or kubernetes
+//	ZENSTACK_LEADER_LEASE     lease name, default zenstack-scheduler
+//	ZENSTACK_LEADER_NAMESPACE Kubernetes namespace, default the pod's namespace
+//	ZENSTACK_LEADER_ID        replica identity, default hostname and PID
+func ConfigFromEnv() Config {
+	return Config{
+		Backend:   strings.ToLower(strings.TrimSpace(os.Getenv("ZENSTACK_LEADER_ELECTION"))),
+		LeaseName: os.Getenv("ZENSTACK_LEADER_LEASE"),
+		Namespace: os.Getenv("ZENSTACK_LEADER_NAMESPACE"),
+		Identity:  os.Getenv("ZENSTACK_LEADER_ID"),
+	}
+}
+
+// New creates the elector for the configured backend.
+func New(cfg Config) (Elector, error) {
+	if cfg.LeaseName == "" {
+		cfg.LeaseName = DefaultLeaseName
+	}
+	if cfg.Identity == "" {
+		host, _ := os.Hostname()
+		cfg.Identity = fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	if cfg.LeaseDuration <= 0 {
+		cfg.LeaseDuration = DefaultLeaseDuration
+	}
+	if cfg.RenewDeadline <= 0 || cfg.RenewDeadline >= cfg.LeaseDuration {
+		cfg.RenewDeadline = cfg.LeaseDuration * 2 / 3
+	}
+	if cfg.RetryPeriod <= 0 {
+		cfg.RetryPeriod = DefaultRetryPeriod
+	}
+
+	switch cfg.Backend {
+	case "", BackendNone:
+		return &standalone{identity: cfg.Identity}, nil
+	case BackendDatabase, "db":
+		return newDatabaseElector(cfg), nil
+	case BackendKubernetes, "k8s":
+		return newKubernetesElector(cfg)
+	}
+	return nil, fmt.Errorf("unknown leader election backend %q (expected none, database or kubernetes)", cfg.Backend)
+}
+
+// state tracks leadership for IsLeader and the metrics exporter.
+type state struct {
+	leader atomic.Bool
+}
+
+func (s *state) set(identity string, leader bool) {
+	if s.leader.Swap(leader) == leader {
+		return
+	}
+	if leader {
+		log.Printf("Replica %s became the scheduler leader", identity)
+		metrics.SchedulerLeader.Set(1)
+	} else {
+		log.Printf("Replica %s is no longer the scheduler leader", identity)
+		metrics.SchedulerLeader.Set(0)
+	}
+}
+
+// IsLeader implements Elector.
+func (s *state) IsLeader() bool { return s.leader.Load() }
+
+// standalone is used when election is disabled: the replica always leads.
+type standalone struct {
+	state
+	identity string
+}
+
+// Run implements Elector.
+func (e *standalone) Run(ctx context.Context, lead func(ctx context.Context)) {
+	e.set(e.identity, true)
+	lead(ctx)
+	e.set(e.identity, false)
+}
+
+// Identity implements Elector.
+func (e *standalone) Identity() string { return e.identity }
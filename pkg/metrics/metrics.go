@@ -0,0 +1,232 @@
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+const namespace = "zenstack"
+
+var (
+	// Registry holds all ZenStack metrics. A dedicated registry keeps the
+	// exposition free of metrics registered by third-party libraries.
+	Registry = prometheus.NewRegistry()
+
+	// ScanDuration observes how long a full scan cycle takes, by scan type (ssl, http).
+	ScanDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "scan_duration_seconds",
+		Help:      "Duration of a full background scan cycle.",
+		Buckets:   []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800},
+	}, []string{"scan"})
+
+	// QueueDepth is the number of jobs waiting for a worker, by queue.
+	QueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "worker_queue_depth",
+		Help:      "Number of jobs waiting to be picked up by a worker.",
+	}, []string{"queue"})
+
+	// NotificationFailures counts failed notification deliveries, by channel type.
+	NotificationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notification_send_failures_total",
+		Help:      "Number of notification deliveries that failed.",
+	}, []string{"channel"})
+
+	// NotificationDeadLetters counts notifications given up after their last retry, by
+	// channel type.
+	NotificationDeadLetters = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notification_dead_letters_total",
+		Help:      "Number of notifications moved to the dead-letter list.",
+	}, []string{"channel"})
+
+	// DBErrors counts failed database operations, by operation.
+	DBErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_errors_total",
+		Help:      "Number of database operations that returned an error.",
+	}, []string{"operation"})
+
+	// HTTPPhaseDuration observes the httptrace phase timings of health checks.
+	HTTPPhaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_phase_duration_seconds",
+		Help:      "Duration of health check phases (dns, tcp, tls, ttfb, total).",
+		Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"domain", "phase"})
+
+	// SchedulerLeader is 1 on the replica currently running the background schedulers.
+	SchedulerLeader = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "scheduler_leader",
+		Help:      "Whether this replica holds the scheduler leader lease (1) or not (0).",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		ScanDuration,
+		QueueDepth,
+		NotificationFailures,
+		NotificationDeadLetters,
+		DBErrors,
+		HTTPPhaseDuration,
+		SchedulerLeader,
+		newDomainCollector(),
+	)
+}
+
+// Handler returns the HTTP handler serving the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{
+		ErrorHandling: promhttp.ContinueOnError,
+	})
+}
+
+// ObservePhases records the phase timings of a single health check, given in milliseconds.
+func ObservePhases(domain string, dns, tcp, tls, ttfb, total int) {
+	phases := map[string]int{
+		"dns":   dns,
+		"tcp":   tcp,
+		"tls":   tls,
+		"ttfb":  ttfb,
+		"total": total,
+	}
+	for phase, ms := range phases {
+		// Phases that did not happen (e.g. TLS over plain HTTP) are not observed
+		if ms <= 0 && phase != "total" {
+			continue
+		}
+		HTTPPhaseDuration.WithLabelValues(domain, phase).Observe(float64(ms) / 1000.0)
+	}
+}
+
+// ForgetDomain drops the per-domain series of a deleted domain.
+func ForgetDomain(domain string) {
+	HTTPPhaseDuration.DeletePartialMatch(prometheus.Labels{"domain": domain})
+}
+
+// InstrumentDB registers GORM callbacks that count failed database operations.
+// Record-not-found errors are expected lookups and are not counted.
+func InstrumentDB(db *gorm.DB) error {
+	count := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+				DBErrors.WithLabelValues(operation).Inc()
+			}
+		}
+	}
+
+	cb := db.Callback()
+	if err := cb.Create().After("gorm:create").Register("metrics:create", count("create")); err != nil {
+		return err
+	}
+	if err := cb.Query().After("gorm:query").Register("metrics:query", count("query")); err != nil {
+		return err
+	}
+	if err := cb.Update().After("gorm:update").Register("metrics:update", count("update")); err != nil {
+		return err
+	}
+	if err := cb.Delete().After("gorm:delete").Register("metrics:delete", count("delete")); err != nil {
+		return err
+	}
+	if err := cb.Row().After("gorm:row").Register("metrics:row", count("row")); err != nil {
+		return err
+	}
+	return cb.Raw().After("gorm:raw").Register("metrics:raw", count("raw"))
+}
+
+// domainCollector exports per-domain gauges straight from the monitored_domains table
+// at scrape time, so values always match what the dashboard shows.
+type domainCollector struct {
+	up                 *prometheus.Desc
+	sslExpiry          *prometheus.Desc
+	registrationExpiry *prometheus.Desc
+	responseTime       *prometheus.Desc
+}
+
+var domainLabels = []string{"domain", "tags", "custom_status"}
+
+func newDomainCollector() *domainCollector {
+	return &domainCollector{
+		up: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "domain", "up"),
+			"Whether the last health check of the domain succeeded (1) or not (0).",
+			domainLabels, nil),
+		sslExpiry: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "ssl", "expiry_seconds"),
+			"Seconds until the SSL certificate of the domain expires (negative when expired).",
+			domainLabels, nil),
+		registrationExpiry: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "domain", "registration_expiry_seconds"),
+			"Seconds until the domain registration expires, from WHOIS.",
+			domainLabels, nil),
+		responseTime: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "domain", "response_time_seconds"),
+			"Total response time of the last health check.",
+			domainLabels, nil),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (c *domainCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.up
+	ch <- c.sslExpiry
+	ch <- c.registrationExpiry
+	ch <- c.responseTime
+}
+
+// Collect implements prometheus.Collector.
+func (c *domainCollector) Collect(ch chan<- prometheus.Metric) {
+	if database.DB == nil {
+		return
+	}
+
+	var domains []database.MonitoredDomain
+	if err := database.DB.Find(&domains).Error; err != nil {
+		return
+	}
+
+	now := time.Now()
+	for _, d := range domains {
+		labels := []string{d.DomainName, normalizeTags(d.Tags), d.CustomStatus}
+
+		up := 0.0
+		if d.IsLive {
+			up = 1.0
+		}
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, up, labels...)
+		ch <- prometheus.MustNewConstMetric(c.responseTime, prometheus.GaugeValue, float64(d.ResponseTime)/1000.0, labels...)
+
+		// Domains that were never scanned successfully have no expiry to report
+		if !d.SSLExpiry.IsZero() {
+			ch <- prometheus.MustNewConstMetric(c.sslExpiry, prometheus.GaugeValue, d.SSLExpiry.Sub(now).Seconds(), labels...)
+		}
+		if !d.LastExpiryDate.IsZero() {
+			ch <- prometheus.MustNewConstMetric(c.registrationExpiry, prometheus.GaugeValue, d.LastExpiryDate.Sub(now).Seconds(), labels...)
+		}
+	}
+}
+
+// normalizeTags trims and joins comma-separated tags so the label value is stable.
+func normalizeTags(tags string) string {
+	var out []string
+	for _, t := range strings.Split(tags, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			out = append(out, t)
+		}
+	}
+	return strings.Join(out, ",")
+}
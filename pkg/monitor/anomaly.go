@@ -0,0 +1,274 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// KindLatency is the check kind of latency anomalies. It doesn't probe anything on
+// its own; its outcomes are derived from HTTP checks.
+const KindLatency = "latency"
+
+// Latency phases with a baseline, matching the heartbeat timings.
+const (
+	LatencyDNS   = "dns"
+	LatencyTCP   = "tcp"
+	LatencyTLS   = "tls"
+	LatencyTTFB  = "ttfb"
+	LatencyTotal = "total"
+)
+
+// allHours is the Hour of baselines covering all hours of the day.
+const allHours = -1
+
+// LatencyDetector keeps an exponentially weighted moving average and variance of the
+// latency of every probe phase per domain, and flags samples that are significantly
+// slower than the baseline. Baselines are kept over all checks and per hour of the
+// day; the hourly one is used once it has warmed up, so daily traffic patterns don't
+// raise anomalies.
+type LatencyDetector struct {
+	Alpha        float64 // Weight of a new sample, default 0.1
+	Threshold    float64 // Standard deviations above the mean that count as an anomaly, default 3
+	Warmup       int     // Samples needed before a baseline is used, default 30
+	MinDeviation float64 // Minimum deviation in milliseconds, filters noise on very stable phases (default 50)
+}
+
+func (d LatencyDetector) alpha() float64 {
+	if d.Alpha > 0 && d.Alpha < 1 {
+		return d.Alpha
+	}
+	return 0.1
+}
+
+func (d LatencyDetector) threshold() float64 {
+	if d.Threshold > 0 {
+		return d.Threshold
+	}
+	return 3
+}
+
+func (d LatencyDetector) warmup() int {
+	if d.Warmup > 0 {
+		return d.Warmup
+	}
+	return 30
+}
+
+func (d LatencyDetector) minDeviation() float64 {
+	if d.MinDeviation > 0 {
+		return d.MinDeviation
+	}
+	return 50
+}
+
+// latencyPhases returns the phase timings of a successful health check. The TLS phase
+// is left out for plain HTTP.
+func latencyPhases(res HealthCheckResult) map[string]int {
+	phases := map[string]int{
+		LatencyDNS:   res.DNSLookup,
+		LatencyTCP:   res.TCPConnection,
+		LatencyTTFB:  res.TTFB,
+		LatencyTotal: res.ResponseTime,
+	}
+	if res.TLSHandshake > 0 {
+		phases[LatencyTLS] = res.TLSHandshake
+	}
+	return phases
+}
+
+// Observe compares the phase timings of a heartbeat with the baselines of the domain,
+// stores an anomaly for every significant deviation and folds the timings into the
+// baselines. A persistent shift becomes the new normal after a while.
+func (d LatencyDetector) Observe(domainID, heartbeatID uint, phases map[string]int, at time.Time) ([]database.LatencyAnomaly, error) {
+	if database.DB == nil {
+		return nil, database.ErrDatabaseNotInitialized
+	}
+
+	hour := at.Hour()
+	var baselines []database.LatencyBaseline
+	if err := database.DB.Where("domain_id = ? AND hour IN ?", domainID, []int{allHours, hour}).Find(&baselines).Error; err != nil {
+		return nil, err
+	}
+	byKey := make(map[string]*database.LatencyBaseline, len(baselines))
+	for i := range baselines {
+		byKey[baselines[i].Phase+"/"+strconv.Itoa(baselines[i].Hour)] = &baselines[i]
+	}
+	baseline := func(phase string, h int) *database.LatencyBaseline {
+		key := phase + "/" + strconv.Itoa(h)
+		if b, ok := byKey[key]; ok {
+			return b
+		}
+		b := &database.LatencyBaseline{DomainID: domainID, Phase: phase, Hour: h}
+		byKey[key] = b
+		return b
+	}
+
+	names := make([]string, 0, len(phases))
+	for phase := range phases {
+		names = append(names, phase)
+	}
+	sort.Strings(names)
+
+	var anomalies []database.LatencyAnomaly
+	for _, phase := range names {
+		value := float64(phases[phase])
+		overall, hourly := baseline(phase, allHours), baseline(phase, hour)
+
+		ref, isHourly := overall, false
+		if hourly.Samples >= d.warmup() {
+			ref, isHourly = hourly, true
+		}
+		if ref.Samples >= d.warmup() {
+			if score, std, ok := d.deviation(ref, value); ok {
+				anomalies = append(anomalies, database.LatencyAnomaly{
+					DomainID:    domainID,
+					HeartbeatID: heartbeatID,
+					Phase:       phase,
+					Value:       phases[phase],
+					Baseline:    ref.Mean,
+					StdDev:      std,
+					Score:       score,
+					Hourly:      isHourly,
+					CreatedAt:   at,
+				})
+			}
+		}
+
+		d.update(overall, value, at)
+		d.update(hourly, value, at)
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, b := range byKey {
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "domain_id"}, {Name: "phase"}, {Name: "hour"}},
+				DoUpdates: clause.AssignmentColumns([]string{"mean", "variance", "samples", "updated_at"}),
+			}).Create(b).Error; err != nil {
+				return err
+			}
+		}
+		if len(anomalies) > 0 {
+			if err := tx.Create(&anomalies).Error; err != nil {
+				return err
+			}
+			if heartbeatID != 0 {
+				return tx.Model(&database.Heartbeat{}).Where("id = ?", heartbeatID).Update("anomaly", true).Error
+			}
+		}
+		return nil
+	})
+	return anomalies, err
+}
+
+// deviation reports how many standard deviations a value is above the baseline and
+// whether that is an anomaly. Only slowdowns count; faster responses are never flagged.
+// The standard deviation has a floor of 10% of the mean so that near-constant phases
+// don't flag every jitter.
+func (d LatencyDetector) deviation(b *database.LatencyBaseline, value float64) (score, std float64, anomalous bool) {
+	std = math.Max(math.Sqrt(b.Variance), b.Mean*0.1)
+	if std <= 0 {
+		std = 1
+	}
+	diff := value - b.Mean
+	score = diff / std
+	return score, std, score >= d.threshold() && diff >= d.minDeviation()
+}
+
+// update folds a sample into the baseline using EWMA of mean and variance.
+func (d LatencyDetector) update(b *database.LatencyBaseline, value float64, at time.Time) {
+	if b.Samples == 0 {
+		b.Mean = value
+		b.Variance = 0
+	} else {
+		alpha := d.alpha()
+		diff := value - b.Mean
+		b.Mean += alpha * diff
+		b.Variance = (1 - alpha) * (b.Variance + alpha*diff*diff)
+	}
+	b.Samples++
+	b.UpdatedAt = at
+}
+
+// latencyOutcome turns the anomalies of one health check into an outcome of the
+// latency check.
+func latencyOutcome(anomalies []database.LatencyAnomaly) Outcome {
+	if len(anomalies) == 0 {
+		return Outcome{Status: StatusUp}
+	}
+
+	phases := make([]string, 0, len(anomalies))
+	details := make([]string, 0, len(anomalies))
+	for _, a := range anomalies {
+		phases = append(phases, a.Phase)
+		details = append(details, fmt.Sprintf("%s %dms (baseline %.0f±%.0fms)", a.Phase, a.Value, a.Baseline, a.StdDev))
+	}
+	return Outcome{
+		Status:  StatusCritical,
+		Message: "Latency anomaly: " + strings.Join(details, ", "),
+		Data: map[string]string{
+			"phases":  strings.Join(phases, ","),
+			"details": strings.Join(details, ", "),
+		},
+		Detail: anomalies,
+	}
+}
+
+// LatencyCheck turns latency anomalies found by the HTTP check into events. An
+// anomaly has to persist for Consecutive checks in a row before it is reported,
+// and is reported once until latency is back to normal.
+type LatencyCheck struct {
+	Consecutive int // Default 2
+}
+
+// Kind implements Check.
+func (c *LatencyCheck) Kind() string { return KindLatency }
+
+// Interval implements Check. Latency outcomes are derived from HTTP checks, so the
+// check is never scheduled on its own.
+func (c *LatencyCheck) Interval() time.Duration { return 0 }
+
+// Policy implements Check.
+func (c *LatencyCheck) Policy() Policy {
+	consecutive := c.Consecutive
+	if consecutive <= 0 {
+		consecutive = 2
+	}
+	return Policy{FailureThreshold: consecutive}
+}
+
+// Targets implements Check.
+func (c *LatencyCheck) Targets(ctx context.Context) ([]Target, error) { return nil, nil }
+
+// Initial implements Check.
+func (c *LatencyCheck) Initial(t Target) TargetState { return TargetState{Status: StatusUp} }
+
+// Run implements Check.
+func (c *LatencyCheck) Run(ctx context.Context, t Target) Outcome {
+	return Outcome{Status: StatusUnknown}
+}
+
+// Event implements Check.
+func (c *LatencyCheck) Event(from, to Status) string {
+	if to == StatusCritical && from != StatusCritical {
+		return EventLatencyAnomaly
+	}
+	return ""
+}
+
+// CleanupLatencyAnomalies removes anomaly markers older than the retention period.
+func CleanupLatencyAnomalies(retention time.Duration) (int64, error) {
+	if database.DB == nil {
+		return 0, database.ErrDatabaseNotInitialized
+	}
+	res := database.DB.Where("created_at < ?", time.Now().Add(-retention)).Delete(&database.LatencyAnomaly{})
+	return res.RowsAffected, res.Error
+}
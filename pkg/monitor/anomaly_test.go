@@ -0,0 +1,155 @@
+package monitor
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+)
+
+func TestLatencyUpdate(t *testing.T) {
+	tests := []struct {
+		name     string
+		detector LatencyDetector
+		values   []float64
+		mean     float64
+		variance float64
+	}{
+		{"first sample", LatencyDetector{}, []float64{120}, 120, 0},
+		{"default alpha", LatencyDetector{}, []float64{100, 110}, 101, 9},
+		{"alpha out of range", LatencyDetector{Alpha: 1.5}, []float64{100, 110}, 101, 9},
+		// 200: mean 100 + 0.5*100, variance 0.5 * (0 + 0.5*100²)
+		// 100: mean 150 - 0.5*50, variance 0.5 * (2500 + 0.5*50²)
+		{"series", LatencyDetector{Alpha: 0.5}, []float64{100, 200, 100}, 125, 1875},
+		{"constant", LatencyDetector{Alpha: 0.5}, []float64{80, 80, 80, 80}, 80, 0},
+	}
+	at := time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b database.LatencyBaseline
+			for _, v := range tt.values {
+				tt.detector.update(&b, v, at)
+			}
+			if math.Abs(b.Mean-tt.mean) > 1e-9 || math.Abs(b.Variance-tt.variance) > 1e-9 || b.Samples != len(tt.values) || !b.UpdatedAt.Equal(at) {
+				t.Errorf("baseline %+v, want mean %g variance %g", b, tt.mean, tt.variance)
+			}
+		})
+	}
+}
+
+func TestLatencyDeviation(t *testing.T) {
+	tests := []struct {
+		name      string
+		detector  LatencyDetector
+		mean      float64
+		variance  float64
+		value     float64
+		score     float64
+		std       float64
+		anomalous bool
+	}{
+		{"at the threshold", LatencyDetector{}, 100, 400, 160, 3, 20, true},
+		{"below the threshold", LatencyDetector{}, 100, 400, 159, 2.95, 20, false},
+		{"faster than the baseline", LatencyDetector{}, 1000, 10000, 500, -5, 100, false},
+		// The standard deviation has a floor of 10% of the mean
+		{"stable phase within the minimum deviation", LatencyDetector{}, 100, 0, 140, 4, 10, false},
+		{"stable phase at the minimum deviation", LatencyDetector{}, 100, 0, 150, 5, 10, true},
+		{"zero baseline", LatencyDetector{}, 0, 0, 50, 50, 1, true},
+		{"configured threshold and deviation", LatencyDetector{Threshold: 2, MinDeviation: 10}, 100, 100, 125, 2.5, 10, true},
+		{"configured minimum deviation", LatencyDetector{MinDeviation: 100}, 100, 400, 190, 4.5, 20, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &database.LatencyBaseline{Mean: tt.mean, Variance: tt.variance, Samples: 50}
+			score, std, anomalous := tt.detector.deviation(b, tt.value)
+			if math.Abs(score-tt.score) > 1e-9 || math.Abs(std-tt.std) > 1e-9 || anomalous != tt.anomalous {
+				t.Errorf("deviation() = %g, %g, %v; want %g, %g, %v", score, std, anomalous, tt.score, tt.std, tt.anomalous)
+			}
+		})
+	}
+}
+
+func TestLatencyObserve(t *testing.T) {
+	openTestDB(t, &database.LatencyBaseline{}, &database.LatencyAnomaly{}, &database.Heartbeat{})
+	d := LatencyDetector{Alpha: 0.5, Warmup: 3}
+	hour := func(h int) time.Time { return time.Date(2026, 3, 1, h, 30, 0, 0, time.UTC) }
+
+	steps := []struct {
+		name     string
+		at       time.Time
+		value    int
+		anomaly  bool
+		hourly   bool
+		baseline float64
+	}{
+		{"warm-up", hour(10), 100, false, false, 0},
+		{"warm-up", hour(10), 100, false, false, 0},
+		{"warm-up", hour(10), 100, false, false, 0},
+		// Until the baseline of 22:00 has warmed up, the one over all hours is used
+		{"slower at another hour", hour(22), 1000, true, false, 100},
+		{"overall baseline adapted", hour(22), 1000, false, false, 0},
+		{"overall baseline adapted", hour(22), 1000, false, false, 0},
+		// Below the overall mean of 887.5ms, yet far above the usual 100ms at 10:00
+		{"slower than usual for the hour", hour(10), 400, true, true, 100},
+		{"usual for the hour", hour(22), 1050, false, false, 0},
+	}
+	for i, s := range steps {
+		hb := database.Heartbeat{DomainID: 1, Latency: s.value, CreatedAt: s.at}
+		if err := database.DB.Create(&hb).Error; err != nil {
+			t.Fatal(err)
+		}
+		anomalies, err := d.Observe(1, hb.ID, map[string]int{LatencyTotal: s.value}, s.at)
+		if err != nil {
+			t.Fatalf("step %d: %v", i+1, err)
+		}
+		if !s.anomaly {
+			if len(anomalies) != 0 {
+				t.Errorf("step %d, %s: got anomalies %+v", i+1, s.name, anomalies)
+			}
+			continue
+		}
+		if len(anomalies) != 1 {
+			t.Fatalf("step %d, %s: got %d anomalies, want 1", i+1, s.name, len(anomalies))
+		}
+		a := anomalies[0]
+		if a.Phase != LatencyTotal || a.Value != s.value || a.Hourly != s.hourly || a.Baseline != s.baseline || a.HeartbeatID != hb.ID {
+			t.Errorf("step %d, %s: got anomaly %+v", i+1, s.name, a)
+		}
+		database.DB.First(&hb, hb.ID)
+		if !hb.Anomaly {
+			t.Errorf("step %d, %s: heartbeat not marked", i+1, s.name)
+		}
+	}
+
+	// A spike before the baseline of another domain has warmed up isn't flagged
+	for _, v := range []int{100, 100, 1000} {
+		anomalies, err := d.Observe(2, 0, map[string]int{LatencyTotal: v}, hour(10))
+		if err != nil || len(anomalies) != 0 {
+			t.Errorf("warm-up sample %d: got %+v, %v", v, anomalies, err)
+		}
+	}
+
+	var stored int64
+	database.DB.Model(&database.LatencyAnomaly{}).Count(&stored)
+	if stored != 2 {
+		t.Errorf("stored %d anomalies, want 2", stored)
+	}
+
+	// Baselines over all hours and per hour are stored
+	want := map[int]struct {
+		mean    float64
+		samples int
+	}{allHours: {846.875, 8}, 10: {250, 4}, 22: {1025, 4}}
+	var baselines []database.LatencyBaseline
+	database.DB.Where("domain_id = ?", 1).Find(&baselines)
+	if len(baselines) != len(want) {
+		t.Fatalf("stored %d baselines, want %d", len(baselines), len(want))
+	}
+	for _, b := range baselines {
+		w := want[b.Hour]
+		if b.Phase != LatencyTotal || math.Abs(b.Mean-w.mean) > 1e-9 || b.Samples != w.samples {
+			t.Errorf("baseline of hour %d: %+v, want mean %g over %d samples", b.Hour, b, w.mean, w.samples)
+		}
+	}
+}
@@ -0,0 +1,511 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/content"
+	"github.com/harveywai/zenstack/pkg/database"
+	"github.com/harveywai/zenstack/pkg/metrics"
+	"github.com/harveywai/zenstack/pkg/statuspage"
+	"github.com/harveywai/zenstack/pkg/synthetic"
+	"gorm.io/gorm"
+)
+
+// Check kinds of the built-in checks.
+const (
+	KindHTTP      = "http"
+	KindSSL       = "ssl"
+	KindContent   = "content"
+	KindSynthetic = "synthetic"
+)
+
+// DomainKey is the target key of a monitored domain, shared by all domain checks.
+func DomainKey(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+// DomainTarget builds the target of a monitored domain.
+func DomainTarget(d database.MonitoredDomain) Target {
+	return Target{Key: DomainKey(d.ID), Name: d.DomainName, Domain: d}
+}
+
+func loadDomainTargets(query interface{}, args ...interface{}) ([]Target, error) {
+	if database.DB == nil {
+		return nil, database.ErrDatabaseNotInitialized
+	}
+
+	var domains []database.MonitoredDomain
+	db := database.DB
+	if query != nil {
+		db = db.Where(query, args...)
+	}
+	if err := db.Find(&domains).Error; err != nil {
+		return nil, err
+	}
+
+	targets := make([]Target, 0, len(domains))
+	for _, d := range domains {
+		targets = append(targets, DomainTarget(d))
+	}
+	return targets, nil
+}
+
+// HTTPCheck probes every domain over HTTPS (falling back to HTTP), records a heartbeat
+// and the daily uptime, and reports the site as up or down.
+type HTTPCheck struct {
+	Every        time.Duration // Default 2 minutes
+	Timeout      time.Duration // Default 5 seconds
+	NodeLocation string        // Recorded on heartbeats, default "Japan-Nagoya"
+	Latency      LatencyDetector
+}
+
+// Kind implements Check.
+func (c *HTTPCheck) Kind() string { return KindHTTP }
+
+// Interval implements Check.
+func (c *HTTPCheck) Interval() time.Duration { return durationOr(c.Every, 2*time.Minute) }
+
+// Policy implements Check.
+func (c *HTTPCheck) Policy() Policy { return Policy{FailureThreshold: 1} }
+
+// Targets implements Check.
+func (c *HTTPCheck) Targets(ctx context.Context) ([]Target, error) {
+	return loadDomainTargets(nil)
+}
+
+// Initial implements Check. Domains without any heartbeat were never checked, so
+// their first result must not be reported as a recovery.
+func (c *HTTPCheck) Initial(t Target) TargetState {
+	if t.Domain.IsLive {
+		return TargetState{Status: StatusUp}
+	}
+	var count int64
+	if database.DB != nil {
+		database.DB.Model(&database.Heartbeat{}).Where("domain_id = ?", t.Domain.ID).Count(&count)
+	}
+	if count == 0 {
+		return TargetState{Status: StatusUnknown}
+	}
+	return TargetState{Status: StatusDown}
+}
+
+// Run implements Check.
+func (c *HTTPCheck) Run(ctx context.Context, t Target) Outcome {
+	res := c.probe(ctx, t)
+	if ctx.Err() != nil {
+		return Outcome{Status: StatusUnknown}
+	}
+
+	metrics.ObservePhases(t.Domain.DomainName, res.DNSLookup, res.TCPConnection, res.TLSHandshake, res.TTFB, res.ResponseTime)
+
+	location := c.NodeLocation
+	if location == "" {
+		location = "Japan-Nagoya"
+	}
+	heartbeat := database.Heartbeat{
+		DomainID:      t.Domain.ID,
+		Latency:       res.ResponseTime,
+		StatusCode:    res.StatusCode,
+		DNSLookup:     res.DNSLookup,
+		TCPConnection: res.TCPConnection,
+		TLSHandshake:  res.TLSHandshake,
+		TTFB:          res.TTFB,
+		NodeLocation:  location,
+		CreatedAt:     time.Now(),
+	}
+	if err := database.DB.Create(&heartbeat).Error; err != nil {
+		log.Printf("Error creating heartbeat for domain %s: %v", t.Domain.DomainName, err)
+	}
+	res.HeartbeatID = heartbeat.ID
+
+	// Keep the evidence of every failed check next to its heartbeat
+	if !res.IsLive && res.Diagnostic != nil {
+		id, err := saveSnapshot(res.Diagnostic, t.Domain.ID, heartbeat.ID, 0, TriggerFailure)
+		if err != nil {
+			log.Printf("Error saving diagnostic snapshot for domain %s: %v", t.Domain.DomainName, err)
+		}
+		res.SnapshotID = id
+	}
+
+	out := httpOutcome(res)
+	// Compare the timings of successful checks with the latency baseline of the domain
+	if res.IsLive && heartbeat.ID != 0 {
+		anomalies, err := c.Latency.Observe(t.Domain.ID, heartbeat.ID, latencyPhases(res), heartbeat.CreatedAt)
+		if err != nil {
+			log.Printf("Error updating latency baseline for domain %s: %v", t.Domain.DomainName, err)
+		} else {
+			out.Derived = append(out.Derived, Derived{Kind: KindLatency, Outcome: latencyOutcome(anomalies)})
+		}
+	}
+	out.Persist = func(tx *gorm.DB) error {
+		// Heartbeats are the raw observations and saved above, since diagnostics and
+		// latency anomalies refer to them
+		err := tx.Model(&database.MonitoredDomain{}).Where("id = ?", t.Domain.ID).Updates(map[string]interface{}{
+			"is_live":          res.IsLive,
+			"status_code":      res.StatusCode,
+			"last_status_code": res.StatusCode,
+			"response_time":    res.ResponseTime,
+		}).Error
+		if err != nil {
+			return fmt.Errorf("failed to update domain health: %w", err)
+		}
+		// Roll the result into the daily uptime used by public status pages
+		if err := statuspage.RecordCheck(tx, t.Domain.ID, res.IsLive, heartbeat.CreatedAt); err != nil {
+			return fmt.Errorf("failed to record daily uptime: %w", err)
+		}
+		return nil
+	}
+	return out
+}
+
+// Probe implements Prober. No heartbeat, snapshot or latency baseline is recorded.
+func (c *HTTPCheck) Probe(ctx context.Context, t Target) Outcome {
+	res := c.probe(ctx, t)
+	if ctx.Err() != nil {
+		return Outcome{Status: StatusUnknown}
+	}
+	return httpOutcome(res)
+}
+
+func (c *HTTPCheck) probe(ctx context.Context, t Target) HealthCheckResult {
+	return CheckDomainHealth(ctx, t.Domain.DomainName, durationOr(c.Timeout, 5*time.Second))
+}
+
+// httpOutcome reports the result of a health check.
+func httpOutcome(res HealthCheckResult) Outcome {
+	code := strconv.Itoa(res.StatusCode)
+	out := Outcome{
+		Status: StatusUp,
+		Data: map[string]string{
+			"status":        code,
+			"status_code":   code,
+			"code":          code,
+			"response_time": strconv.Itoa(res.ResponseTime),
+		},
+		Detail: res,
+	}
+	if !res.IsLive {
+		out.Status = StatusDown
+		out.Message = fmt.Sprintf("Health check failed (status code %d)", res.StatusCode)
+		if res.Err != nil {
+			out.Data["error"] = res.Err.Error()
+		}
+		if res.Diagnostic != nil {
+			out.Data["error_class"] = res.Diagnostic.ErrorClass
+			out.Message = fmt.Sprintf("Health check failed: %s (status code %d)", res.Diagnostic.ErrorClass, res.StatusCode)
+		}
+	}
+	return out
+}
+
+// Event implements Check.
+func (c *HTTPCheck) Event(from, to Status) string {
+	switch {
+	case from == StatusUp && to == StatusDown:
+		return EventSiteDown
+	case from == StatusDown && to == StatusUp:
+		return EventSiteUp
+	}
+	return ""
+}
+
+// SSLCheck inspects the certificate of every domain. Expiry reminders follow the
+// reminder stages of the domain and are derived as KindSSLReminder outcomes; the check
+// itself reports renewals of certificates in warning or critical state.
+type SSLCheck struct {
+	Every      time.Duration // Default 6 hours
+	Timeout    time.Duration // Default 5 seconds
+	Thresholds Thresholds    // Default DefaultThresholds
+}
+
+// Kind implements Check.
+func (c *SSLCheck) Kind() string { return KindSSL }
+
+// Interval implements Check.
+func (c *SSLCheck) Interval() time.Duration { return durationOr(c.Every, 6*time.Hour) }
+
+// Policy implements Check.
+func (c *SSLCheck) Policy() Policy { return Policy{FailureThreshold: 1} }
+
+// Targets implements Check.
+func (c *SSLCheck) Targets(ctx context.Context) ([]Target, error) {
+	return loadDomainTargets(nil)
+}
+
+// Initial implements Check.
+func (c *SSLCheck) Initial(t Target) TargetState {
+	return TargetState{Status: statusForSSL(t.Domain.SSLStatus)}
+}
+
+func (c *SSLCheck) thresholds() Thresholds {
+	if c.Thresholds.CriticalDays == 0 && c.Thresholds.WarningDays == 0 {
+		return DefaultThresholds
+	}
+	return c.Thresholds
+}
+
+// Run implements Check.
+func (c *SSLCheck) Run(ctx context.Context, t Target) Outcome {
+	res := DeepScanSSL(ctx, t.Domain.DomainName, durationOr(c.Timeout, 5*time.Second))
+	if ctx.Err() != nil {
+		return Outcome{Status: StatusUnknown}
+	}
+
+	now := time.Now()
+	updateData := map[string]interface{}{
+		"ssl_status":      SSLOffline,
+		"last_check_time": now,
+	}
+	if res.IsReachable {
+		updateData["ssl_status"] = c.thresholds().SSLStatus(res.DaysRemaining)
+		updateData["ssl_expiry"] = res.ExpiryDate
+		if res.Issuer != "" {
+			updateData["issuer"] = res.Issuer
+		}
+		if res.Serial != "" {
+			updateData["ssl_serial"] = res.Serial
+		}
+	}
+	save := func(tx *gorm.DB) error {
+		return tx.Model(&database.MonitoredDomain{}).Where("id = ?", t.Domain.ID).Updates(updateData).Error
+	}
+
+	// Unreachable hosts say nothing about the certificate; availability is the HTTP check's job
+	if !res.IsReachable {
+		return Outcome{Status: StatusUnknown, Message: "TLS connection failed", Persist: save}
+	}
+
+	label := updateData["ssl_status"].(string)
+	days := strconv.Itoa(res.DaysRemaining)
+	out := Outcome{
+		Status:  statusForSSL(label),
+		Message: fmt.Sprintf("SSL certificate %s (%d days remaining)", label, res.DaysRemaining),
+		Data: map[string]string{
+			"days":           days,
+			"days_remaining": days,
+			"ssl_status":     label,
+			"expiry":         res.ExpiryDate.Format("2006-01-02 15:04:05"),
+			"expiry_date":    res.ExpiryDate.Format("2006-01-02"),
+			"issuer":         res.Issuer,
+		},
+		Detail:  res,
+		Persist: save,
+	}
+
+	due, err := nextReminder(t.Domain, res)
+	if err != nil {
+		log.Printf("Error loading certificate reminders for domain %s: %v", t.Domain.DomainName, err)
+	}
+	if due != nil {
+		data := make(map[string]string, len(out.Data)+2)
+		for k, v := range out.Data {
+			data[k] = v
+		}
+		data["stage"] = strconv.Itoa(due.Stage)
+		data["serial"] = due.Serial
+		out.Derived = append(out.Derived, Derived{Kind: KindSSLReminder, Outcome: Outcome{
+			Status:  StatusCritical,
+			Message: fmt.Sprintf("SSL certificate expires in %d days (reminder at %d days)", res.DaysRemaining, due.Stage),
+			Data:    data,
+			Detail:  *due,
+		}})
+	}
+	return out
+}
+
+// Probe implements Prober. Run only reads, and leaves its writes to Persist.
+func (c *SSLCheck) Probe(ctx context.Context, t Target) Outcome {
+	out := c.Run(ctx, t)
+	out.Persist, out.Derived = nil, nil
+	return out
+}
+
+// Event implements Check.
+func (c *SSLCheck) Event(from, to Status) string {
+	if to == StatusUp && (from == StatusCritical || from == StatusWarning) {
+		return EventSSLRenewed
+	}
+	return ""
+}
+
+// ContentCheck compares watched pages against their baseline. Every detected change
+// is reported; content.Check itself makes sure the same change is reported once.
+type ContentCheck struct {
+	Every time.Duration // Default 10 minutes
+}
+
+// Kind implements Check.
+func (c *ContentCheck) Kind() string { return KindContent }
+
+// Interval implements Check.
+func (c *ContentCheck) Interval() time.Duration { return durationOr(c.Every, 10*time.Minute) }
+
+// Policy implements Check.
+func (c *ContentCheck) Policy() Policy { return Policy{FailureThreshold: 1, EveryFailure: true} }
+
+// Targets implements Check.
+func (c *ContentCheck) Targets(ctx context.Context) ([]Target, error) {
+	return loadDomainTargets("content_watch = ? AND is_live = ?", true, true)
+}
+
+// Initial implements Check.
+func (c *ContentCheck) Initial(t Target) TargetState {
+	return TargetState{Status: StatusUnknown}
+}
+
+// Run implements Check.
+func (c *ContentCheck) Run(ctx context.Context, t Target) Outcome {
+	change, err := content.Check(t.Domain)
+	if err != nil {
+		log.Printf("Content check failed for domain %s: %v", t.Domain.DomainName, err)
+		return Outcome{Status: StatusUnknown, Message: err.Error()}
+	}
+	if change == nil {
+		return Outcome{Status: StatusUp}
+	}
+
+	log.Printf("Content of domain %s changed by %.1f%%", t.Domain.DomainName, change.ChangePercent)
+	return Outcome{
+		Status:  StatusCritical,
+		Message: fmt.Sprintf("Content changed by %.1f%%", change.ChangePercent),
+		Data: map[string]string{
+			"change_percent": fmt.Sprintf("%.1f", change.ChangePercent),
+			"change_id":      fmt.Sprintf("%d", change.ID),
+			"diff":           change.Diff,
+		},
+		Detail: change,
+	}
+}
+
+// Event implements Check.
+func (c *ContentCheck) Event(from, to Status) string {
+	if to == StatusCritical {
+		return EventContentChanged
+	}
+	return ""
+}
+
+// SyntheticCheck runs the stored multi-step scenarios whose interval has elapsed.
+type SyntheticCheck struct {
+	Every time.Duration // How often due scenarios are looked up, default 30 seconds
+}
+
+// SyntheticKey is the target key of a synthetic check.
+func SyntheticKey(id uint) string {
+	return "synthetic-" + strconv.FormatUint(uint64(id), 10)
+}
+
+// SyntheticTarget builds the target of a synthetic check. Notifications are addressed
+// by domain, so standalone scenarios use their own name as the domain name.
+func SyntheticTarget(chk database.SyntheticCheck) Target {
+	d := database.MonitoredDomain{DomainName: chk.Name}
+	if chk.DomainID != 0 && database.DB != nil {
+		if err := database.DB.First(&d, chk.DomainID).Error; err != nil {
+			d = database.MonitoredDomain{DomainName: chk.Name}
+		}
+	}
+	return Target{
+		Key:    SyntheticKey(chk.ID),
+		Name:   chk.Name,
+		Domain: d,
+		Object: chk,
+	}
+}
+
+// Kind implements Check.
+func (c *SyntheticCheck) Kind() string { return KindSynthetic }
+
+// Interval implements Check.
+func (c *SyntheticCheck) Interval() time.Duration { return durationOr(c.Every, 30*time.Second) }
+
+// Policy implements Check.
+func (c *SyntheticCheck) Policy() Policy { return Policy{FailureThreshold: 1} }
+
+// Targets implements Check.
+func (c *SyntheticCheck) Targets(ctx context.Context) ([]Target, error) {
+	checks, err := synthetic.DueChecks(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	targets := make([]Target, 0, len(checks))
+	for _, chk := range checks {
+		targets = append(targets, SyntheticTarget(chk))
+	}
+	return targets, nil
+}
+
+// Initial implements Check. A check that never ran counts as passing so that its
+// first failure is reported.
+func (c *SyntheticCheck) Initial(t Target) TargetState {
+	chk, _ := t.Object.(database.SyntheticCheck)
+	if chk.LastRunAt.IsZero() || chk.LastSuccess {
+		return TargetState{Status: StatusUp}
+	}
+	return TargetState{Status: StatusDown}
+}
+
+// Run implements Check.
+func (c *SyntheticCheck) Run(ctx context.Context, t Target) Outcome {
+	chk, ok := t.Object.(database.SyntheticCheck)
+	if !ok {
+		return Outcome{Status: StatusUnknown, Message: "invalid synthetic target"}
+	}
+
+	run, _, err := synthetic.Execute(ctx, chk)
+	if err != nil {
+		log.Printf("Error running synthetic check %s: %v", chk.Name, err)
+		return Outcome{Status: StatusUnknown, Message: err.Error()}
+	}
+
+	out := syntheticOutcome(chk, run)
+	out.Persist = func(tx *gorm.DB) error { return synthetic.SaveState(tx, run) }
+	return out
+}
+
+// syntheticOutcome reports the result of a synthetic run.
+func syntheticOutcome(chk database.SyntheticCheck, run *database.SyntheticRun) Outcome {
+	out := Outcome{
+		Status: StatusUp,
+		Data:   map[string]string{"check": chk.Name},
+		Detail: run,
+	}
+	if !run.Success {
+		out.Status = StatusDown
+		out.Message = fmt.Sprintf("Step %q failed: %s", run.FailedStep, run.Error)
+		out.Data["step"] = run.FailedStep
+		out.Data["step_index"] = fmt.Sprintf("%d", run.FailedStepIndex+1)
+		out.Data["error"] = run.Error
+	}
+	return out
+}
+
+// Probe implements Prober. The run is returned without being stored.
+func (c *SyntheticCheck) Probe(ctx context.Context, t Target) Outcome {
+	chk, ok := t.Object.(database.SyntheticCheck)
+	if !ok {
+		return Outcome{Status: StatusUnknown, Message: "invalid synthetic target"}
+	}
+	return syntheticOutcome(chk, synthetic.Probe(ctx, chk))
+}
+
+// Event implements Check.
+func (c *SyntheticCheck) Event(from, to Status) string {
+	switch {
+	case from == StatusUp && to == StatusDown:
+		return EventSyntheticFailed
+	case from == StatusDown && to == StatusUp:
+		return EventSyntheticRecovered
+	}
+	return ""
+}
+
+func durationOr(d, def time.Duration) time.Duration {
+	if d > 0 {
+		return d
+	}
+	return def
+}
@@ -0,0 +1,179 @@
+package monitor
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+)
+
+// diagnosticBodySize caps how much of the response body a snapshot keeps.
+const diagnosticBodySize = 4 << 10
+
+// Request phases, in order. A failure's phase is the last one the request reached.
+const (
+	PhaseDNS      = "dns"
+	PhaseConnect  = "connect"
+	PhaseTLS      = "tls"
+	PhaseRequest  = "request"
+	PhaseResponse = "response" // Request written, waiting for the response
+	PhaseBody     = "body"     // Response received
+)
+
+// Error classes of diagnostic snapshots. Timeouts are reported as "timeout_<phase>".
+const (
+	ClassDNSNotFound        = "dns_nxdomain"
+	ClassDNSTimeout         = "dns_timeout"
+	ClassDNS                = "dns_error"
+	ClassConnectionRefused  = "connection_refused"
+	ClassConnectionReset    = "connection_reset"
+	ClassNetworkUnreachable = "network_unreachable"
+	ClassCertificate        = "certificate_invalid"
+	ClassTLSAlert           = "tls_alert"
+	ClassTLS                = "tls_error"
+	ClassHTTPStatus         = "http_status"
+	ClassUnknown            = "error"
+)
+
+// Snapshot triggers.
+const (
+	TriggerFailure    = "failure"
+	TriggerTransition = "transition"
+)
+
+// Diagnostic describes what happened during one health check request.
+type Diagnostic struct {
+	URL         string
+	Phase       string
+	ErrorClass  string
+	Error       string
+	ResolvedIPs []string
+	RemoteAddr  string
+	TLSVersion  string
+	TLSError    string
+	StatusCode  int
+	Headers     http.Header
+	Body        string
+}
+
+// fail records a transport error and classifies it by the phase it happened in.
+func (d *Diagnostic) fail(err error) {
+	d.Error = err.Error()
+	d.ErrorClass = classifyError(err, d.Phase)
+	if d.TLSError == "" && d.Phase == PhaseTLS {
+		d.TLSError = err.Error()
+	}
+}
+
+// classifyError maps a transport error to an error class.
+func classifyError(err error, phase string) string {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		switch {
+		case dnsErr.IsNotFound:
+			return ClassDNSNotFound
+		case dnsErr.IsTimeout:
+			return ClassDNSTimeout
+		}
+		return ClassDNS
+	}
+
+	var verifyErr *tls.CertificateVerificationError
+	var hostErr x509.HostnameError
+	var authErr x509.UnknownAuthorityError
+	var invalidErr x509.CertificateInvalidError
+	if errors.As(err, &verifyErr) || errors.As(err, &hostErr) || errors.As(err, &authErr) || errors.As(err, &invalidErr) {
+		return ClassCertificate
+	}
+
+	var alertErr tls.AlertError
+	if errors.As(err, &alertErr) {
+		return ClassTLSAlert
+	}
+	var recordErr tls.RecordHeaderError
+	if errors.As(err, &recordErr) {
+		return ClassTLS
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return "timeout_" + phase
+	}
+
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return ClassConnectionRefused
+	case errors.Is(err, syscall.ECONNRESET):
+		return ClassConnectionReset
+	case errors.Is(err, syscall.ENETUNREACH), errors.Is(err, syscall.EHOSTUNREACH):
+		return ClassNetworkUnreachable
+	}
+
+	if phase == PhaseTLS {
+		return ClassTLS
+	}
+	return ClassUnknown
+}
+
+// Snapshot converts the diagnostic into its database record.
+func (d *Diagnostic) Snapshot(domainID uint, trigger string) database.DiagnosticSnapshot {
+	headers := ""
+	if len(d.Headers) > 0 {
+		if data, err := json.Marshal(d.Headers); err == nil {
+			headers = string(data)
+		}
+	}
+	return database.DiagnosticSnapshot{
+		DomainID:    domainID,
+		Trigger:     trigger,
+		URL:         d.URL,
+		ErrorClass:  d.ErrorClass,
+		Error:       d.Error,
+		Phase:       d.Phase,
+		ResolvedIPs: strings.Join(d.ResolvedIPs, ","),
+		RemoteAddr:  d.RemoteAddr,
+		TLSVersion:  d.TLSVersion,
+		TLSError:    d.TLSError,
+		StatusCode:  d.StatusCode,
+		Headers:     headers,
+		Body:        strings.ToValidUTF8(d.Body, ""),
+		CreatedAt:   time.Now(),
+	}
+}
+
+// saveSnapshot stores a diagnostic and links it from its heartbeat, if any.
+func saveSnapshot(d *Diagnostic, domainID, heartbeatID, incidentID uint, trigger string) (uint, error) {
+	if database.DB == nil {
+		return 0, database.ErrDatabaseNotInitialized
+	}
+
+	snap := d.Snapshot(domainID, trigger)
+	snap.HeartbeatID = heartbeatID
+	snap.IncidentID = incidentID
+	if err := database.DB.Create(&snap).Error; err != nil {
+		return 0, err
+	}
+	if heartbeatID != 0 {
+		if err := database.DB.Model(&database.Heartbeat{}).Where("id = ?", heartbeatID).Update("diagnostic_id", snap.ID).Error; err != nil {
+			return snap.ID, err
+		}
+	}
+	return snap.ID, nil
+}
+
+// CleanupDiagnostics removes snapshots older than the retention period.
+func CleanupDiagnostics(retention time.Duration) (int64, error) {
+	if database.DB == nil {
+		return 0, database.ErrDatabaseNotInitialized
+	}
+	res := database.DB.Where("created_at < ?", time.Now().Add(-retention)).Delete(&database.DiagnosticSnapshot{})
+	return res.RowsAffected, res.Error
+}
@@ -0,0 +1,106 @@
+package monitor
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestClassifyError(t *testing.T) {
+	// Errors as returned by http.Client.Do
+	dial := func(err error) error {
+		return &url.Error{Op: "Get", URL: "https://example.com", Err: &net.OpError{Op: "dial", Net: "tcp", Err: err}}
+	}
+	syscallErr := func(call string, errno syscall.Errno) error { return os.NewSyscallError(call, errno) }
+
+	tests := []struct {
+		name  string
+		err   error
+		phase string
+		want  string
+	}{
+		{"unknown host", dial(&net.DNSError{Err: "no such host", Name: "example.invalid", IsNotFound: true}), PhaseDNS, ClassDNSNotFound},
+		{"DNS timeout", dial(&net.DNSError{Err: "i/o timeout", Name: "example.com", IsTimeout: true}), PhaseDNS, ClassDNSTimeout},
+		{"DNS server failure", dial(&net.DNSError{Err: "server misbehaving", Name: "example.com"}), PhaseDNS, ClassDNS},
+		{"connection refused", dial(syscallErr("connect", syscall.ECONNREFUSED)), PhaseConnect, ClassConnectionRefused},
+		{"connection reset", &url.Error{Op: "Get", Err: &net.OpError{Op: "read", Err: syscallErr("read", syscall.ECONNRESET)}}, PhaseResponse, ClassConnectionReset},
+		{"network unreachable", dial(syscallErr("connect", syscall.ENETUNREACH)), PhaseConnect, ClassNetworkUnreachable},
+		{"host unreachable", dial(syscallErr("connect", syscall.EHOSTUNREACH)), PhaseConnect, ClassNetworkUnreachable},
+		{"connect timeout", dial(os.ErrDeadlineExceeded), PhaseConnect, "timeout_connect"},
+		{"response timeout", &url.Error{Op: "Get", Err: context.DeadlineExceeded}, PhaseResponse, "timeout_response"},
+		{"TLS timeout", &url.Error{Op: "Get", Err: os.ErrDeadlineExceeded}, PhaseTLS, "timeout_tls"},
+		{"untrusted certificate", &url.Error{Op: "Get", Err: &tls.CertificateVerificationError{Err: x509.UnknownAuthorityError{}}}, PhaseTLS, ClassCertificate},
+		{"wrong host name", &url.Error{Op: "Get", Err: x509.HostnameError{Host: "example.com", Certificate: &x509.Certificate{}}}, PhaseTLS, ClassCertificate},
+		{"expired certificate", &url.Error{Op: "Get", Err: x509.CertificateInvalidError{Reason: x509.Expired}}, PhaseTLS, ClassCertificate},
+		{"handshake failure alert", &url.Error{Op: "Get", Err: &net.OpError{Op: "remote error", Err: tls.AlertError(40)}}, PhaseTLS, ClassTLSAlert},
+		{"plain HTTP on the TLS port", &url.Error{Op: "Get", Err: tls.RecordHeaderError{Msg: "first record does not look like a TLS handshake"}}, PhaseTLS, ClassTLS},
+		{"handshake closed", &url.Error{Op: "Get", Err: errors.New("EOF")}, PhaseTLS, ClassTLS},
+		{"other error", &url.Error{Op: "Get", Err: errors.New("EOF")}, PhaseResponse, ClassUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyError(tt.err, tt.phase); got != tt.want {
+				t.Errorf("classifyError(%v, %s) = %s, want %s", tt.err, tt.phase, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckHealthDiagnostic(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		case "/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/slow":
+			<-r.Context().Done()
+		}
+	}))
+	defer srv.Close()
+	// The server logs the handshake the client aborts
+	tlsSrv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	tlsSrv.Config.ErrorLog = log.New(io.Discard, "", 0)
+	tlsSrv.StartTLS()
+	defer tlsSrv.Close()
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closed.Close()
+
+	tests := []struct {
+		name   string
+		url    string
+		status int
+		phase  string
+		class  string
+	}{
+		{"up", srv.URL + "/", 200, PhaseBody, ""},
+		{"HTTP 4xx", srv.URL + "/missing", 404, PhaseBody, ClassHTTPStatus},
+		{"HTTP 5xx", srv.URL + "/unavailable", 503, PhaseBody, ClassHTTPStatus},
+		{"timeout", srv.URL + "/slow", 0, PhaseResponse, "timeout_response"},
+		{"connection refused", closed.URL, 0, PhaseConnect, ClassConnectionRefused},
+		{"untrusted certificate", tlsSrv.URL, 0, PhaseTLS, ClassCertificate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+			defer cancel()
+			res := checkHealthWithTrace(ctx, &http.Client{}, tt.url, "example.com")
+			d := res.Diagnostic
+			if res.StatusCode != tt.status || d.Phase != tt.phase || d.ErrorClass != tt.class || res.IsLive != (tt.class == "") {
+				t.Errorf("status %d, live %v, phase %s, class %q (%s); want status %d, phase %s, class %q",
+					res.StatusCode, res.IsLive, d.Phase, d.ErrorClass, d.Error, tt.status, tt.phase, tt.class)
+			}
+		})
+	}
+}
@@ -0,0 +1,553 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"github.com/harveywai/zenstack/pkg/metrics"
+	"gorm.io/gorm"
+)
+
+// Target is a single thing a check observes, usually a monitored domain.
+type Target struct {
+	Key    string                   // Unique key within a check kind, e.g. the domain ID
+	Name   string                   // Human-readable name used in logs and events
+	Domain database.MonitoredDomain // Domain the target belongs to; only DomainName is set for standalone targets
+	Object interface{}              // Check-specific payload, e.g. a synthetic check definition
+}
+
+// Outcome is the result of running a check against a target once.
+type Outcome struct {
+	Status  Status
+	Message string            // Short human-readable explanation, used in logs and incidents
+	Data    map[string]string // Template variables passed along with events
+	Detail  interface{}       // Check-specific result, e.g. the stored synthetic run
+	Derived []Derived         // Outcomes of other checks observed while running this one
+
+	// Persist writes the state the outcome changes, e.g. whether a site is live, which
+	// Initial restores the state machine from. The sink applies it in the transaction
+	// queueing the event of a transition, so that both are committed or neither;
+	// without an event the engine applies it.
+	Persist func(tx *gorm.DB) error
+}
+
+// Derived is an outcome for another check kind and the same target, e.g. a latency
+// anomaly found by an HTTP check. It goes through the state machine of that check.
+type Derived struct {
+	Kind    string
+	Outcome Outcome
+}
+
+// Policy tunes how the state machine reacts to outcomes of a check.
+type Policy struct {
+	FailureThreshold int           // Consecutive failing outcomes before the target is considered failing (default 1)
+	RepeatInterval   time.Duration // Re-emit the failing event while the failure persists (0 = never)
+	EveryFailure     bool          // Emit an event for every failing outcome; for checks reporting discrete occurrences
+}
+
+// Check is one kind of monitoring (HTTP, SSL, content, synthetic, ...). The engine
+// schedules it, runs it on a bounded worker pool and feeds its outcomes through
+// one state machine per target.
+type Check interface {
+	// Kind is the unique name of the check, e.g. "http".
+	Kind() string
+	// Interval is how often the targets of the check are scheduled, or 0 for checks
+	// that only receive outcomes derived from other checks.
+	Interval() time.Duration
+	// Policy returns the state machine settings of the check.
+	Policy() Policy
+	// Targets returns the targets to check in the current round.
+	Targets(ctx context.Context) ([]Target, error)
+	// Initial restores the state of a target from persisted data on first observation.
+	Initial(t Target) TargetState
+	// Run probes the target and reports the outcome. Writes of the state Initial reads
+	// belong in Outcome.Persist.
+	Run(ctx context.Context, t Target) Outcome
+	// Event names the event emitted for a transition, or "" for none.
+	Event(from, to Status) string
+}
+
+// Event is emitted whenever a target changes state in a way its check cares about,
+// and again every RepeatInterval while a failure persists.
+type Event struct {
+	Name    string
+	Kind    string
+	Target  Target
+	From    Status
+	To      Status
+	Repeat  bool
+	Outcome Outcome
+	At      time.Time
+}
+
+// Sink receives the events of the engine. It must apply ev.Outcome.Persist along with
+// its own writes. When it returns an error, the engine forgets the state of the
+// target, so that the next run restores it from the database and emits the event
+// again.
+type Sink interface {
+	Handle(ctx context.Context, ev Event) error
+}
+
+// SinkFunc adapts a function to the Sink interface.
+type SinkFunc func(ctx context.Context, ev Event) error
+
+// Handle implements Sink.
+func (f SinkFunc) Handle(ctx context.Context, ev Event) error { return f(ctx, ev) }
+
+// Options configures an Engine.
+type Options struct {
+	Workers   int              // Number of concurrent check workers (default 5)
+	QueueSize int              // Capacity of the job queue (default 1024)
+	Sink      Sink             // Receives events; events are dropped when nil
+	Now       func() time.Time // Clock, overridable in tests
+}
+
+type job struct {
+	check  Check
+	target Target
+	slot   chan struct{} // Inflight slot of the target, released once processed
+	done   func()
+}
+
+type task struct {
+	name     string
+	interval time.Duration
+	fn       func(ctx context.Context)
+}
+
+// Engine schedules checks, runs them with bounded concurrency and turns their
+// outcomes into events through a per-target state machine.
+type Engine struct {
+	workers int
+	queue   chan job
+	sink    Sink
+	now     func() time.Time
+
+	mu       sync.Mutex
+	running  bool // Run is active, i.e. this replica is the leader
+	checks   map[string]Check
+	order    []string
+	tasks    []task
+	states   map[string]*TargetState
+	inflight map[string]chan struct{} // Closed when the target's queued or running check finishes
+}
+
+// New creates an engine. Checks and tasks must be registered before Run.
+func New(opts Options) *Engine {
+	if opts.Workers <= 0 {
+		opts.Workers = 5
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1024
+	}
+	if opts.Now == nil {
+		opts.Now = time.Now
+	}
+	return &Engine{
+		workers:  opts.Workers,
+		queue:    make(chan job, opts.QueueSize),
+		sink:     opts.Sink,
+		now:      opts.Now,
+		checks:   make(map[string]Check),
+		states:   make(map[string]*TargetState),
+		inflight: make(map[string]chan struct{}),
+	}
+}
+
+// Register adds a check to the engine.
+func (e *Engine) Register(c Check) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, exists := e.checks[c.Kind()]; !exists {
+		e.order = append(e.order, c.Kind())
+	}
+	e.checks[c.Kind()] = c
+}
+
+// AddTask registers a housekeeping function run every interval, e.g. data retention.
+func (e *Engine) AddTask(name string, interval time.Duration, fn func(ctx context.Context)) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.tasks = append(e.tasks, task{name: name, interval: interval, fn: fn})
+}
+
+// Run starts the workers, the per-check schedulers and the tasks, and blocks until
+// ctx is cancelled. In-flight checks see the cancelled context and stop early.
+// Run may be called again after it returned, e.g. when a replica regains leadership;
+// target states are then restored from the database again since another replica
+// may have run the checks in the meantime.
+func (e *Engine) Run(ctx context.Context) {
+	e.reset()
+	e.setRunning(true)
+	defer func() {
+		e.setRunning(false)
+		e.reset()
+	}()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < e.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			e.work(ctx)
+		}()
+	}
+
+	e.mu.Lock()
+	checks := make([]Check, 0, len(e.order))
+	for _, kind := range e.order {
+		checks = append(checks, e.checks[kind])
+	}
+	tasks := append([]task(nil), e.tasks...)
+	e.mu.Unlock()
+
+	for _, c := range checks {
+		if c.Interval() <= 0 {
+			continue
+		}
+		wg.Add(1)
+		go func(c Check) {
+			defer wg.Done()
+			e.every(ctx, c.Interval(), func() { e.schedule(ctx, c) })
+		}(c)
+	}
+	for _, t := range tasks {
+		wg.Add(1)
+		go func(t task) {
+			defer wg.Done()
+			e.every(ctx, t.interval, func() { t.fn(ctx) })
+		}(t)
+	}
+
+	log.Printf("Monitoring engine started with %d checks and %d workers", len(checks), e.workers)
+	wg.Wait()
+	log.Println("Monitoring engine stopped")
+}
+
+// reset drops queued jobs and all target states.
+func (e *Engine) reset() {
+	for {
+		select {
+		case j := <-e.queue:
+			e.release(stateKey(j.check.Kind(), j.target.Key), j.slot)
+			if j.done != nil {
+				j.done()
+			}
+			continue
+		default:
+		}
+		break
+	}
+
+	e.mu.Lock()
+	e.states = make(map[string]*TargetState)
+	e.mu.Unlock()
+	metrics.QueueDepth.WithLabelValues("monitor").Set(0)
+}
+
+func (e *Engine) setRunning(running bool) {
+	e.mu.Lock()
+	e.running = running
+	e.mu.Unlock()
+}
+
+// Running reports whether Run is active. With leader election, only the leader runs
+// the engine.
+func (e *Engine) Running() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.running
+}
+
+// every runs fn immediately and then on every tick until ctx is cancelled.
+func (e *Engine) every(ctx context.Context, interval time.Duration, fn func()) {
+	fn()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fn()
+		}
+	}
+}
+
+// schedule enqueues one round of a check and records the round duration once all
+// of its jobs finished.
+func (e *Engine) schedule(ctx context.Context, c Check) {
+	targets, err := c.Targets(ctx)
+	if err != nil {
+		log.Printf("Error loading targets for %s check: %v", c.Kind(), err)
+		return
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	start := e.now()
+	var round sync.WaitGroup
+	for _, t := range targets {
+		round.Add(1)
+		if !e.enqueue(ctx, job{check: c, target: t, done: round.Done}) {
+			round.Done()
+		}
+	}
+
+	go func() {
+		round.Wait()
+		metrics.ScanDuration.WithLabelValues(c.Kind()).Observe(e.now().Sub(start).Seconds())
+	}()
+}
+
+// enqueue adds a job unless the same target is already queued or running for the check.
+func (e *Engine) enqueue(ctx context.Context, j job) bool {
+	key := stateKey(j.check.Kind(), j.target.Key)
+	slot, ok := e.claim(ctx, key, false)
+	if !ok {
+		return false
+	}
+	j.slot = slot
+
+	select {
+	case e.queue <- j:
+		metrics.QueueDepth.WithLabelValues("monitor").Set(float64(len(e.queue)))
+		return true
+	case <-ctx.Done():
+		e.release(key, slot)
+		return false
+	}
+}
+
+// claim takes the inflight slot of a target, so that one check of it is queued or
+// running at a time. When the slot is taken, it waits for it to be released if wait
+// is set, until ctx is done, and fails otherwise.
+func (e *Engine) claim(ctx context.Context, key string, wait bool) (chan struct{}, bool) {
+	for {
+		e.mu.Lock()
+		busy, taken := e.inflight[key]
+		if !taken {
+			slot := make(chan struct{})
+			e.inflight[key] = slot
+			e.mu.Unlock()
+			return slot, true
+		}
+		e.mu.Unlock()
+
+		if !wait {
+			return nil, false
+		}
+		select {
+		case <-busy:
+		case <-ctx.Done():
+			return nil, false
+		}
+	}
+}
+
+// release frees an inflight slot taken by claim.
+func (e *Engine) release(key string, slot chan struct{}) {
+	e.mu.Lock()
+	if e.inflight[key] == slot {
+		delete(e.inflight, key)
+	}
+	e.mu.Unlock()
+	close(slot)
+}
+
+// Enqueue schedules an out-of-band check of a target, e.g. from an API call.
+// It reports false when the target is already queued or the engine is stopping.
+func (e *Engine) Enqueue(ctx context.Context, kind string, t Target) bool {
+	c, ok := e.check(kind)
+	if !ok {
+		return false
+	}
+	return e.enqueue(ctx, job{check: c, target: t})
+}
+
+func (e *Engine) work(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case j := <-e.queue:
+			metrics.QueueDepth.WithLabelValues("monitor").Set(float64(len(e.queue)))
+			e.process(ctx, j.check, j.target)
+			e.release(stateKey(j.check.Kind(), j.target.Key), j.slot)
+			if j.done != nil {
+				j.done()
+			}
+		}
+	}
+}
+
+// ErrNotLeader is returned by RunNow for checks that can't run without recording
+// their results while another replica leads.
+var ErrNotLeader = errors.New("checks of this kind only run on the leader")
+
+// Prober is implemented by checks that can run without recording anything.
+type Prober interface {
+	// Probe runs the check like Run, but writes nothing and leaves Persist and
+	// Derived unset.
+	Probe(ctx context.Context, t Target) Outcome
+}
+
+// RunNow runs a check against a target synchronously, bypassing the queue. While the
+// engine runs, it waits for any queued or running check of the target, and its
+// outcome goes through the state machine and event pipeline like a scheduled check.
+// Otherwise another replica leads and owns the target states and the records of the
+// checks, so the check is only probed and its outcome returned; checks that aren't
+// Probers fail with ErrNotLeader.
+func (e *Engine) RunNow(ctx context.Context, kind string, t Target) (Outcome, error) {
+	c, ok := e.check(kind)
+	if !ok {
+		return Outcome{}, fmt.Errorf("unknown check kind: %s", kind)
+	}
+	if !e.Running() {
+		p, ok := c.(Prober)
+		if !ok {
+			return Outcome{}, ErrNotLeader
+		}
+		return p.Probe(ctx, t), nil
+	}
+
+	key := stateKey(kind, t.Key)
+	slot, ok := e.claim(ctx, key, true)
+	if !ok {
+		return Outcome{}, ctx.Err()
+	}
+	defer e.release(key, slot)
+	return e.process(ctx, c, t), nil
+}
+
+func (e *Engine) check(kind string) (Check, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c, ok := e.checks[kind]
+	return c, ok
+}
+
+// process runs one check, advances the target's state machine and emits the resulting
+// events, including those of outcomes derived for other checks.
+func (e *Engine) process(ctx context.Context, c Check, t Target) Outcome {
+	// The initial state is restored before running, since Run persists the new result
+	e.restore(c, t)
+
+	out := c.Run(ctx, t)
+	if ctx.Err() != nil {
+		// Results of cancelled checks are not trustworthy (timeouts look like outages)
+		return out
+	}
+
+	if !e.observe(ctx, c, t, out) {
+		persist(t, out)
+	}
+	for _, d := range out.Derived {
+		dc, ok := e.check(d.Kind)
+		if !ok {
+			continue
+		}
+		e.restore(dc, t)
+		if !e.observe(ctx, dc, t, d.Outcome) {
+			persist(t, d.Outcome)
+		}
+	}
+	return out
+}
+
+// persist applies the writes of an outcome no event was emitted for.
+func persist(t Target, out Outcome) {
+	if out.Persist == nil || database.DB == nil {
+		return
+	}
+	if err := database.DB.Transaction(out.Persist); err != nil {
+		log.Printf("Error saving check result for %s: %v", t.Name, err)
+	}
+}
+
+// restore initializes the state of a target on first observation.
+func (e *Engine) restore(c Check, t Target) {
+	key := stateKey(c.Kind(), t.Key)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, ok := e.states[key]; !ok {
+		initial := c.Initial(t)
+		e.states[key] = &initial
+	}
+}
+
+// observe feeds an outcome into the state machine of a target and emits the event
+// of the resulting transition, if any. It reports whether an event was emitted, which
+// leaves persisting the outcome to the sink.
+func (e *Engine) observe(ctx context.Context, c Check, t Target, out Outcome) bool {
+	key := stateKey(c.Kind(), t.Key)
+	e.mu.Lock()
+	st, ok := e.states[key]
+	if !ok {
+		// Forgotten while running, e.g. the domain was deleted
+		e.mu.Unlock()
+		return false
+	}
+	tr := st.advance(out, c.Policy(), e.now())
+	e.mu.Unlock()
+
+	if !tr.emit {
+		return false
+	}
+
+	name := c.Event(tr.from, tr.to)
+	if name == "" || e.sink == nil {
+		return false
+	}
+
+	err := e.sink.Handle(ctx, Event{
+		Name:    name,
+		Kind:    c.Kind(),
+		Target:  t,
+		From:    tr.from,
+		To:      tr.to,
+		Repeat:  tr.repeat,
+		Outcome: out,
+		At:      e.now(),
+	})
+	if err != nil {
+		log.Printf("Error handling %s event for %s: %v", name, t.Name, err)
+		e.mu.Lock()
+		if e.states[key] == st {
+			delete(e.states, key)
+		}
+		e.mu.Unlock()
+	}
+	return true
+}
+
+// State returns the current state of a target for a check kind.
+func (e *Engine) State(kind, key string) (TargetState, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	st, ok := e.states[stateKey(kind, key)]
+	if !ok {
+		return TargetState{}, false
+	}
+	return *st, true
+}
+
+// Forget drops all state of a target, e.g. after its domain was deleted.
+func (e *Engine) Forget(key string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for kind := range e.checks {
+		delete(e.states, stateKey(kind, key))
+	}
+}
+
+func stateKey(kind, key string) string {
+	return kind + "/" + key
+}
@@ -0,0 +1,280 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openTestDB(t *testing.T, models ...interface{}) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	prev := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = prev })
+}
+
+// runEngine runs an engine, as on the leader, until the test ends.
+func runEngine(t *testing.T, e *Engine) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		e.Run(ctx)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-done
+	})
+	for !e.Running() {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// fakeCheck reports the queued outcomes in order and restores its state from live,
+// like HTTPCheck restores it from the is_live column.
+type fakeCheck struct {
+	outcomes []Status
+	live     bool
+	persists int
+}
+
+func (c *fakeCheck) Kind() string                                  { return "fake" }
+func (c *fakeCheck) Interval() time.Duration                       { return 0 }
+func (c *fakeCheck) Policy() Policy                                { return Policy{FailureThreshold: 1} }
+func (c *fakeCheck) Targets(ctx context.Context) ([]Target, error) { return nil, nil }
+
+func (c *fakeCheck) Initial(t Target) TargetState {
+	if c.live {
+		return TargetState{Status: StatusUp}
+	}
+	return TargetState{Status: StatusDown}
+}
+
+func (c *fakeCheck) Run(ctx context.Context, t Target) Outcome {
+	status := c.outcomes[0]
+	c.outcomes = c.outcomes[1:]
+	return Outcome{Status: status, Persist: func(tx *gorm.DB) error {
+		c.persists++
+		c.live = status == StatusUp
+		return nil
+	}}
+}
+
+func (c *fakeCheck) Event(from, to Status) string {
+	if from == StatusUp && to == StatusDown {
+		return EventSiteDown
+	}
+	return ""
+}
+
+func TestEnginePersistsOutcomesWithoutEvent(t *testing.T) {
+	openTestDB(t)
+
+	check := &fakeCheck{outcomes: []Status{StatusUp, StatusUp}, live: true}
+	e := New(Options{Sink: SinkFunc(func(ctx context.Context, ev Event) error {
+		t.Errorf("unexpected event %s", ev.Name)
+		return nil
+	})})
+	e.Register(check)
+	runEngine(t, e)
+
+	for i := 0; i < 2; i++ {
+		if _, err := e.RunNow(context.Background(), "fake", Target{Key: "1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if check.persists != 2 {
+		t.Errorf("persisted %d outcomes, want 2", check.persists)
+	}
+}
+
+func TestEngineRepeatsEventsTheSinkFailed(t *testing.T) {
+	openTestDB(t)
+
+	check := &fakeCheck{outcomes: []Status{StatusDown, StatusDown, StatusDown}, live: true}
+	var events int
+	e := New(Options{Sink: SinkFunc(func(ctx context.Context, ev Event) error {
+		events++
+		if events == 1 {
+			// The transaction queueing the notification failed, so the state wasn't saved
+			return errors.New("database is locked")
+		}
+		return database.DB.Transaction(ev.Outcome.Persist)
+	})})
+	e.Register(check)
+	runEngine(t, e)
+
+	for i := 0; i < 3; i++ {
+		if _, err := e.RunNow(context.Background(), "fake", Target{Key: "1"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if events != 2 {
+		t.Errorf("got %d events, want 2 (the failed one and its retry)", events)
+	}
+	if check.live {
+		t.Error("the down state was not saved along with the retried event")
+	}
+	if st, _ := e.State("fake", "1"); st.Status != StatusDown {
+		t.Errorf("state = %s, want down", st.Status)
+	}
+}
+
+func TestRunNowOnFollowerRecordsNothing(t *testing.T) {
+	openTestDB(t, &database.MonitoredDomain{}, &database.Heartbeat{}, &database.DiagnosticSnapshot{},
+		&database.LatencyBaseline{}, &database.LatencyAnomaly{}, &database.DailyUptime{})
+
+	status := http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	e := New(Options{Sink: SinkFunc(func(ctx context.Context, ev Event) error {
+		t.Errorf("unexpected event %s", ev.Name)
+		return nil
+	})})
+	e.Register(&HTTPCheck{Timeout: 2 * time.Second})
+	d := database.MonitoredDomain{DomainName: strings.TrimPrefix(srv.URL, "http://"), IsLive: true}
+	d.ID = 1
+	if err := database.DB.Create(&d).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		code int
+		want Status
+	}{{http.StatusOK, StatusUp}, {http.StatusServiceUnavailable, StatusDown}} {
+		status = tt.code
+		out, err := e.RunNow(context.Background(), KindHTTP, DomainTarget(d))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.Status != tt.want {
+			t.Errorf("%d: status = %s, want %s", tt.code, out.Status, tt.want)
+		}
+		if out.Persist != nil || out.Derived != nil {
+			t.Errorf("%d: the probe returned records to save", tt.code)
+		}
+	}
+
+	for _, model := range []interface{}{&database.Heartbeat{}, &database.DiagnosticSnapshot{},
+		&database.LatencyBaseline{}, &database.LatencyAnomaly{}, &database.DailyUptime{}} {
+		var n int64
+		database.DB.Model(model).Count(&n)
+		if n != 0 {
+			t.Errorf("the follower wrote %d %T rows", n, model)
+		}
+	}
+	var saved database.MonitoredDomain
+	if database.DB.First(&saved, d.ID); !saved.IsLive {
+		t.Error("the follower updated the domain")
+	}
+	if _, ok := e.State(KindHTTP, DomainTarget(d).Key); ok {
+		t.Error("the follower recorded a target state")
+	}
+}
+
+func TestRunNowOnFollowerRefusesChecksThatRecord(t *testing.T) {
+	openTestDB(t)
+	e := New(Options{})
+	e.Register(&fakeCheck{outcomes: []Status{StatusDown}})
+
+	if _, err := e.RunNow(context.Background(), "fake", Target{Key: "1"}); !errors.Is(err, ErrNotLeader) {
+		t.Errorf("got %v, want ErrNotLeader", err)
+	}
+}
+
+// gatedCheck blocks in Run until released and records the most runs at once.
+type gatedCheck struct {
+	fakeCheck
+	started chan struct{}
+	gate    chan struct{} // Lets one run finish, or all once closed
+
+	mu            sync.Mutex
+	running, most int
+}
+
+func (c *gatedCheck) Run(ctx context.Context, t Target) Outcome {
+	c.mu.Lock()
+	c.running++
+	c.most = max(c.most, c.running)
+	c.mu.Unlock()
+	c.started <- struct{}{}
+	<-c.gate
+
+	c.mu.Lock()
+	c.running--
+	c.mu.Unlock()
+	return Outcome{Status: StatusUp}
+}
+
+func TestRunNowWaitsForTheQueuedCheck(t *testing.T) {
+	openTestDB(t)
+	check := &gatedCheck{fakeCheck: fakeCheck{live: true}, started: make(chan struct{}, 2), gate: make(chan struct{})}
+	e := New(Options{})
+	e.Register(check)
+	runEngine(t, e)
+	// Release checks still blocked when the test fails, before the engine stops
+	t.Cleanup(func() { close(check.gate) })
+
+	if !e.Enqueue(context.Background(), "fake", Target{Key: "1"}) {
+		t.Fatal("the check was not queued")
+	}
+	<-check.started
+
+	done := make(chan error)
+	go func() {
+		_, err := e.RunNow(context.Background(), "fake", Target{Key: "1"})
+		done <- err
+	}()
+	select {
+	case <-check.started:
+		t.Fatal("RunNow ran while the queued check was running")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if e.Enqueue(context.Background(), "fake", Target{Key: "1"}) {
+		t.Error("queued a check of a busy target")
+	}
+
+	check.gate <- struct{}{}
+	<-check.started
+	check.gate <- struct{}{}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if check.most != 1 {
+		t.Errorf("ran %d checks of the target at once", check.most)
+	}
+
+	// A cancelled caller stops waiting
+	if !e.Enqueue(context.Background(), "fake", Target{Key: "1"}) {
+		t.Fatal("the check was not queued")
+	}
+	<-check.started
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := e.RunNow(ctx, "fake", Target{Key: "1"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("got %v, want the context error", err)
+	}
+	check.gate <- struct{}{}
+}
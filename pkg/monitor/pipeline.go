@@ -0,0 +1,221 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"github.com/harveywai/zenstack/pkg/incident"
+	"github.com/harveywai/zenstack/pkg/notify"
+	"github.com/harveywai/zenstack/pkg/oncall"
+	"github.com/harveywai/zenstack/pkg/synthetic"
+	"gorm.io/gorm"
+)
+
+// Events emitted by the built-in checks. Each has a default message template.
+const (
+	EventSiteDown           = "SITE_DOWN"
+	EventSiteUp             = "SITE_UP"
+	EventSSLCritical        = "SSL_CRITICAL"
+	EventSSLRenewed         = "SSL_RENEWED"
+	EventContentChanged     = "CONTENT_CHANGED"
+	EventSyntheticFailed    = "SYNTHETIC_FAILED"
+	EventSyntheticRecovered = "SYNTHETIC_RECOVERED"
+	EventLatencyAnomaly     = "LATENCY_ANOMALY"
+)
+
+// Dispatch is the default event pipeline. It keeps automatic incidents in sync with
+// site availability, pages on-call escalation policies and sends every event to the
+// notification outbox of the routed channels. The state change of the event is saved
+// in the transaction queueing its notification.
+func Dispatch(ctx context.Context, ev Event) error {
+	log.Printf("Monitor event %s for %s (%s -> %s)", ev.Name, ev.Target.Name, ev.From, ev.To)
+
+	notifyEvent := true
+	switch ev.Name {
+	case EventSiteDown:
+		inc, err := incident.OpenForDomain(ev.Target.Domain, ev.Outcome.Message)
+		if err != nil {
+			log.Printf("Error opening incident for domain %s: %v", ev.Target.Name, err)
+		}
+		attachSnapshot(ev, inc)
+	case EventSiteUp:
+		inc, err := incident.ResolveForDomain(ev.Target.Domain.ID, "Service recovered")
+		if err != nil {
+			log.Printf("Error resolving incident for domain %s: %v", ev.Target.Name, err)
+		}
+		attachSnapshot(ev, inc)
+	case EventLatencyAnomaly:
+		// Anomalies are always visible on the heartbeat chart; notifying is opt-in per domain
+		notifyEvent = ev.Target.Domain.AnomalyAlerts
+	}
+
+	if database.DB == nil {
+		return nil
+	}
+
+	// Queue the notification together with the state change and the certificate
+	// reminder it sends, so restarts neither lose nor re-send events and reminder stages
+	claimed := true
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if ev.Outcome.Persist != nil {
+			if err := ev.Outcome.Persist(tx); err != nil {
+				return err
+			}
+		}
+		if !notifyEvent {
+			return nil
+		}
+		if due, ok := ev.Outcome.Detail.(reminderDue); ok && ev.Kind == KindSSLReminder {
+			var err error
+			if claimed, err = claimReminder(tx, ev.Target.Domain.ID, due, ev.At); err != nil || !claimed {
+				return err
+			}
+			err = tx.Model(&database.MonitoredDomain{}).
+				Where("id = ?", ev.Target.Domain.ID).
+				Update("last_notification_sent", ev.At).Error
+			if err != nil {
+				return err
+			}
+		}
+		err := notify.EnqueueNotification(tx, ev.Name, ev.Target.Domain, ev.Outcome.Data)
+		if errors.Is(err, notify.ErrNoChannel) || errors.Is(err, notify.ErrNoTemplate) {
+			// Retrying wouldn't help; the state change is kept and reminder stages count as sent
+			log.Printf("Not notifying %s for %s: %v", ev.Name, ev.Target.Name, err)
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to queue %s notification: %w", ev.Name, err)
+	}
+	if !notifyEvent {
+		return nil
+	}
+	if !claimed {
+		log.Printf("Reminder of %s for %s was already sent", ev.Name, ev.Target.Name)
+		return nil
+	}
+
+	// Page the escalation policies of matching routing rules, or resolve their escalations
+	if err := oncall.HandleEvent(ctx, ev.Name, ev.Target.Domain, ev.Outcome.Data); err != nil {
+		log.Printf("Error escalating %s for %s: %v", ev.Name, ev.Target.Name, err)
+	}
+	return nil
+}
+
+// attachSnapshot records the diagnostic of an up/down transition and links it to the
+// incident. The snapshot of a failed check already exists and is relinked.
+func attachSnapshot(ev Event, inc *database.Incident) {
+	res, ok := ev.Outcome.Detail.(HealthCheckResult)
+	if !ok || res.Diagnostic == nil {
+		return
+	}
+
+	var incidentID uint
+	if inc != nil {
+		incidentID = inc.ID
+	}
+
+	if res.SnapshotID != 0 {
+		err := database.DB.Model(&database.DiagnosticSnapshot{}).Where("id = ?", res.SnapshotID).
+			Updates(map[string]interface{}{"trigger": TriggerTransition, "incident_id": incidentID}).Error
+		if err != nil {
+			log.Printf("Error linking diagnostic snapshot for domain %s: %v", ev.Target.Name, err)
+		}
+		return
+	}
+
+	if _, err := saveSnapshot(res.Diagnostic, ev.Target.Domain.ID, res.HeartbeatID, incidentID, TriggerTransition); err != nil {
+		log.Printf("Error saving diagnostic snapshot for domain %s: %v", ev.Target.Name, err)
+	}
+}
+
+// NewDefault creates an engine with all built-in checks and housekeeping tasks,
+// sending events through Dispatch unless opts.Sink is set.
+func NewDefault(opts Options) *Engine {
+	if opts.Sink == nil {
+		opts.Sink = SinkFunc(Dispatch)
+	}
+
+	e := New(opts)
+	e.Register(&HTTPCheck{})
+	e.Register(&SSLCheck{})
+	e.Register(&ContentCheck{})
+	e.Register(&SyntheticCheck{})
+	e.Register(&LatencyCheck{})
+	e.Register(&ReminderCheck{})
+
+	e.AddTask("notification-outbox", 5*time.Second, func(ctx context.Context) { notify.ProcessOutbox(ctx) })
+	e.AddTask("notification-groups", 5*time.Second, func(ctx context.Context) { notify.FlushGroups(ctx) })
+	e.AddTask("digests", time.Minute, func(ctx context.Context) { notify.ProcessDigests(ctx) })
+	e.AddTask("escalations", 30*time.Second, oncall.Tick)
+	e.AddTask("heartbeat-cleanup", 6*time.Hour, func(ctx context.Context) { CleanupHeartbeats(24 * time.Hour) })
+	e.AddTask("diagnostic-cleanup", 6*time.Hour, func(ctx context.Context) {
+		if n, err := CleanupDiagnostics(7 * 24 * time.Hour); err != nil {
+			log.Printf("Error cleaning up diagnostic snapshots: %v", err)
+		} else if n > 0 {
+			log.Printf("Cleaned up %d old diagnostic snapshots (older than 7 days)", n)
+		}
+	})
+	e.AddTask("outbox-cleanup", 6*time.Hour, func(ctx context.Context) {
+		if n, err := notify.CleanupOutbox(7 * 24 * time.Hour); err != nil {
+			log.Printf("Error cleaning up notification outbox: %v", err)
+		} else if n > 0 {
+			log.Printf("Cleaned up %d delivered notifications (older than 7 days)", n)
+		}
+	})
+	e.AddTask("delivery-cleanup", 6*time.Hour, func(ctx context.Context) {
+		if n, err := notify.CleanupDeliveries(30 * 24 * time.Hour); err != nil {
+			log.Printf("Error cleaning up delivery history: %v", err)
+		} else if n > 0 {
+			log.Printf("Cleaned up %d delivery attempts (older than 30 days)", n)
+		}
+	})
+	e.AddTask("suppressed-cleanup", 6*time.Hour, func(ctx context.Context) {
+		if n, err := notify.CleanupSuppressed(30 * 24 * time.Hour); err != nil {
+			log.Printf("Error cleaning up suppressed events: %v", err)
+		} else if n > 0 {
+			log.Printf("Cleaned up %d suppressed events (older than 30 days)", n)
+		}
+	})
+	e.AddTask("reminder-cleanup", 24*time.Hour, func(ctx context.Context) {
+		if n, err := CleanupReminders(365 * 24 * time.Hour); err != nil {
+			log.Printf("Error cleaning up certificate reminders: %v", err)
+		} else if n > 0 {
+			log.Printf("Cleaned up %d reminders of certificates expired for over a year", n)
+		}
+	})
+	e.AddTask("synthetic-cleanup", time.Hour, func(ctx context.Context) {
+		if n, err := synthetic.Cleanup(7 * 24 * time.Hour); err != nil {
+			log.Printf("Error cleaning up synthetic runs: %v", err)
+		} else if n > 0 {
+			log.Printf("Cleaned up %d old synthetic runs (older than 7 days)", n)
+		}
+	})
+	return e
+}
+
+// CleanupHeartbeats removes heartbeats older than the retention period to keep
+// chart queries fast. Long-range uptime is kept in DailyUptime.
+func CleanupHeartbeats(retention time.Duration) {
+	if database.DB == nil {
+		return
+	}
+
+	cutoff := time.Now().Add(-retention)
+	result := database.DB.Where("created_at < ?", cutoff).Delete(&database.Heartbeat{})
+	if result.Error != nil {
+		log.Printf("Error cleaning up old heartbeats: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		log.Printf("Cleaned up %d old heartbeats (older than %s)", result.RowsAffected, retention)
+	}
+
+	// Anomaly markers belong to heartbeats and share their retention
+	if _, err := CleanupLatencyAnomalies(retention); err != nil {
+		log.Printf("Error cleaning up old latency anomalies: %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"gorm.io/gorm"
+)
+
+func TestDispatchKeepsStateUnsavedWhenEnqueueFails(t *testing.T) {
+	// The outbox table is created once the first dispatch failed
+	openTestDB(t, &database.MonitoredDomain{}, &database.Incident{}, &database.IncidentUpdate{},
+		&database.Channel{}, &database.MessageTemplate{}, &database.TemplateVariant{}, &database.RoutingRule{},
+		&database.Silence{}, &database.SuppressedEvent{}, &database.EscalationPolicy{}, &database.Escalation{})
+
+	d := database.MonitoredDomain{DomainName: "example.com", IsLive: true}
+	d.ID = 1
+	for _, row := range []interface{}{
+		&d,
+		&database.Channel{Type: "webhook", Name: "ops", IsActive: true, Settings: `{"platform":"webhook","url":"http://127.0.0.1:1/hook"}`},
+		&database.MessageTemplate{Name: "SiteDown", EventName: EventSiteDown, TitleTemplate: "Site down", BodyTemplate: "{{domain}} is down"},
+	} {
+		if err := database.DB.Create(row).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ev := Event{Name: EventSiteDown, Kind: KindHTTP, Target: DomainTarget(d), From: StatusUp, To: StatusDown, Outcome: Outcome{
+		Status: StatusDown,
+		Persist: func(tx *gorm.DB) error {
+			return tx.Model(&database.MonitoredDomain{}).Where("id = ?", d.ID).Update("is_live", false).Error
+		},
+	}}
+	isLive := func() bool {
+		var saved database.MonitoredDomain
+		if err := database.DB.First(&saved, d.ID).Error; err != nil {
+			t.Fatal(err)
+		}
+		return saved.IsLive
+	}
+
+	if err := Dispatch(context.Background(), ev); err == nil {
+		t.Fatal("dispatched without an outbox")
+	}
+	if !isLive() {
+		t.Error("the down state was saved without its notification")
+	}
+
+	if err := database.DB.AutoMigrate(&database.OutboxMessage{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Dispatch(context.Background(), ev); err != nil {
+		t.Fatal(err)
+	}
+	if isLive() {
+		t.Error("the down state was not saved along with its notification")
+	}
+	var queued int64
+	database.DB.Model(&database.OutboxMessage{}).Where("event = ?", EventSiteDown).Count(&queued)
+	if queued != 1 {
+		t.Errorf("queued %d notifications, want 1", queued)
+	}
+}
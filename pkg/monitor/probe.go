@@ -0,0 +1,267 @@
+package monitor
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// HealthCheckResult represents the result of an HTTP health check
+type HealthCheckResult struct {
+	DomainName    string
+	IsLive        bool
+	StatusCode    int
+	ResponseTime  int         // Total response time in milliseconds
+	DNSLookup     int         // DNS lookup time in milliseconds
+	TCPConnection int         // TCP connection time in milliseconds
+	TLSHandshake  int         // TLS handshake time in milliseconds (0 for HTTP)
+	TTFB          int         // Time to First Byte in milliseconds
+	Err           error       // Transport error of the reported attempt, if any
+	Diagnostic    *Diagnostic // What happened during the reported attempt
+	HeartbeatID   uint        // Heartbeat recorded for this result
+	SnapshotID    uint        // Diagnostic snapshot stored for this result, if any
+}
+
+// CheckDomainHealth performs an HTTP health check on a domain using httptrace.
+// HTTPS is tried first, then HTTP. Captures detailed timing metrics: DNS lookup,
+// TCP connection, TLS handshake, TTFB.
+func CheckDomainHealth(ctx context.Context, domainName string, timeout time.Duration) HealthCheckResult {
+	urls := []string{
+		"https://" + domainName,
+		"http://" + domainName,
+	}
+
+	client := &http.Client{
+		Timeout: timeout,
+	}
+
+	var failed []HealthCheckResult
+	for _, urlStr := range urls {
+		result := checkHealthWithTrace(ctx, client, urlStr, domainName)
+		if result.IsLive {
+			return result
+		}
+		failed = append(failed, result)
+	}
+
+	// If both HTTPS and HTTP failed, domain is not live. Report the attempt that got
+	// furthest: one that received a response beats the HTTPS transport error.
+	report := failed[0]
+	for _, r := range failed {
+		if r.StatusCode > 0 {
+			report = r
+			break
+		}
+	}
+	return HealthCheckResult{
+		DomainName: domainName,
+		StatusCode: report.StatusCode,
+		Err:        report.Err,
+		Diagnostic: report.Diagnostic,
+	}
+}
+
+// checkHealthWithTrace performs HTTP request with detailed timing using httptrace
+func checkHealthWithTrace(ctx context.Context, client *http.Client, urlStr, domainName string) HealthCheckResult {
+	var dnsStart, dnsDone, connectStart, connectDone, tlsStart, tlsDone, gotFirstByte time.Time
+	var dnsLookup, tcpConnection, tlsHandshake, ttfb int
+
+	diag := &Diagnostic{URL: urlStr, Phase: PhaseDNS}
+	startTime := time.Now()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", urlStr, nil)
+	if err != nil {
+		diag.fail(err)
+		return HealthCheckResult{
+			DomainName:   domainName,
+			ResponseTime: int(time.Since(startTime).Milliseconds()),
+			Err:          err,
+			Diagnostic:   diag,
+		}
+	}
+
+	// Trace callbacks may run on transport goroutines; mu guards diag until Do returns
+	var mu sync.Mutex
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(dsi httptrace.DNSStartInfo) {
+			dnsStart = time.Now()
+		},
+		DNSDone: func(ddi httptrace.DNSDoneInfo) {
+			dnsDone = time.Now()
+			if dnsDone.After(dnsStart) {
+				dnsLookup = int(dnsDone.Sub(dnsStart).Milliseconds())
+			}
+			mu.Lock()
+			for _, addr := range ddi.Addrs {
+				diag.ResolvedIPs = append(diag.ResolvedIPs, addr.String())
+			}
+			if ddi.Err == nil {
+				diag.Phase = PhaseConnect
+			}
+			mu.Unlock()
+		},
+		ConnectStart: func(network, addr string) {
+			connectStart = time.Now()
+			mu.Lock()
+			diag.Phase = PhaseConnect
+			mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			connectDone = time.Now()
+			if connectDone.After(connectStart) {
+				// TCP connection time is the duration between ConnectStart and ConnectDone
+				tcpConnection = int(connectDone.Sub(connectStart).Milliseconds())
+			}
+			if err == nil {
+				mu.Lock()
+				diag.RemoteAddr = addr
+				diag.Phase = PhaseRequest
+				mu.Unlock()
+			}
+		},
+		TLSHandshakeStart: func() {
+			tlsStart = time.Now()
+			mu.Lock()
+			diag.Phase = PhaseTLS
+			mu.Unlock()
+		},
+		TLSHandshakeDone: func(cs tls.ConnectionState, err error) {
+			tlsDone = time.Now()
+			if tlsDone.After(tlsStart) {
+				tlsHandshake = int(tlsDone.Sub(tlsStart).Milliseconds())
+			}
+			mu.Lock()
+			if err != nil {
+				diag.TLSError = err.Error()
+			} else {
+				diag.TLSVersion = tls.VersionName(cs.Version)
+				diag.Phase = PhaseRequest
+			}
+			mu.Unlock()
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			mu.Lock()
+			diag.Phase = PhaseResponse
+			mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			gotFirstByte = time.Now()
+			// Calculate TTFB from connection done (or TLS done if HTTPS)
+			if !tlsDone.IsZero() {
+				ttfb = int(gotFirstByte.Sub(tlsDone).Milliseconds())
+			} else if !connectDone.IsZero() {
+				ttfb = int(gotFirstByte.Sub(connectDone).Milliseconds())
+			} else {
+				ttfb = int(gotFirstByte.Sub(startTime).Milliseconds())
+			}
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	resp, err := client.Do(req)
+	mu.Lock()
+	defer mu.Unlock()
+	if err != nil {
+		diag.fail(err)
+		return HealthCheckResult{
+			DomainName:    domainName,
+			ResponseTime:  int(time.Since(startTime).Milliseconds()),
+			DNSLookup:     dnsLookup,
+			TCPConnection: tcpConnection,
+			TLSHandshake:  tlsHandshake,
+			TTFB:          ttfb,
+			Err:           err,
+			Diagnostic:    diag,
+		}
+	}
+	defer resp.Body.Close()
+
+	responseTime := int(time.Since(startTime).Milliseconds())
+
+	diag.Phase = PhaseBody
+	diag.StatusCode = resp.StatusCode
+	diag.Headers = resp.Header.Clone()
+	if body, err := io.ReadAll(io.LimitReader(resp.Body, diagnosticBodySize)); err == nil {
+		diag.Body = string(body)
+	}
+
+	// Consider 2xx and 3xx status codes as "live"
+	isLive := resp.StatusCode >= 200 && resp.StatusCode < 400
+	if !isLive {
+		diag.ErrorClass = ClassHTTPStatus
+	}
+
+	// Reused connections report no phases; estimate proportions from the total time
+	if dnsLookup == 0 && tcpConnection == 0 && tlsHandshake == 0 && ttfb == 0 {
+		dnsLookup = responseTime * 20 / 100
+		tcpConnection = responseTime * 30 / 100
+		ttfb = responseTime * 50 / 100
+	}
+
+	return HealthCheckResult{
+		DomainName:    domainName,
+		IsLive:        isLive,
+		StatusCode:    resp.StatusCode,
+		ResponseTime:  responseTime,
+		DNSLookup:     dnsLookup,
+		TCPConnection: tcpConnection,
+		TLSHandshake:  tlsHandshake,
+		TTFB:          ttfb,
+		Diagnostic:    diag,
+	}
+}
+
+// SSLScanResult represents the result of a deep SSL certificate scan
+type SSLScanResult struct {
+	DomainName    string
+	ExpiryDate    time.Time
+	DaysRemaining int
+	Issuer        string
+	Serial        string // Serial number of the leaf certificate, in hex
+	IsReachable   bool
+	Err           error
+}
+
+// DeepScanSSL fetches the leaf certificate of a domain on port 443. Verification is
+// skipped so that expired or otherwise invalid certificates can still be inspected.
+func DeepScanSSL(ctx context.Context, domainName string, timeout time.Duration) SSLScanResult {
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: timeout},
+		Config:    &tls.Config{InsecureSkipVerify: true},
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", domainName+":443")
+	if err != nil {
+		return SSLScanResult{DomainName: domainName, DaysRemaining: -1, Err: err}
+	}
+	defer conn.Close()
+
+	certs := conn.(*tls.Conn).ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return SSLScanResult{DomainName: domainName, DaysRemaining: -1}
+	}
+	cert := certs[0]
+
+	expiryLocal := cert.NotAfter.In(time.Local)
+
+	issuer := ""
+	if len(cert.Issuer.Organization) > 0 {
+		issuer = cert.Issuer.Organization[0]
+	}
+
+	return SSLScanResult{
+		DomainName: domainName,
+		ExpiryDate: expiryLocal,
+		// math.Ceil gives a more intuitive countdown, e.g. 205.5 days -> 206 days
+		DaysRemaining: int(math.Ceil(time.Until(expiryLocal).Hours() / 24)),
+		Issuer:        issuer,
+		Serial:        cert.SerialNumber.Text(16),
+		IsReachable:   true,
+	}
+}
@@ -0,0 +1,206 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// KindSSLReminder is the check kind of certificate reminders. Its outcomes are derived
+// from SSL checks whenever a reminder stage of the certificate is due.
+const KindSSLReminder = "ssl-reminder"
+
+// DefaultReminderStages are the reminder stages, in days before expiry, used when no
+// global ReminderSchedule is configured.
+var DefaultReminderStages = []int{14, 7, 3, 1}
+
+// MaxReminderStage is the earliest reminder stage, in days before expiry.
+const MaxReminderStage = 365
+
+// ParseReminderStages parses a comma-separated list of days before expiry. Stages are
+// returned without duplicates, earliest first; 0 reminds on the day of expiry.
+func ParseReminderStages(list string) ([]int, error) {
+	seen := make(map[int]bool)
+	var stages []int
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		days, err := strconv.Atoi(item)
+		if err != nil || days < 0 || days > MaxReminderStage {
+			return nil, fmt.Errorf("invalid reminder stage %q (expected days between 0 and %d)", item, MaxReminderStage)
+		}
+		if !seen[days] {
+			seen[days] = true
+			stages = append(stages, days)
+		}
+	}
+	if len(stages) == 0 {
+		return nil, fmt.Errorf("at least one reminder stage is required")
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(stages)))
+	return stages, nil
+}
+
+// FormatReminderStages formats stages the way ReminderSchedule.Stages stores them.
+func FormatReminderStages(stages []int) string {
+	items := make([]string, len(stages))
+	for i, days := range stages {
+		items[i] = strconv.Itoa(days)
+	}
+	return strings.Join(items, ",")
+}
+
+// ReminderStages returns the reminder stages of a domain and the tags of the schedules
+// they come from. Domains with tagged schedules use all stages of those schedules,
+// other domains the global schedule, or DefaultReminderStages without one.
+func ReminderStages(domain database.MonitoredDomain) ([]int, []string, error) {
+	if database.DB == nil {
+		return nil, nil, database.ErrDatabaseNotInitialized
+	}
+
+	var schedules []database.ReminderSchedule
+	if err := database.DB.Order("tag").Find(&schedules).Error; err != nil {
+		return nil, nil, err
+	}
+
+	tags := make(map[string]bool)
+	for _, tag := range strings.Split(domain.Tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags[strings.ToLower(tag)] = true
+		}
+	}
+
+	global := DefaultReminderStages
+	var lists []string
+	var sources []string
+	for _, s := range schedules {
+		switch {
+		case s.Tag == "":
+			if stages, err := ParseReminderStages(s.Stages); err == nil {
+				global = stages
+			}
+		case tags[strings.ToLower(s.Tag)]:
+			lists = append(lists, s.Stages)
+			sources = append(sources, s.Tag)
+		}
+	}
+	if len(lists) == 0 {
+		return global, nil, nil
+	}
+	stages, err := ParseReminderStages(strings.Join(lists, ","))
+	if err != nil {
+		return global, nil, nil
+	}
+	return stages, sources, nil
+}
+
+// dueStage returns the reminder stage a certificate is in: the latest stage its
+// remaining days have reached. Stages passed while the certificate wasn't checked are
+// skipped, so only the most urgent reminder is sent.
+func dueStage(stages []int, daysRemaining int) (int, bool) {
+	stage, ok := 0, false
+	for _, days := range stages {
+		if daysRemaining <= days && (!ok || days < stage) {
+			stage, ok = days, true
+		}
+	}
+	return stage, ok
+}
+
+// reminderDue is the Detail of a certificate reminder outcome.
+type reminderDue struct {
+	Serial    string
+	Stage     int
+	ExpiresAt time.Time
+}
+
+// nextReminder returns the reminder due for a certificate, if its stage or a later one
+// hasn't been sent for the serial yet.
+func nextReminder(domain database.MonitoredDomain, res SSLScanResult) (*reminderDue, error) {
+	if domain.ID == 0 || res.Serial == "" {
+		return nil, nil
+	}
+	stages, _, err := ReminderStages(domain)
+	if err != nil {
+		return nil, err
+	}
+	stage, ok := dueStage(stages, res.DaysRemaining)
+	if !ok {
+		return nil, nil
+	}
+
+	var sent int64
+	err = database.DB.Model(&database.CertificateReminder{}).
+		Where("domain_id = ? AND serial = ? AND stage <= ?", domain.ID, res.Serial, stage).
+		Count(&sent).Error
+	if err != nil || sent > 0 {
+		return nil, err
+	}
+	return &reminderDue{Serial: res.Serial, Stage: stage, ExpiresAt: res.ExpiryDate}, nil
+}
+
+// claimReminder records that a reminder is sent. It returns false if the stage was
+// already sent for the certificate, e.g. by an on-demand scan running concurrently.
+func claimReminder(tx *gorm.DB, domainID uint, due reminderDue, at time.Time) (bool, error) {
+	res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&database.CertificateReminder{
+		DomainID:  domainID,
+		Serial:    due.Serial,
+		Stage:     due.Stage,
+		ExpiresAt: due.ExpiresAt,
+		SentAt:    at,
+	})
+	return res.RowsAffected == 1, res.Error
+}
+
+// CleanupReminders removes the reminders of certificates that expired before the
+// retention period.
+func CleanupReminders(retention time.Duration) (int64, error) {
+	if database.DB == nil {
+		return 0, database.ErrDatabaseNotInitialized
+	}
+	res := database.DB.Where("expires_at < ?", time.Now().Add(-retention)).Delete(&database.CertificateReminder{})
+	return res.RowsAffected, res.Error
+}
+
+// ReminderCheck turns due certificate reminders found by the SSL check into events.
+// Each derived outcome is a reminder of its own; SSLCheck only derives stages that
+// weren't sent yet.
+type ReminderCheck struct{}
+
+// Kind implements Check.
+func (c *ReminderCheck) Kind() string { return KindSSLReminder }
+
+// Interval implements Check. Reminders are derived from SSL checks, so the check is
+// never scheduled on its own.
+func (c *ReminderCheck) Interval() time.Duration { return 0 }
+
+// Policy implements Check.
+func (c *ReminderCheck) Policy() Policy { return Policy{FailureThreshold: 1, EveryFailure: true} }
+
+// Targets implements Check.
+func (c *ReminderCheck) Targets(ctx context.Context) ([]Target, error) { return nil, nil }
+
+// Initial implements Check.
+func (c *ReminderCheck) Initial(t Target) TargetState { return TargetState{Status: StatusUp} }
+
+// Run implements Check.
+func (c *ReminderCheck) Run(ctx context.Context, t Target) Outcome {
+	return Outcome{Status: StatusUnknown}
+}
+
+// Event implements Check.
+func (c *ReminderCheck) Event(from, to Status) string {
+	if to == StatusCritical {
+		return EventSSLCritical
+	}
+	return ""
+}
@@ -0,0 +1,76 @@
+package monitor
+
+import "time"
+
+// Status is the state of a target as seen by one check.
+type Status string
+
+const (
+	StatusUnknown  Status = "unknown"  // Not observed yet, or the last outcome was inconclusive
+	StatusUp       Status = "up"       // Healthy
+	StatusWarning  Status = "warning"  // Degraded but not failing, e.g. certificate expiring within 30 days
+	StatusCritical Status = "critical" // Failing condition on a reachable target, e.g. certificate about to expire
+	StatusDown     Status = "down"     // Unreachable or broken
+)
+
+// Failing reports whether the status counts as a failure for thresholds and repeats.
+func (s Status) Failing() bool {
+	return s == StatusCritical || s == StatusDown
+}
+
+// TargetState is the state machine of one target for one check.
+type TargetState struct {
+	Status              Status    `json:"status"`
+	Since               time.Time `json:"since"`                // When the current status was entered
+	ConsecutiveFailures int       `json:"consecutive_failures"` // Failing outcomes in a row, including unconfirmed ones
+	LastCheckedAt       time.Time `json:"last_checked_at"`
+	LastEventAt         time.Time `json:"last_event_at"` // When an event was last emitted, used for repeats
+}
+
+type transition struct {
+	from, to Status
+	emit     bool
+	repeat   bool
+}
+
+// advance feeds one outcome into the state machine. Unknown outcomes are ignored,
+// failing outcomes only take effect after the policy's failure threshold, and a
+// persisting failure is re-emitted every RepeatInterval (or on every outcome for
+// EveryFailure checks).
+func (st *TargetState) advance(out Outcome, p Policy, now time.Time) transition {
+	st.LastCheckedAt = now
+	if st.Status == "" {
+		st.Status = StatusUnknown
+	}
+	if out.Status == "" || out.Status == StatusUnknown {
+		return transition{from: st.Status, to: st.Status}
+	}
+
+	if out.Status.Failing() {
+		st.ConsecutiveFailures++
+	} else {
+		st.ConsecutiveFailures = 0
+	}
+
+	threshold := p.FailureThreshold
+	if threshold < 1 {
+		threshold = 1
+	}
+	if out.Status.Failing() && st.ConsecutiveFailures < threshold {
+		return transition{from: st.Status, to: st.Status}
+	}
+
+	if out.Status != st.Status {
+		tr := transition{from: st.Status, to: out.Status, emit: true}
+		st.Status = out.Status
+		st.Since = now
+		st.LastEventAt = now
+		return tr
+	}
+
+	if st.Status.Failing() && (p.EveryFailure || (p.RepeatInterval > 0 && now.Sub(st.LastEventAt) >= p.RepeatInterval)) {
+		st.LastEventAt = now
+		return transition{from: st.Status, to: st.Status, emit: true, repeat: true}
+	}
+	return transition{from: st.Status, to: st.Status}
+}
@@ -0,0 +1,164 @@
+package monitor
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestAdvance(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// step is one outcome observed a minute after the previous one, and the
+	// transition it is expected to produce; "" expects no event.
+	type step struct {
+		out    Status
+		event  string // "from>to", with a trailing "+" for repeats
+		status Status // State after the step
+	}
+	tests := []struct {
+		name    string
+		initial Status
+		policy  Policy
+		steps   []step
+	}{
+		{
+			name:  "first outcome leaves unknown",
+			steps: []step{{StatusUp, "unknown>up", StatusUp}},
+		},
+		{
+			name:    "unchanged status emits nothing",
+			initial: StatusUp,
+			steps:   []step{{StatusUp, "", StatusUp}, {StatusUp, "", StatusUp}},
+		},
+		{
+			name:    "unknown outcomes are ignored",
+			initial: StatusDown,
+			steps:   []step{{StatusUnknown, "", StatusDown}, {"", "", StatusDown}, {StatusUp, "down>up", StatusUp}},
+		},
+		{
+			name:    "failure threshold of 0 means 1",
+			initial: StatusUp,
+			steps:   []step{{StatusDown, "up>down", StatusDown}},
+		},
+		{
+			name:    "failures below the threshold are unconfirmed",
+			initial: StatusUp,
+			policy:  Policy{FailureThreshold: 3},
+			steps: []step{
+				{StatusDown, "", StatusUp},
+				{StatusDown, "", StatusUp},
+				{StatusDown, "up>down", StatusDown},
+				{StatusUp, "down>up", StatusUp},
+			},
+		},
+		{
+			name:    "a success resets the failure count",
+			initial: StatusUp,
+			policy:  Policy{FailureThreshold: 2},
+			steps: []step{
+				{StatusDown, "", StatusUp},
+				{StatusUp, "", StatusUp},
+				{StatusDown, "", StatusUp},
+				{StatusCritical, "up>critical", StatusCritical},
+			},
+		},
+		{
+			name:    "an unknown outcome doesn't reset the failure count",
+			initial: StatusUp,
+			policy:  Policy{FailureThreshold: 2},
+			steps:   []step{{StatusDown, "", StatusUp}, {StatusUnknown, "", StatusUp}, {StatusDown, "up>down", StatusDown}},
+		},
+		{
+			name:    "recoveries and warnings skip the threshold",
+			initial: StatusDown,
+			policy:  Policy{FailureThreshold: 3},
+			steps:   []step{{StatusWarning, "down>warning", StatusWarning}, {StatusUp, "warning>up", StatusUp}},
+		},
+		{
+			name:    "failing statuses change once confirmed",
+			initial: StatusCritical,
+			policy:  Policy{FailureThreshold: 2},
+			steps:   []step{{StatusCritical, "", StatusCritical}, {StatusDown, "critical>down", StatusDown}},
+		},
+		{
+			name:    "persisting failures repeat every interval",
+			initial: StatusUp,
+			policy:  Policy{RepeatInterval: 2 * time.Minute},
+			steps: []step{
+				{StatusDown, "up>down", StatusDown},
+				{StatusDown, "", StatusDown},
+				{StatusDown, "down>down+", StatusDown},
+				{StatusDown, "", StatusDown},
+				{StatusDown, "down>down+", StatusDown},
+			},
+		},
+		{
+			name:    "warnings don't repeat",
+			initial: StatusUp,
+			policy:  Policy{RepeatInterval: time.Minute},
+			steps:   []step{{StatusWarning, "up>warning", StatusWarning}, {StatusWarning, "", StatusWarning}},
+		},
+		{
+			name:    "every failure is emitted",
+			initial: StatusUp,
+			policy:  Policy{EveryFailure: true},
+			steps: []step{
+				{StatusDown, "up>down", StatusDown},
+				{StatusDown, "down>down+", StatusDown},
+				{StatusUp, "down>up", StatusUp},
+				{StatusUp, "", StatusUp},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			st := TargetState{Status: tt.initial}
+			now := start
+			for i, s := range tt.steps {
+				now = now.Add(time.Minute)
+				tr := st.advance(Outcome{Status: s.out}, tt.policy, now)
+
+				event := ""
+				if tr.emit {
+					event = fmt.Sprintf("%s>%s", tr.from, tr.to)
+					if tr.repeat {
+						event += "+"
+					}
+				}
+				if event != s.event {
+					t.Errorf("step %d (%s): event %q, want %q", i, s.out, event, s.event)
+				}
+				if st.Status != s.status {
+					t.Errorf("step %d (%s): status %s, want %s", i, s.out, st.Status, s.status)
+				}
+				if !st.LastCheckedAt.Equal(now) {
+					t.Errorf("step %d: last checked at %s, want %s", i, st.LastCheckedAt, now)
+				}
+				if tr.emit && !st.LastEventAt.Equal(now) {
+					t.Errorf("step %d: last event at %s, want %s", i, st.LastEventAt, now)
+				}
+			}
+		})
+	}
+}
+
+func TestAdvanceTracksSince(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	st := TargetState{Status: StatusUp, Since: start}
+
+	st.advance(Outcome{Status: StatusDown}, Policy{FailureThreshold: 2}, start.Add(time.Minute))
+	if !st.Since.Equal(start) || st.ConsecutiveFailures != 1 {
+		t.Errorf("unconfirmed failure: since %s, %d failures", st.Since, st.ConsecutiveFailures)
+	}
+	confirmed := start.Add(2 * time.Minute)
+	st.advance(Outcome{Status: StatusDown}, Policy{FailureThreshold: 2}, confirmed)
+	if !st.Since.Equal(confirmed) || st.ConsecutiveFailures != 2 {
+		t.Errorf("confirmed failure: since %s, %d failures", st.Since, st.ConsecutiveFailures)
+	}
+	st.advance(Outcome{Status: StatusDown}, Policy{FailureThreshold: 2, RepeatInterval: time.Second}, start.Add(3*time.Minute))
+	if !st.Since.Equal(confirmed) {
+		t.Errorf("repeat moved since to %s", st.Since)
+	}
+}
@@ -0,0 +1,46 @@
+package monitor
+
+// Thresholds are the days-remaining limits used to classify certificates. They are
+// shared by the background SSL check and the on-demand scan API.
+type Thresholds struct {
+	CriticalDays int // Below this many days a certificate is critical
+	WarningDays  int // Below this many days a certificate is in warning
+}
+
+// DefaultThresholds is used unless a check is configured otherwise.
+var DefaultThresholds = Thresholds{CriticalDays: 7, WarningDays: 30}
+
+// SSL status labels stored on MonitoredDomain.SSLStatus.
+const (
+	SSLValid    = "Valid"
+	SSLWarning  = "Warning"
+	SSLCritical = "Critical"
+	SSLExpired  = "Expired"
+	SSLOffline  = "Offline"
+)
+
+// SSLStatus classifies a certificate by its remaining days.
+func (t Thresholds) SSLStatus(daysRemaining int) string {
+	switch {
+	case daysRemaining < 0:
+		return SSLExpired
+	case daysRemaining < t.CriticalDays:
+		return SSLCritical
+	case daysRemaining < t.WarningDays:
+		return SSLWarning
+	}
+	return SSLValid
+}
+
+// statusForSSL maps an SSL status label to the state machine status.
+func statusForSSL(label string) Status {
+	switch label {
+	case SSLValid:
+		return StatusUp
+	case SSLWarning:
+		return StatusWarning
+	case SSLCritical, SSLExpired:
+		return StatusCritical
+	}
+	return StatusUnknown
+}
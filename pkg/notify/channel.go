@@ -0,0 +1,524 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"github.com/harveywai/zenstack/pkg/secrets"
+	"gorm.io/gorm"
+)
+
+// Settings are the type-specific settings of a channel, stored as a JSON object in
+// database.Channel.Settings.
+type Settings interface {
+	// Validate normalizes the settings, e.g. trims spaces and defaults the SMTP port,
+	// and checks them.
+	Validate() error
+	// notifier returns the notifier delivering to the channel.
+	notifier() Notifier
+	// describe names channels without a name.
+	describe() string
+}
+
+// WebhookSettings are the settings of webhook channels.
+type WebhookSettings struct {
+	Platform string `json:"platform"` // e.g. slack, dingtalk, pagerduty; unknown platforms get the generic payload
+	URL      string `json:"url"`
+	Secret   string `json:"secret"` // Signing secret, or the routing or API key of incident platforms
+}
+
+// EmailSettings are the settings of email channels.
+type EmailSettings struct {
+	Host       string `json:"host"`
+	Port       int    `json:"port"`
+	Security   string `json:"security"` // none, starttls or tls; empty uses STARTTLS when the server offers it
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	From       string `json:"from"`
+	Recipients string `json:"recipients"` // Comma-separated email addresses
+}
+
+// TelegramSettings are the settings of Telegram channels. The bot mode and secret are
+// managed by the telegram package and can't be set through the channel API.
+type TelegramSettings struct {
+	Token     string `json:"token"`
+	ChatID    string `json:"chat_id"`
+	BotMode   string `json:"bot_mode"`   // How the bot receives commands: polling, webhook or empty for none
+	BotSecret string `json:"bot_secret"` // Secret token Telegram sends with webhook updates
+}
+
+// SettingField describes a setting of a channel type.
+type SettingField struct {
+	Key         string `json:"key"`
+	Type        string `json:"type"` // string or number
+	Required    bool   `json:"required"`
+	Secret      bool   `json:"secret,omitempty"`    // Write-only: never returned, kept when omitted from updates
+	Redacted    bool   `json:"redacted,omitempty"`  // Secret returned as its scheme and host, see RedactURL
+	ReadOnly    bool   `json:"read_only,omitempty"` // Managed by the server and ignored in requests
+	Description string `json:"description"`
+}
+
+// ChannelType is a type of notification channel and the schema of its settings.
+type ChannelType struct {
+	Type     string         `json:"type"`
+	Settings []SettingField `json:"settings"`
+
+	new func() Settings
+}
+
+var channelTypes = []ChannelType{
+	{
+		Type: ChannelWebhook,
+		Settings: []SettingField{
+			{Key: "platform", Type: "string", Required: true, Description: "slack, dingtalk, feishu, wecom, discord, teams, pagerduty, opsgenie or webhook for the generic JSON payload"},
+			{Key: "url", Type: "string", Secret: true, Redacted: true, Description: "Webhook URL; the API base URL for pagerduty and opsgenie, which use their public API without one"},
+			{Key: "secret", Type: "string", Secret: true, Description: "Signing secret, or the routing key (pagerduty) or API key (opsgenie), which is required"},
+		},
+		new: func() Settings { return &WebhookSettings{} },
+	},
+	{
+		Type: ChannelEmail,
+		Settings: []SettingField{
+			{Key: "host", Type: "string", Required: true, Description: "SMTP server"},
+			{Key: "port", Type: "number", Description: "SMTP port, 587 by default"},
+			{Key: "security", Type: "string", Description: "none, starttls or tls; empty uses STARTTLS when the server offers it"},
+			{Key: "username", Type: "string", Description: "SMTP username; authentication is skipped without one"},
+			{Key: "password", Type: "string", Secret: true, Description: "SMTP password"},
+			{Key: "from", Type: "string", Required: true, Description: "Sender address"},
+			{Key: "recipients", Type: "string", Required: true, Description: "Comma-separated recipient addresses"},
+		},
+		new: func() Settings { return &EmailSettings{} },
+	},
+	{
+		Type: ChannelTelegram,
+		Settings: []SettingField{
+			{Key: "token", Type: "string", Required: true, Secret: true, Description: "Bot token"},
+			{Key: "chat_id", Type: "string", Required: true, Description: "Chat the notifications are sent to"},
+			{Key: "bot_mode", Type: "string", ReadOnly: true, Description: "How the bot receives commands: polling, webhook or empty for none"},
+			{Key: "bot_secret", Type: "string", ReadOnly: true, Secret: true, Description: "Secret token Telegram sends with webhook updates"},
+		},
+		new: func() Settings { return &TelegramSettings{} },
+	},
+}
+
+// ChannelTypes returns the channel types and the schemas of their settings.
+func ChannelTypes() []ChannelType {
+	return channelTypes
+}
+
+func channelType(typ string) (ChannelType, error) {
+	for _, t := range channelTypes {
+		if t.Type == typ {
+			return t, nil
+		}
+	}
+	return ChannelType{}, fmt.Errorf("invalid channel type %q (expected webhook, email or telegram)", typ)
+}
+
+// DecodeSettings returns the settings of a channel, with its secrets decrypted.
+func DecodeSettings(ch database.Channel) (Settings, error) {
+	t, err := channelType(ch.Type)
+	if err != nil {
+		return nil, err
+	}
+	s := t.new()
+	if ch.Settings == "" {
+		return s, nil
+	}
+	values, err := settingValues(ch)
+	if err != nil {
+		return nil, err
+	}
+	err = t.mapSecrets(values, func(key, v string) (string, error) { return secrets.Open(v, secretContext(ch, key)) })
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt settings of channel %d: %w", ch.ID, err)
+	}
+	decrypted, _ := json.Marshal(values)
+	if err := json.Unmarshal(decrypted, s); err != nil {
+		return nil, fmt.Errorf("invalid settings of channel %d: %w", ch.ID, err)
+	}
+	return s, nil
+}
+
+// settingValues returns the stored settings of a channel as they are, secrets sealed.
+func settingValues(ch database.Channel) (map[string]interface{}, error) {
+	values := map[string]interface{}{}
+	if ch.Settings == "" {
+		return values, nil
+	}
+	if err := json.Unmarshal([]byte(ch.Settings), &values); err != nil {
+		return nil, fmt.Errorf("invalid settings of channel %d: %w", ch.ID, err)
+	}
+	return values, nil
+}
+
+// mapSecrets replaces the non-empty secret settings of the type with fn of their key
+// and value.
+func (t ChannelType) mapSecrets(values map[string]interface{}, fn func(key, value string) (string, error)) error {
+	for _, f := range t.Settings {
+		v, _ := values[f.Key].(string)
+		if !f.Secret || v == "" {
+			continue
+		}
+		mapped, err := fn(f.Key, v)
+		if err != nil {
+			return fmt.Errorf("%s: %w", f.Key, err)
+		}
+		values[f.Key] = mapped
+	}
+	return nil
+}
+
+// secretContext returns the additional data secret settings are sealed with, which
+// binds them to their channel and setting.
+func secretContext(ch database.Channel, key string) string {
+	return ChannelRef(ch.Type, ch.ID) + "/" + key
+}
+
+// ApplySettings merges a JSON object of settings into the settings of a channel and
+// validates the result. Omitted keys keep their value, so secrets don't need to be
+// sent again; read-only keys are ignored and unknown keys rejected.
+func ApplySettings(ch *database.Channel, patch json.RawMessage) error {
+	t, err := channelType(ch.Type)
+	if err != nil {
+		return err
+	}
+	s, err := DecodeSettings(*ch)
+	if err != nil {
+		return err
+	}
+
+	if len(bytes.TrimSpace(patch)) > 0 && string(bytes.TrimSpace(patch)) != "null" {
+		var values map[string]json.RawMessage
+		if err := json.Unmarshal(patch, &values); err != nil {
+			return fmt.Errorf("settings must be a JSON object")
+		}
+		current, _ := json.Marshal(s)
+		var currentValues map[string]interface{}
+		json.Unmarshal(current, &currentValues)
+		for _, f := range t.Settings {
+			if f.ReadOnly {
+				delete(values, f.Key)
+			}
+			// A redacted value sent back as it was returned keeps the stored one
+			if v, ok := values[f.Key]; ok && f.Redacted {
+				var sent string
+				stored, _ := currentValues[f.Key].(string)
+				if json.Unmarshal(v, &sent) == nil && stored != "" && sent == RedactURL(stored) {
+					delete(values, f.Key)
+				}
+			}
+		}
+		known, _ := json.Marshal(values)
+		dec := json.NewDecoder(bytes.NewReader(known))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(s); err != nil {
+			return fmt.Errorf("invalid settings: %w", err)
+		}
+	}
+
+	if err := s.Validate(); err != nil {
+		return err
+	}
+	return EncodeSettings(ch, s)
+}
+
+// EncodeSettings stores settings in a channel, sealing its secrets. The secrets are
+// bound to the channel ID, so new channels are saved with CreateChannel.
+func EncodeSettings(ch *database.Channel, s Settings) error {
+	t, err := channelType(ch.Type)
+	if err != nil {
+		return err
+	}
+	encoded, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to encode settings: %w", err)
+	}
+	var values map[string]interface{}
+	if err := json.Unmarshal(encoded, &values); err != nil {
+		return fmt.Errorf("failed to encode settings: %w", err)
+	}
+	err = t.mapSecrets(values, func(key, v string) (string, error) { return secrets.Seal(v, secretContext(*ch, key)) })
+	if err != nil {
+		return fmt.Errorf("failed to encrypt settings: %w", err)
+	}
+	encoded, _ = json.Marshal(values)
+	ch.Settings = string(encoded)
+	return nil
+}
+
+// CreateChannel inserts a new channel and seals its secrets again for the ID it got.
+func CreateChannel(tx *gorm.DB, ch *database.Channel) error {
+	if tx == nil {
+		return database.ErrDatabaseNotInitialized
+	}
+	return tx.Transaction(func(tx *gorm.DB) error {
+		s, err := DecodeSettings(*ch)
+		if err != nil {
+			return err
+		}
+		if err := tx.Create(ch).Error; err != nil {
+			return err
+		}
+		if err := EncodeSettings(ch, s); err != nil {
+			return err
+		}
+		return tx.Model(ch).Update("settings", ch.Settings).Error
+	})
+}
+
+// ChannelView is a channel as returned by the API, with its secret settings removed.
+type ChannelView struct {
+	database.Channel
+	Ref        string                 `json:"ref"`
+	Settings   map[string]interface{} `json:"settings"`
+	SecretsSet []string               `json:"secrets_set"` // Secret settings that have a value
+}
+
+// ViewChannel returns the API view of a channel. Secrets are left out, except for
+// redacted ones like webhook URLs, whose token is in the path or query.
+func ViewChannel(ch database.Channel) (ChannelView, error) {
+	t, err := channelType(ch.Type)
+	if err != nil {
+		return ChannelView{}, err
+	}
+	values, err := settingValues(ch)
+	if err != nil {
+		return ChannelView{}, err
+	}
+	view := ChannelView{Channel: ch, Ref: ChannelRef(ch.Type, ch.ID), Settings: values, SecretsSet: []string{}}
+	for _, f := range t.Settings {
+		if !f.Secret {
+			continue
+		}
+		v, _ := values[f.Key].(string)
+		delete(values, f.Key)
+		if v == "" {
+			continue
+		}
+		view.SecretsSet = append(view.SecretsSet, f.Key)
+		if f.Redacted {
+			if opened, err := secrets.Open(v, secretContext(ch, f.Key)); err == nil {
+				values[f.Key] = RedactURL(opened)
+			}
+		}
+	}
+	return view, nil
+}
+
+// RedactURL returns the scheme and host of a URL, leaving out the path and query
+// that carry the token of Slack, Discord, WeCom or DingTalk webhooks.
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+	if u.Path == "" && u.RawQuery == "" {
+		return u.Scheme + "://" + u.Host
+	}
+	return u.Scheme + "://" + u.Host + "/…"
+}
+
+// RewrapChannelSecrets seals the secret settings stored in plaintext or sealed without
+// their channel as additional data, and re-encrypts those sealed under an older
+// master key with the primary key. It returns the number
+// of channels updated; channels failing are skipped and their errors joined.
+func RewrapChannelSecrets() (int, error) {
+	if database.DB == nil {
+		return 0, database.ErrDatabaseNotInitialized
+	}
+
+	var channels []database.Channel
+	if err := database.DB.Order("id").Find(&channels).Error; err != nil {
+		return 0, fmt.Errorf("failed to load channels: %w", err)
+	}
+
+	updated := 0
+	var errs []error
+	for _, ch := range channels {
+		t, err := channelType(ch.Type)
+		if err != nil {
+			continue
+		}
+		values, err := settingValues(ch)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		changed := false
+		err = t.mapSecrets(values, func(key, v string) (string, error) {
+			rewrapped, ok, err := secrets.Rewrap(v, secretContext(ch, key))
+			changed = changed || ok
+			return rewrapped, err
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("channel %d: %w", ch.ID, err))
+			continue
+		}
+		if !changed {
+			continue
+		}
+		encoded, _ := json.Marshal(values)
+		// Only update the settings if nobody changed them meanwhile
+		res := database.DB.Model(&database.Channel{}).Where("id = ? AND settings = ?", ch.ID, ch.Settings).Update("settings", string(encoded))
+		if res.Error != nil {
+			errs = append(errs, fmt.Errorf("failed to save channel %d: %w", ch.ID, res.Error))
+			continue
+		}
+		updated += int(res.RowsAffected)
+	}
+	return updated, errors.Join(errs...)
+}
+
+// SealedSecrets returns the secret settings of a channel that are stored encrypted,
+// decrypted. Secrets stored in plaintext are left out.
+func SealedSecrets(ch database.Channel) (map[string]string, error) {
+	t, err := channelType(ch.Type)
+	if err != nil {
+		return nil, err
+	}
+	values, err := settingValues(ch)
+	if err != nil {
+		return nil, err
+	}
+	out := make(map[string]string)
+	err = t.mapSecrets(values, func(key, v string) (string, error) {
+		if !secrets.IsSealed(v) {
+			return v, nil
+		}
+		opened, err := secrets.Open(v, secretContext(ch, key))
+		if err != nil {
+			return v, err
+		}
+		out[key] = opened
+		return v, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ChannelSecretKeys counts the secret settings of all channels by the ID of the
+// master key they are sealed under; plaintext secrets are counted under "".
+func ChannelSecretKeys() (map[string]int, error) {
+	if database.DB == nil {
+		return nil, database.ErrDatabaseNotInitialized
+	}
+
+	var channels []database.Channel
+	if err := database.DB.Find(&channels).Error; err != nil {
+		return nil, fmt.Errorf("failed to load channels: %w", err)
+	}
+
+	counts := make(map[string]int)
+	for _, ch := range channels {
+		t, err := channelType(ch.Type)
+		if err != nil {
+			continue
+		}
+		values, err := settingValues(ch)
+		if err != nil {
+			continue
+		}
+		t.mapSecrets(values, func(key, v string) (string, error) {
+			counts[secrets.SealedKeyID(v)]++
+			return v, nil
+		})
+	}
+	return counts, nil
+}
+
+// runtimeChannel returns the runtime channel of a channel model.
+func runtimeChannel(ch database.Channel) (Channel, error) {
+	s, err := DecodeSettings(ch)
+	if err != nil {
+		return Channel{}, err
+	}
+	n := s.notifier()
+	name := ch.Name
+	if name == "" {
+		name = s.describe()
+	}
+	return Channel{Ref: ChannelRef(ch.Type, ch.ID), Name: name, Platform: n.Platform(), Locale: ch.Locale, Quiet: ChannelQuietHours(ch), Notifier: n}, nil
+}
+
+// ActiveSettings returns the settings of the first active channel of a type, or nil
+// if there is none.
+func ActiveSettings(typ string) (Settings, error) {
+	if database.DB == nil {
+		return nil, database.ErrDatabaseNotInitialized
+	}
+	var ch database.Channel
+	if err := database.DB.Where("type = ? AND is_active = ?", typ, true).Order("id").Limit(1).Find(&ch).Error; err != nil {
+		return nil, err
+	}
+	if ch.ID == 0 {
+		return nil, nil
+	}
+	return DecodeSettings(ch)
+}
+
+// Validate implements Settings.
+func (s *WebhookSettings) Validate() error {
+	s.Platform = strings.TrimSpace(s.Platform)
+	s.URL = strings.TrimSpace(s.URL)
+	if s.Platform == "" {
+		return fmt.Errorf("platform is required")
+	}
+	if s.URL == "" && RequiresWebhookURL(s.Platform) {
+		return fmt.Errorf("url is required")
+	}
+	if s.Secret == "" && !RequiresWebhookURL(s.Platform) {
+		return fmt.Errorf("secret (routing or API key) is required")
+	}
+	if s.URL != "" {
+		u, err := url.Parse(s.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid url (expected an http or https URL)")
+		}
+	}
+	return nil
+}
+
+func (s *WebhookSettings) notifier() Notifier { return NewNotifier(*s) }
+
+func (s *WebhookSettings) describe() string { return s.Platform }
+
+// Validate implements Settings.
+func (s *EmailSettings) Validate() error {
+	s.Host = strings.TrimSpace(s.Host)
+	s.Security = strings.ToLower(strings.TrimSpace(s.Security))
+	s.Username = strings.TrimSpace(s.Username)
+	s.From = strings.TrimSpace(s.From)
+	s.Recipients = strings.Join(ParseRecipients(s.Recipients), ",")
+	if s.Port == 0 {
+		s.Port = 587
+	}
+	return ValidateEmailSettings(*s)
+}
+
+func (s *EmailSettings) notifier() Notifier { return NewEmailNotifier(*s) }
+
+func (s *EmailSettings) describe() string { return s.Recipients }
+
+// Validate implements Settings.
+func (s *TelegramSettings) Validate() error {
+	s.Token = strings.TrimSpace(s.Token)
+	s.ChatID = strings.TrimSpace(s.ChatID)
+	if s.Token == "" || s.ChatID == "" {
+		return fmt.Errorf("token and chat_id are required")
+	}
+	return nil
+}
+
+func (s *TelegramSettings) notifier() Notifier {
+	return &TelegramNotifier{Token: s.Token, ChatID: s.ChatID}
+}
+
+func (s *TelegramSettings) describe() string { return "Telegram " + s.ChatID }
@@ -0,0 +1,124 @@
+package notify
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"github.com/harveywai/zenstack/pkg/secrets"
+)
+
+func loadTestKeys(t *testing.T) {
+	t.Helper()
+	key, err := secrets.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ZENSTACK_MASTER_KEY", key)
+	if err := secrets.Load(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWebhookURLIsSealedAndRedacted(t *testing.T) {
+	loadTestKeys(t)
+
+	const hook = "https://qyapi.weixin.qq.com/cgi-bin/webhook/send?key=TOKEN"
+	ch := database.Channel{Type: ChannelWebhook}
+	if err := ApplySettings(&ch, []byte(`{"platform":"wecom","url":"`+hook+`"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(ch.Settings, "TOKEN") {
+		t.Errorf("stored settings contain the webhook token: %s", ch.Settings)
+	}
+
+	view, err := ViewChannel(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	redacted := view.Settings["url"]
+	if redacted != "https://qyapi.weixin.qq.com/…" {
+		t.Errorf("url = %v, want it redacted to the host", redacted)
+	}
+
+	// Updates omitting the URL or sending back the redacted one keep it
+	for _, patch := range []string{`{"platform":"wecom"}`, `{"url":"` + redacted.(string) + `"}`} {
+		if err := ApplySettings(&ch, []byte(patch)); err != nil {
+			t.Fatal(err)
+		}
+		s, err := DecodeSettings(ch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := s.(*WebhookSettings).URL; got != hook {
+			t.Errorf("after %s: url = %q, want %q", patch, got, hook)
+		}
+	}
+}
+
+func TestChannelSecretsAreBoundToTheirChannel(t *testing.T) {
+	loadTestKeys(t)
+	openTestDB(t, &database.Channel{})
+
+	var channels []database.Channel
+	for _, hook := range []string{"https://hooks.slack.com/services/A", "https://hooks.slack.com/services/B"} {
+		ch := database.Channel{Type: ChannelWebhook, IsActive: true}
+		if err := ApplySettings(&ch, []byte(`{"platform":"slack","url":"`+hook+`"}`)); err != nil {
+			t.Fatal(err)
+		}
+		if err := CreateChannel(database.DB, &ch); err != nil {
+			t.Fatal(err)
+		}
+		channels = append(channels, ch)
+	}
+
+	for i, want := range []string{"https://hooks.slack.com/services/A", "https://hooks.slack.com/services/B"} {
+		ch, err := LoadChannel(ChannelRef(ChannelWebhook, channels[i].ID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := ch.Notifier.(*SlackNotifier).WebhookURL; got != want {
+			t.Errorf("channel %d: url %q, want %q", i, got, want)
+		}
+	}
+
+	// Settings copied from another channel don't decrypt
+	swapped := channels[0]
+	swapped.Settings = channels[1].Settings
+	if _, err := DecodeSettings(swapped); err == nil {
+		t.Error("decrypted the secrets of another channel")
+	}
+}
+
+func TestRewrapChannelSecrets(t *testing.T) {
+	loadTestKeys(t)
+	openTestDB(t, &database.Channel{})
+
+	// Channels migrated from the legacy tables store their secrets in plaintext
+	ch := database.Channel{Type: ChannelTelegram, IsActive: true, Settings: `{"token":"123:ABC","chat_id":"1"}`}
+	if err := database.DB.Create(&ch).Error; err != nil {
+		t.Fatal(err)
+	}
+	if n, err := RewrapChannelSecrets(); n != 1 || err != nil {
+		t.Fatalf("RewrapChannelSecrets() = %d, %v", n, err)
+	}
+	if n, err := RewrapChannelSecrets(); n != 0 || err != nil {
+		t.Errorf("second RewrapChannelSecrets() = %d, %v", n, err)
+	}
+
+	database.DB.First(&ch, ch.ID)
+	if strings.Contains(ch.Settings, "123:ABC") {
+		t.Errorf("token still in plaintext: %s", ch.Settings)
+	}
+	s, err := DecodeSettings(ch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.(*TelegramSettings).Token; got != "123:ABC" {
+		t.Errorf("token = %q", got)
+	}
+	counts, err := ChannelSecretKeys()
+	if err != nil || len(counts) != 1 || counts[""] != 0 {
+		t.Errorf("ChannelSecretKeys() = %v, %v", counts, err)
+	}
+}
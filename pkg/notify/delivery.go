@@ -0,0 +1,141 @@
+package notify
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"github.com/harveywai/zenstack/pkg/metrics"
+)
+
+// DeliveryInfo describes a delivery attempt for the delivery history.
+type DeliveryInfo struct {
+	Channel      string // Channel ref, or the contact kind of a paged user
+	Recipient    string // Channel name or contact address
+	OutboxID     uint
+	EscalationID uint
+	UserID       uint
+	Attempt      int
+	DeliveryID   string // Shared by all attempts of a notification; a new one is generated if empty
+}
+
+// deliveryTrace collects response details from the notifiers during a delivery.
+type deliveryTrace struct {
+	statusCode int
+	deliveryID string
+}
+
+type traceKey struct{}
+
+// traceStatus records the status code of a response for the delivery history.
+func traceStatus(ctx context.Context, code int) {
+	if t, ok := ctx.Value(traceKey{}).(*deliveryTrace); ok {
+		t.statusCode = code
+	}
+}
+
+// deliveryID returns the ID of the delivery in progress, which notifiers pass on to
+// receivers that deduplicate retries.
+func deliveryID(ctx context.Context) string {
+	if t, ok := ctx.Value(traceKey{}).(*deliveryTrace); ok && t.deliveryID != "" {
+		return t.deliveryID
+	}
+	return NewDeliveryID()
+}
+
+// NewDeliveryID returns a random delivery ID.
+func NewDeliveryID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// Deliver sends a message through a notifier, counts failures and records the
+// attempt in the delivery history.
+func Deliver(ctx context.Context, n Notifier, msg Message, info DeliveryInfo) error {
+	if _, ok := n.(actionRenderer); !ok {
+		msg = msg.withActionLinks()
+	}
+
+	if info.DeliveryID == "" {
+		info.DeliveryID = NewDeliveryID()
+	}
+	trace := &deliveryTrace{deliveryID: info.DeliveryID}
+	start := time.Now()
+	err := n.Send(context.WithValue(ctx, traceKey{}, trace), msg)
+	latency := time.Since(start)
+	if err != nil {
+		metrics.NotificationFailures.WithLabelValues(n.Platform()).Inc()
+	}
+
+	var smtpErr *textproto.Error
+	if errors.As(err, &smtpErr) {
+		trace.statusCode = smtpErr.Code
+	}
+	recordDelivery(n, msg, info, trace.statusCode, latency, err)
+	return err
+}
+
+// recordDelivery stores a delivery attempt. Failing to record never fails the delivery.
+func recordDelivery(n Notifier, msg Message, info DeliveryInfo, statusCode int, latency time.Duration, err error) {
+	if database.DB == nil {
+		return
+	}
+
+	attempt := database.DeliveryAttempt{
+		OutboxID:     info.OutboxID,
+		EscalationID: info.EscalationID,
+		UserID:       info.UserID,
+		Channel:      info.Channel,
+		Platform:     n.Platform(),
+		Recipient:    info.Recipient,
+		Event:        msg.Event,
+		Domain:       msg.Domain,
+		DedupKey:     msg.DedupKey,
+		DeliveryID:   info.DeliveryID,
+		Title:        msg.Title,
+		Message:      msg.text(),
+		Attempt:      info.Attempt,
+		Success:      err == nil,
+		StatusCode:   statusCode,
+		LatencyMs:    latency.Milliseconds(),
+	}
+	if attempt.Attempt == 0 {
+		attempt.Attempt = 1
+	}
+	if err != nil {
+		attempt.Error = err.Error()
+	}
+	if dbErr := database.DB.Create(&attempt).Error; dbErr != nil {
+		log.Printf("Error recording delivery to %s: %v", info.Channel, dbErr)
+	}
+}
+
+// requestError drops the URL from transport errors, since webhook and bot URLs
+// contain credentials that must not end up in logs or the delivery history.
+func requestError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+// CleanupDeliveries removes delivery attempts older than the retention period.
+func CleanupDeliveries(retention time.Duration) (int64, error) {
+	if database.DB == nil {
+		return 0, database.ErrDatabaseNotInitialized
+	}
+
+	result := database.DB.Where("created_at < ?", time.Now().Add(-retention)).Delete(&database.DeliveryAttempt{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,356 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"gorm.io/gorm"
+)
+
+// DigestEvent is the event of digest messages, rendered with its message template.
+const DigestEvent = "DIGEST"
+
+// Digest defaults.
+const (
+	DefaultDigestAt      = "09:00"
+	DefaultDigestHorizon = 30                 // Days
+	digestLookback       = 7 * 24 * time.Hour // Incident period of the first digest
+)
+
+// DigestExpiry is a certificate or domain registration listed in a digest.
+type DigestExpiry struct {
+	Domain  string
+	Expiry  time.Time
+	Expired bool
+	Detail  string // Certificate issuer or registrar
+}
+
+// DigestIncident is an incident listed in a digest.
+type DigestIncident struct {
+	ID         uint
+	Title      string
+	Domain     string
+	Status     string
+	Impact     string
+	StartedAt  time.Time
+	ResolvedAt *time.Time
+	Duration   time.Duration // Until resolution, or until the digest for ongoing incidents
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseDays returns the weekdays of a digest, or nil for every day.
+func parseDays(days string) (map[time.Weekday]bool, error) {
+	list := splitList(days)
+	if len(list) == 0 {
+		return nil, nil
+	}
+	set := make(map[time.Weekday]bool, len(list))
+	for _, d := range list {
+		key := strings.ToLower(d)
+		if len(key) > 3 {
+			key = key[:3]
+		}
+		wd, ok := weekdays[key]
+		if !ok {
+			return nil, fmt.Errorf("invalid weekday %q (expected mon, tue, wed, thu, fri, sat or sun)", d)
+		}
+		set[wd] = true
+	}
+	return set, nil
+}
+
+// parseClock parses a "HH:MM" time of day.
+func parseClock(at string) (int, int, error) {
+	h, m, ok := strings.Cut(strings.TrimSpace(at), ":")
+	hour, herr := strconv.Atoi(h)
+	minute, merr := strconv.Atoi(m)
+	if !ok || herr != nil || merr != nil || hour < 0 || hour > 23 || minute < 0 || minute > 59 {
+		return 0, 0, fmt.Errorf("invalid time %q (expected HH:MM)", at)
+	}
+	return hour, minute, nil
+}
+
+// NextDigestRun returns the first send time of a digest after a time.
+func NextDigestRun(d database.Digest, after time.Time) (time.Time, error) {
+	days, err := parseDays(d.Days)
+	if err != nil {
+		return time.Time{}, err
+	}
+	at := d.At
+	if at == "" {
+		at = DefaultDigestAt
+	}
+	hour, minute, err := parseClock(at)
+	if err != nil {
+		return time.Time{}, err
+	}
+	loc := time.UTC
+	if d.TimeZone != "" {
+		if loc, err = time.LoadLocation(d.TimeZone); err != nil {
+			return time.Time{}, fmt.Errorf("invalid time zone %q", d.TimeZone)
+		}
+	}
+
+	local := after.In(loc)
+	for i := 0; i <= 7; i++ {
+		day := local.AddDate(0, 0, i)
+		run := time.Date(day.Year(), day.Month(), day.Day(), hour, minute, 0, 0, loc)
+		if run.After(after) && (days == nil || days[run.Weekday()]) {
+			return run, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("no send time found")
+}
+
+// ValidateDigest checks the schedule, sections and channels of a digest.
+func ValidateDigest(d database.Digest) error {
+	if strings.TrimSpace(d.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if _, err := NextDigestRun(d, time.Now()); err != nil {
+		return err
+	}
+	if d.Horizon < 1 || d.Horizon > 365 {
+		return fmt.Errorf("horizon must be between 1 and 365 days")
+	}
+	if !d.Certificates && !d.Registrations && !d.Incidents {
+		return fmt.Errorf("at least one of certificates, registrations or incidents is required")
+	}
+	refs := splitList(d.Channels)
+	if len(refs) == 0 {
+		return fmt.Errorf("at least one channel is required")
+	}
+	return ValidateChannelRefs(refs)
+}
+
+// BuildDigest collects the expirations and incidents of a digest and renders its
+// message.
+func BuildDigest(d database.Digest, now time.Time) (Message, error) {
+	if database.DB == nil {
+		return Message{}, database.ErrDatabaseNotInitialized
+	}
+
+	var domains []database.MonitoredDomain
+	if err := database.DB.Find(&domains).Error; err != nil {
+		return Message{}, fmt.Errorf("failed to load domains: %w", err)
+	}
+	var silences []database.Silence
+	if err := database.DB.Where("starts_at <= ? AND ends_at > ?", now, now).Find(&silences).Error; err != nil {
+		return Message{}, fmt.Errorf("failed to load silences: %w", err)
+	}
+	tags := splitList(d.Tags)
+	names := make(map[uint]string)
+	silenced := make(map[uint]bool)
+	horizon := now.AddDate(0, 0, d.Horizon)
+	certificates := []DigestExpiry{}
+	registrations := []DigestExpiry{}
+	for _, dom := range domains {
+		if len(tags) > 0 && !intersects(tags, splitList(dom.Tags)) {
+			continue
+		}
+		names[dom.ID] = dom.DomainName
+		if silencedInDigest(silences, dom) {
+			silenced[dom.ID] = true
+			continue
+		}
+		if d.Certificates && !dom.SSLExpiry.IsZero() && dom.SSLExpiry.Before(horizon) {
+			certificates = append(certificates, DigestExpiry{Domain: dom.DomainName, Expiry: dom.SSLExpiry, Expired: dom.SSLExpiry.Before(now), Detail: dom.Issuer})
+		}
+		if d.Registrations && !dom.LastExpiryDate.IsZero() && dom.LastExpiryDate.Before(horizon) {
+			registrations = append(registrations, DigestExpiry{Domain: dom.DomainName, Expiry: dom.LastExpiryDate, Expired: dom.LastExpiryDate.Before(now), Detail: dom.Registrar})
+		}
+	}
+	sort.Slice(certificates, func(i, j int) bool { return certificates[i].Expiry.Before(certificates[j].Expiry) })
+	sort.Slice(registrations, func(i, j int) bool { return registrations[i].Expiry.Before(registrations[j].Expiry) })
+
+	since := now.Add(-digestLookback)
+	if d.LastSentAt != nil {
+		since = *d.LastSentAt
+	}
+	incidents := []DigestIncident{}
+	if d.Incidents {
+		var rows []database.Incident
+		err := database.DB.
+			Where("started_at >= ? OR resolved_at IS NULL OR resolved_at >= ?", since, since).
+			Order("started_at asc").
+			Find(&rows).Error
+		if err != nil {
+			return Message{}, fmt.Errorf("failed to load incidents: %w", err)
+		}
+		for _, inc := range rows {
+			name, ok := names[inc.DomainID]
+			if len(tags) > 0 && !ok {
+				continue
+			}
+			if silenced[inc.DomainID] {
+				continue
+			}
+			end := now
+			if inc.ResolvedAt != nil {
+				end = *inc.ResolvedAt
+			}
+			incidents = append(incidents, DigestIncident{
+				ID:         inc.ID,
+				Title:      inc.Title,
+				Domain:     name,
+				Status:     inc.Status,
+				Impact:     inc.Impact,
+				StartedAt:  inc.StartedAt,
+				ResolvedAt: inc.ResolvedAt,
+				Duration:   end.Sub(inc.StartedAt),
+			})
+		}
+	}
+
+	msg, err := TemplateMessage(DigestEvent, map[string]interface{}{
+		"name":               d.Name,
+		"horizon":            d.Horizon,
+		"since":              since,
+		"show_certificates":  d.Certificates,
+		"show_registrations": d.Registrations,
+		"show_incidents":     d.Incidents,
+		"certificates":       certificates,
+		"registrations":      registrations,
+		"incidents":          incidents,
+	})
+	if err != nil {
+		return Message{}, err
+	}
+	msg.DedupKey = fmt.Sprintf("digest:%d:%s", d.ID, now.UTC().Format("2006-01-02T15:04"))
+	msg.Fields = []Field{
+		{Name: "Certificates", Value: strconv.Itoa(len(certificates))},
+		{Name: "Registrations", Value: strconv.Itoa(len(registrations))},
+		{Name: "Incidents", Value: strconv.Itoa(len(incidents))},
+	}
+	return msg, nil
+}
+
+// silencedInDigest reports whether a silence matching digest events covers a domain,
+// which leaves its expirations and incidents out of digests.
+func silencedInDigest(silences []database.Silence, domain database.MonitoredDomain) bool {
+	for _, s := range silences {
+		if (s.DomainIDs != "" || s.Tags != "") && SilenceMatches(s, DigestEvent, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// digestChannels returns the active channels of a digest.
+func digestChannels(d database.Digest) ([]Channel, error) {
+	channels, err := ActiveChannels()
+	if err != nil {
+		return nil, err
+	}
+	refs := make(map[string]bool)
+	for _, ref := range splitList(d.Channels) {
+		refs[strings.ToLower(ref)] = true
+	}
+	var selected []Channel
+	for _, ch := range channels {
+		if refs[ch.Ref] {
+			selected = append(selected, ch)
+		}
+	}
+	return selected, nil
+}
+
+// SendDigest queues a digest to its channels and schedules the next one. When
+// scheduled is set, the digest is only sent if nobody else sent it for that run.
+// Notifications held by the quiet hours of the channels are included.
+func SendDigest(d database.Digest, scheduled bool) error {
+	now := time.Now()
+	msg, err := BuildDigest(d, now)
+	if err != nil {
+		return err
+	}
+	channels, err := digestChannels(d)
+	if err != nil {
+		return err
+	}
+	if len(channels) == 0 {
+		return fmt.Errorf("no active channel for digest %s", d.Name)
+	}
+	next, err := NextDigestRun(d, now)
+	if err != nil {
+		return err
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		silence := CheckSilence(tx, DigestEvent, database.MonitoredDomain{}, PathDigest)
+		query := tx.Model(&database.Digest{}).Where("id = ?", d.ID)
+		if scheduled {
+			query = query.Where("next_run_at = ?", d.NextRunAt)
+		}
+		claim := query.Updates(map[string]interface{}{"last_sent_at": now, "next_run_at": next})
+		if claim.Error != nil {
+			return claim.Error
+		}
+		if claim.RowsAffected == 0 || silence != nil {
+			// A silenced digest still moves on to its next run
+			return nil
+		}
+		return enqueueDigest(tx, msg, channels, now)
+	})
+}
+
+// enqueueDigest queues a digest to each of its channels, along with the
+// notifications held for the channel by quiet hours.
+func enqueueDigest(tx *gorm.DB, msg Message, channels []Channel, now time.Time) error {
+	rows := make([]database.OutboxMessage, 0, len(channels))
+	for _, ch := range channels {
+		held, err := takeHeld(tx, ch.Ref)
+		if err != nil {
+			return err
+		}
+		row, err := newOutboxMessage(ch.Ref, ch.Platform, withHeld(msg.For(ch), held), now)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, row)
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return fmt.Errorf("failed to enqueue digest: %w", err)
+	}
+	return nil
+}
+
+// ProcessDigests sends the digests that are due and returns the number sent.
+func ProcessDigests(ctx context.Context) int {
+	if database.DB == nil {
+		return 0
+	}
+
+	var due []database.Digest
+	if err := database.DB.Where("is_active = ? AND next_run_at <= ?", true, time.Now()).Find(&due).Error; err != nil {
+		log.Printf("Error loading digests: %v", err)
+		return 0
+	}
+
+	sent := 0
+	for _, d := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := SendDigest(d, true); err != nil {
+			log.Printf("Error sending digest %s: %v", d.Name, err)
+			// Skip to the next run rather than retrying a broken digest every minute
+			if next, nerr := NextDigestRun(d, time.Now()); nerr == nil {
+				database.DB.Model(&database.Digest{}).Where("id = ? AND next_run_at = ?", d.ID, d.NextRunAt).Update("next_run_at", next)
+			}
+			continue
+		}
+		sent++
+	}
+	return sent
+}
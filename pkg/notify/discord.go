@@ -0,0 +1,47 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// DiscordNotifier posts embeds to a Discord channel webhook.
+type DiscordNotifier struct {
+	WebhookURL string
+	Client     *http.Client
+}
+
+var discordColors = map[string]int{
+	SeverityCritical: 0xE53E3E,
+	SeverityWarning:  0xDD6B20,
+	SeverityResolved: 0x38A169,
+	SeverityInfo:     0x3182CE,
+}
+
+// Platform implements Notifier.
+func (n *DiscordNotifier) Platform() string { return PlatformDiscord }
+
+// Send implements Notifier.
+func (n *DiscordNotifier) Send(ctx context.Context, msg Message) error {
+	// Embeds are limited to 25 fields
+	fields := make([]map[string]interface{}, 0, len(msg.Fields))
+	for i, f := range msg.Fields {
+		if i == 25 {
+			break
+		}
+		fields = append(fields, map[string]interface{}{"name": f.Name, "value": f.Value, "inline": true})
+	}
+
+	_, err := postJSON(ctx, n.Client, n.WebhookURL, map[string]interface{}{
+		"embeds": []map[string]interface{}{{
+			"title":       msg.Title,
+			"description": msg.Body,
+			"color":       discordColors[msg.Severity],
+			"fields":      fields,
+			"footer":      map[string]string{"text": msg.Event},
+			"timestamp":   msg.Time.Format(time.RFC3339),
+		}},
+	}, nil)
+	return err
+}
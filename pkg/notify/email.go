@@ -0,0 +1,304 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PlatformEmail is the platform name of email notifications.
+const PlatformEmail = "email"
+
+// SMTP connection security modes.
+const (
+	SecurityNone     = "none"     // Plain connection, never upgraded
+	SecuritySTARTTLS = "starttls" // Plain connection upgraded with STARTTLS, which is required
+	SecurityTLS      = "tls"      // Implicit TLS, usually on port 465
+)
+
+// EmailNotifier sends multipart (plain text and HTML) emails over SMTP.
+type EmailNotifier struct {
+	Host     string
+	Port     int
+	Security string // SecurityNone, SecuritySTARTTLS or SecurityTLS; empty uses STARTTLS when offered
+	Username string // Authentication is skipped without a username
+	Password string
+	From     string
+	To       []string
+	Timeout  time.Duration  // Connection timeout, default 10 seconds
+	RootCAs  *x509.CertPool // Trusted certificate authorities, default the system roots
+}
+
+// NewEmailNotifier returns the notifier of email channel settings.
+func NewEmailNotifier(s EmailSettings) *EmailNotifier {
+	return &EmailNotifier{
+		Host:     s.Host,
+		Port:     s.Port,
+		Security: strings.ToLower(strings.TrimSpace(s.Security)),
+		Username: s.Username,
+		Password: s.Password,
+		From:     s.From,
+		To:       ParseRecipients(s.Recipients),
+	}
+}
+
+// ParseRecipients splits a comma- or semicolon-separated list of email addresses.
+func ParseRecipients(list string) []string {
+	var recipients []string
+	for _, r := range strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ';' || r == '\n' }) {
+		if r = strings.TrimSpace(r); r != "" {
+			recipients = append(recipients, r)
+		}
+	}
+	return recipients
+}
+
+// ValidateEmailSettings checks the server and addresses of email channel settings.
+func ValidateEmailSettings(s EmailSettings) error {
+	if s.Host == "" || s.From == "" {
+		return fmt.Errorf("host and from are required")
+	}
+	if s.Port <= 0 || s.Port > 65535 {
+		return fmt.Errorf("invalid port: %d", s.Port)
+	}
+	switch strings.ToLower(strings.TrimSpace(s.Security)) {
+	case "", SecurityNone, SecuritySTARTTLS, SecurityTLS:
+	default:
+		return fmt.Errorf("invalid security %q (expected none, starttls or tls)", s.Security)
+	}
+	if _, err := mail.ParseAddress(s.From); err != nil {
+		return fmt.Errorf("invalid from address: %w", err)
+	}
+	recipients := ParseRecipients(s.Recipients)
+	if len(recipients) == 0 {
+		return fmt.Errorf("at least one recipient is required")
+	}
+	for _, r := range recipients {
+		if _, err := mail.ParseAddress(r); err != nil {
+			return fmt.Errorf("invalid recipient %q: %w", r, err)
+		}
+	}
+	return nil
+}
+
+// Platform implements Notifier.
+func (n *EmailNotifier) Platform() string { return PlatformEmail }
+
+// Send implements Notifier.
+func (n *EmailNotifier) Send(ctx context.Context, msg Message) error {
+	if len(n.To) == 0 {
+		return fmt.Errorf("no recipients")
+	}
+	from, err := mail.ParseAddress(n.From)
+	if err != nil {
+		return fmt.Errorf("invalid from address: %w", err)
+	}
+	to := make([]string, 0, len(n.To))
+	header := make([]string, 0, len(n.To))
+	for _, r := range n.To {
+		addr, err := mail.ParseAddress(r)
+		if err != nil {
+			return fmt.Errorf("invalid recipient %q: %w", r, err)
+		}
+		to = append(to, addr.Address)
+		header = append(header, addr.String())
+	}
+
+	data, err := buildEmail(from, header, msg)
+	if err != nil {
+		return err
+	}
+
+	client, err := n.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if n.Username != "" {
+		// PlainAuth refuses to send credentials over unencrypted connections except to localhost
+		if err := client.Auth(smtp.PlainAuth("", n.Username, n.Password, n.Host)); err != nil {
+			return fmt.Errorf("smtp auth failed: %w", err)
+		}
+	}
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("smtp MAIL FROM failed: %w", err)
+	}
+	for _, addr := range to {
+		if err := client.Rcpt(addr); err != nil {
+			return fmt.Errorf("smtp RCPT TO %s failed: %w", addr, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("smtp DATA failed: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("smtp server rejected message: %w", err)
+	}
+	return client.Quit()
+}
+
+// dial connects to the SMTP server and sets up TLS according to the security mode.
+func (n *EmailNotifier) dial(ctx context.Context) (*smtp.Client, error) {
+	timeout := n.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	addr := net.JoinHostPort(n.Host, strconv.Itoa(n.Port))
+	tlsConfig := &tls.Config{ServerName: n.Host, RootCAs: n.RootCAs}
+
+	var conn net.Conn
+	var err error
+	if n.Security == SecurityTLS {
+		conn, err = (&tls.Dialer{Config: tlsConfig}).DialContext(ctx, "tcp", addr)
+	} else {
+		conn, err = (&net.Dialer{}).DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	// The deadline covers the whole SMTP conversation
+	conn.SetDeadline(time.Now().Add(2 * timeout))
+
+	client, err := smtp.NewClient(conn, n.Host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("smtp handshake failed: %w", err)
+	}
+
+	if n.Security == SecurityNone || n.Security == SecurityTLS {
+		return client, nil
+	}
+	if ok, _ := client.Extension("STARTTLS"); !ok {
+		if n.Security == SecuritySTARTTLS {
+			client.Close()
+			return nil, fmt.Errorf("smtp server %s does not support STARTTLS", addr)
+		}
+		return client, nil
+	}
+	if err := client.StartTLS(tlsConfig); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("smtp STARTTLS failed: %w", err)
+	}
+	return client, nil
+}
+
+// buildEmail renders a multipart/alternative message with a plain text and an HTML part.
+func buildEmail(from *mail.Address, to []string, msg Message) ([]byte, error) {
+	var boundary [12]byte
+	if _, err := rand.Read(boundary[:]); err != nil {
+		return nil, err
+	}
+	b := "zenstack-" + hex.EncodeToString(boundary[:])
+
+	date := msg.Time
+	if date.IsZero() {
+		date = time.Now()
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", headerValue(msg.Title)))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s.%d@zenstack>\r\n", hex.EncodeToString(boundary[:6]), date.UnixNano())
+	if msg.Event != "" {
+		fmt.Fprintf(&buf, "X-ZenStack-Event: %s\r\n", headerValue(msg.Event))
+	}
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%q\r\n\r\n", b)
+
+	parts := []struct{ contentType, body string }{
+		{"text/plain", msg.plainEmail()},
+		{"text/html", msg.htmlEmail()},
+	}
+	for _, p := range parts {
+		fmt.Fprintf(&buf, "--%s\r\n", b)
+		fmt.Fprintf(&buf, "Content-Type: %s; charset=utf-8\r\n", p.contentType)
+		buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+		qp := quotedprintable.NewWriter(&buf)
+		if _, err := qp.Write([]byte(strings.ReplaceAll(strings.ReplaceAll(p.body, "\r\n", "\n"), "\n", "\r\n"))); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+		buf.WriteString("\r\n")
+	}
+	fmt.Fprintf(&buf, "--%s--\r\n", b)
+	return buf.Bytes(), nil
+}
+
+// headerValue keeps user-controlled text from breaking out of a header line.
+func headerValue(s string) string {
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(s)
+}
+
+// plainEmail renders the plain text part: the body followed by the fields.
+func (m Message) plainEmail() string {
+	var b strings.Builder
+	b.WriteString(m.Body)
+	if len(m.Fields) > 0 {
+		b.WriteString("\n")
+	}
+	for _, f := range m.Fields {
+		fmt.Fprintf(&b, "\n%s: %s", f.Name, f.Value)
+	}
+	return b.String()
+}
+
+var emailColors = map[string]string{
+	SeverityCritical: "#e53e3e",
+	SeverityWarning:  "#dd6b20",
+	SeverityResolved: "#38a169",
+	SeverityInfo:     "#3182ce",
+}
+
+// htmlEmail returns the HTML part: the rendered HTML template if there is one,
+// otherwise a simple layout of the title, body and fields.
+func (m Message) htmlEmail() string {
+	if m.HTML != "" {
+		return m.HTML
+	}
+
+	color := emailColors[m.Severity]
+	if color == "" {
+		color = emailColors[SeverityInfo]
+	}
+
+	var b strings.Builder
+	b.WriteString(`<!DOCTYPE html><html><body style="margin:0;padding:24px;background:#f7fafc;font-family:-apple-system,Segoe UI,Helvetica,Arial,sans-serif;color:#1a202c">`)
+	b.WriteString(`<table role="presentation" width="100%" style="max-width:600px;margin:0 auto;background:#ffffff;border-radius:6px;border-top:4px solid ` + color + `">`)
+	fmt.Fprintf(&b, `<tr><td style="padding:20px 24px 8px"><h2 style="margin:0;font-size:18px">%s</h2></td></tr>`, html.EscapeString(m.Title))
+	fmt.Fprintf(&b, `<tr><td style="padding:8px 24px;font-size:14px;line-height:1.5">%s</td></tr>`, strings.ReplaceAll(html.EscapeString(m.Body), "\n", "<br>"))
+	if len(m.Fields) > 0 {
+		b.WriteString(`<tr><td style="padding:8px 24px"><table role="presentation" style="font-size:13px;border-collapse:collapse">`)
+		for _, f := range m.Fields {
+			fmt.Fprintf(&b, `<tr><td style="padding:4px 16px 4px 0;color:#718096">%s</td><td style="padding:4px 0">%s</td></tr>`, html.EscapeString(f.Name), html.EscapeString(f.Value))
+		}
+		b.WriteString(`</table></td></tr>`)
+	}
+	fmt.Fprintf(&b, `<tr><td style="padding:16px 24px 20px;font-size:12px;color:#a0aec0">%s · %s</td></tr>`, html.EscapeString(m.Event), m.Time.Format("2006-01-02 15:04:05 MST"))
+	b.WriteString(`</table></body></html>`)
+	return b.String()
+}
@@ -0,0 +1,236 @@
+package notify
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// session is a mail transaction received by an smtpServer.
+type session struct {
+	TLS  bool   // The transaction ran over TLS
+	Auth string // Decoded AUTH PLAIN credentials
+	From string
+	To   []string
+	Data string
+}
+
+// smtpServer is a minimal SMTP server for testing the security modes: it offers
+// STARTTLS if startTLS is set and speaks TLS from the start if implicitTLS is set.
+type smtpServer struct {
+	startTLS    bool
+	implicitTLS bool
+
+	ln       net.Listener
+	tls      *tls.Config
+	roots    *x509.CertPool
+	mu       sync.Mutex
+	sessions []session
+}
+
+func newSMTPServer(t *testing.T, startTLS, implicitTLS bool) *smtpServer {
+	t.Helper()
+	// The certificate of httptest servers is valid for 127.0.0.1
+	https := httptest.NewUnstartedServer(nil)
+	https.StartTLS()
+	cert := https.TLS.Certificates[0]
+	roots := x509.NewCertPool()
+	roots.AddCert(https.Certificate())
+	https.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &smtpServer{
+		startTLS:    startTLS,
+		implicitTLS: implicitTLS,
+		ln:          ln,
+		tls:         &tls.Config{Certificates: []tls.Certificate{cert}},
+		roots:       roots,
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) port() int { return s.ln.Addr().(*net.TCPAddr).Port }
+
+func (s *smtpServer) received() []session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]session(nil), s.sessions...)
+}
+
+func (s *smtpServer) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	secure := false
+	if s.implicitTLS {
+		conn = tls.Server(conn, s.tls)
+		secure = true
+	}
+	tp := textproto.NewConn(conn)
+	reply := func(format string, args ...interface{}) { tp.PrintfLine(format, args...) }
+
+	// The client waits for each reply, so nothing is left buffered when STARTTLS
+	// replaces the connection
+	reply("220 fake ESMTP")
+	var cur session
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			reply("250-fake")
+			if s.startTLS && !secure {
+				reply("250-STARTTLS")
+			}
+			reply("250 AUTH PLAIN")
+		case "STARTTLS":
+			reply("220 ready")
+			conn = tls.Server(conn, s.tls)
+			tp = textproto.NewConn(conn)
+			secure = true
+		case "AUTH":
+			_, encoded, _ := strings.Cut(arg, " ")
+			decoded, _ := base64.StdEncoding.DecodeString(encoded)
+			cur.Auth = string(decoded)
+			reply("235 authenticated")
+		case "MAIL":
+			cur.From, cur.TLS = strings.Trim(strings.TrimPrefix(arg, "FROM:"), "<>"), secure
+			reply("250 ok")
+		case "RCPT":
+			cur.To = append(cur.To, strings.Trim(strings.TrimPrefix(arg, "TO:"), "<>"))
+			reply("250 ok")
+		case "DATA":
+			reply("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			cur.Data = string(data)
+			s.mu.Lock()
+			s.sessions = append(s.sessions, cur)
+			s.mu.Unlock()
+			cur = session{}
+			reply("250 queued")
+		case "QUIT":
+			reply("221 bye")
+			return
+		default:
+			reply("502 not implemented")
+		}
+	}
+}
+
+func TestEmailSecurityModes(t *testing.T) {
+	tests := []struct {
+		name        string
+		security    string
+		startTLS    bool
+		implicitTLS bool
+		wantTLS     bool
+		wantErr     string
+	}{
+		{name: "none ignores STARTTLS", security: SecurityNone, startTLS: true},
+		{name: "default upgrades when offered", startTLS: true, wantTLS: true},
+		{name: "default stays plain when not offered"},
+		{name: "starttls", security: SecuritySTARTTLS, startTLS: true, wantTLS: true},
+		{name: "starttls requires the offer", security: SecuritySTARTTLS, wantErr: "does not support STARTTLS"},
+		{name: "implicit tls", security: SecurityTLS, implicitTLS: true, wantTLS: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := newSMTPServer(t, tt.startTLS, tt.implicitTLS)
+			n := NewEmailNotifier(EmailSettings{
+				Host:       "127.0.0.1",
+				Port:       srv.port(),
+				Security:   tt.security,
+				Username:   "alerts",
+				Password:   "pa55",
+				From:       "ZenStack <alerts@example.com>",
+				Recipients: "ops@example.com, Oncall <oncall@example.com>",
+			})
+			n.RootCAs = srv.roots
+
+			err := n.Send(context.Background(), testMessage())
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("got %v, want an error containing %q", err, tt.wantErr)
+				}
+				if got := srv.received(); len(got) != 0 {
+					t.Errorf("sent %d messages despite the error", len(got))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := srv.received()
+			if len(got) != 1 {
+				t.Fatalf("received %d messages, want 1", len(got))
+			}
+			s := got[0]
+			if s.TLS != tt.wantTLS {
+				t.Errorf("sent over TLS: %v, want %v", s.TLS, tt.wantTLS)
+			}
+			if s.Auth != "\x00alerts\x00pa55" {
+				t.Errorf("auth = %q", s.Auth)
+			}
+			if s.From != "alerts@example.com" || fmt.Sprint(s.To) != "[ops@example.com oncall@example.com]" {
+				t.Errorf("envelope from %s to %v", s.From, s.To)
+			}
+			for _, want := range []string{
+				"Subject: Site down\n",
+				`To: <ops@example.com>, "Oncall" <oncall@example.com>`,
+				"X-ZenStack-Event: SITE_DOWN\n",
+				"Content-Type: text/plain; charset=utf-8",
+				"Content-Type: text/html; charset=utf-8",
+				"Status: 503",
+			} {
+				if !strings.Contains(s.Data, want) {
+					t.Errorf("message lacks %q:\n%s", want, s.Data)
+				}
+			}
+		})
+	}
+}
+
+func TestEmailRejectsUntrustedCertificates(t *testing.T) {
+	for _, security := range []string{SecuritySTARTTLS, SecurityTLS} {
+		t.Run(security, func(t *testing.T) {
+			srv := newSMTPServer(t, security == SecuritySTARTTLS, security == SecurityTLS)
+			n := NewEmailNotifier(EmailSettings{Host: "127.0.0.1", Port: srv.port(), Security: security, From: "alerts@example.com", Recipients: "ops@example.com"})
+			n.Timeout = 2 * time.Second
+
+			if err := n.Send(context.Background(), testMessage()); err == nil {
+				t.Error("sent to a server with an untrusted certificate")
+			}
+			if got := srv.received(); len(got) != 0 {
+				t.Errorf("received %d messages", len(got))
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"github.com/harveywai/zenstack/pkg/metrics"
+	"gorm.io/gorm"
+)
+
+// MaxGroupWindow is the longest time events can be held back for grouping.
+const MaxGroupWindow = 24 * time.Hour
+
+// Grouping returns the grouping window and keys of the first matched rule that groups
+// events. A zero window sends events one by one.
+func (r Route) Grouping() (time.Duration, []string) {
+	for _, rule := range r.Rules {
+		if rule.GroupWindow > 0 {
+			return time.Duration(rule.GroupWindow) * time.Second, splitList(rule.GroupBy)
+		}
+	}
+	return 0, nil
+}
+
+// GroupKey returns the values of the grouping keys in the event data of a message,
+// e.g. "issuer=R3,expiry_date=2024-05-01".
+func GroupKey(msg Message, by []string) string {
+	if msg.source == nil || len(by) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(by))
+	for _, key := range by {
+		value := ""
+		if v, ok := msg.source.data[key]; ok && v != nil {
+			value = fmt.Sprint(v)
+		}
+		parts = append(parts, key+"="+value)
+	}
+	return strings.Join(parts, ",")
+}
+
+// groupable reports whether messages to a platform may be grouped. Incident
+// management platforms deduplicate by alert key, which a group would hide.
+func groupable(platform string) bool {
+	switch normalizePlatform(platform) {
+	case PlatformPagerDuty, PlatformOpsgenie:
+		return false
+	}
+	return true
+}
+
+// EnqueueGrouped adds a message to the open group of each channel with the same event
+// and group key, or opens a group that is flushed after the window. Channels that
+// can't group receive the message at once.
+func EnqueueGrouped(tx *gorm.DB, msg Message, channels []Channel, window time.Duration, key string) error {
+	if tx == nil {
+		return database.ErrDatabaseNotInitialized
+	}
+
+	var direct []Channel
+	for _, ch := range channels {
+		if !groupable(ch.Platform) {
+			direct = append(direct, ch)
+			continue
+		}
+		if err := addToGroup(tx, msg, ch, window, key); err != nil {
+			return err
+		}
+	}
+	return Enqueue(tx, msg, direct)
+}
+
+func addToGroup(tx *gorm.DB, msg Message, ch Channel, window time.Duration, key string) error {
+	payload, err := json.Marshal(msg.For(ch))
+	if err != nil {
+		return fmt.Errorf("failed to encode message: %w", err)
+	}
+
+	var group database.NotificationGroup
+	err = tx.Where("channel = ? AND event = ? AND group_key = ?", ch.Ref, msg.Event, key).Limit(1).Find(&group).Error
+	switch {
+	case err != nil:
+		return fmt.Errorf("failed to load notification group: %w", err)
+	case group.ID == 0:
+		group = database.NotificationGroup{
+			Channel:  ch.Ref,
+			Platform: ch.Platform,
+			Event:    msg.Event,
+			GroupKey: key,
+			FlushAt:  time.Now().Add(window),
+		}
+		if err := tx.Create(&group).Error; err != nil {
+			return fmt.Errorf("failed to open notification group: %w", err)
+		}
+	}
+
+	item := database.NotificationGroupItem{GroupID: group.ID, Domain: msg.Domain, Payload: string(payload)}
+	if err := tx.Create(&item).Error; err != nil {
+		return fmt.Errorf("failed to add notification to group: %w", err)
+	}
+	return tx.Model(&database.NotificationGroup{}).Where("id = ?", group.ID).
+		Update("size", gorm.Expr("size + 1")).Error
+}
+
+// FlushGroups queues one message for each group whose window has passed and returns
+// the number of groups flushed. Groups flushed during the quiet hours of their
+// channel are held like single messages.
+func FlushGroups(ctx context.Context) int {
+	if database.DB == nil {
+		return 0
+	}
+
+	var due []database.NotificationGroup
+	if err := database.DB.Where("flush_at <= ?", time.Now()).Order("flush_at asc").Find(&due).Error; err != nil {
+		log.Printf("Error loading notification groups: %v", err)
+		return 0
+	}
+
+	flushed := 0
+	for _, g := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		var quiet *QuietHours
+		if ch, err := findChannel(g.Channel); err == nil {
+			quiet = ChannelQuietHours(ch)
+		}
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			var items []database.NotificationGroupItem
+			if err := tx.Where("group_id = ?", g.ID).Order("id asc").Find(&items).Error; err != nil {
+				return err
+			}
+
+			// Deleting the group claims it; another replica may have been faster
+			claim := tx.Delete(&database.NotificationGroup{}, g.ID)
+			if claim.Error != nil || claim.RowsAffected == 0 {
+				return claim.Error
+			}
+			if err := tx.Where("group_id = ?", g.ID).Delete(&database.NotificationGroupItem{}).Error; err != nil {
+				return err
+			}
+			if len(items) == 0 {
+				return nil
+			}
+
+			msgs := make([]Message, 0, len(items))
+			for _, item := range items {
+				var m Message
+				if err := json.Unmarshal([]byte(item.Payload), &m); err != nil {
+					log.Printf("Skipping undecodable notification in group %d: %v", g.ID, err)
+					continue
+				}
+				msgs = append(msgs, m)
+			}
+			if len(msgs) == 0 {
+				return nil
+			}
+
+			merged, now := MergeMessages(msgs), time.Now()
+			row, err := newOutboxMessage(g.Channel, g.Platform, merged, now)
+			if err != nil {
+				return err
+			}
+			holdOutbox(&row, quiet, merged, now)
+			if err := tx.Create(&row).Error; err != nil {
+				return err
+			}
+			flushed++
+			log.Printf("Flushed notification group %s %s (%d event(s)) to %s", g.Event, g.GroupKey, len(msgs), g.Channel)
+			return nil
+		})
+		if err != nil {
+			log.Printf("Error flushing notification group %d: %v", g.ID, err)
+		}
+	}
+
+	var open int64
+	database.DB.Model(&database.NotificationGroup{}).Count(&open)
+	metrics.QueueDepth.WithLabelValues("notification-groups").Set(float64(open))
+	return flushed
+}
+
+// MergeMessages combines grouped messages into one, listing each message. A single
+// message is returned unchanged.
+func MergeMessages(msgs []Message) Message {
+	first := msgs[0]
+	if len(msgs) == 1 {
+		return first
+	}
+
+	merged := Message{
+		Event:    first.Event,
+		Severity: first.Severity,
+		Title:    fmt.Sprintf("%s (+%d)", first.Title, len(msgs)-1),
+		Time:     first.Time,
+		Resolve:  first.Resolve,
+		Urgency:  first.Urgency,
+	}
+
+	var body, text, html []string
+	var domains []string
+	seen := make(map[string]bool)
+	for _, m := range msgs {
+		body = append(body, "• "+m.Body)
+		text = append(text, "• "+m.text())
+		if m.HTML != "" {
+			html = append(html, m.HTML)
+		}
+		if m.Domain != "" && !seen[m.Domain] {
+			seen[m.Domain] = true
+			domains = append(domains, m.Domain)
+		}
+		if m.Time.After(merged.Time) {
+			merged.Time = m.Time
+		}
+	}
+	merged.Body = strings.Join(body, "\n")
+	merged.Text = strings.Join(text, "\n")
+	if len(html) == len(msgs) {
+		merged.HTML = strings.Join(html, "<hr>")
+	}
+	if len(domains) == 1 {
+		merged.Domain = domains[0]
+	}
+	merged.Fields = []Field{{Name: "Events", Value: fmt.Sprintf("%d", len(msgs))}}
+	if len(domains) > 0 {
+		merged.Fields = append(merged.Fields, Field{Name: "Domains", Value: strings.Join(domains, ", ")})
+	}
+	merged.Extra = map[string]interface{}{"grouped": len(msgs), "domains": domains}
+	return merged
+}
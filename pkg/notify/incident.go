@@ -0,0 +1,96 @@
+package notify
+
+import "strings"
+
+// Urgencies of alerts on incident management platforms, ordered from most to least
+// severe. They match the PagerDuty Events v2 severities.
+const (
+	UrgencyCritical = "critical"
+	UrgencyError    = "error"
+	UrgencyWarning  = "warning"
+	UrgencyInfo     = "info"
+)
+
+// alertTypes groups events that trigger and resolve the same alert. Events not listed
+// only trigger alerts, which are resolved on the platform itself.
+var alertTypes = map[string]struct {
+	kind    string
+	resolve bool
+}{
+	"SITE_DOWN":           {kind: "site"},
+	"SITE_UP":             {kind: "site", resolve: true},
+	"SSL_CRITICAL":        {kind: "ssl"},
+	"SSL_RENEWED":         {kind: "ssl", resolve: true},
+	"SYNTHETIC_FAILED":    {kind: "synthetic"},
+	"SYNTHETIC_RECOVERED": {kind: "synthetic", resolve: true},
+	"CONTENT_CHANGED":     {kind: "content"},
+	"LATENCY_ANOMALY":     {kind: "latency"},
+}
+
+// AlertKey returns the stable dedup key of an event's alert and whether the event
+// resolves it. Trigger and resolve events of the same domain and type share the key,
+// e.g. "zenstack/example.com/site" for SITE_DOWN and SITE_UP.
+func AlertKey(event, subject string) (key string, resolve bool) {
+	t, ok := alertTypes[event]
+	if !ok {
+		t.kind = strings.ToLower(event)
+	}
+	return "zenstack/" + subject + "/" + t.kind, t.resolve
+}
+
+// Urgency maps an event to an alert urgency. Certificate alerts follow the SSL status:
+// expired certificates are critical, certificates about to expire are errors.
+func Urgency(event, sslStatus string) string {
+	switch event {
+	case "SITE_DOWN", "SYNTHETIC_FAILED":
+		return UrgencyCritical
+	case "SSL_CRITICAL":
+		switch sslStatus {
+		case "Expired":
+			return UrgencyCritical
+		case "Warning":
+			return UrgencyWarning
+		}
+		return UrgencyError
+	case "CONTENT_CHANGED":
+		return UrgencyError
+	case "LATENCY_ANOMALY":
+		return UrgencyWarning
+	}
+	return UrgencyInfo
+}
+
+// urgencyRank orders urgencies from info (0) to critical (3). Unknown urgencies rank
+// as info.
+func urgencyRank(urgency string) int {
+	switch urgency {
+	case UrgencyCritical:
+		return 3
+	case UrgencyError:
+		return 2
+	case UrgencyWarning:
+		return 1
+	}
+	return 0
+}
+
+// trimURL removes a trailing slash and known API paths, so that both a base URL and
+// a full endpoint URL can be configured.
+func trimURL(base string, suffixes ...string) string {
+	base = strings.TrimRight(strings.TrimSpace(base), "/")
+	for _, s := range suffixes {
+		base = strings.TrimSuffix(base, s)
+	}
+	return base
+}
+
+// truncate shortens s to at most n bytes without splitting a UTF-8 sequence.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && s[n]&0xC0 == 0x80 {
+		n--
+	}
+	return s[:n]
+}
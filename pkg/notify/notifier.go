@@ -0,0 +1,276 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Severities of notification messages, derived from the event.
+const (
+	SeverityCritical = "critical"
+	SeverityWarning  = "warning"
+	SeverityResolved = "resolved"
+	SeverityInfo     = "info"
+)
+
+// Platforms with a native notifier. Any other platform receives the generic
+// NotificationPayload JSON.
+const (
+	PlatformSlack     = "slack"
+	PlatformDingTalk  = "dingtalk"
+	PlatformFeishu    = "feishu"
+	PlatformWeCom     = "wecom"
+	PlatformDiscord   = "discord"
+	PlatformTeams     = "teams"
+	PlatformWebhook   = "webhook"
+	PlatformPagerDuty = "pagerduty"
+	PlatformOpsgenie  = "opsgenie"
+	PlatformTelegram  = "telegram"
+)
+
+// Message is a rendered notification, independent of the channel delivering it.
+type Message struct {
+	Event    string
+	Severity string
+	Domain   string
+	Title    string
+	Body     string
+	Text     string  // Plain text variant for chat channels, falls back to Body
+	HTML     string  // HTML variant for email; a default layout is used when empty
+	Fields   []Field // Key facts shown as a table or field list where supported
+	Extra    map[string]interface{}
+	Time     time.Time
+	DedupKey string          // Stable alert key shared by the trigger and resolve events of a problem
+	Resolve  bool            // The event resolves the alert with the same DedupKey
+	Urgency  string          // critical, error, warning or info, for incident management platforms
+	Blocks   json.RawMessage `json:",omitempty"` // Slack blocks rendered from a slack template variant
+	Actions  []Action        `json:",omitempty"` // Links such as snoozing the domain, buttons where supported
+
+	source *renderSource // Template and data the message was rendered from, see Render
+}
+
+// Field is a labelled value of a message.
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Notifier delivers messages to one channel in its native format.
+type Notifier interface {
+	// Platform is the lower-case platform name, used in logs and metrics.
+	Platform() string
+	// Send delivers the message.
+	Send(ctx context.Context, msg Message) error
+}
+
+// actionRenderer is implemented by notifiers rendering message actions as buttons.
+// Other notifiers get the actions as links appended to the message, see withActionLinks.
+type actionRenderer interface {
+	rendersActions()
+}
+
+// NewNotifier returns the notifier of webhook channel settings. Unknown platforms
+// use the generic webhook notifier.
+func NewNotifier(s WebhookSettings) Notifier {
+	switch normalizePlatform(s.Platform) {
+	case PlatformSlack:
+		return &SlackNotifier{WebhookURL: s.URL}
+	case PlatformDingTalk:
+		return &DingTalkNotifier{WebhookURL: s.URL, Secret: s.Secret}
+	case PlatformFeishu:
+		return &FeishuNotifier{WebhookURL: s.URL, Secret: s.Secret}
+	case PlatformWeCom:
+		return &WeComNotifier{WebhookURL: s.URL}
+	case PlatformDiscord:
+		return &DiscordNotifier{WebhookURL: s.URL}
+	case PlatformTeams:
+		return &TeamsNotifier{WebhookURL: s.URL}
+	case PlatformPagerDuty:
+		return &PagerDutyNotifier{RoutingKey: s.Secret, BaseURL: s.URL}
+	case PlatformOpsgenie:
+		return &OpsgenieNotifier{APIKey: s.Secret, BaseURL: s.URL}
+	}
+	return &WebhookNotifier{WebhookURL: s.URL, Secret: s.Secret, Name: strings.ToLower(s.Platform)}
+}
+
+// normalizePlatform maps platform names and common aliases to the platform constants.
+func normalizePlatform(platform string) string {
+	p := strings.ToLower(strings.TrimSpace(platform))
+	switch p {
+	case "lark":
+		return PlatformFeishu
+	case "wechat", "wechatwork", "wechat_work", "weixin", "workwechat":
+		return PlatformWeCom
+	case "msteams", "microsoft teams":
+		return PlatformTeams
+	case "pager_duty", "pager duty":
+		return PlatformPagerDuty
+	}
+	return p
+}
+
+// RequiresWebhookURL reports whether a platform needs a webhook URL. Incident
+// management platforms use their public API unless a base URL is configured, and
+// authenticate with the secret key instead.
+func RequiresWebhookURL(platform string) bool {
+	switch normalizePlatform(platform) {
+	case PlatformPagerDuty, PlatformOpsgenie:
+		return false
+	}
+	return true
+}
+
+// Severity classifies an event for message colors and icons.
+func Severity(event string) string {
+	switch event {
+	case "SITE_DOWN", "SSL_CRITICAL", "SYNTHETIC_FAILED":
+		return SeverityCritical
+	case "CONTENT_CHANGED", "LATENCY_ANOMALY":
+		return SeverityWarning
+	case "SITE_UP", "SSL_RENEWED", "SYNTHETIC_RECOVERED":
+		return SeverityResolved
+	}
+	return SeverityInfo
+}
+
+// text returns the plain text variant of the message.
+func (m Message) text() string {
+	if m.Text != "" {
+		return m.Text
+	}
+	return m.Body
+}
+
+// withActionLinks appends the actions of the message as links to its text variants.
+func (m Message) withActionLinks() Message {
+	if len(m.Actions) == 0 {
+		return m
+	}
+	var text, markup strings.Builder
+	for _, a := range m.Actions {
+		fmt.Fprintf(&text, "\n%s: %s", a.Label, a.URL)
+		fmt.Fprintf(&markup, `<a href="%s">%s</a> `, html.EscapeString(a.URL), html.EscapeString(a.Label))
+	}
+	m.Body += "\n" + text.String()
+	if m.Text != "" {
+		m.Text += "\n" + text.String()
+	}
+	if m.HTML != "" {
+		m.HTML += "<p>" + strings.TrimSpace(markup.String()) + "</p>"
+	}
+	return m
+}
+
+// markdown renders the body followed by one paragraph per field, for platforms
+// without structured layouts.
+func (m Message) markdown(bold string) string {
+	var b strings.Builder
+	b.WriteString(m.Body)
+	for _, f := range m.Fields {
+		fmt.Fprintf(&b, "\n\n%s%s:%s %s", bold, f.Name, bold, f.Value)
+	}
+	return b.String()
+}
+
+// httpClient is shared by the notifiers without their own client.
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+func clientOr(c *http.Client) *http.Client {
+	if c != nil {
+		return c
+	}
+	return httpClient
+}
+
+// postJSON posts a JSON body and returns the response body of a 2xx response.
+func postJSON(ctx context.Context, client *http.Client, url string, body interface{}, header http.Header) ([]byte, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	return postBody(ctx, client, url, jsonData, header)
+}
+
+// postBody posts an encoded JSON body, e.g. one that was signed, and returns the
+// response body of a 2xx response.
+func postBody(ctx context.Context, client *http.Client, url string, jsonData []byte, header http.Header) ([]byte, error) {
+	req, err := newPostRequest(ctx, url, jsonData)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	return doRequest(ctx, client, req)
+}
+
+// newPostRequest returns a request posting a JSON body.
+func newPostRequest(ctx context.Context, url string, jsonData []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req, nil
+}
+
+// doRequest sends a request and returns the response body, failing for non-2xx status
+// codes.
+func doRequest(ctx context.Context, client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := clientOr(client).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send request: %w", requestError(err))
+	}
+	defer resp.Body.Close()
+	traceStatus(ctx, resp.StatusCode)
+
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err := fmt.Errorf("webhook returned status code %d: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+		return respBody, classifyStatus(resp, err)
+	}
+	return respBody, nil
+}
+
+// RetryAfterError is returned when a platform rate limits deliveries and asks to
+// retry after a delay, e.g. HTTP 429 with a Retry-After header.
+type RetryAfterError struct {
+	After time.Duration
+	Err   error
+}
+
+func (e *RetryAfterError) Error() string { return e.Err.Error() }
+func (e *RetryAfterError) Unwrap() error { return e.Err }
+
+// PermanentError marks failures that retrying won't fix, e.g. a rejected request or
+// an unknown webhook. The outbox moves such messages to the dead-letter list at once.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string { return e.Err.Error() }
+func (e *PermanentError) Unwrap() error { return e.Err }
+
+// classifyStatus wraps the error of a non-2xx response: 429 responses become a
+// RetryAfterError, other client errors except 408 a PermanentError.
+func classifyStatus(resp *http.Response, err error) error {
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		after := time.Duration(0)
+		if secs, perr := strconv.Atoi(strings.TrimSpace(resp.Header.Get("Retry-After"))); perr == nil && secs > 0 {
+			after = time.Duration(secs) * time.Second
+		}
+		return &RetryAfterError{After: after, Err: err}
+	case resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusRequestTimeout:
+		return &PermanentError{Err: err}
+	}
+	return err
+}
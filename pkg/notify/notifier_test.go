@@ -0,0 +1,312 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// request is a request received by a platform stand-in.
+type request struct {
+	Method string
+	Path   string
+	Query  map[string]string
+	Header http.Header
+	Body   map[string]interface{}
+}
+
+// platform stands in for a notification platform, recording the requests it receives
+// and answering with status and reply.
+type platform struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []request
+	status   int
+	header   http.Header
+	reply    string
+}
+
+func newPlatform(t *testing.T) *platform {
+	p := &platform{status: http.StatusOK, header: http.Header{}, reply: "{}"}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		req := request{Method: r.Method, Path: r.URL.Path, Query: map[string]string{}, Header: r.Header}
+		for k := range r.URL.Query() {
+			req.Query[k] = r.URL.Query().Get(k)
+		}
+		json.Unmarshal(raw, &req.Body)
+
+		p.mu.Lock()
+		p.requests = append(p.requests, req)
+		status, reply := p.status, p.reply
+		for k, v := range p.header {
+			w.Header()[k] = v
+		}
+		p.mu.Unlock()
+		w.WriteHeader(status)
+		w.Write([]byte(reply))
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+// last returns the last request received.
+func (p *platform) last(t *testing.T) request {
+	t.Helper()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.requests) == 0 {
+		t.Fatal("no request received")
+	}
+	return p.requests[len(p.requests)-1]
+}
+
+// lookup returns the value at a dot-separated path of a decoded JSON body, with
+// numbers indexing arrays.
+func lookup(body interface{}, path string) interface{} {
+	v := body
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			v = node[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i >= len(node) {
+				return nil
+			}
+			v = node[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+func testMessage() Message {
+	return Message{
+		Event:    "SITE_DOWN",
+		Severity: SeverityCritical,
+		Domain:   "example.com",
+		Title:    "Site down",
+		Body:     "example.com is not responding",
+		Fields:   []Field{{Name: "Status", Value: "503"}},
+		Time:     time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+		DedupKey: "zenstack/example.com/http",
+		Urgency:  UrgencyCritical,
+	}
+}
+
+func TestNativeNotifiers(t *testing.T) {
+	tests := []struct {
+		platform string
+		want     map[string]interface{} // Values expected at paths of the body
+	}{
+		{PlatformSlack, map[string]interface{}{
+			"text":                   "Site down: example.com is not responding",
+			"blocks.0.text.text":     ":rotating_light: Site down",
+			"blocks.2.fields.0.text": "*Status*\n503",
+		}},
+		{PlatformDingTalk, map[string]interface{}{
+			"msgtype":        "markdown",
+			"markdown.title": "Site down",
+			"markdown.text":  "### Site down\n\nexample.com is not responding\n\n**Status:** 503",
+		}},
+		{PlatformFeishu, map[string]interface{}{
+			"msg_type":                     "interactive",
+			"card.header.title.content":    "Site down",
+			"card.elements.0.text.content": "example.com is not responding",
+		}},
+		{PlatformWeCom, map[string]interface{}{
+			"msgtype": "markdown",
+		}},
+		{PlatformDiscord, map[string]interface{}{
+			"embeds.0.timestamp": "2026-01-02T03:04:05Z",
+		}},
+		{PlatformTeams, map[string]interface{}{
+			"type": "message",
+		}},
+		{"custom", map[string]interface{}{
+			"event":  "SITE_DOWN",
+			"domain": "example.com",
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.platform, func(t *testing.T) {
+			p := newPlatform(t)
+			n := NewNotifier(WebhookSettings{Platform: tt.platform, URL: p.URL + "/hook"})
+			if err := n.Send(context.Background(), testMessage()); err != nil {
+				t.Fatal(err)
+			}
+			req := p.last(t)
+			if req.Method != http.MethodPost || req.Path != "/hook" {
+				t.Errorf("request %s %s, want POST /hook", req.Method, req.Path)
+			}
+			if ct := req.Header.Get("Content-Type"); ct != "application/json" {
+				t.Errorf("content type %q", ct)
+			}
+			for path, want := range tt.want {
+				if got := lookup(req.Body, path); got != want {
+					t.Errorf("%s = %#v, want %#v", path, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDingTalkSignsRequests(t *testing.T) {
+	p := newPlatform(t)
+	now := time.UnixMilli(1_700_000_000_000)
+	n := &DingTalkNotifier{WebhookURL: p.URL + "/robot/send?access_token=abc", Secret: "SEC1", Now: func() time.Time { return now }}
+	if err := n.Send(context.Background(), testMessage()); err != nil {
+		t.Fatal(err)
+	}
+
+	q := p.last(t).Query
+	if q["access_token"] != "abc" || q["timestamp"] != "1700000000000" || q["sign"] != DingTalkSign(now.UnixMilli(), "SEC1") {
+		t.Errorf("query = %v", q)
+	}
+}
+
+func TestRobotErrorCodes(t *testing.T) {
+	for _, platform := range []string{PlatformDingTalk, PlatformWeCom} {
+		t.Run(platform, func(t *testing.T) {
+			p := newPlatform(t)
+			p.reply = `{"errcode":310000,"errmsg":"keywords not in content"}`
+			err := NewNotifier(WebhookSettings{Platform: platform, URL: p.URL}).Send(context.Background(), testMessage())
+			if err == nil || !strings.Contains(err.Error(), "keywords not in content (code: 310000)") {
+				t.Errorf("got %v, want the robot API error", err)
+			}
+		})
+	}
+
+	p := newPlatform(t)
+	p.reply = `{"code":19021,"msg":"sign match fail"}`
+	err := NewNotifier(WebhookSettings{Platform: PlatformFeishu, URL: p.URL}).Send(context.Background(), testMessage())
+	if err == nil || !strings.Contains(err.Error(), "sign match fail") {
+		t.Errorf("feishu: got %v, want the API error", err)
+	}
+}
+
+func TestPagerDuty(t *testing.T) {
+	p := newPlatform(t)
+	p.status = http.StatusAccepted
+	// The configured URL may include the endpoint path
+	n := NewNotifier(WebhookSettings{Platform: "PagerDuty", URL: p.URL + "/v2/enqueue/", Secret: "R0UTING"})
+
+	if err := n.Send(context.Background(), testMessage()); err != nil {
+		t.Fatal(err)
+	}
+	req := p.last(t)
+	if req.Path != "/v2/enqueue" {
+		t.Errorf("path = %s, want /v2/enqueue", req.Path)
+	}
+	for path, want := range map[string]interface{}{
+		"routing_key":                   "R0UTING",
+		"event_action":                  "trigger",
+		"dedup_key":                     "zenstack/example.com/http",
+		"payload.summary":               "Site down: example.com is not responding",
+		"payload.source":                "example.com",
+		"payload.severity":              "critical",
+		"payload.timestamp":             "2026-01-02T03:04:05Z",
+		"payload.class":                 "SITE_DOWN",
+		"payload.custom_details.Status": "503",
+	} {
+		if got := lookup(req.Body, path); got != want {
+			t.Errorf("trigger %s = %#v, want %#v", path, got, want)
+		}
+	}
+
+	resolve := testMessage()
+	resolve.Event, resolve.Resolve = "SITE_UP", true
+	if err := n.Send(context.Background(), resolve); err != nil {
+		t.Fatal(err)
+	}
+	req = p.last(t)
+	if req.Body["event_action"] != "resolve" || req.Body["dedup_key"] != "zenstack/example.com/http" || req.Body["payload"] != nil {
+		t.Errorf("resolve body = %v", req.Body)
+	}
+}
+
+func TestOpsgenie(t *testing.T) {
+	p := newPlatform(t)
+	p.status = http.StatusAccepted
+	n := NewNotifier(WebhookSettings{Platform: PlatformOpsgenie, URL: p.URL, Secret: "G3NIE"})
+
+	if err := n.Send(context.Background(), testMessage()); err != nil {
+		t.Fatal(err)
+	}
+	req := p.last(t)
+	if req.Path != "/v2/alerts" {
+		t.Errorf("path = %s, want /v2/alerts", req.Path)
+	}
+	if auth := req.Header.Get("Authorization"); auth != "GenieKey G3NIE" {
+		t.Errorf("authorization = %q", auth)
+	}
+	for path, want := range map[string]interface{}{
+		"message":        "Site down: example.com",
+		"alias":          "zenstack/example.com/http",
+		"priority":       "P1",
+		"entity":         "example.com",
+		"tags.1":         "SITE_DOWN",
+		"details.Status": "503",
+	} {
+		if got := lookup(req.Body, path); got != want {
+			t.Errorf("create %s = %#v, want %#v", path, got, want)
+		}
+	}
+
+	resolve := testMessage()
+	resolve.Event, resolve.Resolve = "SITE_UP", true
+	if err := n.Send(context.Background(), resolve); err != nil {
+		t.Fatal(err)
+	}
+	req = p.last(t)
+	if req.Path != "/v2/alerts/zenstack/example.com/http/close" || req.Query["identifierType"] != "alias" {
+		t.Errorf("close request %s?%v", req.Path, req.Query)
+	}
+	if auth := req.Header.Get("Authorization"); auth != "GenieKey G3NIE" {
+		t.Errorf("close authorization = %q", auth)
+	}
+}
+
+func TestIncidentPlatformsRequireKeys(t *testing.T) {
+	for _, platform := range []string{PlatformPagerDuty, PlatformOpsgenie} {
+		err := NewNotifier(WebhookSettings{Platform: platform}).Send(context.Background(), testMessage())
+		if err == nil {
+			t.Errorf("%s: sent without a key", platform)
+		}
+	}
+}
+
+func TestStatusErrors(t *testing.T) {
+	p := newPlatform(t)
+	n := NewNotifier(WebhookSettings{Platform: PlatformPagerDuty, URL: p.URL, Secret: "R0UTING"})
+
+	p.status, p.reply = http.StatusBadRequest, `{"status":"invalid event"}`
+	var permanent *PermanentError
+	if err := n.Send(context.Background(), testMessage()); !errors.As(err, &permanent) {
+		t.Errorf("400: got %v, want a PermanentError", err)
+	}
+
+	p.status = http.StatusTooManyRequests
+	p.header.Set("Retry-After", "30")
+	var retry *RetryAfterError
+	if err := n.Send(context.Background(), testMessage()); !errors.As(err, &retry) || retry.After != 30*time.Second {
+		t.Errorf("429: got %v, want a RetryAfterError after 30s", err)
+	}
+
+	p.status = http.StatusBadGateway
+	err := n.Send(context.Background(), testMessage())
+	if err == nil || errors.As(err, &permanent) || errors.As(err, &retry) {
+		t.Errorf("502: got %v, want a temporary error", err)
+	}
+}
@@ -2,16 +2,19 @@ package notify
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"os"
 	"regexp"
 	"strings"
 	"time"
 
 	"github.com/harveywai/zenstack/pkg/database"
+	"github.com/harveywai/zenstack/pkg/metrics"
 )
 
 // formatMessage replaces placeholders in a template string with actual values from the data map.
@@ -55,21 +58,36 @@ type NotificationPayload struct {
 	Extra  map[string]interface{} `json:"extra,omitempty"`
 }
 
-// SendNotification sends a notification to all active webhook configurations for a given event.
-// It retrieves the message template for the event, formats it with the provided data,
-// and sends POST requests to all active notification configs.
+// SendNotification queues a notification for a given event to the channels selected by
+// the routing rules, or to all active channels when no rule matches. The outbox
+// delivers it with retries; see EnqueueNotification.
 func SendNotification(eventName string, domain database.MonitoredDomain, extraData map[string]string) error {
 	if database.DB == nil {
 		return fmt.Errorf("database not initialized")
 	}
+	return EnqueueNotification(database.DB, eventName, domain, extraData)
+}
+
+// BuildMessage renders the message template of an event and returns the message
+// along with the routing input of the event.
+func BuildMessage(eventName string, domain database.MonitoredDomain, extraData map[string]string) (Message, RouteInput, error) {
+	if database.DB == nil {
+		return Message{}, RouteInput{}, fmt.Errorf("database not initialized")
+	}
 
 	// Get message template for this event
 	var template database.MessageTemplate
-	if err := database.DB.Where("event_name = ?", eventName).First(&template).Error; err != nil {
+	if err := database.DB.Preload("Variants").Where("event_name = ?", eventName).First(&template).Error; err != nil {
 		log.Printf("No template found for event %s, skipping notification", eventName)
-		return fmt.Errorf("no template found for event: %s", eventName)
+		return Message{}, RouteInput{}, fmt.Errorf("%w for event %s", ErrNoTemplate, eventName)
 	}
 
+	msg, in := buildMessage(template, eventName, domain, extraData)
+	return msg, in, nil
+}
+
+// buildMessage renders a message template with the data of an event.
+func buildMessage(template database.MessageTemplate, eventName string, domain database.MonitoredDomain, extraData map[string]string) (Message, RouteInput) {
 	// Prepare data map for template formatting
 	data := make(map[string]string)
 	data["domain"] = domain.DomainName
@@ -85,128 +103,156 @@ func SendNotification(eventName string, domain database.MonitoredDomain, extraDa
 		data[k] = v
 	}
 
-	// Format title and body templates
-	title := formatMessage(template.TitleTemplate, data)
-	body := formatMessage(template.BodyTemplate, data)
-
-	// Format Telegram template text if available
-	telegramText := formatMessage(template.TemplateText, data)
-	if telegramText == "" {
-		// Fallback to body template if TemplateText is empty
-		telegramText = body
-	}
-
-	// Get all active notification configs (webhooks)
-	var configs []database.NotificationConfig
-	if err := database.DB.Where("is_active = ?", true).Find(&configs).Error; err != nil {
-		log.Printf("Error fetching notification configs: %v", err)
-		return err
-	}
-
-	// Get all active Telegram notification configs
-	var telegramConfigs []database.NotifyConfig
-	if err := database.DB.Where("is_active = ?", true).Find(&telegramConfigs).Error; err != nil {
-		log.Printf("Error fetching Telegram notification configs: %v", err)
-		// Don't return error, just log it
-	}
-
-	// Prepare webhook payload
-	payload := NotificationPayload{
-		Title:  title,
-		Body:   body,
-		Event:  eventName,
-		Domain: domain.DomainName,
-		Time:   time.Now().Format(time.RFC3339),
+	msg := Message{
+		Event:    eventName,
+		Severity: Severity(eventName),
+		Domain:   domain.DomainName,
+		Fields:   messageFields(domain, extraData),
 		Extra: map[string]interface{}{
 			"ssl_expiry":     domain.SSLExpiry.Format(time.RFC3339),
 			"ssl_status":     domain.SSLStatus,
 			"days_remaining": int(time.Until(domain.SSLExpiry).Hours() / 24),
 			"registrar":      domain.Registrar,
 		},
+		Time: time.Now(),
 	}
+	msg.source = &renderSource{template: template, data: templateData(msg, domain, data)}
 
-	// Send to all active webhooks
-	successCount := 0
-	for _, config := range configs {
-		if err := sendWebhook(config, payload); err != nil {
-			log.Printf("Failed to send notification to %s (%s): %v", config.Platform, config.WebhookURL, err)
-		} else {
-			successCount++
-			log.Printf("Successfully sent notification to %s for domain %s", config.Platform, domain.DomainName)
-		}
+	// Synthetic checks without a domain are identified by the check name
+	subject := domain.DomainName
+	if subject == "" {
+		subject = data["check"]
 	}
+	msg.DedupKey, msg.Resolve = AlertKey(eventName, subject)
+	msg.Urgency = Urgency(eventName, data["ssl_status"])
+	msg.source.data["urgency"] = msg.Urgency
+
+	// Render in the default locale; channels render their own format and locale
+	msg = msg.Render("", FormatPlain)
 
-	// Send to all active Telegram bots
-	for _, tgConfig := range telegramConfigs {
-		if err := SendTelegramMessage(tgConfig.TGToken, tgConfig.TGChatID, telegramText); err != nil {
-			log.Printf("Failed to send Telegram notification (chat_id: %s): %v", tgConfig.TGChatID, err)
+	if !msg.Resolve && domain.ID != 0 {
+		if link, err := SnoozeURL(domain.ID); err == nil {
+			msg.Actions = []Action{{Label: "Snooze", URL: link}}
 		} else {
-			successCount++
-			log.Printf("Successfully sent Telegram notification for domain %s", domain.DomainName)
+			log.Printf("Error signing snooze link for %s: %v", domain.DomainName, err)
 		}
 	}
 
-	if successCount == 0 {
-		return fmt.Errorf("failed to send notification to any channel")
+	return msg, RouteInput{
+		Event:        eventName,
+		Tags:         domain.Tags,
+		CustomStatus: domain.CustomStatus,
+		Severity:     msg.Urgency,
+		Resolve:      msg.Resolve,
 	}
-
-	return nil
 }
 
-// sendWebhook sends a POST request to a specific webhook URL with the notification payload
-func sendWebhook(config database.NotificationConfig, payload NotificationPayload) error {
-	// Marshal payload to JSON
-	jsonData, err := json.Marshal(payload)
-	if err != nil {
-		return fmt.Errorf("failed to marshal payload: %w", err)
+// TemplateMessage renders the message template of an event that isn't about a single
+// domain, such as a digest, with the given data.
+func TemplateMessage(eventName string, data map[string]interface{}) (Message, error) {
+	if database.DB == nil {
+		return Message{}, database.ErrDatabaseNotInitialized
 	}
 
-	// Create HTTP request
-	req, err := http.NewRequest("POST", config.WebhookURL, bytes.NewBuffer(jsonData))
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+	var template database.MessageTemplate
+	if err := database.DB.Preload("Variants").Where("event_name = ?", eventName).First(&template).Error; err != nil {
+		return Message{}, fmt.Errorf("%w for event %s", ErrNoTemplate, eventName)
 	}
 
-	// Set headers
-	req.Header.Set("Content-Type", "application/json")
-	if config.SecretKey != "" {
-		// Add secret key as Authorization header or custom header based on platform
-		switch strings.ToLower(config.Platform) {
-		case "slack":
-			// Slack uses Bearer token
-			req.Header.Set("Authorization", "Bearer "+config.SecretKey)
-		case "dingtalk", "feishu":
-			// DingTalk and Feishu might use custom headers
-			req.Header.Set("X-Secret-Key", config.SecretKey)
-		default:
-			// Generic webhook - use Authorization header
-			req.Header.Set("Authorization", "Bearer "+config.SecretKey)
-		}
+	msg := Message{
+		Event:    eventName,
+		Severity: Severity(eventName),
+		Time:     time.Now(),
 	}
-
-	// Send request with timeout
-	client := &http.Client{
-		Timeout: 10 * time.Second,
+	source := map[string]interface{}{"event": eventName, "severity": msg.Severity, "time": msg.Time}
+	for k, v := range data {
+		source[k] = v
 	}
+	msg.source = &renderSource{template: template, data: source}
+	return msg.Render("", FormatPlain), nil
+}
 
-	resp, err := client.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+// templateData returns the data of message templates: the event data as strings,
+// which {{key}} placeholders use, and typed values for template functions.
+func templateData(msg Message, domain database.MonitoredDomain, data map[string]string) map[string]interface{} {
+	out := make(map[string]interface{}, len(data)+8)
+	for k, v := range data {
+		out[k] = v
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
-		return fmt.Errorf("webhook returned status code %d", resp.StatusCode)
+	// The expiry reported by the check is more recent than the stored one
+	expiry := domain.SSLExpiry
+	if t, ok := toTime(data["expiry"]); ok {
+		expiry = t
 	}
+	out["ssl_expiry"] = expiry
+	out["event"] = msg.Event
+	out["severity"] = msg.Severity
+	out["time"] = msg.Time
+	out["fields"] = msg.Fields
+	var tags []string
+	for _, t := range strings.Split(domain.Tags, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	out["tags"] = tags
+	return out
+}
 
-	return nil
+// messageFieldLabels lists the event data shown as message fields, in order.
+var messageFieldLabels = []Field{
+	{Name: "status_code", Value: "Status Code"},
+	{Name: "error_class", Value: "Error"},
+	{Name: "response_time", Value: "Response Time (ms)"},
+	{Name: "days_remaining", Value: "Days Remaining"},
+	{Name: "expiry_date", Value: "Expiry Date"},
+	{Name: "change_percent", Value: "Changed (%)"},
+	{Name: "check", Value: "Check"},
+	{Name: "step", Value: "Step"},
+	{Name: "phases", Value: "Phases"},
+}
+
+// messageFields returns the domain and the known event data as message fields.
+func messageFields(domain database.MonitoredDomain, data map[string]string) []Field {
+	var fields []Field
+	if domain.DomainName != "" {
+		fields = append(fields, Field{Name: "Domain", Value: domain.DomainName})
+	}
+	for _, l := range messageFieldLabels {
+		if v := data[l.Name]; v != "" {
+			fields = append(fields, Field{Name: l.Value, Value: v})
+		}
+	}
+	return fields
 }
 
 // sendTGMessage sends a message to Telegram using the Bot API
-// It uses http.Post to send a POST request to https://api.telegram.org/bot<token>/sendMessage
+// It uses http.Post to send a POST request to <TelegramAPIURL>/bot<token>/sendMessage
 // Parameters: chat_id and text
 // This is the internal implementation function
-func sendTGMessage(token string, chatID string, content string) error {
+func sendTGMessage(token string, chatID string, content string) (err error) {
+	defer func() {
+		if err != nil {
+			metrics.NotificationFailures.WithLabelValues("telegram").Inc()
+		}
+	}()
+	return postTelegram(context.Background(), token, chatID, content, nil)
+}
+
+// TelegramAPIURL returns the base URL of the Telegram Bot API: ZENSTACK_TELEGRAM_API_URL,
+// e.g. a local Bot API server or a stub in tests, or https://api.telegram.org.
+func TelegramAPIURL() string {
+	base := strings.TrimRight(os.Getenv("ZENSTACK_TELEGRAM_API_URL"), "/")
+	if base == "" {
+		base = "https://api.telegram.org"
+	}
+	return base
+}
+
+// postTelegram calls sendMessage of the Telegram Bot API. A non-nil markup is sent as
+// the reply_markup of the message, e.g. an inline keyboard.
+func postTelegram(ctx context.Context, token string, chatID string, content string, markup interface{}) error {
 	if token == "" || chatID == "" {
 		return fmt.Errorf("telegram token and chat_id are required")
 	}
@@ -216,13 +262,16 @@ func sendTGMessage(token string, chatID string, content string) error {
 	}
 
 	// Construct the API URL
-	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", token)
+	apiURL := fmt.Sprintf("%s/bot%s/sendMessage", TelegramAPIURL(), token)
 
 	// Prepare the request payload
-	payload := map[string]string{
+	payload := map[string]interface{}{
 		"chat_id": chatID,
 		"text":    content,
 	}
+	if markup != nil {
+		payload["reply_markup"] = markup
+	}
 
 	// Marshal payload to JSON
 	jsonData, err := json.Marshal(payload)
@@ -231,7 +280,7 @@ func sendTGMessage(token string, chatID string, content string) error {
 	}
 
 	// Create HTTP request with POST method
-	req, err := http.NewRequest("POST", apiURL, bytes.NewBuffer(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", apiURL, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
@@ -246,9 +295,10 @@ func sendTGMessage(token string, chatID string, content string) error {
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		return fmt.Errorf("failed to send request: %w", requestError(err))
 	}
 	defer resp.Body.Close()
+	traceStatus(ctx, resp.StatusCode)
 
 	// Read response body for error details
 	bodyBytes, err := io.ReadAll(resp.Body)
@@ -263,11 +313,19 @@ func sendTGMessage(token string, chatID string, content string) error {
 			OK          bool   `json:"ok"`
 			ErrorCode   int    `json:"error_code,omitempty"`
 			Description string `json:"description,omitempty"`
+			Parameters  struct {
+				RetryAfter int `json:"retry_after,omitempty"`
+			} `json:"parameters"`
 		}
 		if err := json.Unmarshal(bodyBytes, &errorResp); err == nil {
-			return fmt.Errorf("telegram API error: %s (code: %d)", errorResp.Description, errorResp.ErrorCode)
+			apiErr := fmt.Errorf("telegram API error: %s (code: %d)", errorResp.Description, errorResp.ErrorCode)
+			if errorResp.Parameters.RetryAfter > 0 {
+				// Flood control: Telegram tells how long to wait before the next message
+				return &RetryAfterError{After: time.Duration(errorResp.Parameters.RetryAfter) * time.Second, Err: apiErr}
+			}
+			return classifyStatus(resp, apiErr)
 		}
-		return fmt.Errorf("telegram API returned status code %d: %s", resp.StatusCode, string(bodyBytes))
+		return classifyStatus(resp, fmt.Errorf("telegram API returned status code %d: %s", resp.StatusCode, string(bodyBytes)))
 	}
 
 	// Verify response is OK
@@ -284,7 +342,7 @@ func sendTGMessage(token string, chatID string, content string) error {
 }
 
 // SendTelegramMessage is an exported alias for sendTGMessage
-// It uses http.Post to send a POST request to https://api.telegram.org/bot<token>/sendMessage
+// It uses http.Post to send a POST request to <TelegramAPIURL>/bot<token>/sendMessage
 // Parameters: chat_id and text
 // This function is exported so it can be called directly from other packages
 func SendTelegramMessage(token string, chatID string, content string) error {
@@ -305,14 +363,18 @@ func SendTelegramAlert(message string) error {
 		return fmt.Errorf("message cannot be empty")
 	}
 
-	// Get active Telegram config from database
-	var config database.NotifyConfig
-	if err := database.DB.Where("is_active = ?", true).First(&config).Error; err != nil {
-		return fmt.Errorf("no active Telegram configuration found: %w", err)
+	// Get the first active Telegram channel from the database
+	s, err := ActiveSettings(ChannelTelegram)
+	if err != nil {
+		return fmt.Errorf("failed to load Telegram channel: %w", err)
+	}
+	if s == nil {
+		return fmt.Errorf("no active Telegram channel found")
 	}
+	config := s.(*TelegramSettings)
 
-	// Send message using the active configuration
-	return sendTGMessage(config.TGToken, config.TGChatID, message)
+	// Send message using the active channel
+	return sendTGMessage(config.Token, config.ChatID, message)
 }
 
 // NotifyTelegram is an alias for SendTelegramAlert (backward compatibility)
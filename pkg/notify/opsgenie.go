@@ -0,0 +1,79 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// DefaultOpsgenieURL is the base URL of the Opsgenie API. EU accounts use
+// https://api.eu.opsgenie.com.
+const DefaultOpsgenieURL = "https://api.opsgenie.com"
+
+// opsgeniePriorities maps urgencies to Opsgenie alert priorities.
+var opsgeniePriorities = map[string]string{
+	UrgencyCritical: "P1",
+	UrgencyError:    "P2",
+	UrgencyWarning:  "P3",
+	UrgencyInfo:     "P5",
+}
+
+// OpsgenieNotifier creates and closes Opsgenie alerts. The message's DedupKey is used
+// as the alert alias, which Opsgenie deduplicates on while the alert is open.
+type OpsgenieNotifier struct {
+	APIKey  string // API key of an API integration
+	BaseURL string // Default DefaultOpsgenieURL
+	Client  *http.Client
+}
+
+// Platform implements Notifier.
+func (n *OpsgenieNotifier) Platform() string { return PlatformOpsgenie }
+
+// Send implements Notifier.
+func (n *OpsgenieNotifier) Send(ctx context.Context, msg Message) error {
+	if n.APIKey == "" {
+		return fmt.Errorf("opsgenie API key is required")
+	}
+	base := trimURL(n.BaseURL, "/v2/alerts")
+	if base == "" {
+		base = DefaultOpsgenieURL
+	}
+	header := http.Header{}
+	header.Set("Authorization", "GenieKey "+n.APIKey)
+
+	if msg.Resolve {
+		endpoint := base + "/v2/alerts/" + url.PathEscape(msg.DedupKey) + "/close?identifierType=alias"
+		_, err := postJSON(ctx, n.Client, endpoint, map[string]string{
+			"source": "ZenStack",
+			"note":   truncate(msg.Title+": "+msg.text(), 25000),
+		}, header)
+		return err
+	}
+
+	details := make(map[string]string, len(msg.Fields)+1)
+	for _, f := range msg.Fields {
+		details[f.Name] = f.Value
+	}
+	details["event"] = msg.Event
+
+	priority := opsgeniePriorities[msg.Urgency]
+	if priority == "" {
+		priority = "P3"
+	}
+	message := msg.Title
+	if msg.Domain != "" {
+		message += ": " + msg.Domain
+	}
+	_, err := postJSON(ctx, n.Client, base+"/v2/alerts", map[string]interface{}{
+		"message":     truncate(message, 130),
+		"alias":       truncate(msg.DedupKey, 512),
+		"description": truncate(msg.text(), 15000),
+		"priority":    priority,
+		"source":      "ZenStack",
+		"entity":      msg.Domain,
+		"tags":        []string{"zenstack", msg.Event},
+		"details":     details,
+	}, header)
+	return err
+}
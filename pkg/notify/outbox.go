@@ -0,0 +1,340 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"github.com/harveywai/zenstack/pkg/metrics"
+	"gorm.io/gorm"
+)
+
+// Outbox message states.
+const (
+	OutboxPending   = "pending"   // Waiting for its next attempt
+	OutboxHeld      = "held"      // Held back by quiet hours until NextAttemptAt, unless a digest includes it first
+	OutboxSending   = "sending"   // Claimed by a worker until NextAttemptAt
+	OutboxDelivered = "delivered" // Accepted by the channel
+	OutboxDead      = "dead"      // Retries exhausted or failed permanently; replayable by admins
+)
+
+// Outbox delivery settings.
+const (
+	// OutboxMaxAttempts is the number of attempts before a message is dead-lettered.
+	// With the backoff below the last attempt happens about an hour after the first.
+	OutboxMaxAttempts = 8
+
+	outboxBaseDelay = 15 * time.Second
+	outboxMaxDelay  = 30 * time.Minute
+	outboxLease     = 2 * time.Minute // Claimed messages are retried if a worker died mid-delivery
+	outboxTimeout   = 30 * time.Second
+	outboxWorkers   = 4
+	outboxBatch     = 100
+)
+
+// ErrNoChannel is returned for events no active channel or escalation policy is
+// routed to.
+var ErrNoChannel = errors.New("no active channel")
+
+// ErrNoTemplate is returned for events without a message template.
+var ErrNoTemplate = errors.New("no message template")
+
+// EnqueueNotification renders and routes an event and stores one outbox message per
+// routed channel in tx. Callers pass the transaction of the state change causing the
+// notification, so that both are committed or neither. Silenced events are recorded
+// as suppressed instead.
+func EnqueueNotification(tx *gorm.DB, eventName string, domain database.MonitoredDomain, extraData map[string]string) error {
+	if CheckSilence(tx, eventName, domain, PathNotification) != nil {
+		return nil
+	}
+
+	msg, in, err := BuildMessage(eventName, domain, extraData)
+	if err != nil {
+		return err
+	}
+
+	route, err := RouteEvent(in)
+	if err != nil {
+		log.Printf("Error routing %s notification: %v", eventName, err)
+		return err
+	}
+	if len(route.Channels) == 0 {
+		if len(route.Policies()) > 0 {
+			// The event only pages through escalation policies
+			return nil
+		}
+		return fmt.Errorf("%w for event %s", ErrNoChannel, eventName)
+	}
+
+	if window, by := route.Grouping(); window > 0 {
+		return EnqueueGrouped(tx, msg, route.Channels, window, GroupKey(msg, by))
+	}
+	return Enqueue(tx, msg, route.Channels)
+}
+
+// Enqueue stores a message for delivery to each of the channels. Messages to
+// channels in quiet hours are held, see QuietHours.
+func Enqueue(tx *gorm.DB, msg Message, channels []Channel) error {
+	if tx == nil {
+		return database.ErrDatabaseNotInitialized
+	}
+	if len(channels) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	rows := make([]database.OutboxMessage, 0, len(channels))
+	for _, ch := range channels {
+		// Each channel gets the message in its own format and locale
+		row, err := newOutboxMessage(ch.Ref, ch.Platform, msg.For(ch), now)
+		if err != nil {
+			return err
+		}
+		holdOutbox(&row, ch.Quiet, msg, now)
+		rows = append(rows, row)
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return fmt.Errorf("failed to enqueue notification: %w", err)
+	}
+	return nil
+}
+
+// newOutboxMessage returns a pending outbox message for a channel.
+func newOutboxMessage(ref, platform string, msg Message, now time.Time) (database.OutboxMessage, error) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return database.OutboxMessage{}, fmt.Errorf("failed to encode message: %w", err)
+	}
+	return database.OutboxMessage{
+		Channel:       ref,
+		Platform:      platform,
+		Event:         msg.Event,
+		Domain:        msg.Domain,
+		DedupKey:      msg.DedupKey,
+		DeliveryID:    NewDeliveryID(),
+		Payload:       string(payload),
+		Status:        OutboxPending,
+		MaxAttempts:   OutboxMaxAttempts,
+		NextAttemptAt: now,
+	}, nil
+}
+
+// ProcessOutbox delivers the messages that are due, including those whose worker
+// lease expired or whose quiet hours ended, and returns the number delivered.
+func ProcessOutbox(ctx context.Context) int {
+	if database.DB == nil {
+		return 0
+	}
+
+	var due []database.OutboxMessage
+	err := database.DB.
+		Where("status IN ? AND next_attempt_at <= ?", []string{OutboxPending, OutboxSending, OutboxHeld}, time.Now()).
+		Order("next_attempt_at asc, id asc").
+		Limit(outboxBatch).
+		Find(&due).Error
+	if err != nil {
+		log.Printf("Error loading notification outbox: %v", err)
+		return 0
+	}
+
+	jobs := make(chan database.OutboxMessage)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	delivered := 0
+	for i := 0; i < outboxWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for m := range jobs {
+				if deliverOutbox(ctx, m) {
+					mu.Lock()
+					delivered++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for _, m := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		jobs <- m
+	}
+	close(jobs)
+	wg.Wait()
+
+	var pending int64
+	database.DB.Model(&database.OutboxMessage{}).Where("status IN ?", []string{OutboxPending, OutboxSending}).Count(&pending)
+	metrics.QueueDepth.WithLabelValues("notification-outbox").Set(float64(pending))
+	return delivered
+}
+
+// deliverOutbox claims a message, sends it and records the result. It reports
+// whether the message was delivered.
+func deliverOutbox(ctx context.Context, m database.OutboxMessage) bool {
+	if m.Status == OutboxSending && m.Attempts >= m.MaxAttempts {
+		// The worker of the last attempt never reported back
+		finishOutbox(m, OutboxDead, m.Attempts, "delivery did not complete", time.Now())
+		return false
+	}
+
+	// Claim the message; another worker or replica may have been faster
+	claim := database.DB.Model(&database.OutboxMessage{}).
+		Where("id = ? AND status = ? AND attempts = ?", m.ID, m.Status, m.Attempts).
+		Updates(map[string]interface{}{
+			"status":          OutboxSending,
+			"attempts":        m.Attempts + 1,
+			"next_attempt_at": time.Now().Add(outboxLease),
+		})
+	if claim.Error != nil || claim.RowsAffected == 0 {
+		return false
+	}
+	m.Attempts++
+
+	err := sendOutbox(ctx, m)
+	if err == nil {
+		finishOutbox(m, OutboxDelivered, m.Attempts, "", time.Now())
+		return true
+	}
+
+	var permanent *PermanentError
+	if errors.As(err, &permanent) || m.Attempts >= m.MaxAttempts {
+		log.Printf("Giving up notification %d to %s after %d attempt(s): %v", m.ID, m.Channel, m.Attempts, err)
+		metrics.NotificationDeadLetters.WithLabelValues(m.Platform).Inc()
+		finishOutbox(m, OutboxDead, m.Attempts, err.Error(), time.Now())
+		return false
+	}
+
+	delay := outboxBackoff(m.Attempts, err)
+	log.Printf("Notification %d to %s failed (attempt %d/%d), retrying in %s: %v", m.ID, m.Channel, m.Attempts, m.MaxAttempts, delay.Round(time.Second), err)
+	finishOutbox(m, OutboxPending, m.Attempts, err.Error(), time.Now().Add(delay))
+	return false
+}
+
+// sendOutbox decodes a message and sends it to its channel.
+func sendOutbox(ctx context.Context, m database.OutboxMessage) error {
+	var msg Message
+	if err := json.Unmarshal([]byte(m.Payload), &msg); err != nil {
+		return &PermanentError{Err: fmt.Errorf("invalid payload: %w", err)}
+	}
+	ch, err := LoadChannel(m.Channel)
+	if err != nil {
+		return &PermanentError{Err: err}
+	}
+
+	id := m.DeliveryID
+	if id == "" {
+		// Queued before messages had delivery IDs
+		id = fmt.Sprintf("outbox-%d", m.ID)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, outboxTimeout)
+	defer cancel()
+	return Deliver(ctx, ch.Notifier, msg, DeliveryInfo{
+		Channel:    ch.Ref,
+		Recipient:  ch.Name,
+		OutboxID:   m.ID,
+		Attempt:    m.Attempts,
+		DeliveryID: id,
+	})
+}
+
+// finishOutbox records the result of an attempt. For pending messages at is the time
+// of the next attempt.
+func finishOutbox(m database.OutboxMessage, status string, attempts int, lastError string, at time.Time) {
+	updates := map[string]interface{}{
+		"status":     status,
+		"last_error": lastError,
+	}
+	if status == OutboxDelivered {
+		updates["delivered_at"] = at
+	} else {
+		updates["next_attempt_at"] = at
+	}
+	err := database.DB.Model(&database.OutboxMessage{}).
+		Where("id = ? AND attempts = ?", m.ID, attempts).
+		Updates(updates).Error
+	if err != nil {
+		log.Printf("Error updating notification %d: %v", m.ID, err)
+	}
+}
+
+// outboxBackoff returns the delay before the next attempt: exponential from
+// outboxBaseDelay with equal jitter, or the delay a rate-limited platform asked for.
+func outboxBackoff(attempts int, err error) time.Duration {
+	var retry *RetryAfterError
+	if errors.As(err, &retry) && retry.After > 0 {
+		// A little jitter keeps queued messages from hitting the limit together again
+		return retry.After + time.Duration(rand.Int63n(int64(time.Second)))
+	}
+
+	delay := outboxBaseDelay
+	for i := 1; i < attempts && delay < outboxMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > outboxMaxDelay {
+		delay = outboxMaxDelay
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// ReplayOutbox moves a dead-lettered message back into the queue with fresh retries.
+func ReplayOutbox(id uint) (*database.OutboxMessage, error) {
+	if database.DB == nil {
+		return nil, database.ErrDatabaseNotInitialized
+	}
+
+	var m database.OutboxMessage
+	if err := database.DB.First(&m, id).Error; err != nil {
+		return nil, err
+	}
+	if m.Status != OutboxDead {
+		return &m, fmt.Errorf("only dead-lettered notifications can be replayed (status %s)", m.Status)
+	}
+
+	if _, err := replayOutbox(database.DB.Where("id = ?", id)); err != nil {
+		return nil, err
+	}
+	if err := database.DB.First(&m, id).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// ReplayDeadLetters moves all dead-lettered messages back into the queue.
+func ReplayDeadLetters() (int64, error) {
+	if database.DB == nil {
+		return 0, database.ErrDatabaseNotInitialized
+	}
+	return replayOutbox(database.DB)
+}
+
+func replayOutbox(query *gorm.DB) (int64, error) {
+	result := query.Model(&database.OutboxMessage{}).
+		Where("status = ?", OutboxDead).
+		Updates(map[string]interface{}{
+			"status":          OutboxPending,
+			"attempts":        0,
+			"next_attempt_at": time.Now(),
+		})
+	return result.RowsAffected, result.Error
+}
+
+// CleanupOutbox removes delivered messages older than the retention period. Dead
+// letters are kept until an admin replays or deletes them.
+func CleanupOutbox(retention time.Duration) (int64, error) {
+	if database.DB == nil {
+		return 0, database.ErrDatabaseNotInitialized
+	}
+
+	result := database.DB.
+		Where("status = ? AND created_at < ?", OutboxDelivered, time.Now().Add(-retention)).
+		Delete(&database.OutboxMessage{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,314 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func openTestDB(t *testing.T, models ...interface{}) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	prev := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = prev })
+}
+
+// fakeNotifier answers each send with the next queued HTTP status, or 200 once the
+// queue is empty, and records the domains of the messages it was sent.
+type fakeNotifier struct {
+	mu       sync.Mutex
+	statuses []int
+	header   http.Header
+	sent     []string
+	delay    time.Duration
+}
+
+func (n *fakeNotifier) Platform() string { return "fake" }
+
+func (n *fakeNotifier) Send(ctx context.Context, msg Message) error {
+	n.mu.Lock()
+	status := http.StatusOK
+	if len(n.statuses) > 0 {
+		status, n.statuses = n.statuses[0], n.statuses[1:]
+	}
+	n.sent = append(n.sent, msg.Domain)
+	n.mu.Unlock()
+
+	time.Sleep(n.delay)
+	if status < 300 {
+		return nil
+	}
+	return classifyStatus(&http.Response{StatusCode: status, Header: n.header}, fmt.Errorf("status %d", status))
+}
+
+func (n *fakeNotifier) sends() []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]string(nil), n.sent...)
+}
+
+// fakeSettings are the settings of the fake channel type, which delivers through a
+// fakeNotifier.
+type fakeSettings struct{ n *fakeNotifier }
+
+func (s *fakeSettings) Validate() error    { return nil }
+func (s *fakeSettings) notifier() Notifier { return s.n }
+func (s *fakeSettings) describe() string   { return "fake" }
+
+// fakeChannel opens a test database with a channel delivering through the returned
+// notifier.
+func fakeChannel(t *testing.T) (Channel, *fakeNotifier) {
+	t.Helper()
+	openTestDB(t, &database.Channel{}, &database.OutboxMessage{}, &database.DeliveryAttempt{})
+
+	n := &fakeNotifier{header: http.Header{}}
+	prev := channelTypes
+	channelTypes = append(append([]ChannelType(nil), prev...), ChannelType{Type: "fake", new: func() Settings { return &fakeSettings{n} }})
+	t.Cleanup(func() { channelTypes = prev })
+
+	m := database.Channel{Type: "fake", Name: "fake", IsActive: true}
+	if err := database.DB.Create(&m).Error; err != nil {
+		t.Fatal(err)
+	}
+	ch, err := LoadChannel(ChannelRef(m.Type, m.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ch, n
+}
+
+func enqueueTest(t *testing.T, ch Channel, domains ...string) {
+	t.Helper()
+	for _, domain := range domains {
+		msg := testMessage()
+		msg.Domain = domain
+		if err := Enqueue(database.DB, msg, []Channel{ch}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func outboxRow(t *testing.T) database.OutboxMessage {
+	t.Helper()
+	var m database.OutboxMessage
+	if err := database.DB.First(&m).Error; err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+// makeDue moves the next attempts of all messages into the past.
+func makeDue(t *testing.T) {
+	t.Helper()
+	err := database.DB.Model(&database.OutboxMessage{}).Where("1 = 1").Update("next_attempt_at", time.Now().Add(-time.Second)).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOutboxRetriesAtTheBackoffTime(t *testing.T) {
+	ch, n := fakeChannel(t)
+	n.statuses = []int{http.StatusBadGateway}
+	enqueueTest(t, ch, "example.com")
+
+	before := time.Now()
+	if got := ProcessOutbox(context.Background()); got != 0 {
+		t.Fatalf("delivered %d, want 0", got)
+	}
+	m := outboxRow(t)
+	if m.Status != OutboxPending || m.Attempts != 1 || m.LastError != "status 502" {
+		t.Errorf("after the failure: status %s, %d attempt(s), last error %q", m.Status, m.Attempts, m.LastError)
+	}
+	// The first retry waits between half and all of outboxBaseDelay
+	if wait := m.NextAttemptAt.Sub(before); wait < outboxBaseDelay/2 || wait > outboxBaseDelay+time.Second {
+		t.Errorf("retry in %s, want %s to %s", wait, outboxBaseDelay/2, outboxBaseDelay)
+	}
+
+	// Nothing is sent before the backoff time
+	ProcessOutbox(context.Background())
+	if got := len(n.sends()); got != 1 {
+		t.Fatalf("sent %d times before the retry was due", got)
+	}
+
+	makeDue(t)
+	if got := ProcessOutbox(context.Background()); got != 1 {
+		t.Fatalf("retry delivered %d, want 1", got)
+	}
+	m = outboxRow(t)
+	if m.Status != OutboxDelivered || m.Attempts != 2 || m.DeliveredAt == nil {
+		t.Errorf("after the retry: status %s, %d attempts, delivered at %v", m.Status, m.Attempts, m.DeliveredAt)
+	}
+}
+
+func TestOutboxDeadLettersClientErrors(t *testing.T) {
+	ch, n := fakeChannel(t)
+	n.statuses = []int{http.StatusBadRequest}
+	enqueueTest(t, ch, "example.com")
+
+	ProcessOutbox(context.Background())
+	m := outboxRow(t)
+	if m.Status != OutboxDead || m.Attempts != 1 {
+		t.Fatalf("status %s after %d attempt(s), want dead after 1", m.Status, m.Attempts)
+	}
+	makeDue(t)
+	ProcessOutbox(context.Background())
+	if got := len(n.sends()); got != 1 {
+		t.Errorf("dead letter was sent %d times", got)
+	}
+
+	// Replaying queues it with fresh retries
+	replayed, err := ReplayOutbox(m.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replayed.Status != OutboxPending || replayed.Attempts != 0 {
+		t.Errorf("replayed: status %s, %d attempts", replayed.Status, replayed.Attempts)
+	}
+	if got := ProcessOutbox(context.Background()); got != 1 {
+		t.Errorf("replay delivered %d, want 1", got)
+	}
+	if _, err := ReplayOutbox(m.ID); err == nil {
+		t.Error("replayed a delivered message")
+	}
+}
+
+func TestOutboxDeadLettersAfterMaxAttempts(t *testing.T) {
+	ch, n := fakeChannel(t)
+	for i := 0; i < OutboxMaxAttempts; i++ {
+		n.statuses = append(n.statuses, http.StatusServiceUnavailable)
+	}
+	enqueueTest(t, ch, "example.com")
+
+	for i := 0; i < OutboxMaxAttempts; i++ {
+		makeDue(t)
+		ProcessOutbox(context.Background())
+	}
+	if m := outboxRow(t); m.Status != OutboxDead || m.Attempts != OutboxMaxAttempts {
+		t.Errorf("status %s after %d attempts, want dead after %d", m.Status, m.Attempts, OutboxMaxAttempts)
+	}
+}
+
+func TestOutboxHonoursRetryAfter(t *testing.T) {
+	ch, n := fakeChannel(t)
+	n.statuses = []int{http.StatusTooManyRequests}
+	n.header.Set("Retry-After", "120")
+	enqueueTest(t, ch, "example.com")
+
+	before := time.Now()
+	ProcessOutbox(context.Background())
+	m := outboxRow(t)
+	if m.Status != OutboxPending {
+		t.Fatalf("status %s, want pending", m.Status)
+	}
+	// Up to a second of jitter is added to the requested delay
+	if wait := m.NextAttemptAt.Sub(before); wait < 2*time.Minute || wait > 2*time.Minute+2*time.Second {
+		t.Errorf("retry in %s, want 2m", wait)
+	}
+}
+
+func TestOutboxBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		max      time.Duration
+	}{
+		{1, outboxBaseDelay},
+		{2, 2 * outboxBaseDelay},
+		{4, 8 * outboxBaseDelay},
+		{20, outboxMaxDelay},
+	}
+	for _, tt := range tests {
+		for i := 0; i < 20; i++ {
+			if got := outboxBackoff(tt.attempts, errors.New("failed")); got < tt.max/2 || got > tt.max {
+				t.Errorf("attempt %d: backoff %s, want %s to %s", tt.attempts, got, tt.max/2, tt.max)
+			}
+		}
+	}
+
+	// A Retry-After without a delay falls back to the exponential backoff
+	if got := outboxBackoff(1, &RetryAfterError{Err: errors.New("429")}); got > outboxBaseDelay {
+		t.Errorf("retry after 0: backoff %s", got)
+	}
+}
+
+func TestConcurrentOutboxRunsDeliverOnce(t *testing.T) {
+	ch, n := fakeChannel(t)
+	n.delay = 20 * time.Millisecond
+	var domains []string
+	for i := 0; i < 10; i++ {
+		domains = append(domains, "site"+strconv.Itoa(i)+".example.com")
+	}
+	enqueueTest(t, ch, domains...)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	delivered := 0
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got := ProcessOutbox(context.Background())
+			mu.Lock()
+			delivered += got
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	// A run losing a claim to a locked database leaves the message for the next run
+	delivered += ProcessOutbox(context.Background())
+	if delivered != len(domains) {
+		t.Errorf("delivered %d messages, want %d", delivered, len(domains))
+	}
+	counts := make(map[string]int)
+	for _, domain := range n.sends() {
+		counts[domain]++
+	}
+	for _, domain := range domains {
+		if counts[domain] != 1 {
+			t.Errorf("%s was sent %d times", domain, counts[domain])
+		}
+	}
+}
+
+func TestCleanupOutbox(t *testing.T) {
+	ch, n := fakeChannel(t)
+	enqueueTest(t, ch, "delivered.example.com")
+	ProcessOutbox(context.Background())
+	n.statuses = []int{http.StatusBadRequest}
+	enqueueTest(t, ch, "dead.example.com")
+	ProcessOutbox(context.Background())
+	enqueueTest(t, ch, "recent.example.com")
+	ProcessOutbox(context.Background())
+	database.DB.Model(&database.OutboxMessage{}).Where("domain <> ?", "recent.example.com").Update("created_at", time.Now().Add(-48*time.Hour))
+
+	removed, err := CleanupOutbox(24 * time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if removed != 1 {
+		t.Errorf("removed %d messages, want 1", removed)
+	}
+	var left []string
+	database.DB.Model(&database.OutboxMessage{}).Order("id").Pluck("domain", &left)
+	if fmt.Sprint(left) != "[dead.example.com recent.example.com]" {
+		t.Errorf("kept %v, want the dead letter and the recent message", left)
+	}
+}
@@ -0,0 +1,74 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// DefaultPagerDutyURL is the base URL of the PagerDuty Events API.
+const DefaultPagerDutyURL = "https://events.pagerduty.com"
+
+// PagerDutyNotifier sends trigger and resolve events to the PagerDuty Events API v2.
+// Alerts are deduplicated by the message's DedupKey, so a recovery resolves the
+// incident opened by the failure.
+type PagerDutyNotifier struct {
+	RoutingKey string // Integration key of the service
+	BaseURL    string // Default DefaultPagerDutyURL
+	Client     *http.Client
+}
+
+// Platform implements Notifier.
+func (n *PagerDutyNotifier) Platform() string { return PlatformPagerDuty }
+
+// Send implements Notifier.
+func (n *PagerDutyNotifier) Send(ctx context.Context, msg Message) error {
+	if n.RoutingKey == "" {
+		return fmt.Errorf("pagerduty routing key is required")
+	}
+	base := trimURL(n.BaseURL, "/v2/enqueue")
+	if base == "" {
+		base = DefaultPagerDutyURL
+	}
+
+	event := map[string]interface{}{
+		"routing_key":  n.RoutingKey,
+		"event_action": "trigger",
+		"dedup_key":    msg.DedupKey,
+		"client":       "ZenStack",
+	}
+	if msg.Resolve {
+		// Resolve events only need the dedup key
+		event["event_action"] = "resolve"
+	} else {
+		details := make(map[string]string, len(msg.Fields)+1)
+		for _, f := range msg.Fields {
+			details[f.Name] = f.Value
+		}
+		if msg.Body != "" {
+			details["Details"] = msg.Body
+		}
+
+		source := msg.Domain
+		if source == "" {
+			source = "zenstack"
+		}
+		urgency := msg.Urgency
+		if urgency == "" {
+			urgency = UrgencyError
+		}
+		event["payload"] = map[string]interface{}{
+			"summary":        truncate(msg.Title+": "+msg.text(), 1024),
+			"source":         source,
+			"severity":       urgency,
+			"timestamp":      msg.Time.Format(time.RFC3339),
+			"class":          msg.Event,
+			"component":      msg.Domain,
+			"custom_details": details,
+		}
+	}
+
+	_, err := postJSON(ctx, n.Client, base+"/v2/enqueue", event, nil)
+	return err
+}
@@ -0,0 +1,335 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"text/template/parse"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+)
+
+// Preview is a message template rendered for a locale and format, along with the
+// variables its sources use.
+type Preview struct {
+	Event     string            `json:"event"`
+	Locale    string            `json:"locale"`
+	Format    string            `json:"format"`
+	Title     string            `json:"title"`
+	Body      string            `json:"body"`
+	Text      string            `json:"text"`
+	HTML      string            `json:"html,omitempty"`
+	Blocks    json.RawMessage   `json:"blocks,omitempty"`
+	Fields    []Field           `json:"fields"`
+	Data      map[string]string `json:"data"`      // Event data the template was rendered with
+	Variables []string          `json:"variables"` // Keys referenced by any source of the template
+	Unknown   []string          `json:"unknown"`   // Referenced keys the event doesn't provide
+	Unused    []string          `json:"unused"`    // Event data keys no source references
+	Errors    []string          `json:"errors"`    // Sources failing to render; they fall back to {{key}} substitution
+}
+
+// variableAliases are event data keys carrying the same value. A template using one
+// of them doesn't leave the others unused.
+var variableAliases = [][]string{
+	{"days", "days_remaining"},
+	{"status", "status_code", "code"},
+	{"expiry", "expiry_date", "ssl_expiry"},
+}
+
+// SampleDomain is the domain previews are rendered for when no real one is chosen.
+func SampleDomain() database.MonitoredDomain {
+	return database.MonitoredDomain{
+		DomainName:   "example.com",
+		Registrar:    "Example Registrar",
+		Status:       "Active",
+		SSLExpiry:    time.Now().Add(5 * 24 * time.Hour),
+		SSLStatus:    "Critical",
+		Issuer:       "Example CA",
+		Tags:         "production",
+		CustomStatus: "Production",
+	}
+}
+
+// SampleData returns event data like the checks emit for an event, using the
+// certificate of the domain for certificate events.
+func SampleData(event string, domain database.MonitoredDomain) map[string]string {
+	days := strconv.Itoa(int(time.Until(domain.SSLExpiry).Hours() / 24))
+	switch event {
+	case "SITE_DOWN":
+		return map[string]string{"status": "503", "status_code": "503", "code": "503", "response_time": "1840",
+			"error": "unexpected status code 503", "error_class": "http_5xx"}
+	case "SITE_UP":
+		return map[string]string{"status": "200", "status_code": "200", "code": "200", "response_time": "142"}
+	case "SSL_CRITICAL", "SSL_RENEWED":
+		data := map[string]string{
+			"days":           days,
+			"days_remaining": days,
+			"ssl_status":     domain.SSLStatus,
+			"expiry":         domain.SSLExpiry.Format("2006-01-02 15:04:05"),
+			"expiry_date":    domain.SSLExpiry.Format("2006-01-02"),
+			"issuer":         domain.Issuer,
+		}
+		if event == "SSL_CRITICAL" {
+			data["stage"] = days
+			data["serial"] = domain.SSLSerial
+		}
+		return data
+	case "CONTENT_CHANGED":
+		return map[string]string{"change_percent": "12.5", "change_id": "1", "diff": "- Welcome\n+ Hacked by example"}
+	case "SYNTHETIC_FAILED":
+		return map[string]string{"check": "Login flow", "step": "Submit login form", "step_index": "2",
+			"error": "expected status 200, got 500"}
+	case "SYNTHETIC_RECOVERED":
+		return map[string]string{"check": "Login flow"}
+	case "LATENCY_ANOMALY":
+		return map[string]string{"phases": "ttfb,total", "details": "ttfb 950ms (baseline 120ms), total 1100ms (baseline 260ms)"}
+	}
+	return map[string]string{}
+}
+
+// PreviewTemplate renders a message template with the data of an event about a
+// domain, or about SampleDomain if the domain has no name. Sample event data is
+// overridden by data. The returned message can be sent like a regular notification.
+func PreviewTemplate(t database.MessageTemplate, domain database.MonitoredDomain, data map[string]string, locale, format string) (Message, Preview) {
+	if domain.DomainName == "" {
+		domain = SampleDomain()
+	}
+	if locale == "" {
+		locale = DefaultLocale()
+	}
+	if format == "" {
+		format = FormatPlain
+	}
+
+	eventData := SampleData(t.EventName, domain)
+	for k, v := range data {
+		eventData[k] = v
+	}
+	msg, _ := buildMessage(t, t.EventName, domain, eventData)
+	source := msg.source
+	msg = msg.Render(locale, format)
+
+	p := Preview{
+		Event:  t.EventName,
+		Locale: locale,
+		Format: format,
+		Title:  msg.Title,
+		Body:   msg.Body,
+		Text:   msg.text(),
+		HTML:   msg.HTML,
+		Blocks: msg.Blocks,
+		Fields: msg.Fields,
+		Data:   eventData,
+
+		Variables: []string{},
+		Unknown:   []string{},
+		Unused:    []string{},
+		Errors:    []string{},
+	}
+
+	used := make(map[string]bool)
+	r := renderer{locale: locale, data: source.data}
+	for _, src := range templateSources(t) {
+		if strings.TrimSpace(src.text) == "" {
+			continue
+		}
+		keys, err := templateKeys(src.text)
+		if err != nil {
+			p.Errors = append(p.Errors, fmt.Sprintf("%s: %v", src.name, err))
+			continue
+		}
+		for _, k := range keys {
+			used[k] = true
+		}
+		if err := r.in(&src.variant).check(src.text, src.format); err != nil {
+			p.Errors = append(p.Errors, fmt.Sprintf("%s: %v", src.name, err))
+		}
+	}
+
+	for k := range used {
+		p.Variables = append(p.Variables, k)
+		if _, ok := source.data[k]; !ok {
+			p.Unknown = append(p.Unknown, k)
+		}
+	}
+	eventData["domain"] = domain.DomainName
+	for k := range eventData {
+		if !used[k] && !aliasUsed(k, used) {
+			p.Unused = append(p.Unused, k)
+		}
+	}
+	delete(eventData, "domain")
+	sort.Strings(p.Variables)
+	sort.Strings(p.Unknown)
+	sort.Strings(p.Unused)
+	return msg, p
+}
+
+func aliasUsed(key string, used map[string]bool) bool {
+	for _, group := range variableAliases {
+		in := false
+		for _, k := range group {
+			in = in || k == key
+		}
+		if !in {
+			continue
+		}
+		for _, k := range group {
+			if used[k] {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// templateSource is one template string of a message template.
+type templateSource struct {
+	name    string
+	text    string
+	format  string
+	variant database.TemplateVariant // Locale of the source, if from a variant
+}
+
+// templateSources lists the template strings of a message template: its variants,
+// or the legacy fields of templates without variants.
+func templateSources(t database.MessageTemplate) []templateSource {
+	if len(t.Variants) == 0 {
+		return []templateSource{
+			{name: "title_template", text: t.TitleTemplate, format: FormatPlain},
+			{name: "body_template", text: t.BodyTemplate, format: FormatPlain},
+			{name: "template_text", text: t.TemplateText, format: FormatPlain},
+			{name: "template", text: t.Template, format: FormatPlain},
+			{name: "html_template", text: t.HTMLTemplate, format: FormatHTML},
+		}
+	}
+
+	var sources []templateSource
+	for _, v := range t.Variants {
+		name := v.Format + " variant"
+		if v.Locale != "" {
+			name = v.Locale + " " + name
+		}
+		sources = append(sources,
+			templateSource{name: name + " title", text: v.Title, format: FormatPlain, variant: v},
+			templateSource{name: name + " body", text: v.Body, format: v.Format, variant: v})
+	}
+	return sources
+}
+
+// check renders a template source and reports the errors the renderer would only log.
+func (r renderer) check(src, format string) error {
+	if format == FormatHTML {
+		_, err := r.executeHTML(src)
+		return err
+	}
+	out, err := r.executeText(src)
+	if err != nil || format != FormatSlack {
+		return err
+	}
+	if _, err := parseBlocks(out); err != nil {
+		return fmt.Errorf("not a JSON array of blocks or an object with a blocks array: %w", err)
+	}
+	return nil
+}
+
+// templateKeys returns the data keys a template references, through {{key}},
+// {{.key}}, {{$.key}} or {{value "key"}}. Fields of range and with blocks refer to
+// the element rather than the data and aren't included.
+func templateKeys(src string) ([]string, error) {
+	t, err := template.New("message").Funcs(renderer{}.funcs()).Parse(legacyPlaceholders(src))
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var keys []string
+	add := func(k string) {
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	if t.Tree != nil {
+		walkKeys(t.Tree.Root, true, add)
+	}
+	return keys, nil
+}
+
+// walkKeys collects the data keys of a parse tree. top tells whether dot is the data.
+func walkKeys(node parse.Node, top bool, add func(string)) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			walkKeys(c, top, add)
+		}
+	case *parse.ActionNode:
+		walkKeys(n.Pipe, top, add)
+	case *parse.TemplateNode:
+		walkKeys(n.Pipe, top, add)
+	case *parse.PipeNode:
+		if n == nil {
+			return
+		}
+		for _, cmd := range n.Cmds {
+			walkKeys(cmd, top, add)
+		}
+	case *parse.CommandNode:
+		if len(n.Args) > 1 {
+			if fn, ok := n.Args[0].(*parse.IdentifierNode); ok && fn.Ident == "value" {
+				if key, ok := n.Args[1].(*parse.StringNode); ok {
+					add(key.Text)
+				}
+			}
+		}
+		for _, arg := range n.Args {
+			walkKeys(arg, top, add)
+		}
+	case *parse.FieldNode:
+		if top {
+			add(n.Ident[0])
+		}
+	case *parse.VariableNode:
+		if len(n.Ident) > 1 && n.Ident[0] == "$" {
+			add(n.Ident[1])
+		}
+	case *parse.ChainNode:
+		walkKeys(n.Node, top, add)
+	case *parse.IfNode:
+		walkKeys(n.Pipe, top, add)
+		walkKeys(n.List, top, add)
+		walkKeys(n.ElseList, top, add)
+	case *parse.RangeNode:
+		walkKeys(n.Pipe, top, add)
+		walkKeys(n.List, false, add)
+		walkKeys(n.ElseList, top, add)
+	case *parse.WithNode:
+		walkKeys(n.Pipe, top, add)
+		walkKeys(n.List, false, add)
+		walkKeys(n.ElseList, top, add)
+	}
+}
+
+// TestMessage returns the message sent to check that a channel is configured.
+func TestMessage() Message {
+	return Message{
+		Event:    "TEST",
+		Severity: SeverityInfo,
+		Title:    "Test notification from ZenStack",
+		Body:     "Hello from ZenStack. Notifications to this channel are configured correctly.",
+		Time:     time.Now(),
+	}
+}
+
+// SendTest delivers a message to a channel right away, bypassing routing, silences
+// and the outbox. The message is sent as rendered by the caller.
+func SendTest(ctx context.Context, ch Channel, msg Message) error {
+	return Deliver(ctx, ch.Notifier, msg, DeliveryInfo{Channel: ch.Ref, Recipient: ch.Name})
+}
@@ -0,0 +1,209 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"gorm.io/gorm"
+)
+
+// QuietHours is a daily window during which a channel only receives notifications
+// with an urgency of at least Severity. Other notifications are held in the outbox
+// until the window ends, unless a digest sent to the channel includes them first.
+type QuietHours struct {
+	Start    string `json:"start"` // Local time "HH:MM"
+	End      string `json:"end"`   // Before Start for windows spanning midnight
+	TimeZone string `json:"time_zone"`
+	Severity string `json:"severity"` // Lowest urgency delivered during the window
+}
+
+// ChannelQuietHours returns the quiet hours of a channel, or nil if it has none.
+func ChannelQuietHours(ch database.Channel) *QuietHours {
+	if ch.QuietStart == "" || ch.QuietEnd == "" {
+		return nil
+	}
+	q := &QuietHours{Start: ch.QuietStart, End: ch.QuietEnd, TimeZone: ch.TimeZone, Severity: ch.QuietSeverity}
+	if q.Severity == "" {
+		q.Severity = UrgencyCritical
+	}
+	return q
+}
+
+// ValidateQuietHours normalizes and checks the quiet hours of a channel.
+func ValidateQuietHours(ch *database.Channel) error {
+	ch.QuietStart = strings.TrimSpace(ch.QuietStart)
+	ch.QuietEnd = strings.TrimSpace(ch.QuietEnd)
+	ch.TimeZone = strings.TrimSpace(ch.TimeZone)
+	ch.QuietSeverity = strings.ToLower(strings.TrimSpace(ch.QuietSeverity))
+
+	if (ch.QuietStart == "") != (ch.QuietEnd == "") {
+		return fmt.Errorf("quiet_start and quiet_end must be set together")
+	}
+	if ch.QuietStart != "" {
+		sh, sm, err := parseClock(ch.QuietStart)
+		if err != nil {
+			return fmt.Errorf("quiet_start: %w", err)
+		}
+		eh, em, err := parseClock(ch.QuietEnd)
+		if err != nil {
+			return fmt.Errorf("quiet_end: %w", err)
+		}
+		if sh == eh && sm == em {
+			return fmt.Errorf("quiet_start and quiet_end must differ")
+		}
+		ch.QuietStart = fmt.Sprintf("%02d:%02d", sh, sm)
+		ch.QuietEnd = fmt.Sprintf("%02d:%02d", eh, em)
+	}
+	if ch.TimeZone != "" {
+		if _, err := time.LoadLocation(ch.TimeZone); err != nil {
+			return fmt.Errorf("invalid time zone %q", ch.TimeZone)
+		}
+	}
+	switch ch.QuietSeverity {
+	case "", UrgencyCritical, UrgencyError, UrgencyWarning, UrgencyInfo:
+	default:
+		return fmt.Errorf("invalid quiet_severity %q (expected critical, error, warning or info)", ch.QuietSeverity)
+	}
+	return nil
+}
+
+// Until returns the end of the quiet hours if t falls within them.
+func (q QuietHours) Until(t time.Time) (time.Time, bool) {
+	sh, sm, err := parseClock(q.Start)
+	if err != nil {
+		return time.Time{}, false
+	}
+	eh, em, err := parseClock(q.End)
+	if err != nil {
+		return time.Time{}, false
+	}
+	loc := time.UTC
+	if q.TimeZone != "" {
+		if l, err := time.LoadLocation(q.TimeZone); err == nil {
+			loc = l
+		}
+	}
+
+	y, m, d := t.In(loc).Date()
+	start := time.Date(y, m, d, sh, sm, 0, 0, loc)
+	end := time.Date(y, m, d, eh, em, 0, 0, loc)
+	if end.After(start) {
+		if !t.Before(start) && t.Before(end) {
+			return end, true
+		}
+		return time.Time{}, false
+	}
+	// The window spans midnight: it either started yesterday or ends tomorrow
+	if t.Before(end) {
+		return end, true
+	}
+	if !t.Before(start) {
+		return time.Date(y, m, d+1, eh, em, 0, 0, loc), true
+	}
+	return time.Time{}, false
+}
+
+// Holds reports whether a message sent at t is held back, and until when. Digests
+// are sent when they're scheduled and never held.
+func (q QuietHours) Holds(msg Message, t time.Time) (time.Time, bool) {
+	if msg.Event == DigestEvent || urgencyRank(quietUrgency(msg)) >= urgencyRank(q.Severity) {
+		return time.Time{}, false
+	}
+	return q.Until(t)
+}
+
+// quietUrgency returns the urgency quiet hours compare with their severity. Resolve
+// events take the urgency of their trigger, so that whoever an alert woke up also
+// learns that it's resolved.
+func quietUrgency(msg Message) string {
+	if !msg.Resolve {
+		return msg.Urgency
+	}
+	if t, ok := alertTypes[msg.Event]; ok {
+		for event, other := range alertTypes {
+			if other.kind == t.kind && !other.resolve {
+				return Urgency(event, "")
+			}
+		}
+	}
+	return msg.Urgency
+}
+
+// holdOutbox holds an outbox message back until the quiet hours of its channel end.
+func holdOutbox(row *database.OutboxMessage, q *QuietHours, msg Message, now time.Time) {
+	if q == nil {
+		return
+	}
+	if until, ok := q.Holds(msg, now); ok {
+		row.Status = OutboxHeld
+		row.NextAttemptAt = until
+	}
+}
+
+// takeHeld removes the messages held for a channel from the outbox and returns them.
+// Messages released by a worker meanwhile are left alone.
+func takeHeld(tx *gorm.DB, ref string) ([]Message, error) {
+	var rows []database.OutboxMessage
+	if err := tx.Where("channel = ? AND status = ?", ref, OutboxHeld).Order("id asc").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to load held notifications: %w", err)
+	}
+
+	var held []Message
+	for _, row := range rows {
+		var m Message
+		if err := json.Unmarshal([]byte(row.Payload), &m); err != nil {
+			// Left to be released and dead-lettered by the outbox
+			continue
+		}
+		claim := tx.Where("id = ? AND status = ?", row.ID, OutboxHeld).Delete(&database.OutboxMessage{})
+		if claim.Error != nil {
+			return nil, claim.Error
+		}
+		if claim.RowsAffected > 0 {
+			held = append(held, m)
+		}
+	}
+	return held, nil
+}
+
+// withHeld lists the notifications held back by quiet hours at the end of a digest.
+func withHeld(m Message, held []Message) Message {
+	if len(held) == 0 {
+		return m
+	}
+
+	heading := fmt.Sprintf("Held during quiet hours (%d):", len(held))
+	lines := make([]string, 0, len(held))
+	items := make([]string, 0, len(held))
+	for _, h := range held {
+		lines = append(lines, "• "+h.Title)
+		items = append(items, "<li>"+html.EscapeString(h.Title)+"</li>")
+	}
+	section := heading + "\n" + strings.Join(lines, "\n")
+
+	m.Body = strings.TrimRight(m.Body, "\n") + "\n\n" + section
+	if m.Text != "" {
+		m.Text = strings.TrimRight(m.Text, "\n") + "\n\n" + section
+	}
+	if m.HTML != "" {
+		m.HTML += "<p>" + html.EscapeString(heading) + "</p><ul>" + strings.Join(items, "") + "</ul>"
+	}
+	if len(m.Blocks) > 0 {
+		var blocks []interface{}
+		if err := json.Unmarshal(m.Blocks, &blocks); err == nil {
+			blocks = append(blocks, map[string]interface{}{
+				"type": "section",
+				"text": map[string]string{"type": "mrkdwn", "text": "*" + heading + "*\n" + strings.Join(lines, "\n")},
+			})
+			if encoded, err := json.Marshal(blocks); err == nil {
+				m.Blocks = encoded
+			}
+		}
+	}
+	m.Fields = append(append([]Field(nil), m.Fields...), Field{Name: "Held", Value: fmt.Sprintf("%d", len(held))})
+	return m
+}
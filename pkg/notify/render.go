@@ -0,0 +1,510 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+	"unicode"
+
+	"github.com/harveywai/zenstack/pkg/database"
+)
+
+// Message formats of template variants.
+const (
+	FormatPlain    = "plain"
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+	FormatSlack    = "slack" // Slack Block Kit JSON
+)
+
+// Formats lists the valid variant formats.
+var Formats = []string{FormatPlain, FormatMarkdown, FormatHTML, FormatSlack}
+
+// DefaultLocale returns the locale of channels without one, from ZENSTACK_LOCALE.
+func DefaultLocale() string {
+	if l := strings.TrimSpace(os.Getenv("ZENSTACK_LOCALE")); l != "" {
+		return l
+	}
+	return "en"
+}
+
+// FormatFor returns the message format rendered for a platform.
+func FormatFor(platform string) string {
+	switch normalizePlatform(platform) {
+	case PlatformSlack:
+		return FormatSlack
+	case PlatformDingTalk, PlatformFeishu, PlatformWeCom, PlatformDiscord, PlatformTeams:
+		return FormatMarkdown
+	case PlatformEmail:
+		return FormatHTML
+	}
+	return FormatPlain
+}
+
+// renderSource is what a message was rendered from, kept to render it again for
+// the format and locale of each channel.
+type renderSource struct {
+	template database.MessageTemplate
+	data     map[string]interface{}
+}
+
+// For returns the message rendered for the format and locale of a channel.
+func (m Message) For(ch Channel) Message {
+	return m.Render(ch.Locale, FormatFor(ch.Platform))
+}
+
+// Render returns the message rendered in a locale and format. Messages not built
+// from a template are returned unchanged.
+func (m Message) Render(locale, format string) Message {
+	if m.source == nil {
+		return m
+	}
+	if locale == "" {
+		locale = DefaultLocale()
+	}
+	r := renderer{locale: locale, data: m.source.data}
+	t := m.source.template
+	out := m
+	out.Blocks = nil
+
+	plain := findVariant(t.Variants, locale, FormatPlain)
+	if plain == nil {
+		// Templates without variants keep their legacy fields
+		out.Title = r.text(t.TitleTemplate)
+		out.Body = r.text(t.BodyTemplate)
+		text := t.TemplateText
+		if text == "" {
+			text = t.Template
+		}
+		out.Text = r.text(text)
+		if out.Text == "" {
+			out.Text = out.Body
+		}
+		out.HTML = r.html(t.HTMLTemplate)
+		return out
+	}
+
+	pr := r.in(plain)
+	out.Title = pr.text(plain.Title)
+	out.Text = pr.text(plain.Body)
+	out.Body = out.Text
+	out.HTML = ""
+
+	switch format {
+	case FormatMarkdown, FormatSlack:
+		if v := findVariant(t.Variants, locale, FormatMarkdown); v != nil && v != plain {
+			out.Body = r.in(v).text(v.Body)
+			if v.Title != "" {
+				out.Title = r.in(v).text(v.Title)
+			}
+		}
+		if format == FormatSlack {
+			if v := findVariant(t.Variants, locale, FormatSlack); v != nil && v.Format == FormatSlack {
+				out.Blocks = r.in(v).blocks(v.Body)
+			}
+		}
+	case FormatHTML:
+		if v := findVariant(t.Variants, locale, FormatHTML); v != nil && v.Format == FormatHTML {
+			out.HTML = r.in(v).html(v.Body)
+			if v.Title != "" {
+				out.Title = r.in(v).text(v.Title)
+			}
+		}
+	}
+	return out
+}
+
+// findVariant returns the variant for a locale and format, falling back from the
+// locale to its language, other regions of the language, the default locale and
+// variants without locale, and from markdown, html and slack to plain text.
+func findVariant(variants []database.TemplateVariant, locale, format string) *database.TemplateVariant {
+	formats := []string{format}
+	switch format {
+	case FormatSlack:
+		formats = append(formats, FormatMarkdown, FormatPlain)
+	case FormatMarkdown, FormatHTML:
+		formats = append(formats, FormatPlain)
+	}
+
+	for _, l := range localeChain(locale) {
+		for _, f := range formats {
+			for i := range variants {
+				if variants[i].Format == f && l.match(variants[i].Locale) {
+					return &variants[i]
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// localeMatch matches a locale exactly, or any region of a language.
+type localeMatch struct {
+	locale string
+	region bool
+}
+
+func (m localeMatch) match(locale string) bool {
+	if m.region {
+		return len(locale) > len(m.locale) && strings.EqualFold(locale[:len(m.locale)], m.locale) && strings.ContainsRune("-_", rune(locale[len(m.locale)]))
+	}
+	return strings.EqualFold(locale, m.locale)
+}
+
+// localeChain returns the locales tried for a locale, most specific first.
+func localeChain(locale string) []localeMatch {
+	var chain []localeMatch
+	add := func(m localeMatch) {
+		for _, c := range chain {
+			if c.region == m.region && strings.EqualFold(c.locale, m.locale) {
+				return
+			}
+		}
+		chain = append(chain, m)
+	}
+	for _, l := range []string{locale, DefaultLocale()} {
+		lang := l
+		if i := strings.IndexAny(l, "-_"); i > 0 {
+			lang = l[:i]
+		}
+		add(localeMatch{locale: l})
+		add(localeMatch{locale: lang})
+		if lang != "" {
+			add(localeMatch{locale: lang, region: true})
+		}
+	}
+	add(localeMatch{})
+	return chain
+}
+
+// renderer executes templates with the data of a message.
+type renderer struct {
+	locale string
+	data   map[string]interface{}
+}
+
+// in returns the renderer for a variant, so that e.g. durations are humanized in
+// the language of the variant found rather than the one asked for.
+func (r renderer) in(v *database.TemplateVariant) renderer {
+	if v.Locale != "" {
+		r.locale = v.Locale
+	}
+	return r
+}
+
+func (r renderer) text(src string) string {
+	out, err := r.executeText(src)
+	if err != nil {
+		log.Printf("Error rendering message template: %v", err)
+		return formatMessage(src, r.strings(false))
+	}
+	return out
+}
+
+func (r renderer) html(src string) string {
+	out, err := r.executeHTML(src)
+	if err != nil {
+		log.Printf("Error rendering HTML message template: %v", err)
+		return formatMessage(src, r.strings(true))
+	}
+	return out
+}
+
+// blocks renders a Slack variant, which must be a JSON array of blocks or an object
+// with a blocks array.
+func (r renderer) blocks(src string) json.RawMessage {
+	out, err := r.executeText(src)
+	if err == nil {
+		var blocks json.RawMessage
+		if blocks, err = parseBlocks(out); err == nil {
+			return blocks
+		}
+	}
+	log.Printf("Error rendering Slack blocks template, using the default layout: %v", err)
+	return nil
+}
+
+// parseBlocks returns the blocks of a rendered Slack variant.
+func parseBlocks(out string) (json.RawMessage, error) {
+	out = strings.TrimSpace(out)
+	var obj struct {
+		Blocks json.RawMessage `json:"blocks"`
+	}
+	if strings.HasPrefix(out, "{") && json.Unmarshal([]byte(out), &obj) == nil && len(obj.Blocks) > 0 {
+		out = string(obj.Blocks)
+	}
+	var list []json.RawMessage
+	if err := json.Unmarshal([]byte(out), &list); err != nil {
+		return nil, err
+	}
+	return json.RawMessage(out), nil
+}
+
+func (r renderer) executeText(src string) (string, error) {
+	if src == "" {
+		return "", nil
+	}
+	t, err := template.New("message").Funcs(r.funcs()).Parse(legacyPlaceholders(src))
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, r.data); err != nil {
+		return "", err
+	}
+	return strings.ReplaceAll(b.String(), "<no value>", ""), nil
+}
+
+func (r renderer) executeHTML(src string) (string, error) {
+	if src == "" {
+		return "", nil
+	}
+	t, err := htmltemplate.New("message").Funcs(htmltemplate.FuncMap(r.funcs())).Parse(legacyPlaceholders(src))
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := t.Execute(&b, r.data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// strings returns the data as strings, for the {{key}} substitution used when a
+// template fails.
+func (r renderer) strings(escape bool) map[string]string {
+	out := make(map[string]string, len(r.data))
+	for k, v := range r.data {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		if escape {
+			s = htmltemplate.HTMLEscapeString(s)
+		}
+		out[k] = s
+	}
+	return out
+}
+
+// funcs returns the template functions.
+func (r renderer) funcs() template.FuncMap {
+	return template.FuncMap{
+		// value looks up a key, keeping the placeholder of unknown keys like formatMessage
+		"value": func(key string) interface{} {
+			if v, ok := r.data[key]; ok {
+				return v
+			}
+			return "{{" + key + "}}"
+		},
+		"date": func(layout string, v interface{}) string {
+			t, ok := toTime(v)
+			if !ok {
+				return ""
+			}
+			return t.Format(layout)
+		},
+		"humanize": func(v interface{}) string { return humanize(toDuration(v), r.locale) },
+		"since": func(v interface{}) time.Duration {
+			if t, ok := toTime(v); ok {
+				return time.Since(t)
+			}
+			return 0
+		},
+		"until": func(v interface{}) time.Duration {
+			if t, ok := toTime(v); ok {
+				return time.Until(t)
+			}
+			return 0
+		},
+		"now":   time.Now,
+		"upper": strings.ToUpper,
+		"lower": strings.ToLower,
+		"title": func(s string) string {
+			words := strings.Fields(s)
+			for i, w := range words {
+				runes := []rune(w)
+				runes[0] = unicode.ToUpper(runes[0])
+				words[i] = string(runes)
+			}
+			return strings.Join(words, " ")
+		},
+		"trim":     strings.TrimSpace,
+		"contains": strings.Contains,
+		"replace":  func(old, new, s string) string { return strings.ReplaceAll(s, old, new) },
+		"join":     func(sep string, list []string) string { return strings.Join(list, sep) },
+		"default": func(def interface{}, v interface{}) interface{} {
+			if v == nil || v == "" {
+				return def
+			}
+			return v
+		},
+		"truncate": func(n int, s string) string {
+			runes := []rune(s)
+			if n <= 0 || len(runes) <= n {
+				return s
+			}
+			return string(runes[:n]) + "…"
+		},
+		"json": func(v interface{}) (string, error) {
+			b, err := json.Marshal(v)
+			return string(b), err
+		},
+	}
+}
+
+// templateKeywords are the words of {{word}} actions that aren't data keys.
+var templateKeywords = map[string]bool{"end": true, "else": true, "break": true, "continue": true, "nil": true, "true": true, "false": true}
+
+var placeholderPattern = regexp.MustCompile(`\{\{(-?\s*)(\w+)(\s*-?)\}\}`)
+
+// legacyPlaceholders rewrites {{key}} placeholders to {{value "key"}}, so that
+// templates written for formatMessage keep working. Keys named like a function, such
+// as {{title}} or {{date}}, are data keys too: functions take arguments, so a bare
+// function name is never a useful action.
+func legacyPlaceholders(src string) string {
+	return placeholderPattern.ReplaceAllStringFunc(src, func(match string) string {
+		m := placeholderPattern.FindStringSubmatch(match)
+		if templateKeywords[m[2]] {
+			return match
+		}
+		return "{{" + m[1] + "value " + strconv.Quote(m[2]) + m[3] + "}}"
+	})
+}
+
+// toTime converts times, RFC 3339 or database timestamps and Unix seconds.
+func toTime(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, !t.IsZero()
+	case *time.Time:
+		if t != nil {
+			return *t, !t.IsZero()
+		}
+	case int64:
+		return time.Unix(t, 0), true
+	case int:
+		return time.Unix(int64(t), 0), true
+	case string:
+		for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+			if parsed, err := time.Parse(layout, t); err == nil {
+				return parsed, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// toDuration converts durations, seconds and duration strings such as "90m".
+func toDuration(v interface{}) time.Duration {
+	switch d := v.(type) {
+	case time.Duration:
+		return d
+	case int:
+		return time.Duration(d) * time.Second
+	case int64:
+		return time.Duration(d) * time.Second
+	case float64:
+		return time.Duration(d * float64(time.Second))
+	case string:
+		if parsed, err := time.ParseDuration(d); err == nil {
+			return parsed
+		}
+		if secs, err := strconv.ParseFloat(d, 64); err == nil {
+			return time.Duration(secs * float64(time.Second))
+		}
+	}
+	return 0
+}
+
+// humanUnits are the units of humanized durations, largest first.
+var humanUnits = []struct {
+	size   time.Duration
+	en, zh string
+}{
+	{24 * time.Hour, "day", "天"},
+	{time.Hour, "hour", "小时"},
+	{time.Minute, "minute", "分钟"},
+}
+
+// humanize formats a duration with its two largest units, e.g. "3 days 4 hours".
+func humanize(d time.Duration, locale string) string {
+	if d < 0 {
+		d = -d
+	}
+	zh := strings.HasPrefix(strings.ToLower(locale), "zh")
+
+	var parts []string
+	for _, u := range humanUnits {
+		n := int(d / u.size)
+		if n == 0 {
+			if len(parts) > 0 {
+				break
+			}
+			continue
+		}
+		d -= time.Duration(n) * u.size
+		switch {
+		case zh:
+			parts = append(parts, fmt.Sprintf("%d%s", n, u.zh))
+		case n == 1:
+			parts = append(parts, fmt.Sprintf("1 %s", u.en))
+		default:
+			parts = append(parts, fmt.Sprintf("%d %ss", n, u.en))
+		}
+		if len(parts) == 2 {
+			break
+		}
+	}
+	if len(parts) == 0 {
+		if zh {
+			return "不到1分钟"
+		}
+		return "less than a minute"
+	}
+	if zh {
+		return strings.Join(parts, "")
+	}
+	return strings.Join(parts, " ")
+}
+
+// ValidateVariant checks the format and parses the templates of a variant.
+func ValidateVariant(v database.TemplateVariant) error {
+	valid := false
+	for _, f := range Formats {
+		valid = valid || v.Format == f
+	}
+	if !valid {
+		return fmt.Errorf("invalid format %q (expected one of %s)", v.Format, strings.Join(Formats, ", "))
+	}
+	if strings.TrimSpace(v.Body) == "" {
+		return fmt.Errorf("body is required")
+	}
+	if err := ValidateTemplate(v.Title, FormatPlain); err != nil {
+		return fmt.Errorf("invalid title: %w", err)
+	}
+	if err := ValidateTemplate(v.Body, v.Format); err != nil {
+		return fmt.Errorf("invalid body: %w", err)
+	}
+	return nil
+}
+
+// ValidateTemplate parses a template of a format.
+func ValidateTemplate(src, format string) error {
+	src = legacyPlaceholders(src)
+	funcs := renderer{}.funcs()
+	if format == FormatHTML {
+		_, err := htmltemplate.New("message").Funcs(htmltemplate.FuncMap(funcs)).Parse(src)
+		return err
+	}
+	_, err := template.New("message").Funcs(funcs).Parse(src)
+	return err
+}
@@ -0,0 +1,180 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+)
+
+func renderTest(t database.MessageTemplate, data map[string]interface{}) Message {
+	return Message{source: &renderSource{template: t, data: data}}
+}
+
+func TestRenderLegacyFields(t *testing.T) {
+	data := map[string]interface{}{
+		"domain":      "a&b.example.com",
+		"status_code": "503",
+		"title":       "Disk full",
+		"date":        "2026-01-02",
+		"now":         "soon",
+		"name":        "hello world",
+	}
+	tests := []struct {
+		name string
+		src  string
+		want string
+	}{
+		{"legacy placeholder", "Site {{domain}} is down", "Site a&b.example.com is down"},
+		{"spaces and trim markers", "{{ domain }}: {{- status_code }}", "a&b.example.com:503"},
+		{"unknown keys are kept", "{{domain}} {{missing}}", "a&b.example.com {{missing}}"},
+		{"keys named like functions", `{{title}} {{upper "on"}} {{date}}`, "Disk full ON 2026-01-02"},
+		{"key named like a function without arguments", "due {{now}}", "due soon"},
+		{"functions with arguments", `{{title .name}} {{upper "x"}} {{date "2006" .date}}`, "Hello World X 2026"},
+		{"keywords are kept", "{{if .domain}}up{{end}}", "up"},
+		{"missing fields render empty", "[{{.missing}}]", "[]"},
+		{"parse errors fall back to substitution", "{{domain}} {{if}}", "a&b.example.com {{if}}"},
+		{"execution errors fall back to substitution", `{{domain}} {{template "missing"}}`, `a&b.example.com {{template "missing"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := renderTest(database.MessageTemplate{TitleTemplate: tt.src, BodyTemplate: tt.src}, data).Render("en", FormatPlain)
+			if out.Title != tt.want {
+				t.Errorf("title = %q, want %q", out.Title, tt.want)
+			}
+			if out.Text != tt.want {
+				t.Errorf("text = %q, want the body %q", out.Text, tt.want)
+			}
+		})
+	}
+
+	out := renderTest(database.MessageTemplate{HTMLTemplate: "<b>{{domain}}</b> {{title}}"}, data).Render("en", FormatHTML)
+	if want := "<b>a&amp;b.example.com</b> Disk full"; out.HTML != want {
+		t.Errorf("html = %q, want %q", out.HTML, want)
+	}
+	out = renderTest(database.MessageTemplate{HTMLTemplate: "<b>{{domain}}</b> {{if}}"}, data).Render("en", FormatHTML)
+	if want := "<b>a&amp;b.example.com</b> {{if}}"; out.HTML != want {
+		t.Errorf("html fallback = %q, want %q", out.HTML, want)
+	}
+}
+
+func TestFindVariant(t *testing.T) {
+	variants := []database.TemplateVariant{
+		{Locale: "en", Format: FormatPlain, Title: "en plain"},
+		{Locale: "en", Format: FormatMarkdown, Title: "en markdown"},
+		{Locale: "zh-CN", Format: FormatPlain, Title: "zh-CN plain"},
+		{Locale: "zh-CN", Format: FormatHTML, Title: "zh-CN html"},
+		{Format: FormatHTML, Title: "any html"},
+	}
+	tests := []struct {
+		name          string
+		defaultLocale string
+		variants      []database.TemplateVariant
+		locale        string
+		format        string
+		want          string
+	}{
+		{name: "exact", locale: "en", format: FormatPlain, want: "en plain"},
+		{name: "locale is case-insensitive", locale: "ZH-cn", format: FormatHTML, want: "zh-CN html"},
+		{name: "region falls back to the language", locale: "en-US", format: FormatMarkdown, want: "en markdown"},
+		{name: "language falls back to a region", locale: "zh", format: FormatPlain, want: "zh-CN plain"},
+		{name: "other region of the language", locale: "zh_TW", format: FormatPlain, want: "zh-CN plain"},
+		{name: "markdown falls back to plain", locale: "zh-CN", format: FormatMarkdown, want: "zh-CN plain"},
+		{name: "slack falls back to markdown", locale: "en", format: FormatSlack, want: "en markdown"},
+		{name: "the locale's plain text beats another locale's format", locale: "zh-TW", format: FormatMarkdown, want: "zh-CN plain"},
+		{name: "unknown locale uses the default locale", locale: "de", format: FormatMarkdown, want: "en markdown"},
+		{name: "default locale from the environment", defaultLocale: "zh-CN", locale: "de", format: FormatPlain, want: "zh-CN plain"},
+		{
+			name:     "variants without locale come last",
+			variants: []database.TemplateVariant{{Format: FormatHTML, Title: "any html"}, {Locale: "fr", Format: FormatPlain, Title: "fr plain"}},
+			locale:   "de", format: FormatHTML, want: "any html",
+		},
+		{
+			name:     "no match",
+			variants: []database.TemplateVariant{{Locale: "fr", Format: FormatPlain}},
+			locale:   "de", format: FormatPlain,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ZENSTACK_LOCALE", tt.defaultLocale)
+			list := tt.variants
+			if list == nil {
+				list = variants
+			}
+			got := ""
+			if v := findVariant(list, tt.locale, tt.format); v != nil {
+				got = v.Title
+			}
+			if got != tt.want {
+				t.Errorf("findVariant(%s, %s) = %q, want %q", tt.locale, tt.format, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenderVariants(t *testing.T) {
+	tmpl := database.MessageTemplate{
+		TitleTemplate: "legacy",
+		Variants: []database.TemplateVariant{
+			{Locale: "en", Format: FormatPlain, Title: "{{domain}} down", Body: "down for {{humanize .for}}"},
+			{Locale: "en", Format: FormatMarkdown, Body: "**{{domain}}** down"},
+			{Locale: "en", Format: FormatSlack, Body: `{"blocks":[{"type":"section","text":{"type":"mrkdwn","text":"{{domain}}"}}]}`},
+			{Locale: "en", Format: FormatHTML, Title: "<{{domain}}>", Body: "<p>{{domain}}</p>"},
+			{Locale: "zh", Format: FormatPlain, Title: "{{domain}} 宕机", Body: "已宕机{{humanize .for}}"},
+		},
+	}
+	data := map[string]interface{}{"domain": "example.com", "for": 90 * time.Minute}
+	msg := renderTest(tmpl, data)
+
+	tests := []struct {
+		locale, format    string
+		title, text, body string
+		html, blocks      string
+	}{
+		{"en", FormatPlain, "example.com down", "down for 1 hour 30 minutes", "down for 1 hour 30 minutes", "", ""},
+		{"en", FormatMarkdown, "example.com down", "down for 1 hour 30 minutes", "**example.com** down", "", ""},
+		{"en", FormatSlack, "example.com down", "down for 1 hour 30 minutes", "**example.com** down", "", `[{"type":"section","text":{"type":"mrkdwn","text":"example.com"}}]`},
+		{"en", FormatHTML, "<example.com>", "down for 1 hour 30 minutes", "down for 1 hour 30 minutes", "<p>example.com</p>", ""},
+		// Only the plain text exists in Chinese; other formats don't mix in English
+		{"zh-CN", FormatMarkdown, "example.com 宕机", "已宕机1小时30分钟", "已宕机1小时30分钟", "", ""},
+		{"zh-CN", FormatHTML, "example.com 宕机", "已宕机1小时30分钟", "已宕机1小时30分钟", "", ""},
+	}
+	for _, tt := range tests {
+		out := msg.Render(tt.locale, tt.format)
+		if out.Title != tt.title || out.Text != tt.text || out.Body != tt.body || out.HTML != tt.html || string(out.Blocks) != tt.blocks {
+			t.Errorf("%s %s: got title %q, text %q, body %q, html %q, blocks %s", tt.locale, tt.format, out.Title, out.Text, out.Body, out.HTML, out.Blocks)
+		}
+	}
+
+	// Invalid Slack blocks leave the default layout
+	tmpl.Variants[2].Body = `{"blocks": {{domain}}}`
+	if out := renderTest(tmpl, data).Render("en", FormatSlack); out.Blocks != nil {
+		t.Errorf("blocks = %s, want none", out.Blocks)
+	}
+}
+
+func TestHumanize(t *testing.T) {
+	tests := []struct {
+		d      time.Duration
+		en, zh string
+	}{
+		{0, "less than a minute", "不到1分钟"},
+		{59 * time.Second, "less than a minute", "不到1分钟"},
+		{90 * time.Second, "1 minute", "1分钟"},
+		{2 * time.Hour, "2 hours", "2小时"},
+		{26*time.Hour + 5*time.Minute, "1 day 2 hours", "1天2小时"},
+		{3*24*time.Hour + 4*time.Hour + 5*time.Minute, "3 days 4 hours", "3天4小时"},
+		// Units below a zero unit are dropped
+		{24*time.Hour + 5*time.Minute, "1 day", "1天"},
+		{-45 * time.Minute, "45 minutes", "45分钟"},
+	}
+	for _, tt := range tests {
+		if got := humanize(tt.d, "en-US"); got != tt.en {
+			t.Errorf("humanize(%s, en) = %q, want %q", tt.d, got, tt.en)
+		}
+		if got := humanize(tt.d, "zh-CN"); got != tt.zh {
+			t.Errorf("humanize(%s, zh) = %q, want %q", tt.d, got, tt.zh)
+		}
+	}
+}
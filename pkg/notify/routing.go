@@ -0,0 +1,286 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+)
+
+// Channel kinds used in channel refs such as "webhook:3".
+const (
+	ChannelWebhook  = "webhook"
+	ChannelEmail    = "email"
+	ChannelTelegram = "telegram"
+)
+
+// Channel is an active notification channel.
+type Channel struct {
+	Ref      string      `json:"ref"` // Kind and ID, e.g. "webhook:3"
+	Name     string      `json:"name"`
+	Platform string      `json:"platform"`
+	Locale   string      `json:"locale,omitempty"` // Language of the messages, empty for the default locale
+	Quiet    *QuietHours `json:"quiet_hours,omitempty"`
+	Notifier Notifier    `json:"-"`
+}
+
+// TelegramNotifier sends plain text messages through a Telegram bot.
+type TelegramNotifier struct {
+	Token  string
+	ChatID string
+}
+
+// Platform implements Notifier.
+func (n *TelegramNotifier) Platform() string { return PlatformTelegram }
+
+// Send implements Notifier. Message actions become inline keyboard buttons.
+func (n *TelegramNotifier) Send(ctx context.Context, msg Message) error {
+	var markup interface{}
+	if len(msg.Actions) > 0 {
+		row := make([]map[string]string, 0, len(msg.Actions))
+		for _, a := range msg.Actions {
+			row = append(row, map[string]string{"text": a.Label, "url": a.URL})
+		}
+		markup = map[string]interface{}{"inline_keyboard": [][]map[string]string{row}}
+	}
+	return postTelegram(ctx, n.Token, n.ChatID, msg.text(), markup)
+}
+
+func (n *TelegramNotifier) rendersActions() {}
+
+// ChannelRef formats the ref of a channel.
+func ChannelRef(kind string, id uint) string {
+	return kind + ":" + strconv.FormatUint(uint64(id), 10)
+}
+
+// ActiveChannels loads all active channels.
+func ActiveChannels() ([]Channel, error) {
+	if database.DB == nil {
+		return nil, database.ErrDatabaseNotInitialized
+	}
+
+	var models []database.Channel
+	if err := database.DB.Where("is_active = ?", true).Order("id").Find(&models).Error; err != nil {
+		return nil, fmt.Errorf("failed to load channels: %w", err)
+	}
+
+	channels := make([]Channel, 0, len(models))
+	for _, m := range models {
+		ch, err := runtimeChannel(m)
+		if err != nil {
+			log.Printf("Skipping channel %d: %v", m.ID, err)
+			continue
+		}
+		channels = append(channels, ch)
+	}
+	return channels, nil
+}
+
+// LoadChannel returns the channel of a ref, whether it is active or not.
+func LoadChannel(ref string) (Channel, error) {
+	m, err := findChannel(ref)
+	if err != nil {
+		return Channel{}, err
+	}
+	return runtimeChannel(m)
+}
+
+// findChannel loads the channel model of a ref.
+func findChannel(ref string) (database.Channel, error) {
+	if database.DB == nil {
+		return database.Channel{}, database.ErrDatabaseNotInitialized
+	}
+
+	kind, id, ok := strings.Cut(strings.ToLower(strings.TrimSpace(ref)), ":")
+	if !ok {
+		return database.Channel{}, fmt.Errorf("invalid channel ref %q (expected e.g. webhook:1)", ref)
+	}
+	if _, err := channelType(kind); err != nil {
+		return database.Channel{}, err
+	}
+	var ch database.Channel
+	if err := database.DB.Where("id = ? AND type = ?", id, kind).Limit(1).Find(&ch).Error; err != nil {
+		return database.Channel{}, err
+	}
+	if ch.ID == 0 {
+		return database.Channel{}, fmt.Errorf("channel %s not found", ref)
+	}
+	return ch, nil
+}
+
+// RouteInput describes an event for routing.
+type RouteInput struct {
+	Event        string
+	Tags         string // Comma-separated tags of the domain
+	CustomStatus string
+	Severity     string // Urgency of the event: critical, error, warning or info
+	Resolve      bool   // Resolve events skip the severity condition so they follow their trigger
+}
+
+// Route is the result of routing an event.
+type Route struct {
+	Rules    []database.RoutingRule `json:"rules"`    // Matched rules, in evaluation order
+	Channels []Channel              `json:"channels"` // Channels the event is delivered to
+	Default  bool                   `json:"default"`  // No rule matched, so all active channels receive the event
+}
+
+// Policies returns the escalation policies of the matched rules, without duplicates.
+func (r Route) Policies() []uint {
+	var ids []uint
+	seen := make(map[uint]bool)
+	for _, rule := range r.Rules {
+		if rule.EscalationPolicyID != 0 && !seen[rule.EscalationPolicyID] {
+			seen[rule.EscalationPolicyID] = true
+			ids = append(ids, rule.EscalationPolicyID)
+		}
+	}
+	return ids
+}
+
+// RouteEvent evaluates the active routing rules against an event. Matching rules add
+// their channels until a rule without Continue matches. Events matching no rule are
+// sent to all active channels.
+func RouteEvent(in RouteInput) (Route, error) {
+	channels, err := ActiveChannels()
+	if err != nil {
+		return Route{}, err
+	}
+
+	var rules []database.RoutingRule
+	if err := database.DB.Where("is_active = ?", true).Order("position asc, id asc").Find(&rules).Error; err != nil {
+		return Route{}, fmt.Errorf("failed to load routing rules: %w", err)
+	}
+
+	route := Route{Rules: []database.RoutingRule{}, Channels: []Channel{}}
+	refs := make(map[string]bool)
+	for _, rule := range rules {
+		if !RuleMatches(rule, in) {
+			continue
+		}
+		route.Rules = append(route.Rules, rule)
+		for _, ref := range splitList(rule.Channels) {
+			refs[strings.ToLower(ref)] = true
+		}
+		if !rule.Continue {
+			break
+		}
+	}
+
+	if len(route.Rules) == 0 {
+		route.Default = true
+		route.Channels = channels
+		return route, nil
+	}
+	for _, ch := range channels {
+		if refs[ch.Ref] {
+			route.Channels = append(route.Channels, ch)
+		}
+	}
+	return route, nil
+}
+
+// RuleMatches reports whether all conditions of a rule match the event. Within a
+// condition any of the listed values matches.
+func RuleMatches(rule database.RoutingRule, in RouteInput) bool {
+	if events := splitList(rule.Events); len(events) > 0 {
+		ok := false
+		for _, pattern := range events {
+			if matched, _ := path.Match(strings.ToUpper(pattern), strings.ToUpper(in.Event)); matched {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			return false
+		}
+	}
+	if tags := splitList(rule.Tags); len(tags) > 0 && !intersects(tags, splitList(in.Tags)) {
+		return false
+	}
+	if statuses := splitList(rule.CustomStatus); len(statuses) > 0 && !intersects(statuses, []string{in.CustomStatus}) {
+		return false
+	}
+	if severities := splitList(rule.Severities); len(severities) > 0 && !in.Resolve && !intersects(severities, []string{in.Severity}) {
+		return false
+	}
+	return true
+}
+
+// ValidateRoutingRule checks the patterns, severities and channel refs of a rule.
+func ValidateRoutingRule(rule database.RoutingRule) error {
+	for _, pattern := range splitList(rule.Events) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid event pattern %q", pattern)
+		}
+	}
+	for _, s := range splitList(rule.Severities) {
+		switch strings.ToLower(s) {
+		case UrgencyCritical, UrgencyError, UrgencyWarning, UrgencyInfo:
+		default:
+			return fmt.Errorf("invalid severity %q (expected critical, error, warning or info)", s)
+		}
+	}
+	if rule.EscalationPolicyID != 0 && database.DB != nil {
+		if err := database.DB.First(&database.EscalationPolicy{}, rule.EscalationPolicyID).Error; err != nil {
+			return fmt.Errorf("escalation policy %d not found", rule.EscalationPolicyID)
+		}
+	}
+	if rule.GroupWindow < 0 || time.Duration(rule.GroupWindow)*time.Second > MaxGroupWindow {
+		return fmt.Errorf("group_window must be between 0 and %d seconds", int(MaxGroupWindow.Seconds()))
+	}
+	for _, key := range splitList(rule.GroupBy) {
+		if !groupKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid group_by key %q", key)
+		}
+	}
+	refs := splitList(rule.Channels)
+	if len(refs) == 0 && rule.EscalationPolicyID == 0 {
+		return fmt.Errorf("at least one channel or an escalation policy is required")
+	}
+	return ValidateChannelRefs(refs)
+}
+
+// ValidateChannelRefs checks that channel refs are well-formed and exist.
+func ValidateChannelRefs(refs []string) error {
+	if database.DB == nil {
+		return nil
+	}
+	for _, ref := range refs {
+		if _, err := findChannel(ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// groupKeyPattern matches the event data keys events can be grouped by.
+var groupKeyPattern = regexp.MustCompile(`^\w+$`)
+
+// splitList splits a comma-separated list, dropping empty items.
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// intersects reports whether the lists share a value, ignoring case.
+func intersects(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if strings.EqualFold(x, y) {
+				return true
+			}
+		}
+	}
+	return false
+}
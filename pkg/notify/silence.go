@@ -0,0 +1,250 @@
+package notify
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/auth"
+	"github.com/harveywai/zenstack/pkg/database"
+	"gorm.io/gorm"
+)
+
+// Silence states, derived from the start and end time.
+const (
+	SilencePending = "pending"
+	SilenceActive  = "active"
+	SilenceExpired = "expired"
+)
+
+// Notification paths a silence can suppress, recorded with suppressed events.
+const (
+	PathNotification = "notification"
+	PathEscalation   = "escalation"
+	PathDigest       = "digest"
+)
+
+// ActionSnooze is the signed link action silencing the events of a domain.
+const ActionSnooze = "silence.snooze"
+
+// SnoozeLinkTTL is how long snooze links in notifications stay valid.
+const SnoozeLinkTTL = 7 * 24 * time.Hour
+
+// MaxSilenceDuration is the longest time a silence can last.
+const MaxSilenceDuration = 366 * 24 * time.Hour
+
+// SnoozeDurations are the durations offered by snooze links.
+var SnoozeDurations = []time.Duration{time.Hour, 4 * time.Hour, 24 * time.Hour, 7 * 24 * time.Hour}
+
+// Action is a link shown with a message, rendered as a button where supported.
+type Action struct {
+	Label string
+	URL   string
+}
+
+// PublicURL returns the base URL of links in notifications: ZENSTACK_PUBLIC_URL, or
+// http://localhost:8080 if it is unset.
+func PublicURL() string {
+	base := strings.TrimRight(os.Getenv("ZENSTACK_PUBLIC_URL"), "/")
+	if base == "" {
+		base = "http://localhost:8080"
+	}
+	return base
+}
+
+// SilenceState returns whether a silence is pending, active or expired at a time.
+func SilenceState(s database.Silence, at time.Time) string {
+	switch {
+	case at.Before(s.StartsAt):
+		return SilencePending
+	case at.Before(s.EndsAt):
+		return SilenceActive
+	}
+	return SilenceExpired
+}
+
+// SilenceMatches reports whether all matchers of a silence match an event of a domain.
+// Within a matcher any of the listed values matches.
+func SilenceMatches(s database.Silence, event string, domain database.MonitoredDomain) bool {
+	if ids := splitList(s.DomainIDs); len(ids) > 0 {
+		if domain.ID == 0 || !intersects(ids, []string{strconv.FormatUint(uint64(domain.ID), 10)}) {
+			return false
+		}
+	}
+	if tags := splitList(s.Tags); len(tags) > 0 && !intersects(tags, splitList(domain.Tags)) {
+		return false
+	}
+	if events := splitList(s.Events); len(events) > 0 {
+		for _, pattern := range events {
+			if matched, _ := path.Match(strings.ToUpper(pattern), strings.ToUpper(event)); matched {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// ValidateSilence checks the matchers and the time range of a silence.
+func ValidateSilence(s database.Silence) error {
+	ids := splitList(s.DomainIDs)
+	if len(ids) == 0 && len(splitList(s.Tags)) == 0 && len(splitList(s.Events)) == 0 {
+		return fmt.Errorf("at least one of domain_ids, tags or events is required")
+	}
+	for _, id := range ids {
+		if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+			return fmt.Errorf("invalid domain ID %q", id)
+		}
+	}
+	for _, pattern := range splitList(s.Events) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid event pattern %q", pattern)
+		}
+	}
+	if s.StartsAt.IsZero() || s.EndsAt.IsZero() {
+		return fmt.Errorf("starts_at and ends_at are required")
+	}
+	if !s.EndsAt.After(s.StartsAt) {
+		return fmt.Errorf("ends_at must be after starts_at")
+	}
+	if s.EndsAt.Sub(s.StartsAt) > MaxSilenceDuration {
+		return fmt.Errorf("silences can last at most %d days", int(MaxSilenceDuration.Hours()/24))
+	}
+	return nil
+}
+
+// ActiveSilence returns the matching silence active at a time that ends last, or nil.
+func ActiveSilence(tx *gorm.DB, event string, domain database.MonitoredDomain, at time.Time) (*database.Silence, error) {
+	if tx == nil {
+		return nil, database.ErrDatabaseNotInitialized
+	}
+
+	var silences []database.Silence
+	if err := tx.Where("starts_at <= ? AND ends_at > ?", at, at).Order("ends_at desc").Find(&silences).Error; err != nil {
+		return nil, fmt.Errorf("failed to load silences: %w", err)
+	}
+	for i := range silences {
+		if SilenceMatches(silences[i], event, domain) {
+			return &silences[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// CheckSilence returns the silence suppressing an event now and records the event as
+// suppressed on the given path in tx, or returns nil if no silence matches. Errors
+// loading silences never suppress an event.
+func CheckSilence(tx *gorm.DB, event string, domain database.MonitoredDomain, via string) *database.Silence {
+	silence, err := ActiveSilence(tx, event, domain, time.Now())
+	if err != nil {
+		log.Printf("Error checking silences for %s: %v", event, err)
+		return nil
+	}
+	if silence == nil {
+		return nil
+	}
+
+	name := domain.DomainName
+	if name == "" {
+		name = "-"
+	}
+	log.Printf("Suppressed %s %s of %s by silence %d until %s", via, event, name, silence.ID, silence.EndsAt.Format(time.RFC3339))
+	record := database.SuppressedEvent{
+		SilenceID: silence.ID,
+		Event:     event,
+		DomainID:  domain.ID,
+		Domain:    domain.DomainName,
+		Path:      via,
+	}
+	if err := tx.Create(&record).Error; err != nil {
+		log.Printf("Error recording suppressed event: %v", err)
+	}
+	return silence
+}
+
+// ExpireSilence ends a silence now. Pending silences end before they start.
+func ExpireSilence(id uint) (*database.Silence, error) {
+	if database.DB == nil {
+		return nil, database.ErrDatabaseNotInitialized
+	}
+
+	var silence database.Silence
+	if err := database.DB.First(&silence, id).Error; err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if SilenceState(silence, now) == SilenceExpired {
+		return &silence, nil
+	}
+	if silence.StartsAt.After(now) {
+		silence.StartsAt = now
+	}
+	silence.EndsAt = now
+	err := database.DB.Model(&silence).Updates(map[string]interface{}{"starts_at": silence.StartsAt, "ends_at": silence.EndsAt}).Error
+	if err != nil {
+		return nil, err
+	}
+	return &silence, nil
+}
+
+// Snooze silences all events of a domain for a duration.
+func Snooze(domainID uint, d time.Duration, createdBy, comment string) (*database.Silence, error) {
+	if database.DB == nil {
+		return nil, database.ErrDatabaseNotInitialized
+	}
+	if d <= 0 || d > MaxSilenceDuration {
+		return nil, fmt.Errorf("duration must be between 1s and %d days", int(MaxSilenceDuration.Hours()/24))
+	}
+
+	now := time.Now()
+	silence := database.Silence{
+		DomainIDs: strconv.FormatUint(uint64(domainID), 10),
+		StartsAt:  now,
+		EndsAt:    now.Add(d),
+		CreatedBy: createdBy,
+		Comment:   comment,
+	}
+	if err := database.DB.Create(&silence).Error; err != nil {
+		return nil, fmt.Errorf("failed to create silence: %w", err)
+	}
+	return &silence, nil
+}
+
+// ParseSnoozeDuration parses a duration such as "90m", "4h" or "7d".
+func ParseSnoozeDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %q (expected e.g. 4h or 7d)", s)
+	}
+	return d, nil
+}
+
+// SnoozeURL returns the signed link snoozing the events of a domain.
+func SnoozeURL(domainID uint) (string, error) {
+	token, err := auth.SignAction(ActionSnooze, domainID, 0, SnoozeLinkTTL)
+	if err != nil {
+		return "", err
+	}
+	return PublicURL() + "/v1/silences/snooze/" + token, nil
+}
+
+// CleanupSuppressed deletes suppressed events older than the retention period.
+func CleanupSuppressed(retention time.Duration) (int64, error) {
+	if database.DB == nil {
+		return 0, database.ErrDatabaseNotInitialized
+	}
+	result := database.DB.Where("created_at < ?", time.Now().Add(-retention)).Delete(&database.SuppressedEvent{})
+	return result.RowsAffected, result.Error
+}
@@ -0,0 +1,93 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// SlackNotifier posts Block Kit messages to a Slack incoming webhook.
+type SlackNotifier struct {
+	WebhookURL string
+	Client     *http.Client
+}
+
+var slackIcons = map[string]string{
+	SeverityCritical: ":rotating_light:",
+	SeverityWarning:  ":warning:",
+	SeverityResolved: ":white_check_mark:",
+	SeverityInfo:     ":information_source:",
+}
+
+// Platform implements Notifier.
+func (n *SlackNotifier) Platform() string { return PlatformSlack }
+
+// Send implements Notifier.
+func (n *SlackNotifier) Send(ctx context.Context, msg Message) error {
+	blocks := []map[string]interface{}{
+		{
+			"type": "header",
+			"text": map[string]interface{}{"type": "plain_text", "text": slackIcons[msg.Severity] + " " + msg.Title, "emoji": true},
+		},
+		{
+			"type": "section",
+			"text": map[string]string{"type": "mrkdwn", "text": msg.Body},
+		},
+	}
+	if len(msg.Fields) > 0 {
+		// Section fields are limited to 10 per block
+		fields := make([]map[string]string, 0, len(msg.Fields))
+		for i, f := range msg.Fields {
+			if i == 10 {
+				break
+			}
+			fields = append(fields, map[string]string{"type": "mrkdwn", "text": "*" + f.Name + "*\n" + f.Value})
+		}
+		blocks = append(blocks, map[string]interface{}{"type": "section", "fields": fields})
+	}
+	if actions := slackActions(msg.Actions); actions != nil {
+		blocks = append(blocks, actions)
+	}
+	blocks = append(blocks, map[string]interface{}{
+		"type":     "context",
+		"elements": []map[string]string{{"type": "mrkdwn", "text": msg.Event + " · " + msg.Time.Format("2006-01-02 15:04:05 MST")}},
+	})
+
+	// text is the fallback shown in notifications and clients without block support
+	payload := map[string]interface{}{
+		"text":   msg.Title + ": " + msg.text(),
+		"blocks": blocks,
+	}
+	if len(msg.Blocks) > 0 {
+		// Blocks of a slack template variant replace the default layout
+		var custom []interface{}
+		if err := json.Unmarshal(msg.Blocks, &custom); err != nil {
+			return &PermanentError{Err: fmt.Errorf("invalid slack blocks: %w", err)}
+		}
+		if actions := slackActions(msg.Actions); actions != nil {
+			custom = append(custom, actions)
+		}
+		payload["blocks"] = custom
+	}
+	_, err := postJSON(ctx, n.Client, n.WebhookURL, payload, nil)
+	return err
+}
+
+// slackActions returns an actions block with a link button per action, or nil.
+func slackActions(actions []Action) map[string]interface{} {
+	if len(actions) == 0 {
+		return nil
+	}
+	buttons := make([]map[string]interface{}, 0, len(actions))
+	for _, a := range actions {
+		buttons = append(buttons, map[string]interface{}{
+			"type": "button",
+			"text": map[string]string{"type": "plain_text", "text": a.Label},
+			"url":  a.URL,
+		})
+	}
+	return map[string]interface{}{"type": "actions", "elements": buttons}
+}
+
+func (n *SlackNotifier) rendersActions() {}
@@ -0,0 +1,62 @@
+package notify
+
+import (
+	"html/template"
+	"io"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+)
+
+// SnoozePage is the data of the page behind a snooze link.
+type SnoozePage struct {
+	Domain  *database.MonitoredDomain
+	Silence *database.Silence // The silence created by this request
+	Error   string            // Shown instead of the form, e.g. for expired links
+}
+
+// The link only shows the durations to pick from, so that link scanners and previews
+// in mail clients don't silence domains.
+var snoozeTemplate = template.Must(template.New("snooze").Funcs(template.FuncMap{
+	"durations": func() []time.Duration { return SnoozeDurations },
+	"label":     func(d time.Duration) string { return humanize(d, "en") },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<title>Snooze notifications - ZenStack</title>
+<style>
+body{margin:0;padding:32px 16px;background:#f7fafc;font-family:-apple-system,Segoe UI,Helvetica,Arial,sans-serif;color:#1a202c}
+main{max-width:520px;margin:0 auto;background:#fff;border-radius:6px;padding:24px;box-shadow:0 1px 3px rgba(0,0,0,.1)}
+h1{font-size:18px;margin:0 0 12px}
+p{font-size:14px;line-height:1.5}
+.meta{color:#718096;font-size:13px}
+button{background:#3182ce;color:#fff;border:0;border-radius:4px;padding:10px 20px;font-size:14px;cursor:pointer;margin:0 8px 8px 0}
+.ok{color:#38a169}.err{color:#e53e3e}
+</style>
+</head>
+<body>
+<main>
+{{if .Error}}
+<h1 class="err">{{.Error}}</h1>
+{{else}}
+<h1>Snooze {{.Domain.DomainName}}</h1>
+{{with .Silence}}
+<p class="ok">Notifications and pages for this domain are silenced until {{.EndsAt.Format "2006-01-02 15:04 MST"}} (silence #{{.ID}}).</p>
+{{else}}
+<p class="meta">Notifications, pages and digest entries of this domain are suppressed for the chosen time. Suppressed events are still logged.</p>
+<form method="post">
+{{range durations}}<button type="submit" name="duration" value="{{.}}">{{label .}}</button>{{end}}
+</form>
+{{end}}
+{{end}}
+</main>
+</body>
+</html>
+`))
+
+// RenderSnoozePage writes the snooze page.
+func RenderSnoozePage(w io.Writer, page SnoozePage) error {
+	return snoozeTemplate.Execute(w, page)
+}
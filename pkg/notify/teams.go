@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+)
+
+// TeamsNotifier posts Adaptive Cards to a Microsoft Teams incoming webhook or
+// Workflows (Power Automate) webhook.
+type TeamsNotifier struct {
+	WebhookURL string
+	Client     *http.Client
+}
+
+var teamsColors = map[string]string{
+	SeverityCritical: "attention",
+	SeverityWarning:  "warning",
+	SeverityResolved: "good",
+	SeverityInfo:     "accent",
+}
+
+// Platform implements Notifier.
+func (n *TeamsNotifier) Platform() string { return PlatformTeams }
+
+// Send implements Notifier.
+func (n *TeamsNotifier) Send(ctx context.Context, msg Message) error {
+	body := []map[string]interface{}{
+		{"type": "TextBlock", "text": msg.Title, "size": "Large", "weight": "Bolder", "color": teamsColors[msg.Severity], "wrap": true},
+		{"type": "TextBlock", "text": msg.Body, "wrap": true},
+	}
+	if len(msg.Fields) > 0 {
+		facts := make([]map[string]string, 0, len(msg.Fields))
+		for _, f := range msg.Fields {
+			facts = append(facts, map[string]string{"title": f.Name, "value": f.Value})
+		}
+		body = append(body, map[string]interface{}{"type": "FactSet", "facts": facts})
+	}
+	body = append(body, map[string]interface{}{
+		"type":     "TextBlock",
+		"text":     msg.Event + " · " + msg.Time.Format("2006-01-02 15:04:05 MST"),
+		"size":     "Small",
+		"isSubtle": true,
+		"wrap":     true,
+	})
+
+	_, err := postJSON(ctx, n.Client, n.WebhookURL, map[string]interface{}{
+		"type": "message",
+		"attachments": []map[string]interface{}{{
+			"contentType": "application/vnd.microsoft.card.adaptive",
+			"content": map[string]interface{}{
+				"$schema": "http://adaptivecards.io/schemas/adaptive-card.json",
+				"type":    "AdaptiveCard",
+				"version": "1.4",
+				"body":    body,
+			},
+		}},
+	}, nil)
+	return err
+}
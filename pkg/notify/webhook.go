@@ -0,0 +1,54 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/webhooksig"
+)
+
+// WebhookNotifier posts the generic NotificationPayload JSON. With a secret, each
+// attempt is signed with HMAC-SHA256 over its timestamp, delivery ID and body;
+// receivers verify it with the webhooksig package. The secret itself is never sent.
+type WebhookNotifier struct {
+	WebhookURL string
+	Secret     string
+	Name       string // Platform name for logs and metrics, default "webhook"
+	Client     *http.Client
+}
+
+// Platform implements Notifier.
+func (n *WebhookNotifier) Platform() string {
+	if n.Name != "" {
+		return n.Name
+	}
+	return PlatformWebhook
+}
+
+// Send implements Notifier.
+func (n *WebhookNotifier) Send(ctx context.Context, msg Message) error {
+	payload := NotificationPayload{
+		Title:  msg.Title,
+		Body:   msg.Body,
+		Event:  msg.Event,
+		Domain: msg.Domain,
+		Time:   msg.Time.Format(time.RFC3339),
+		Extra:  msg.Extra,
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal payload: %w", err)
+	}
+
+	req, err := newPostRequest(ctx, n.WebhookURL, body)
+	if err != nil {
+		return err
+	}
+	webhooksig.SignRequest(req, n.Secret, deliveryID(ctx), body, time.Now())
+	_, err = doRequest(ctx, n.Client, req)
+	return err
+}
@@ -0,0 +1,38 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+)
+
+// WeComNotifier posts markdown messages to a WeCom (WeChat Work) group robot.
+type WeComNotifier struct {
+	WebhookURL string
+	Client     *http.Client
+}
+
+var wecomColors = map[string]string{
+	SeverityCritical: "warning",
+	SeverityWarning:  "comment",
+	SeverityResolved: "info",
+	SeverityInfo:     "comment",
+}
+
+// Platform implements Notifier.
+func (n *WeComNotifier) Platform() string { return PlatformWeCom }
+
+// Send implements Notifier.
+func (n *WeComNotifier) Send(ctx context.Context, msg Message) error {
+	// WeCom markdown supports three font colors: info (green), comment (grey) and warning (orange)
+	content := `### <font color="` + wecomColors[msg.Severity] + `">` + msg.Title + "</font>\n" +
+		msg.markdown("**") + "\n\n> " + msg.Event + " · " + msg.Time.Format("2006-01-02 15:04:05 MST")
+
+	respBody, err := postJSON(ctx, n.Client, n.WebhookURL, map[string]interface{}{
+		"msgtype":  "markdown",
+		"markdown": map[string]string{"content": content},
+	}, nil)
+	if err != nil {
+		return err
+	}
+	return checkErrCode(respBody)
+}
@@ -0,0 +1,59 @@
+package oncall
+
+import (
+	"html/template"
+	"io"
+
+	"github.com/harveywai/zenstack/pkg/database"
+)
+
+// AckPage is the data of the page behind an acknowledgement link.
+type AckPage struct {
+	Escalation *database.Escalation
+	Done       bool   // The escalation was acknowledged by this request
+	Error      string // Shown instead of the form, e.g. for expired links
+}
+
+// The link only shows a confirmation form, so that link scanners and previews in
+// mail clients don't acknowledge pages nobody has read.
+var ackTemplate = template.Must(template.New("ack").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+<title>Acknowledge alert - ZenStack</title>
+<style>
+body{margin:0;padding:32px 16px;background:#f7fafc;font-family:-apple-system,Segoe UI,Helvetica,Arial,sans-serif;color:#1a202c}
+main{max-width:520px;margin:0 auto;background:#fff;border-radius:6px;padding:24px;box-shadow:0 1px 3px rgba(0,0,0,.1)}
+h1{font-size:18px;margin:0 0 12px}
+p{font-size:14px;line-height:1.5;white-space:pre-wrap}
+.meta{color:#718096;font-size:13px}
+button{background:#3182ce;color:#fff;border:0;border-radius:4px;padding:10px 20px;font-size:14px;cursor:pointer}
+.ok{color:#38a169}.err{color:#e53e3e}
+</style>
+</head>
+<body>
+<main>
+{{if .Error}}
+<h1 class="err">{{.Error}}</h1>
+{{else}}
+{{with .Escalation}}
+<h1>{{.Title}}</h1>
+<p class="meta">Escalation #{{.ID}} · {{.Event}}{{if .Domain}} · {{.Domain}}{{end}} · {{.Status}}</p>
+<p>{{.Body}}</p>
+{{end}}
+{{if .Done}}
+<p class="ok">Acknowledged. Escalation has stopped.</p>
+{{else if eq .Escalation.Status "triggered" "exhausted"}}
+<form method="post"><button type="submit">Acknowledge</button></form>
+{{end}}
+{{end}}
+</main>
+</body>
+</html>
+`))
+
+// RenderAckPage writes the acknowledgement page.
+func RenderAckPage(w io.Writer, page AckPage) error {
+	return ackTemplate.Execute(w, page)
+}
@@ -0,0 +1,535 @@
+// Package oncall resolves who is on call from rotation schedules and pages them
+// through escalation policies until an alert is acknowledged.
+package oncall
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/auth"
+	"github.com/harveywai/zenstack/pkg/database"
+	"github.com/harveywai/zenstack/pkg/notify"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Escalation states.
+const (
+	StatusTriggered    = "triggered"    // Paging steps until acknowledged
+	StatusAcknowledged = "acknowledged" // Someone is working on it, paging stopped
+	StatusResolved     = "resolved"     // The alert recovered or was resolved by hand
+	StatusExhausted    = "exhausted"    // All steps were paged without acknowledgement
+)
+
+// Step target kinds besides notification channel refs.
+const (
+	TargetUser     = "user"
+	TargetSchedule = "schedule"
+)
+
+// ActionAcknowledge is the signed link action acknowledging an escalation.
+const ActionAcknowledge = "escalation.ack"
+
+// AckLinkTTL is how long acknowledgement links stay valid.
+const AckLinkTTL = 7 * 24 * time.Hour
+
+// defaultDelay is the wait for an acknowledgement before the next step.
+const defaultDelay = 10 * time.Minute
+
+// ErrNotOpen is returned when acknowledging an escalation that was resolved.
+var ErrNotOpen = errors.New("escalation is already resolved")
+
+// HandleEvent starts the escalation policies of the routing rules matching an event,
+// or resolves the escalations of the alert if the event resolves it.
+func HandleEvent(ctx context.Context, eventName string, domain database.MonitoredDomain, extraData map[string]string) error {
+	msg, in, err := notify.BuildMessage(eventName, domain, extraData)
+	if err != nil {
+		return err
+	}
+
+	if msg.Resolve {
+		n, err := ResolveKey(msg.DedupKey)
+		if err == nil && n > 0 {
+			log.Printf("Resolved %d escalation(s) of %s", n, msg.DedupKey)
+		}
+		return err
+	}
+
+	route, err := notify.RouteEvent(in)
+	if err != nil {
+		return err
+	}
+	policies := route.Policies()
+	if len(policies) > 0 && notify.CheckSilence(database.DB, eventName, domain, notify.PathEscalation) != nil {
+		return nil
+	}
+	for _, policyID := range policies {
+		if _, err := Trigger(ctx, policyID, msg); err != nil {
+			log.Printf("Error starting escalation policy %d for %s: %v", policyID, msg.DedupKey, err)
+		}
+	}
+	return nil
+}
+
+// Trigger starts paging an alert through a policy and pages the first step right away.
+// An open escalation of the same alert and policy is returned instead of paging again,
+// also when a concurrent trigger created it first.
+func Trigger(ctx context.Context, policyID uint, msg notify.Message) (*database.Escalation, error) {
+	if database.DB == nil {
+		return nil, database.ErrDatabaseNotInitialized
+	}
+
+	open, err := openEscalation(policyID, msg.DedupKey)
+	if open != nil || err != nil {
+		return open, err
+	}
+
+	policy, err := LoadPolicy(policyID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load escalation policy: %w", err)
+	}
+	if len(policy.Steps) == 0 {
+		return nil, fmt.Errorf("escalation policy %d has no steps", policyID)
+	}
+
+	esc := database.Escalation{
+		PolicyID: policyID,
+		DedupKey: msg.DedupKey,
+		Event:    msg.Event,
+		Domain:   msg.Domain,
+		Title:    msg.Title,
+		Body:     msg.Body,
+		Urgency:  msg.Urgency,
+		Status:   StatusTriggered,
+		NextAt:   time.Now(),
+	}
+	// idx_open_escalation allows one open escalation per alert and policy
+	res := database.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&esc)
+	if res.Error != nil {
+		return nil, fmt.Errorf("failed to create escalation: %w", res.Error)
+	}
+	if res.RowsAffected == 0 {
+		open, err := openEscalation(policyID, msg.DedupKey)
+		if open == nil && err == nil {
+			err = fmt.Errorf("failed to create escalation of %s", msg.DedupKey)
+		}
+		return open, err
+	}
+
+	if err := advance(ctx, &esc, policy); err != nil {
+		return &esc, err
+	}
+	return &esc, nil
+}
+
+// openEscalation returns the open escalation of an alert and policy, or nil if there
+// is none.
+func openEscalation(policyID uint, dedupKey string) (*database.Escalation, error) {
+	var open database.Escalation
+	err := database.DB.
+		Where("policy_id = ? AND dedup_key = ? AND status IN ?", policyID, dedupKey, []string{StatusTriggered, StatusAcknowledged}).
+		First(&open).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &open, nil
+}
+
+// Tick pages the next step of every escalation whose acknowledgement timeout passed.
+func Tick(ctx context.Context) {
+	if database.DB == nil {
+		return
+	}
+
+	var due []database.Escalation
+	if err := database.DB.Where("status = ? AND next_at <= ?", StatusTriggered, time.Now()).Order("next_at").Find(&due).Error; err != nil {
+		log.Printf("Error loading due escalations: %v", err)
+		return
+	}
+	for i := range due {
+		if silenced(&due[i]) {
+			continue
+		}
+		policy, err := LoadPolicy(due[i].PolicyID)
+		if err != nil {
+			log.Printf("Error loading escalation policy %d: %v", due[i].PolicyID, err)
+			continue
+		}
+		if err := advance(ctx, &due[i], policy); err != nil {
+			log.Printf("Error escalating %s: %v", due[i].DedupKey, err)
+		}
+	}
+}
+
+// silenced reports whether a silence covers the alert of an escalation, and if so
+// postpones the next step until the silence ends.
+func silenced(esc *database.Escalation) bool {
+	var domain database.MonitoredDomain
+	if esc.Domain != "" {
+		if err := database.DB.Where("domain_name = ?", esc.Domain).Limit(1).Find(&domain).Error; err != nil {
+			log.Printf("Error loading domain %s of escalation %d: %v", esc.Domain, esc.ID, err)
+		}
+	}
+	silence := notify.CheckSilence(database.DB, esc.Event, domain, notify.PathEscalation)
+	if silence == nil {
+		return false
+	}
+	esc.NextAt = silence.EndsAt
+	if err := saveProgress(esc); err != nil {
+		log.Printf("Error postponing escalation %d: %v", esc.ID, err)
+	}
+	return true
+}
+
+// advance pages the next step of an escalation and schedules the one after it. Once
+// the last step timed out the policy repeats or the escalation is exhausted.
+func advance(ctx context.Context, esc *database.Escalation, policy *database.EscalationPolicy) error {
+	if esc.Step >= len(policy.Steps) {
+		if esc.Round >= policy.Repeat {
+			esc.Status = StatusExhausted
+			return saveProgress(esc)
+		}
+		esc.Round++
+		esc.Step = 0
+	}
+
+	step := policy.Steps[esc.Step]
+	paged := page(ctx, esc, step, len(policy.Steps))
+
+	delay := time.Duration(step.DelayMinutes) * time.Minute
+	if delay <= 0 {
+		delay = defaultDelay
+	}
+	esc.Step++
+	esc.NextAt = time.Now().Add(delay)
+	esc.Paged = mergeIDs(esc.Paged, paged)
+	return saveProgress(esc)
+}
+
+// saveProgress stores the paging progress unless the escalation was acknowledged or
+// resolved in the meantime.
+func saveProgress(esc *database.Escalation) error {
+	return database.DB.Model(&database.Escalation{}).
+		Where("id = ? AND status = ?", esc.ID, StatusTriggered).
+		Updates(map[string]interface{}{
+			"status":  esc.Status,
+			"step":    esc.Step,
+			"round":   esc.Round,
+			"next_at": esc.NextAt,
+			"paged":   esc.Paged,
+		}).Error
+}
+
+// page notifies the targets of a step and returns the IDs of the users paged.
+func page(ctx context.Context, esc *database.Escalation, step database.EscalationStep, steps int) []uint {
+	var paged []uint
+	for _, target := range strings.Split(step.Targets, ",") {
+		target = strings.ToLower(strings.TrimSpace(target))
+		if target == "" {
+			continue
+		}
+		kind, id, _ := strings.Cut(target, ":")
+		n, _ := strconv.ParseUint(id, 10, 64)
+
+		switch kind {
+		case TargetUser, TargetSchedule:
+			userID := uint(n)
+			if kind == TargetSchedule {
+				schedule, err := LoadSchedule(uint(n))
+				if err != nil {
+					log.Printf("Error loading schedule %d for escalation %d: %v", n, esc.ID, err)
+					continue
+				}
+				var ok bool
+				if userID, ok = OnCall(*schedule, time.Now()); !ok {
+					log.Printf("Nobody is on call for schedule %q, skipping escalation %d step %d", schedule.Name, esc.ID, esc.Step+1)
+					continue
+				}
+			}
+			if err := pageUser(ctx, esc, userID, steps); err != nil {
+				log.Printf("Error paging user %d for escalation %d: %v", userID, esc.ID, err)
+				continue
+			}
+			paged = append(paged, userID)
+		default:
+			if err := pageChannel(ctx, esc, target, steps); err != nil {
+				log.Printf("Error paging %s for escalation %d: %v", target, esc.ID, err)
+			}
+		}
+	}
+	return paged
+}
+
+// pageUser sends the page to the email address and Telegram chat of a user, using the
+// first active email server and Telegram bot.
+func pageUser(ctx context.Context, esc *database.Escalation, userID uint, steps int) error {
+	var user database.User
+	if err := database.DB.First(&user, userID).Error; err != nil {
+		return fmt.Errorf("user not found")
+	}
+	msg, err := pageMessage(esc, user.ID, steps)
+	if err != nil {
+		return err
+	}
+
+	type contact struct {
+		notifier notify.Notifier
+		info     notify.DeliveryInfo
+	}
+	var contacts []contact
+	if user.Email != "" {
+		if s, err := notify.ActiveSettings(notify.ChannelEmail); err == nil && s != nil {
+			n := notify.NewEmailNotifier(*s.(*notify.EmailSettings))
+			n.To = []string{user.Email}
+			contacts = append(contacts, contact{n, notify.DeliveryInfo{Channel: notify.ChannelEmail, Recipient: user.Email}})
+		}
+	}
+	if user.TelegramChatID != "" {
+		if s, err := notify.ActiveSettings(notify.ChannelTelegram); err == nil && s != nil {
+			n := &notify.TelegramNotifier{Token: s.(*notify.TelegramSettings).Token, ChatID: user.TelegramChatID}
+			contacts = append(contacts, contact{n, notify.DeliveryInfo{Channel: notify.ChannelTelegram, Recipient: user.TelegramChatID}})
+		}
+	}
+	if len(contacts) == 0 {
+		return fmt.Errorf("user %s has no reachable contact (email or telegram_chat_id with an active channel)", user.Username)
+	}
+
+	var errs []error
+	for _, c := range contacts {
+		c.info.EscalationID = esc.ID
+		c.info.UserID = user.ID
+		if err := notify.Deliver(ctx, c.notifier, msg, c.info); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", c.notifier.Platform(), err))
+		}
+	}
+	if len(errs) == len(contacts) {
+		return errors.Join(errs...)
+	}
+	log.Printf("Paged %s for escalation %d step %d", user.Username, esc.ID, esc.Step+1)
+	return nil
+}
+
+// pageChannel sends the page to an active notification channel, e.g. the team channel.
+func pageChannel(ctx context.Context, esc *database.Escalation, ref string, steps int) error {
+	channels, err := notify.ActiveChannels()
+	if err != nil {
+		return err
+	}
+	for _, ch := range channels {
+		if ch.Ref != ref {
+			continue
+		}
+		msg, err := pageMessage(esc, 0, steps)
+		if err != nil {
+			return err
+		}
+		return notify.Deliver(ctx, ch.Notifier, msg, notify.DeliveryInfo{Channel: ch.Ref, Recipient: ch.Name, EscalationID: esc.ID})
+	}
+	return fmt.Errorf("channel %s not found or inactive", ref)
+}
+
+// pageMessage renders a page with a signed acknowledgement link for the recipient.
+func pageMessage(esc *database.Escalation, userID uint, steps int) (notify.Message, error) {
+	link, err := AckURL(esc.ID, userID)
+	if err != nil {
+		return notify.Message{}, fmt.Errorf("failed to sign acknowledgement link: %w", err)
+	}
+
+	body := esc.Body
+	if body != "" {
+		body += "\n\n"
+	}
+	body += "Acknowledge: " + link
+
+	return notify.Message{
+		Event:    esc.Event,
+		Severity: notify.Severity(esc.Event),
+		Domain:   esc.Domain,
+		Title:    "[On-call] " + esc.Title,
+		Body:     body,
+		Text:     esc.Title + "\n\n" + body,
+		Fields: []notify.Field{
+			{Name: "Escalation", Value: fmt.Sprintf("#%d, step %d of %d", esc.ID, esc.Step+1, steps)},
+		},
+		Time:     time.Now(),
+		DedupKey: esc.DedupKey,
+		Urgency:  esc.Urgency,
+	}, nil
+}
+
+// AckURL returns the signed link acknowledging an escalation on behalf of a user.
+// Links point to notify.PublicURL.
+func AckURL(escalationID, userID uint) (string, error) {
+	token, err := auth.SignAction(ActionAcknowledge, escalationID, userID, AckLinkTTL)
+	if err != nil {
+		return "", err
+	}
+	return notify.PublicURL() + "/v1/oncall/ack/" + token, nil
+}
+
+// Acknowledge stops paging an escalation. Acknowledging twice keeps the first
+// acknowledgement; exhausted escalations can still be acknowledged.
+func Acknowledge(id, userID uint) (*database.Escalation, error) {
+	if database.DB == nil {
+		return nil, database.ErrDatabaseNotInitialized
+	}
+
+	now := time.Now()
+	err := database.DB.Model(&database.Escalation{}).
+		Where("id = ? AND status IN ?", id, []string{StatusTriggered, StatusExhausted}).
+		Updates(map[string]interface{}{"status": StatusAcknowledged, "acked_by": userID, "acked_at": now}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var esc database.Escalation
+	if err := database.DB.First(&esc, id).Error; err != nil {
+		return nil, err
+	}
+	if esc.Status == StatusResolved {
+		return &esc, ErrNotOpen
+	}
+	return &esc, nil
+}
+
+// Resolve closes an escalation by hand.
+func Resolve(id uint) (*database.Escalation, error) {
+	if database.DB == nil {
+		return nil, database.ErrDatabaseNotInitialized
+	}
+
+	err := database.DB.Model(&database.Escalation{}).
+		Where("id = ? AND status <> ?", id, StatusResolved).
+		Updates(map[string]interface{}{"status": StatusResolved, "resolved_at": time.Now()}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var esc database.Escalation
+	if err := database.DB.First(&esc, id).Error; err != nil {
+		return nil, err
+	}
+	return &esc, nil
+}
+
+// ResolveKey closes all escalations of an alert, e.g. when the site recovered.
+func ResolveKey(dedupKey string) (int64, error) {
+	if database.DB == nil {
+		return 0, database.ErrDatabaseNotInitialized
+	}
+
+	result := database.DB.Model(&database.Escalation{}).
+		Where("dedup_key = ? AND status <> ?", dedupKey, StatusResolved).
+		Updates(map[string]interface{}{"status": StatusResolved, "resolved_at": time.Now()})
+	return result.RowsAffected, result.Error
+}
+
+// LoadPolicy loads an escalation policy with its steps in order.
+func LoadPolicy(id uint) (*database.EscalationPolicy, error) {
+	if database.DB == nil {
+		return nil, database.ErrDatabaseNotInitialized
+	}
+
+	var policy database.EscalationPolicy
+	err := database.DB.
+		Preload("Steps", func(db *gorm.DB) *gorm.DB { return db.Order("position asc, id asc") }).
+		First(&policy, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// ValidatePolicy checks the steps and targets of an escalation policy.
+func ValidatePolicy(policy database.EscalationPolicy) error {
+	if strings.TrimSpace(policy.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if policy.Repeat < 0 {
+		return fmt.Errorf("repeat must not be negative")
+	}
+	if len(policy.Steps) == 0 {
+		return fmt.Errorf("at least one step is required")
+	}
+
+	for i, step := range policy.Steps {
+		if step.DelayMinutes < 0 {
+			return fmt.Errorf("step %d: delay_minutes must not be negative", i+1)
+		}
+		targets := 0
+		for _, target := range strings.Split(step.Targets, ",") {
+			target = strings.ToLower(strings.TrimSpace(target))
+			if target == "" {
+				continue
+			}
+			targets++
+			if err := validateTarget(target); err != nil {
+				return fmt.Errorf("step %d: %w", i+1, err)
+			}
+		}
+		if targets == 0 {
+			return fmt.Errorf("step %d: at least one target is required", i+1)
+		}
+	}
+	return nil
+}
+
+// validateTarget checks that a step target refers to an existing user, schedule or
+// notification channel.
+func validateTarget(target string) error {
+	kind, id, ok := strings.Cut(target, ":")
+	if !ok {
+		return fmt.Errorf("invalid target %q (expected e.g. schedule:1, user:2 or webhook:3)", target)
+	}
+
+	var model interface{}
+	switch kind {
+	case TargetUser:
+		model = &database.User{}
+	case TargetSchedule:
+		model = &database.OnCallSchedule{}
+	case notify.ChannelWebhook, notify.ChannelEmail, notify.ChannelTelegram:
+		return notify.ValidateChannelRefs([]string{target})
+	default:
+		return fmt.Errorf("invalid target kind %q (expected user, schedule, webhook, email or telegram)", kind)
+	}
+	if database.DB != nil {
+		if err := database.DB.First(model, "id = ?", id).Error; err != nil {
+			return fmt.Errorf("target %s not found", target)
+		}
+	}
+	return nil
+}
+
+// mergeIDs adds user IDs to a comma-separated list, keeping it free of duplicates.
+func mergeIDs(list string, ids []uint) string {
+	existing, _ := ParseUserIDs(list)
+	for _, id := range ids {
+		found := false
+		for _, e := range existing {
+			if e == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			existing = append(existing, id)
+		}
+	}
+	sort.Slice(existing, func(i, j int) bool { return existing[i] < existing[j] })
+
+	items := make([]string, len(existing))
+	for i, id := range existing {
+		items[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return strings.Join(items, ",")
+}
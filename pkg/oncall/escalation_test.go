@@ -0,0 +1,294 @@
+package oncall
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"github.com/harveywai/zenstack/pkg/notify"
+	"github.com/harveywai/zenstack/pkg/secrets"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// pager records the pages sent to the webhook channels of a test.
+type pager struct {
+	srv *httptest.Server
+
+	mu    sync.Mutex
+	pages []string // Path of the channel, e.g. /a
+}
+
+func (p *pager) paged() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return strings.Join(p.pages, " ")
+}
+
+// setupEscalations opens a test database and serves webhook channels.
+func setupEscalations(t *testing.T) *pager {
+	t.Helper()
+
+	key, err := secrets.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ZENSTACK_MASTER_KEY", key)
+	if err := secrets.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Concurrent triggers wait for each other's writes
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_busy_timeout=5000"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.AutoMigrate(&database.Channel{}, &database.DeliveryAttempt{}, &database.User{}, &database.MonitoredDomain{}, &database.Silence{},
+		&database.EscalationPolicy{}, &database.EscalationStep{}, &database.Escalation{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = prev })
+
+	p := &pager{}
+	p.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		p.pages = append(p.pages, r.URL.Path)
+		p.mu.Unlock()
+	}))
+	t.Cleanup(p.srv.Close)
+	return p
+}
+
+// channel creates a webhook channel paging the path and returns its ref.
+func (p *pager) channel(t *testing.T, path string) string {
+	t.Helper()
+	ch := database.Channel{Type: notify.ChannelWebhook, IsActive: true}
+	if err := notify.EncodeSettings(&ch, &notify.WebhookSettings{Platform: "custom", URL: p.srv.URL + path}); err != nil {
+		t.Fatal(err)
+	}
+	if err := notify.CreateChannel(database.DB, &ch); err != nil {
+		t.Fatal(err)
+	}
+	return notify.ChannelRef(ch.Type, ch.ID)
+}
+
+func createPolicy(t *testing.T, repeat int, steps ...database.EscalationStep) *database.EscalationPolicy {
+	t.Helper()
+	for i := range steps {
+		steps[i].Position = i
+	}
+	policy := database.EscalationPolicy{Name: "ops", Repeat: repeat, Steps: steps}
+	if err := database.DB.Create(&policy).Error; err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadPolicy(policy.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loaded
+}
+
+func alert(key string) notify.Message {
+	return notify.Message{Event: "SITE_DOWN", DedupKey: key, Domain: "example.com", Title: "example.com is down"}
+}
+
+func loadEscalation(t *testing.T, id uint) database.Escalation {
+	t.Helper()
+	var esc database.Escalation
+	if err := database.DB.First(&esc, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return esc
+}
+
+func TestAdvance(t *testing.T) {
+	p := setupEscalations(t)
+	a, b := p.channel(t, "/a"), p.channel(t, "/b")
+	policy := createPolicy(t, 1,
+		database.EscalationStep{Targets: a, DelayMinutes: 5},
+		database.EscalationStep{Targets: b + ", webhook:99", DelayMinutes: -1},
+	)
+
+	before := time.Now()
+	esc, err := Trigger(context.Background(), policy.ID, alert("site:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		pages  string
+		step   int
+		round  int
+		delay  time.Duration
+		status string
+	}{
+		{"/a", 1, 0, 5 * time.Minute, StatusTriggered},
+		// Unknown targets are skipped and steps without a delay use the default
+		{"/a /b", 2, 0, defaultDelay, StatusTriggered},
+		// The policy repeats once
+		{"/a /b /a", 1, 1, 5 * time.Minute, StatusTriggered},
+		{"/a /b /a /b", 2, 1, defaultDelay, StatusTriggered},
+		{"/a /b /a /b", 2, 1, 0, StatusExhausted},
+	}
+	for i, s := range steps {
+		if i > 0 {
+			before = time.Now()
+			if err := advance(context.Background(), esc, policy); err != nil {
+				t.Fatal(err)
+			}
+		}
+		got := loadEscalation(t, esc.ID)
+		if p.paged() != s.pages || got.Step != s.step || got.Round != s.round || got.Status != s.status {
+			t.Fatalf("advance %d: paged %q, step %d, round %d, status %s", i, p.paged(), got.Step, got.Round, got.Status)
+		}
+		if s.delay > 0 && (got.NextAt.Before(before.Add(s.delay)) || got.NextAt.After(time.Now().Add(s.delay))) {
+			t.Errorf("advance %d: next step at %s, want in %s", i, got.NextAt, s.delay)
+		}
+	}
+}
+
+func TestSaveProgressKeepsAcknowledgement(t *testing.T) {
+	p := setupEscalations(t)
+	policy := createPolicy(t, 0,
+		database.EscalationStep{Targets: p.channel(t, "/a")},
+		database.EscalationStep{Targets: p.channel(t, "/b")},
+	)
+	esc, err := Trigger(context.Background(), policy.ID, alert("site:1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Acknowledged while the next step was paged from an older copy
+	if _, err := Acknowledge(esc.ID, 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := advance(context.Background(), esc, policy); err != nil {
+		t.Fatal(err)
+	}
+	got := loadEscalation(t, esc.ID)
+	if got.Status != StatusAcknowledged || got.Step != 1 || got.AckedBy != 3 {
+		t.Errorf("status %s, step %d, acked by %d, want the acknowledgement at step 1", got.Status, got.Step, got.AckedBy)
+	}
+
+	// Tick only pages escalations still triggered
+	database.DB.Model(&got).Update("next_at", time.Now().Add(-time.Minute))
+	Tick(context.Background())
+	if p.paged() != "/a /b" {
+		t.Errorf("paged %q after the acknowledgement", p.paged())
+	}
+}
+
+func TestAcknowledgeAndResolve(t *testing.T) {
+	p := setupEscalations(t)
+	policy := createPolicy(t, 0, database.EscalationStep{Targets: p.channel(t, "/a")})
+	trigger := func(key string) *database.Escalation {
+		t.Helper()
+		esc, err := Trigger(context.Background(), policy.ID, alert(key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return esc
+	}
+
+	first := trigger("site:1")
+	acked, err := Acknowledge(first.ID, 3)
+	if err != nil || acked.Status != StatusAcknowledged || acked.AckedBy != 3 || acked.AckedAt == nil {
+		t.Fatalf("Acknowledge() = %+v, %v", acked, err)
+	}
+	// The first acknowledgement is kept
+	if again, err := Acknowledge(first.ID, 4); err != nil || again.AckedBy != 3 {
+		t.Errorf("acknowledging again: acked by %d, %v", again.AckedBy, err)
+	}
+
+	// Exhausted escalations can still be acknowledged
+	exhausted := trigger("site:2")
+	database.DB.Model(exhausted).Update("status", StatusExhausted)
+	if got, err := Acknowledge(exhausted.ID, 4); err != nil || got.Status != StatusAcknowledged {
+		t.Errorf("acknowledging an exhausted escalation: %s, %v", got.Status, err)
+	}
+
+	resolved, err := Resolve(first.ID)
+	if err != nil || resolved.Status != StatusResolved || resolved.ResolvedAt == nil {
+		t.Fatalf("Resolve() = %+v, %v", resolved, err)
+	}
+	if got, err := Acknowledge(first.ID, 4); !errors.Is(err, ErrNotOpen) || got.Status != StatusResolved {
+		t.Errorf("acknowledging a resolved escalation: %s, %v", got.Status, err)
+	}
+	if _, err := Acknowledge(999, 4); err == nil {
+		t.Error("acknowledged a missing escalation")
+	}
+
+	// An alert triggered again after it was resolved pages again
+	second := trigger("site:1")
+	if second.ID == first.ID {
+		t.Fatal("the resolved escalation was returned")
+	}
+	n, err := ResolveKey("site:1")
+	if err != nil || n != 1 {
+		t.Errorf("ResolveKey() = %d, %v, want 1", n, err)
+	}
+	if n, _ := ResolveKey("site:1"); n != 0 {
+		t.Errorf("resolving again changed %d escalations", n)
+	}
+	if got := loadEscalation(t, second.ID); got.Status != StatusResolved {
+		t.Errorf("status %s, want resolved", got.Status)
+	}
+	if got := loadEscalation(t, exhausted.ID); got.Status != StatusAcknowledged {
+		t.Errorf("resolving site:1 changed site:2 to %s", got.Status)
+	}
+}
+
+func TestTriggerPagesOncePerAlert(t *testing.T) {
+	p := setupEscalations(t)
+	policy := createPolicy(t, 0, database.EscalationStep{Targets: p.channel(t, "/a")})
+
+	var wg sync.WaitGroup
+	ids := make([]uint, 8)
+	for i := range ids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			esc, err := Trigger(context.Background(), policy.ID, alert("site:1"))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			ids[i] = esc.ID
+		}(i)
+	}
+	wg.Wait()
+
+	for _, id := range ids {
+		if id != ids[0] {
+			t.Errorf("triggers returned escalations %v, want one", ids)
+			break
+		}
+	}
+	var n int64
+	database.DB.Model(&database.Escalation{}).Count(&n)
+	if n != 1 || p.paged() != "/a" {
+		t.Errorf("%d escalations paged %q, want one page", n, p.paged())
+	}
+
+	// The index only covers open escalations
+	if err := database.DB.Create(&database.Escalation{PolicyID: policy.ID, DedupKey: "site:1", Status: StatusTriggered}).Error; err == nil {
+		t.Error("created a second open escalation")
+	}
+	for _, status := range []string{StatusResolved, StatusExhausted, StatusExhausted} {
+		if err := database.DB.Create(&database.Escalation{PolicyID: policy.ID, DedupKey: "site:1", Status: status}).Error; err != nil {
+			t.Errorf("creating a %s escalation: %v", status, err)
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package oncall
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"gorm.io/gorm"
+)
+
+// LoadSchedule loads a schedule with its layers and overrides.
+func LoadSchedule(id uint) (*database.OnCallSchedule, error) {
+	if database.DB == nil {
+		return nil, database.ErrDatabaseNotInitialized
+	}
+
+	var schedule database.OnCallSchedule
+	err := database.DB.
+		Preload("Layers", func(db *gorm.DB) *gorm.DB { return db.Order("position asc, id asc") }).
+		Preload("Overrides", func(db *gorm.DB) *gorm.DB { return db.Order("start asc, id asc") }).
+		First(&schedule, id).Error
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// OnCall returns the user on call for a schedule at t. An override covering t wins,
+// the most recent one if several overlap; otherwise the highest layer that is active
+// at t decides. It returns false if nobody is on call.
+func OnCall(schedule database.OnCallSchedule, t time.Time) (uint, bool) {
+	var override *database.ScheduleOverride
+	for i, o := range schedule.Overrides {
+		if !t.Before(o.Start) && t.Before(o.End) && (override == nil || o.ID > override.ID) {
+			override = &schedule.Overrides[i]
+		}
+	}
+	if override != nil {
+		return override.UserID, true
+	}
+
+	loc := location(schedule.TimeZone)
+	layers := append([]database.ScheduleLayer(nil), schedule.Layers...)
+	sort.SliceStable(layers, func(i, j int) bool {
+		if layers[i].Position != layers[j].Position {
+			return layers[i].Position > layers[j].Position
+		}
+		return layers[i].ID > layers[j].ID
+	})
+	for _, layer := range layers {
+		if user, ok := layerOnCall(layer, t, loc); ok {
+			return user, true
+		}
+	}
+	return 0, false
+}
+
+// layerOnCall returns the user of a layer's rotation at t.
+func layerOnCall(layer database.ScheduleLayer, t time.Time, loc *time.Location) (uint, bool) {
+	users, err := ParseUserIDs(layer.Users)
+	if err != nil || len(users) == 0 || layer.RotationHours <= 0 || t.Before(layer.Start) {
+		return 0, false
+	}
+	if !withinRestriction(layer, t.In(loc)) {
+		return 0, false
+	}
+
+	rotation := time.Duration(layer.RotationHours) * time.Hour
+	turn := int64(t.Sub(layer.Start) / rotation)
+	return users[turn%int64(len(users))], true
+}
+
+// withinRestriction reports whether the local time falls within the layer's daily
+// window. Windows ending before they start span midnight, e.g. 18:00-09:00.
+func withinRestriction(layer database.ScheduleLayer, local time.Time) bool {
+	if layer.RestrictStart == "" && layer.RestrictEnd == "" {
+		return true
+	}
+	start, err1 := parseClock(layer.RestrictStart)
+	end, err2 := parseClock(layer.RestrictEnd)
+	if err1 != nil || err2 != nil || start == end {
+		return true
+	}
+
+	now := local.Hour()*60 + local.Minute()
+	if start < end {
+		return now >= start && now < end
+	}
+	return now >= start || now < end
+}
+
+// parseClock parses "HH:MM" into minutes after midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (expected HH:MM)", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// location loads a schedule's time zone, falling back to UTC.
+func location(name string) *time.Location {
+	if loc, err := time.LoadLocation(name); err == nil && name != "" {
+		return loc
+	}
+	return time.UTC
+}
+
+// ParseUserIDs parses a comma-separated list of user IDs.
+func ParseUserIDs(list string) ([]uint, error) {
+	var ids []uint
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(item, 10, 64)
+		if err != nil || id == 0 {
+			return nil, fmt.Errorf("invalid user ID %q", item)
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids, nil
+}
+
+// ValidateSchedule checks the time zone, layers and overrides of a schedule.
+func ValidateSchedule(schedule database.OnCallSchedule) error {
+	if strings.TrimSpace(schedule.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if schedule.TimeZone != "" {
+		if _, err := time.LoadLocation(schedule.TimeZone); err != nil {
+			return fmt.Errorf("invalid time zone %q", schedule.TimeZone)
+		}
+	}
+
+	for i, layer := range schedule.Layers {
+		users, err := ParseUserIDs(layer.Users)
+		if err != nil {
+			return fmt.Errorf("layer %d: %w", i+1, err)
+		}
+		if len(users) == 0 {
+			return fmt.Errorf("layer %d: at least one user is required", i+1)
+		}
+		if err := usersExist(users...); err != nil {
+			return fmt.Errorf("layer %d: %w", i+1, err)
+		}
+		if layer.RotationHours <= 0 {
+			return fmt.Errorf("layer %d: rotation_hours must be positive", i+1)
+		}
+		if layer.Start.IsZero() {
+			return fmt.Errorf("layer %d: start is required", i+1)
+		}
+		if (layer.RestrictStart == "") != (layer.RestrictEnd == "") {
+			return fmt.Errorf("layer %d: restrict_start and restrict_end must be set together", i+1)
+		}
+		if layer.RestrictStart != "" {
+			if _, err := parseClock(layer.RestrictStart); err != nil {
+				return fmt.Errorf("layer %d: %w", i+1, err)
+			}
+			if _, err := parseClock(layer.RestrictEnd); err != nil {
+				return fmt.Errorf("layer %d: %w", i+1, err)
+			}
+		}
+	}
+
+	for _, o := range schedule.Overrides {
+		if err := ValidateOverride(o); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateOverride checks the user and time range of an override.
+func ValidateOverride(o database.ScheduleOverride) error {
+	if o.UserID == 0 {
+		return fmt.Errorf("override user_id is required")
+	}
+	if o.Start.IsZero() || !o.End.After(o.Start) {
+		return fmt.Errorf("override end must be after start")
+	}
+	return usersExist(o.UserID)
+}
+
+// usersExist checks that all users exist.
+func usersExist(ids ...uint) error {
+	if database.DB == nil {
+		return nil
+	}
+	for _, id := range ids {
+		if err := database.DB.First(&database.User{}, id).Error; err != nil {
+			return fmt.Errorf("user %d not found", id)
+		}
+	}
+	return nil
+}
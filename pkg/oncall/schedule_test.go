@@ -0,0 +1,59 @@
+package oncall
+
+import (
+	"testing"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+)
+
+func TestOnCall(t *testing.T) {
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	weekly := database.ScheduleLayer{ID: 1, Users: "1, 2,3", Start: start, RotationHours: 24}
+	// Berlin is UTC+1 in January: 18:00-09:00 local is 17:00-08:00 UTC
+	nights := database.ScheduleLayer{ID: 2, Position: 1, Users: "4", Start: start, RotationHours: 24, RestrictStart: "18:00", RestrictEnd: "09:00"}
+	overrides := []database.ScheduleOverride{
+		{ID: 1, UserID: 7, Start: start.Add(10 * day), End: start.Add(11 * day)},
+		{ID: 2, UserID: 8, Start: start.Add(10*day + 6*time.Hour), End: start.Add(10*day + 8*time.Hour)},
+	}
+
+	tests := []struct {
+		name   string
+		layers []database.ScheduleLayer
+		at     time.Time
+		want   uint // 0 when nobody is on call
+	}{
+		{"before the rotation starts", []database.ScheduleLayer{weekly}, start.Add(-time.Minute), 0},
+		{"first user at the start", []database.ScheduleLayer{weekly}, start, 1},
+		{"first user until the handover", []database.ScheduleLayer{weekly}, start.Add(day - time.Second), 1},
+		{"second user after the handover", []database.ScheduleLayer{weekly}, start.Add(day), 2},
+		{"third user", []database.ScheduleLayer{weekly}, start.Add(2*day + 5*time.Hour), 3},
+		{"the rotation wraps around", []database.ScheduleLayer{weekly}, start.Add(3 * day), 1},
+		{"weeks later", []database.ScheduleLayer{weekly}, start.Add(7*day + time.Hour), 2},
+		{"higher layer within its window", []database.ScheduleLayer{weekly, nights}, time.Date(2026, 1, 5, 20, 0, 0, 0, time.UTC), 4},
+		{"window spans midnight", []database.ScheduleLayer{weekly, nights}, time.Date(2026, 1, 6, 7, 59, 0, 0, time.UTC), 4},
+		{"lower layer outside the window", []database.ScheduleLayer{weekly, nights}, time.Date(2026, 1, 6, 9, 0, 0, 0, time.UTC), 2},
+		{"window start in local time", []database.ScheduleLayer{weekly, nights}, time.Date(2026, 1, 5, 16, 59, 0, 0, time.UTC), 1},
+		{
+			"same position, the later layer wins",
+			[]database.ScheduleLayer{{ID: 3, Users: "5", Start: start, RotationHours: 24}, {ID: 1, Users: "6", Start: start, RotationHours: 24}},
+			start, 5,
+		},
+		{"layer without valid users", []database.ScheduleLayer{{ID: 1, Users: "1,x", Start: start, RotationHours: 24}}, start, 0},
+		{"layer without rotation", []database.ScheduleLayer{{ID: 1, Users: "1", Start: start}}, start, 0},
+		{"override", []database.ScheduleLayer{weekly}, start.Add(10*day + time.Hour), 7},
+		{"the later of overlapping overrides", []database.ScheduleLayer{weekly}, start.Add(10*day + 7*time.Hour), 8},
+		{"overrides end before their end time", []database.ScheduleLayer{weekly}, start.Add(11 * day), 3},
+		{"override without layers", nil, start.Add(10 * day), 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule := database.OnCallSchedule{TimeZone: "Europe/Berlin", Layers: tt.layers, Overrides: overrides}
+			user, ok := OnCall(schedule, tt.at)
+			if ok != (tt.want != 0) || user != tt.want {
+				t.Errorf("OnCall(%s) = %d, %v, want %d", tt.at.Format(time.RFC3339), user, ok, tt.want)
+			}
+		})
+	}
+}
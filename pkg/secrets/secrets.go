@@ -0,0 +1,388 @@
+// Package secrets encrypts the secrets ZenStack stores, such as bot tokens and
+// webhook keys, with envelope encryption. Each value is encrypted with a random data
+// key (AES-256-GCM), and the data key with a master key. Sealed values are stored as
+//
+//	enc:v2:<master key ID>:<base64 encrypted data key>:<base64 ciphertext>
+//
+// The ciphertext is bound to additional data naming where the value is stored, such
+// as a channel and setting, so that sealed values copied to another place don't
+// open. Values sealed by earlier versions use the prefix enc:v1: and aren't bound;
+// Rewrap upgrades them.
+//
+// Master keys are 32 random bytes, base64-encoded, read from ZENSTACK_MASTER_KEY or
+// from the file named by ZENSTACK_MASTER_KEY_FILE (zenstack.key by default, created
+// on first start when neither is set). Several keys may be listed, separated by
+// commas or newlines. The first is the primary key that seals new values; the others
+// only open values sealed before a rotation. To rotate, list a new key first and
+// restart: Rewrap re-encrypts the data keys of older values with it, after which the
+// old key can be removed.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Prefixes of sealed values. Values without one are plaintext stored before
+// encryption was enabled.
+const (
+	prefix   = "enc:v2:"
+	prefixV1 = "enc:v1:" // Not bound to additional data
+)
+
+// KeySize is the size of master and data keys in bytes.
+const KeySize = 32
+
+// DefaultKeyFile is the master key file used when neither ZENSTACK_MASTER_KEY nor
+// ZENSTACK_MASTER_KEY_FILE is set.
+const DefaultKeyFile = "zenstack.key"
+
+var (
+	// ErrNoKeys is returned when sealing or opening values before keys are loaded.
+	ErrNoKeys = errors.New("secrets: no master key loaded")
+	// ErrUnknownKey is returned for values sealed with a master key that isn't loaded.
+	ErrUnknownKey = errors.New("secrets: value sealed with an unknown master key")
+	// ErrMalformed is returned for values with the prefix of sealed values that can't be parsed.
+	ErrMalformed = errors.New("secrets: malformed sealed value")
+)
+
+// Keyring holds the master keys. The first key is the primary key.
+type Keyring struct {
+	keys []masterKey
+}
+
+type masterKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// ParseKeys parses base64-encoded master keys separated by commas, whitespace or
+// newlines. Lines starting with # are comments.
+func ParseKeys(list string) (*Keyring, error) {
+	k := &Keyring{}
+	seen := make(map[string]bool)
+	for _, line := range strings.Split(list, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			continue
+		}
+		for _, item := range strings.FieldsFunc(line, func(r rune) bool { return r == ',' || r == ' ' || r == '\t' || r == '\r' }) {
+			raw, err := base64.StdEncoding.DecodeString(item)
+			if err != nil || len(raw) != KeySize {
+				return nil, fmt.Errorf("secrets: invalid master key (expected %d base64-encoded bytes)", KeySize)
+			}
+			aead, err := newAEAD(raw)
+			if err != nil {
+				return nil, err
+			}
+			id := KeyID(raw)
+			if !seen[id] {
+				seen[id] = true
+				k.keys = append(k.keys, masterKey{id: id, aead: aead})
+			}
+		}
+	}
+	if len(k.keys) == 0 {
+		return nil, fmt.Errorf("secrets: no master key given")
+	}
+	return k, nil
+}
+
+// KeyID returns the ID of a master key: the start of its SHA-256 fingerprint.
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+// GenerateKey returns a new base64-encoded master key.
+func GenerateKey() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return "", fmt.Errorf("secrets: failed to generate key: %w", err)
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// Primary returns the ID of the primary key.
+func (k *Keyring) Primary() string {
+	return k.keys[0].id
+}
+
+// IDs returns the IDs of all keys, primary first.
+func (k *Keyring) IDs() []string {
+	ids := make([]string, len(k.keys))
+	for i, key := range k.keys {
+		ids[i] = key.id
+	}
+	return ids
+}
+
+func (k *Keyring) key(id string) (masterKey, bool) {
+	for _, key := range k.keys {
+		if key.id == id {
+			return key, true
+		}
+	}
+	return masterKey{}, false
+}
+
+// Seal encrypts a value with a new data key under the primary key, bound to the
+// additional data. Empty values stay empty.
+func (k *Keyring) Seal(plaintext, additional string) (string, error) {
+	if plaintext == "" {
+		return "", nil
+	}
+	dataKey := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return "", fmt.Errorf("secrets: failed to generate data key: %w", err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	ciphertext, err := seal(aead, []byte(plaintext), []byte(additional))
+	if err != nil {
+		return "", err
+	}
+	primary := k.keys[0]
+	wrapped, err := seal(primary.aead, dataKey, []byte(primary.id))
+	if err != nil {
+		return "", err
+	}
+	return format(primary.id, wrapped, ciphertext), nil
+}
+
+// Open decrypts a sealed value with the additional data it was sealed with.
+// Plaintext values are returned as they are.
+func (k *Keyring) Open(value, additional string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	id, wrapped, ciphertext, err := parse(value)
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := k.unwrap(id, wrapped)
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	var ad []byte
+	if !strings.HasPrefix(value, prefixV1) {
+		ad = []byte(additional)
+	}
+	plaintext, err := open(aead, ciphertext, ad)
+	if err != nil {
+		return "", fmt.Errorf("secrets: failed to decrypt value: %w", err)
+	}
+	return string(plaintext), nil
+}
+
+// Rewrap brings a value up to date: plaintext values and values sealed without
+// additional data are sealed with it, and the data key of values sealed under
+// another master key is re-encrypted with the primary key. It reports whether the
+// value changed.
+func (k *Keyring) Rewrap(value, additional string) (string, bool, error) {
+	if value == "" {
+		return value, false, nil
+	}
+	if !IsSealed(value) || strings.HasPrefix(value, prefixV1) {
+		plaintext, err := k.Open(value, additional)
+		if err != nil {
+			return value, false, err
+		}
+		sealed, err := k.Seal(plaintext, additional)
+		if err != nil {
+			return value, false, err
+		}
+		return sealed, true, nil
+	}
+	id, wrapped, ciphertext, err := parse(value)
+	if err != nil {
+		return value, false, err
+	}
+	primary := k.keys[0]
+	if id == primary.id {
+		return value, false, nil
+	}
+	dataKey, err := k.unwrap(id, wrapped)
+	if err != nil {
+		return value, false, err
+	}
+	rewrapped, err := seal(primary.aead, dataKey, []byte(primary.id))
+	if err != nil {
+		return value, false, err
+	}
+	return format(primary.id, rewrapped, ciphertext), true, nil
+}
+
+func (k *Keyring) unwrap(id string, wrapped []byte) ([]byte, error) {
+	key, ok := k.key(id)
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownKey, id)
+	}
+	dataKey, err := open(key.aead, wrapped, []byte(id))
+	if err != nil {
+		return nil, fmt.Errorf("secrets: failed to decrypt data key: %w", err)
+	}
+	return dataKey, nil
+}
+
+// IsSealed reports whether a value was sealed.
+func IsSealed(value string) bool {
+	return strings.HasPrefix(value, prefix) || strings.HasPrefix(value, prefixV1)
+}
+
+// SealedKeyID returns the ID of the master key a value was sealed under, or "" for
+// plaintext and malformed values.
+func SealedKeyID(value string) string {
+	if !IsSealed(value) {
+		return ""
+	}
+	id, _, _, err := parse(value)
+	if err != nil {
+		return ""
+	}
+	return id
+}
+
+func format(id string, wrapped, ciphertext []byte) string {
+	return prefix + id + ":" + base64.StdEncoding.EncodeToString(wrapped) + ":" + base64.StdEncoding.EncodeToString(ciphertext)
+}
+
+func parse(value string) (id string, wrapped, ciphertext []byte, err error) {
+	// Both prefixes have the same length
+	parts := strings.Split(value[len(prefix):], ":")
+	if len(parts) != 3 {
+		return "", nil, nil, ErrMalformed
+	}
+	if wrapped, err = base64.StdEncoding.DecodeString(parts[1]); err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+	if ciphertext, err = base64.StdEncoding.DecodeString(parts[2]); err != nil {
+		return "", nil, nil, ErrMalformed
+	}
+	return parts[0], wrapped, ciphertext, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("secrets: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts with a random nonce, which is prepended to the ciphertext.
+func seal(aead cipher.AEAD, plaintext, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("secrets: failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+func open(aead cipher.AEAD, sealed, additional []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+	return aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], additional)
+}
+
+var (
+	mu      sync.RWMutex
+	keyring *Keyring
+)
+
+// Load loads the master keys from ZENSTACK_MASTER_KEY or the key file. Without
+// either, DefaultKeyFile is created with a new key.
+func Load() error {
+	list := os.Getenv("ZENSTACK_MASTER_KEY")
+	if list == "" {
+		path := os.Getenv("ZENSTACK_MASTER_KEY_FILE")
+		if path == "" {
+			path = DefaultKeyFile
+		}
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) && os.Getenv("ZENSTACK_MASTER_KEY_FILE") == "" {
+			data, err = createKeyFile(path)
+		}
+		if err != nil {
+			return fmt.Errorf("secrets: failed to read master key file: %w", err)
+		}
+		list = string(data)
+	}
+
+	k, err := ParseKeys(list)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	keyring = k
+	mu.Unlock()
+	return nil
+}
+
+// createKeyFile writes a new master key to a file only the owner can read.
+func createKeyFile(path string) ([]byte, error) {
+	key, err := GenerateKey()
+	if err != nil {
+		return nil, err
+	}
+	data := []byte(key + "\n")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return nil, err
+	}
+	log.Printf("Generated master key file %s. Back it up: stored secrets can't be decrypted without it", path)
+	return data, nil
+}
+
+// Keys returns the loaded keyring, or nil before Load.
+func Keys() *Keyring {
+	mu.RLock()
+	defer mu.RUnlock()
+	return keyring
+}
+
+// Seal encrypts a value with the loaded keys, see Keyring.Seal.
+func Seal(plaintext, additional string) (string, error) {
+	k := Keys()
+	if k == nil {
+		return "", ErrNoKeys
+	}
+	return k.Seal(plaintext, additional)
+}
+
+// Open decrypts a value with the loaded keys, see Keyring.Open.
+func Open(value, additional string) (string, error) {
+	if !IsSealed(value) {
+		return value, nil
+	}
+	k := Keys()
+	if k == nil {
+		return "", ErrNoKeys
+	}
+	return k.Open(value, additional)
+}
+
+// Rewrap brings a value up to date with the loaded keys, see Keyring.Rewrap.
+func Rewrap(value, additional string) (string, bool, error) {
+	k := Keys()
+	if k == nil {
+		return value, false, ErrNoKeys
+	}
+	return k.Rewrap(value, additional)
+}
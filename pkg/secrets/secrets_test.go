@@ -0,0 +1,252 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func newKeyring(t *testing.T, keys ...string) *Keyring {
+	t.Helper()
+	k, err := ParseKeys(strings.Join(keys, ","))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func generateKey(t *testing.T) string {
+	t.Helper()
+	key, err := GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// sealV1 seals a value like versions before additional data were bound.
+func sealV1(t *testing.T, k *Keyring, plaintext string) string {
+	t.Helper()
+	sealed, err := k.Seal(plaintext, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return prefixV1 + strings.TrimPrefix(sealed, prefix)
+}
+
+func TestSealOpen(t *testing.T) {
+	k := newKeyring(t, generateKey(t))
+
+	sealed, err := k.Seal("bot-token", "telegram:1/token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsSealed(sealed) || strings.Contains(sealed, "bot-token") || SealedKeyID(sealed) != k.Primary() {
+		t.Fatalf("sealed value %q", sealed)
+	}
+	if again, _ := k.Seal("bot-token", "telegram:1/token"); again == sealed {
+		t.Error("sealing twice gave the same value")
+	}
+
+	got, err := k.Open(sealed, "telegram:1/token")
+	if err != nil || got != "bot-token" {
+		t.Errorf("Open() = %q, %v", got, err)
+	}
+	// A value copied to another channel or setting doesn't open there
+	for _, other := range []string{"telegram:2/token", "telegram:1/bot_secret", ""} {
+		if _, err := k.Open(sealed, other); err == nil {
+			t.Errorf("opened with additional data %q", other)
+		}
+	}
+
+	if got, err := k.Seal("", "x"); got != "" || err != nil {
+		t.Errorf("Seal(\"\") = %q, %v", got, err)
+	}
+	if got, err := k.Open("plain", "x"); got != "plain" || err != nil {
+		t.Errorf("Open(plaintext) = %q, %v", got, err)
+	}
+	if IsSealed("plain") || SealedKeyID("plain") != "" {
+		t.Error("plaintext reported as sealed")
+	}
+}
+
+func TestOpenRejectsBrokenValues(t *testing.T) {
+	k := newKeyring(t, generateKey(t))
+	sealed, err := k.Seal("bot-token", "ctx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(sealed, ":")
+	ciphertext, _ := base64.StdEncoding.DecodeString(parts[4])
+	ciphertext[len(ciphertext)-1] ^= 1
+	tampered := strings.Join(append(parts[:4:4], base64.StdEncoding.EncodeToString(ciphertext)), ":")
+
+	tests := []struct {
+		name      string
+		value     string
+		malformed bool
+	}{
+		{"missing parts", prefix + k.Primary() + ":abc", true},
+		{"invalid data key encoding", prefix + k.Primary() + ":!!:" + parts[4], true},
+		{"invalid ciphertext encoding", prefix + k.Primary() + ":" + parts[3] + ":!!", true},
+		{"data key shorter than a nonce", prefix + k.Primary() + ":AAAA:" + parts[4], true},
+		{"tampered ciphertext", tampered, false},
+		{"tampered key ID", prefix + "00000000:" + parts[3] + ":" + parts[4], false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := k.Open(tt.value, "ctx")
+			if err == nil {
+				t.Fatalf("opened as %q", got)
+			}
+			if errors.Is(err, ErrMalformed) != tt.malformed {
+				t.Errorf("got %v, malformed: %v", err, tt.malformed)
+			}
+		})
+	}
+}
+
+func TestRotation(t *testing.T) {
+	oldKey, newKey := generateKey(t), generateKey(t)
+	old := newKeyring(t, oldKey)
+	sealed, err := old.Seal("webhook-url", "webhook:1/url")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The new key is listed first and becomes the primary key
+	rotated := newKeyring(t, newKey, oldKey)
+	if rotated.Primary() == old.Primary() || strings.Join(rotated.IDs(), ",") != rotated.Primary()+","+old.Primary() {
+		t.Fatalf("keys %v, primary %s", rotated.IDs(), rotated.Primary())
+	}
+	if got, err := rotated.Open(sealed, "webhook:1/url"); err != nil || got != "webhook-url" {
+		t.Fatalf("old value: Open() = %q, %v", got, err)
+	}
+
+	rewrapped, changed, err := rotated.Rewrap(sealed, "webhook:1/url")
+	if err != nil || !changed {
+		t.Fatalf("Rewrap() changed %v, %v", changed, err)
+	}
+	if SealedKeyID(rewrapped) != rotated.Primary() {
+		t.Errorf("rewrapped under %s, want %s", SealedKeyID(rewrapped), rotated.Primary())
+	}
+	if again, changed, err := rotated.Rewrap(rewrapped, "webhook:1/url"); again != rewrapped || changed || err != nil {
+		t.Errorf("rewrapping again changed the value: %v, %v", changed, err)
+	}
+
+	// Once the old key is removed, only rewrapped values open
+	current := newKeyring(t, newKey)
+	if _, err := current.Open(sealed, "webhook:1/url"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("old value: got %v, want ErrUnknownKey", err)
+	}
+	if got, err := current.Open(rewrapped, "webhook:1/url"); err != nil || got != "webhook-url" {
+		t.Errorf("rewrapped value: Open() = %q, %v", got, err)
+	}
+	if _, _, err := current.Rewrap(sealed, "webhook:1/url"); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Rewrap() of an old value: got %v, want ErrUnknownKey", err)
+	}
+}
+
+func TestRewrapBindsOlderValues(t *testing.T) {
+	k := newKeyring(t, generateKey(t))
+
+	v1 := sealV1(t, k, "smtp-password")
+	if got, err := k.Open(v1, "email:3/password"); err != nil || got != "smtp-password" {
+		t.Fatalf("v1 value: Open() = %q, %v", got, err)
+	}
+	for _, value := range []string{v1, "smtp-password"} {
+		rewrapped, changed, err := k.Rewrap(value, "email:3/password")
+		if err != nil || !changed || !strings.HasPrefix(rewrapped, prefix) {
+			t.Fatalf("Rewrap(%.10s) = %q, %v, %v", value, rewrapped, changed, err)
+		}
+		if got, err := k.Open(rewrapped, "email:3/password"); err != nil || got != "smtp-password" {
+			t.Errorf("Open() = %q, %v", got, err)
+		}
+		if _, err := k.Open(rewrapped, "email:4/password"); err == nil {
+			t.Error("the rewrapped value opened for another channel")
+		}
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	a, b := generateKey(t), generateKey(t)
+	short := base64.StdEncoding.EncodeToString(make([]byte, 16))
+	tests := []struct {
+		name string
+		list string
+		keys int
+	}{
+		{"one", a, 1},
+		{"commas and newlines", a + ",\n" + b + "\n", 2},
+		{"comments and duplicates", "# rotated 2026-01\n" + b + "\n" + a + " " + b, 2},
+		{"empty", "\n# no key\n", 0},
+		{"invalid base64", a + ",not-a-key", 0},
+		{"wrong size", short, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			k, err := ParseKeys(tt.list)
+			if tt.keys == 0 {
+				if err == nil {
+					t.Errorf("parsed %v", k.IDs())
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(k.IDs()) != tt.keys {
+				t.Errorf("got %d keys, want %d", len(k.IDs()), tt.keys)
+			}
+		})
+	}
+}
+
+func TestLoadCreatesKeyFile(t *testing.T) {
+	t.Chdir(t.TempDir())
+	t.Setenv("ZENSTACK_MASTER_KEY", "")
+	t.Setenv("ZENSTACK_MASTER_KEY_FILE", "")
+	t.Cleanup(func() {
+		mu.Lock()
+		keyring = nil
+		mu.Unlock()
+	})
+
+	if err := Load(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(DefaultKeyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != 0o600 {
+		t.Errorf("key file mode %o, want 600", mode)
+	}
+	primary := Keys().Primary()
+
+	// The file is read on later starts
+	if err := Load(); err != nil {
+		t.Fatal(err)
+	}
+	if Keys().Primary() != primary {
+		t.Error("a new key was generated on the second start")
+	}
+	sealed, err := Seal("token", "ctx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Open(sealed, "ctx"); err != nil || got != "token" {
+		t.Errorf("Open() = %q, %v", got, err)
+	}
+
+	// A configured key file is never created
+	t.Setenv("ZENSTACK_MASTER_KEY_FILE", "missing.key")
+	if err := Load(); err == nil {
+		t.Error("loaded a missing key file")
+	}
+	if _, err := os.Stat("missing.key"); !os.IsNotExist(err) {
+		t.Error("created the configured key file")
+	}
+}
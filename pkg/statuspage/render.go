@@ -0,0 +1,300 @@
+package statuspage
+
+import (
+	"encoding/xml"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+// stateLabels are the human-readable labels shown for component states.
+var stateLabels = map[string]string{
+	StateOperational:   "Operational",
+	StateDegraded:      "Degraded Performance",
+	StatePartialOutage: "Partial Outage",
+	StateMajorOutage:   "Major Outage",
+	StateUnknown:       "No Data",
+}
+
+// overallLabels are the banner texts shown for the overall page state.
+var overallLabels = map[string]string{
+	StateOperational:   "All Systems Operational",
+	StateDegraded:      "Some Systems Degraded",
+	StatePartialOutage: "Partial System Outage",
+	StateMajorOutage:   "Major System Outage",
+	StateUnknown:       "Status Unknown",
+}
+
+var pageTemplate = template.Must(template.New("status").Funcs(template.FuncMap{
+	"stateLabel":   func(s string) string { return stateLabels[s] },
+	"overallLabel": func(s string) string { return overallLabels[s] },
+	"barClass": func(b DayBar) string {
+		switch {
+		case b.Uptime < 0:
+			return "bg-slate-700"
+		case b.Uptime >= 99.9:
+			return "bg-green-500"
+		case b.Uptime >= 95:
+			return "bg-yellow-500"
+		default:
+			return "bg-red-500"
+		}
+	},
+	"barTitle": func(b DayBar) string {
+		if b.Uptime < 0 {
+			return b.Date + ": no data"
+		}
+		return fmt.Sprintf("%s: %.2f%% uptime", b.Date, b.Uptime)
+	},
+	"stateClass": func(s string) string {
+		switch s {
+		case StateOperational:
+			return "text-green-400"
+		case StateDegraded:
+			return "text-yellow-400"
+		case StatePartialOutage, StateMajorOutage:
+			return "text-red-400"
+		}
+		return "text-slate-400"
+	},
+	"bannerClass": func(s string) string {
+		switch s {
+		case StateOperational:
+			return "bg-green-600"
+		case StateDegraded:
+			return "bg-yellow-600"
+		case StatePartialOutage, StateMajorOutage:
+			return "bg-red-600"
+		}
+		return "bg-slate-700"
+	},
+	"fmtTime": func(v interface{}) string {
+		switch t := v.(type) {
+		case time.Time:
+			return t.UTC().Format("Jan 2, 15:04 UTC")
+		case *time.Time:
+			if t != nil {
+				return t.UTC().Format("Jan 2, 15:04 UTC")
+			}
+		}
+		return ""
+	},
+	"title": func(s string) string {
+		if s == "" {
+			return s
+		}
+		return strings.ToUpper(s[:1]) + s[1:]
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+    <meta charset="UTF-8" />
+    <meta name="viewport" content="width=device-width, initial-scale=1.0" />
+    <title>{{.Title}} Status</title>
+    <script src="https://cdn.tailwindcss.com"></script>
+    <link rel="alternate" type="application/atom+xml" title="{{.Title}} incidents (Atom)" href="/status/{{.Slug}}/feed.atom" />
+    <link rel="alternate" type="application/rss+xml" title="{{.Title}} incidents (RSS)" href="/status/{{.Slug}}/feed.rss" />
+</head>
+<body class="min-h-screen bg-slate-950 text-slate-100">
+    <main class="max-w-4xl mx-auto px-4 py-10 space-y-8">
+        <header>
+            <h1 class="text-2xl font-semibold">{{.Title}}</h1>
+            {{if .Description}}<p class="text-slate-400 mt-1">{{.Description}}</p>{{end}}
+        </header>
+
+        <div class="rounded-lg px-5 py-4 text-lg font-medium {{bannerClass .State}}">{{overallLabel .State}}</div>
+
+        {{if .ActiveIncidents}}
+        <section class="space-y-4">
+            <h2 class="text-lg font-semibold">Active Incidents</h2>
+            {{range .ActiveIncidents}}
+            <article class="rounded-lg border border-red-800 bg-red-950/40 p-4">
+                <h3 class="font-medium">{{.Title}}</h3>
+                <p class="text-xs text-slate-400">Started {{fmtTime .StartedAt}} · Impact: {{.Impact}}</p>
+                <ul class="mt-3 space-y-2">
+                    {{range .Updates}}
+                    <li class="text-sm"><span class="font-semibold">{{title .Status}}</span> — {{.Message}} <span class="text-xs text-slate-500">{{fmtTime .CreatedAt}}</span></li>
+                    {{end}}
+                </ul>
+            </article>
+            {{end}}
+        </section>
+        {{end}}
+
+        <section class="rounded-lg border border-slate-800 divide-y divide-slate-800">
+            {{range .Components}}
+            <div class="p-4">
+                <div class="flex items-center justify-between">
+                    <span class="font-medium">{{.Name}}</span>
+                    <span class="text-sm {{stateClass .State}}">{{stateLabel .State}}</span>
+                </div>
+                <div class="flex mt-3 gap-px">
+                    {{range .Days}}<div class="h-8 flex-1 rounded-sm {{barClass .}}" title="{{barTitle .}}"></div>{{end}}
+                </div>
+                <div class="flex justify-between text-xs text-slate-500 mt-1">
+                    <span>90 days ago</span>
+                    <span>{{printf "%.2f" .UptimePercent}}% uptime</span>
+                    <span>Today</span>
+                </div>
+            </div>
+            {{else}}
+            <div class="p-4 text-slate-400">No components configured.</div>
+            {{end}}
+        </section>
+
+        {{if .PastIncidents}}
+        <section class="space-y-3">
+            <h2 class="text-lg font-semibold">Past Incidents</h2>
+            {{range .PastIncidents}}
+            <article class="border-b border-slate-800 pb-3">
+                <h3 class="font-medium">{{.Title}}</h3>
+                <p class="text-xs text-slate-400">{{fmtTime .StartedAt}}{{if .ResolvedAt}} – resolved {{fmtTime .ResolvedAt}}{{end}}</p>
+                {{range .Updates}}<p class="text-sm text-slate-300 mt-1"><span class="font-semibold">{{title .Status}}</span> — {{.Message}}</p>{{end}}
+            </article>
+            {{end}}
+        </section>
+        {{end}}
+
+        <footer class="text-xs text-slate-500 flex gap-4">
+            <span>Updated {{fmtTime .GeneratedAt}}</span>
+            <a class="hover:text-slate-300" href="/status/{{.Slug}}/summary.json">JSON</a>
+            <a class="hover:text-slate-300" href="/status/{{.Slug}}/feed.atom">Atom</a>
+            <a class="hover:text-slate-300" href="/status/{{.Slug}}/feed.rss">RSS</a>
+        </footer>
+    </main>
+</body>
+</html>`))
+
+// RenderHTML writes the status page as a standalone HTML document.
+func RenderHTML(w io.Writer, s *Summary) error {
+	return pageTemplate.Execute(w, s)
+}
+
+// feedIncidents returns active and past incidents, most recently updated first.
+func feedIncidents(s *Summary) []IncidentSummary {
+	all := make([]IncidentSummary, 0, len(s.ActiveIncidents)+len(s.PastIncidents))
+	all = append(all, s.ActiveIncidents...)
+	all = append(all, s.PastIncidents...)
+	return all
+}
+
+// incidentContent renders the public timeline of an incident as plain text.
+func incidentContent(inc IncidentSummary) string {
+	var b strings.Builder
+	for _, u := range inc.Updates {
+		fmt.Fprintf(&b, "[%s] %s: %s\n", u.CreatedAt.UTC().Format(time.RFC3339), u.Status, u.Message)
+	}
+	if b.Len() == 0 {
+		fmt.Fprintf(&b, "Status: %s", inc.Status)
+	}
+	return strings.TrimSpace(b.String())
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	XMLNS   string      `xml:"xmlns,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Link    []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID      string   `xml:"id"`
+	Title   string   `xml:"title"`
+	Updated string   `xml:"updated"`
+	Link    atomLink `xml:"link"`
+	Content string   `xml:"content"`
+}
+
+// RenderAtom writes the incidents of a status page as an Atom feed.
+// baseURL is the scheme and host the page is served from, e.g. https://status.example.com.
+func RenderAtom(w io.Writer, s *Summary, baseURL string) error {
+	pageURL := strings.TrimRight(baseURL, "/") + "/status/" + s.Slug
+	feed := atomFeed{
+		XMLNS:   "http://www.w3.org/2005/Atom",
+		ID:      pageURL,
+		Title:   s.Title + " incidents",
+		Updated: s.GeneratedAt.UTC().Format(time.RFC3339),
+		Link: []atomLink{
+			{Href: pageURL},
+			{Href: pageURL + "/feed.atom", Rel: "self"},
+		},
+	}
+	for _, inc := range feedIncidents(s) {
+		feed.Entries = append(feed.Entries, atomEntry{
+			ID:      fmt.Sprintf("%s#incident-%d", pageURL, inc.ID),
+			Title:   fmt.Sprintf("%s (%s)", inc.Title, inc.Status),
+			Updated: inc.UpdatedAt.UTC().Format(time.RFC3339),
+			Link:    atomLink{Href: pageURL},
+			Content: incidentContent(inc),
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(feed)
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string `xml:"title"`
+	Link        string `xml:"link"`
+	GUID        string `xml:"guid"`
+	PubDate     string `xml:"pubDate"`
+	Description string `xml:"description"`
+}
+
+// RenderRSS writes the incidents of a status page as an RSS 2.0 feed.
+func RenderRSS(w io.Writer, s *Summary, baseURL string) error {
+	pageURL := strings.TrimRight(baseURL, "/") + "/status/" + s.Slug
+	feed := rssFeed{
+		Version: "2.0",
+		Channel: rssChannel{
+			Title:         s.Title + " incidents",
+			Link:          pageURL,
+			Description:   "Incident history for " + s.Title,
+			LastBuildDate: s.GeneratedAt.UTC().Format(time.RFC1123Z),
+		},
+	}
+	for _, inc := range feedIncidents(s) {
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       fmt.Sprintf("%s (%s)", inc.Title, inc.Status),
+			Link:        pageURL,
+			GUID:        fmt.Sprintf("%s#incident-%d", pageURL, inc.ID),
+			PubDate:     inc.StartedAt.UTC().Format(time.RFC1123Z),
+			Description: incidentContent(inc),
+		})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(feed)
+}
@@ -0,0 +1,328 @@
+package statuspage
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"github.com/harveywai/zenstack/pkg/incident"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// UptimeDays is the number of days shown in the uptime bars of a status page.
+const UptimeDays = 90
+
+// Component states, ordered from best to worst.
+const (
+	StateOperational   = "operational"
+	StateDegraded      = "degraded"
+	StatePartialOutage = "partial_outage"
+	StateMajorOutage   = "major_outage"
+	StateUnknown       = "unknown"
+)
+
+// DayBar is the uptime of a component on a single day. Uptime is -1 when no checks ran that day.
+type DayBar struct {
+	Date   string  `json:"date"`
+	Uptime float64 `json:"uptime"`
+	Checks int     `json:"checks"`
+}
+
+// ComponentSummary is the public view of a status component.
+type ComponentSummary struct {
+	ID            uint     `json:"id"`
+	Name          string   `json:"name"`
+	Description   string   `json:"description,omitempty"`
+	State         string   `json:"state"`
+	UptimePercent float64  `json:"uptime_percent"`
+	Days          []DayBar `json:"days"`
+}
+
+// IncidentUpdateSummary is a public incident timeline entry.
+type IncidentUpdateSummary struct {
+	Status    string    `json:"status"`
+	Message   string    `json:"message"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// IncidentSummary is the public view of an incident. Only public updates are included.
+type IncidentSummary struct {
+	ID         uint                    `json:"id"`
+	Title      string                  `json:"title"`
+	Status     string                  `json:"status"`
+	Impact     string                  `json:"impact"`
+	StartedAt  time.Time               `json:"started_at"`
+	ResolvedAt *time.Time              `json:"resolved_at,omitempty"`
+	UpdatedAt  time.Time               `json:"updated_at"`
+	Updates    []IncidentUpdateSummary `json:"updates"`
+}
+
+// Summary is everything needed to render a status page, in HTML, JSON or as a feed.
+type Summary struct {
+	Slug            string             `json:"slug"`
+	Title           string             `json:"title"`
+	Description     string             `json:"description,omitempty"`
+	State           string             `json:"state"`
+	Components      []ComponentSummary `json:"components"`
+	ActiveIncidents []IncidentSummary  `json:"active_incidents"`
+	PastIncidents   []IncidentSummary  `json:"past_incidents"`
+	GeneratedAt     time.Time          `json:"generated_at"`
+}
+
+// Build assembles the public summary of a status page from domain state, daily uptime
+// rows and public incidents. It never exposes domains or incidents outside the page.
+func Build(page database.StatusPage) (*Summary, error) {
+	if database.DB == nil {
+		return nil, database.ErrDatabaseNotInitialized
+	}
+
+	var components []database.StatusComponent
+	if err := database.DB.Where("page_id = ?", page.ID).
+		Order("sort_order asc, id asc").
+		Find(&components).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	since := now.AddDate(0, 0, -(UptimeDays - 1))
+
+	summary := &Summary{
+		Slug:        page.Slug,
+		Title:       page.Title,
+		Description: page.Description,
+		GeneratedAt: now,
+	}
+
+	var pageDomainIDs []uint
+	for _, comp := range components {
+		ids := ParseDomainIDs(comp.DomainIDs)
+		pageDomainIDs = append(pageDomainIDs, ids...)
+
+		cs, err := buildComponent(comp, ids, since, now)
+		if err != nil {
+			return nil, err
+		}
+		summary.Components = append(summary.Components, cs)
+	}
+
+	active, past, err := loadIncidents(page.ID, pageDomainIDs, now)
+	if err != nil {
+		return nil, err
+	}
+	summary.ActiveIncidents = active
+	summary.PastIncidents = past
+	summary.State = overallState(summary.Components, active)
+
+	return summary, nil
+}
+
+// ParseDomainIDs parses a comma-separated list of domain IDs, skipping invalid entries.
+func ParseDomainIDs(s string) []uint {
+	var ids []uint
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(part, 10, 64)
+		if err != nil || id == 0 {
+			continue
+		}
+		ids = append(ids, uint(id))
+	}
+	return ids
+}
+
+// buildComponent derives the current state and daily uptime bars for one component.
+func buildComponent(comp database.StatusComponent, domainIDs []uint, since, now time.Time) (ComponentSummary, error) {
+	cs := ComponentSummary{
+		ID:          comp.ID,
+		Name:        comp.Name,
+		Description: comp.Description,
+		State:       StateUnknown,
+	}
+
+	var domains []database.MonitoredDomain
+	if len(domainIDs) > 0 {
+		if err := database.DB.Where("id IN ?", domainIDs).Find(&domains).Error; err != nil {
+			return cs, err
+		}
+	}
+	cs.State = componentState(domains)
+
+	var rows []database.DailyUptime
+	if len(domainIDs) > 0 {
+		if err := database.DB.Where("domain_id IN ? AND day >= ?", domainIDs, since.Format("2006-01-02")).
+			Find(&rows).Error; err != nil {
+			return cs, err
+		}
+	}
+
+	type counts struct{ total, success int }
+	byDay := make(map[string]counts)
+	for _, row := range rows {
+		c := byDay[row.Day]
+		c.total += row.TotalChecks
+		c.success += row.SuccessChecks
+		byDay[row.Day] = c
+	}
+
+	var total, success int
+	for d := since; !d.After(now); d = d.AddDate(0, 0, 1) {
+		day := d.Format("2006-01-02")
+		c := byDay[day]
+		bar := DayBar{Date: day, Uptime: -1, Checks: c.total}
+		if c.total > 0 {
+			bar.Uptime = float64(c.success) / float64(c.total) * 100.0
+		}
+		total += c.total
+		success += c.success
+		cs.Days = append(cs.Days, bar)
+	}
+
+	if total > 0 {
+		cs.UptimePercent = float64(success) / float64(total) * 100.0
+	} else {
+		cs.UptimePercent = 100.0
+	}
+
+	return cs, nil
+}
+
+// componentState maps the live state of a component's domains to a single state.
+func componentState(domains []database.MonitoredDomain) string {
+	if len(domains) == 0 {
+		return StateUnknown
+	}
+
+	down, degraded := 0, 0
+	for _, d := range domains {
+		if !d.IsLive {
+			down++
+			continue
+		}
+		if d.SSLStatus == "Expired" {
+			degraded++
+		}
+	}
+
+	switch {
+	case down == len(domains):
+		return StateMajorOutage
+	case down > 0:
+		return StatePartialOutage
+	case degraded > 0:
+		return StateDegraded
+	}
+	return StateOperational
+}
+
+// overallState is the worst component state, escalated by active incidents.
+func overallState(components []ComponentSummary, active []IncidentSummary) string {
+	rank := map[string]int{
+		StateUnknown:       0,
+		StateOperational:   1,
+		StateDegraded:      2,
+		StatePartialOutage: 3,
+		StateMajorOutage:   4,
+	}
+
+	state := StateOperational
+	for _, c := range components {
+		if rank[c.State] > rank[state] {
+			state = c.State
+		}
+	}
+	if len(active) > 0 && rank[state] < rank[StateDegraded] {
+		state = StateDegraded
+	}
+	return state
+}
+
+// loadIncidents returns the public incidents of a page: all unresolved ones and those
+// resolved within the uptime window.
+func loadIncidents(pageID uint, domainIDs []uint, now time.Time) ([]IncidentSummary, []IncidentSummary, error) {
+	query := database.DB.Preload("Updates", func(db *gorm.DB) *gorm.DB {
+		return db.Where("is_public = ?", true).Order("created_at desc")
+	}).Where("is_public = ?", true)
+
+	if len(domainIDs) > 0 {
+		query = query.Where("status_page_id = ? OR domain_id IN ?", pageID, domainIDs)
+	} else {
+		query = query.Where("status_page_id = ?", pageID)
+	}
+
+	cutoff := now.AddDate(0, 0, -UptimeDays)
+	query = query.Where("resolved_at IS NULL OR resolved_at >= ?", cutoff)
+
+	var incidents []database.Incident
+	if err := query.Order("started_at desc").Find(&incidents).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var active, past []IncidentSummary
+	for _, inc := range incidents {
+		is := IncidentSummary{
+			ID:         inc.ID,
+			Title:      inc.Title,
+			Status:     inc.Status,
+			Impact:     inc.Impact,
+			StartedAt:  inc.StartedAt,
+			ResolvedAt: inc.ResolvedAt,
+			UpdatedAt:  inc.UpdatedAt,
+			Updates:    []IncidentUpdateSummary{},
+		}
+		for _, u := range inc.Updates {
+			is.Updates = append(is.Updates, IncidentUpdateSummary{
+				Status:    u.Status,
+				Message:   u.Message,
+				CreatedAt: u.CreatedAt,
+			})
+		}
+
+		if inc.Status == incident.StatusResolved {
+			past = append(past, is)
+		} else {
+			active = append(active, is)
+		}
+	}
+
+	if active == nil {
+		active = []IncidentSummary{}
+	}
+	if past == nil {
+		past = []IncidentSummary{}
+	}
+	return active, past, nil
+}
+
+// RecordCheck adds a single health check result to the daily uptime rollup of a domain.
+func RecordCheck(db *gorm.DB, domainID uint, success bool, at time.Time) error {
+	if db == nil {
+		return database.ErrDatabaseNotInitialized
+	}
+
+	successInc := 0
+	if success {
+		successInc = 1
+	}
+
+	row := database.DailyUptime{
+		DomainID:      domainID,
+		Day:           at.UTC().Format("2006-01-02"),
+		TotalChecks:   1,
+		SuccessChecks: successInc,
+		UpdatedAt:     at,
+	}
+
+	return db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "domain_id"}, {Name: "day"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"total_checks":   gorm.Expr("total_checks + 1"),
+			"success_checks": gorm.Expr("success_checks + ?", successInc),
+			"updated_at":     at,
+		}),
+	}).Create(&row).Error
+}
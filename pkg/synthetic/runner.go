@@ -0,0 +1,340 @@
+package synthetic
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptrace"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxBodySize caps how much of each response is read for extraction and assertions.
+const maxBodySize = 1 << 20
+
+// StepResult holds the outcome and timings of one step, in milliseconds.
+type StepResult struct {
+	Index         int    `json:"index"`
+	Name          string `json:"name"`
+	StatusCode    int    `json:"status_code"`
+	Latency       int    `json:"latency"`
+	DNSLookup     int    `json:"dns_lookup"`
+	TCPConnection int    `json:"tcp_connection"`
+	TLSHandshake  int    `json:"tls_handshake"`
+	TTFB          int    `json:"ttfb"`
+	Success       bool   `json:"success"`
+	Error         string `json:"error,omitempty"`
+}
+
+// Result is the outcome of a scenario run. On failure FailedStep names the step that broke;
+// later steps are not executed.
+type Result struct {
+	Success         bool         `json:"success"`
+	FailedStepIndex int          `json:"failed_step_index"` // -1 when the run succeeded
+	FailedStep      string       `json:"failed_step,omitempty"`
+	Error           string       `json:"error,omitempty"`
+	Duration        int          `json:"duration"`
+	Steps           []StepResult `json:"steps"`
+}
+
+// Run executes the steps of a scenario in order. Cookies persist across steps and
+// extracted values are substituted into later URLs, headers, bodies and assertions
+// using {{name}} placeholders.
+func Run(ctx context.Context, sc *Scenario) Result {
+	start := time.Now()
+	result := Result{Success: true, FailedStepIndex: -1}
+
+	timeout := 10 * time.Second
+	if sc.TimeoutSeconds > 0 {
+		timeout = time.Duration(sc.TimeoutSeconds) * time.Second
+	}
+
+	jar, _ := cookiejar.New(nil)
+	client := &http.Client{Timeout: timeout, Jar: jar}
+
+	vars := make(map[string]string, len(sc.Variables))
+	for k, v := range sc.Variables {
+		vars[k] = v
+	}
+
+	for i, st := range sc.Steps {
+		sr := runStep(ctx, client, i, st, vars)
+		result.Steps = append(result.Steps, sr)
+		if !sr.Success {
+			result.Success = false
+			result.FailedStepIndex = i
+			result.FailedStep = st.Name
+			result.Error = sr.Error
+			break
+		}
+	}
+
+	result.Duration = int(time.Since(start).Milliseconds())
+	return result
+}
+
+var placeholderRe = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+// substitute replaces {{name}} placeholders with variable values, leaving unknown ones intact.
+func substitute(s string, vars map[string]string) string {
+	return placeholderRe.ReplaceAllStringFunc(s, func(m string) string {
+		key := placeholderRe.FindStringSubmatch(m)[1]
+		if v, ok := vars[key]; ok {
+			return v
+		}
+		return m
+	})
+}
+
+func runStep(ctx context.Context, client *http.Client, index int, st Step, vars map[string]string) StepResult {
+	sr := StepResult{Index: index, Name: st.Name}
+
+	var body io.Reader
+	if st.Body != "" {
+		body = strings.NewReader(substitute(st.Body, vars))
+	}
+	req, err := http.NewRequestWithContext(ctx, st.Method, substitute(st.URL, vars), body)
+	if err != nil {
+		sr.Error = fmt.Sprintf("invalid request: %v", err)
+		return sr
+	}
+	for k, v := range st.Headers {
+		req.Header.Set(k, substitute(v, vars))
+	}
+
+	var dnsStart, connectStart, tlsStart, connected time.Time
+	start := time.Now()
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			sr.DNSLookup = int(time.Since(dnsStart).Milliseconds())
+		},
+		ConnectStart: func(string, string) { connectStart = time.Now() },
+		ConnectDone: func(string, string, error) {
+			connected = time.Now()
+			sr.TCPConnection = int(connected.Sub(connectStart).Milliseconds())
+		},
+		TLSHandshakeStart: func() { tlsStart = time.Now() },
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			connected = time.Now()
+			sr.TLSHandshake = int(connected.Sub(tlsStart).Milliseconds())
+		},
+		GotFirstResponseByte: func() {
+			from := connected
+			if from.IsZero() {
+				from = start
+			}
+			sr.TTFB = int(time.Since(from).Milliseconds())
+		},
+	}
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		sr.Latency = int(time.Since(start).Milliseconds())
+		sr.Error = fmt.Sprintf("request failed: %v", err)
+		return sr
+	}
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	resp.Body.Close()
+	sr.Latency = int(time.Since(start).Milliseconds())
+	sr.StatusCode = resp.StatusCode
+	if err != nil {
+		sr.Error = fmt.Sprintf("failed to read response: %v", err)
+		return sr
+	}
+
+	// JSON is decoded lazily, only if an extraction or assertion needs it
+	var parsed interface{}
+	var parseErr error
+	parsedOnce := false
+	jsonBody := func() (interface{}, error) {
+		if !parsedOnce {
+			parsedOnce = true
+			parseErr = json.Unmarshal(respBody, &parsed)
+		}
+		return parsed, parseErr
+	}
+
+	for _, ex := range st.Extract {
+		value, err := extract(ex, resp, respBody, jsonBody)
+		if err != nil {
+			sr.Error = fmt.Sprintf("extract %s: %v", ex.Var, err)
+			return sr
+		}
+		vars[ex.Var] = value
+	}
+
+	for _, as := range st.Assert {
+		if err := check(as, vars, resp, respBody, sr.Latency, jsonBody); err != nil {
+			sr.Error = fmt.Sprintf("assert %s: %v", as.Type, err)
+			return sr
+		}
+	}
+
+	// Without explicit status assertions, anything below 400 counts as success
+	if !hasAssertion(st, AssertStatus) && resp.StatusCode >= 400 {
+		sr.Error = fmt.Sprintf("unexpected status code %d", resp.StatusCode)
+		return sr
+	}
+
+	sr.Success = true
+	return sr
+}
+
+func hasAssertion(st Step, typ string) bool {
+	for _, as := range st.Assert {
+		if as.Type == typ {
+			return true
+		}
+	}
+	return false
+}
+
+func extract(ex Extraction, resp *http.Response, body []byte, jsonBody func() (interface{}, error)) (string, error) {
+	switch ex.From {
+	case FromStatus:
+		return strconv.Itoa(resp.StatusCode), nil
+	case FromHeader:
+		v := resp.Header.Get(ex.Path)
+		if v == "" {
+			return "", fmt.Errorf("header %q not present", ex.Path)
+		}
+		return v, nil
+	case FromCookie:
+		for _, ck := range resp.Cookies() {
+			if ck.Name == ex.Path {
+				return ck.Value, nil
+			}
+		}
+		return "", fmt.Errorf("cookie %q not set", ex.Path)
+	case FromRegex:
+		re, err := regexp.Compile(ex.Path)
+		if err != nil {
+			return "", err
+		}
+		m := re.FindSubmatch(body)
+		if m == nil {
+			return "", fmt.Errorf("pattern %q did not match", ex.Path)
+		}
+		if len(m) > 1 {
+			return string(m[1]), nil
+		}
+		return string(m[0]), nil
+	case FromJSON:
+		doc, err := jsonBody()
+		if err != nil {
+			return "", fmt.Errorf("response is not JSON: %v", err)
+		}
+		v, ok := lookupPath(doc, ex.Path)
+		if !ok {
+			return "", fmt.Errorf("path %q not found", ex.Path)
+		}
+		return stringify(v), nil
+	}
+	return "", fmt.Errorf("unknown source %q", ex.From)
+}
+
+func check(as Assertion, vars map[string]string, resp *http.Response, body []byte, latency int, jsonBody func() (interface{}, error)) error {
+	want := substitute(as.Value, vars)
+
+	switch as.Type {
+	case AssertStatus:
+		got := strconv.Itoa(resp.StatusCode)
+		if len(want) == 3 && strings.HasSuffix(strings.ToLower(want), "xx") {
+			if got[0] != want[0] {
+				return fmt.Errorf("expected status %s, got %s", want, got)
+			}
+			return nil
+		}
+		if got != want {
+			return fmt.Errorf("expected status %s, got %s", want, got)
+		}
+	case AssertBodyContains:
+		if !strings.Contains(string(body), want) {
+			return fmt.Errorf("body does not contain %q", want)
+		}
+	case AssertBodyNotContains:
+		if strings.Contains(string(body), want) {
+			return fmt.Errorf("body contains %q", want)
+		}
+	case AssertHeader:
+		got := resp.Header.Get(as.Path)
+		if got == "" {
+			return fmt.Errorf("header %q not present", as.Path)
+		}
+		if want != "" && !strings.Contains(got, want) {
+			return fmt.Errorf("header %q is %q, expected it to contain %q", as.Path, got, want)
+		}
+	case AssertJSON:
+		doc, err := jsonBody()
+		if err != nil {
+			return fmt.Errorf("response is not JSON: %v", err)
+		}
+		v, ok := lookupPath(doc, as.Path)
+		if !ok {
+			return fmt.Errorf("path %q not found", as.Path)
+		}
+		if want != "" && stringify(v) != want {
+			return fmt.Errorf("path %q is %q, expected %q", as.Path, stringify(v), want)
+		}
+	case AssertMaxLatency:
+		max, err := strconv.Atoi(want)
+		if err != nil {
+			return fmt.Errorf("invalid latency %q", want)
+		}
+		if latency > max {
+			return fmt.Errorf("latency %dms exceeds %dms", latency, max)
+		}
+	default:
+		return fmt.Errorf("unknown assertion type")
+	}
+	return nil
+}
+
+// lookupPath walks a decoded JSON document along a dotted path. Numeric
+// segments index into arrays, e.g. "items.0.id".
+func lookupPath(doc interface{}, path string) (interface{}, bool) {
+	cur := doc
+	for _, seg := range strings.Split(path, ".") {
+		switch node := cur.(type) {
+		case map[string]interface{}:
+			v, ok := node[seg]
+			if !ok {
+				return nil, false
+			}
+			cur = v
+		case []interface{}:
+			idx, err := strconv.Atoi(seg)
+			if err != nil || idx < 0 || idx >= len(node) {
+				return nil, false
+			}
+			cur = node[idx]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// stringify renders a JSON value for use as a variable or comparison.
+func stringify(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(t, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(t)
+	}
+	b, _ := json.Marshal(v)
+	return string(b)
+}
@@ -0,0 +1,242 @@
+package synthetic
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSubstitute(t *testing.T) {
+	vars := map[string]string{"user": "probe", "token": "abc", "empty": ""}
+	tests := []struct{ in, want string }{
+		{"{{user}}", "probe"},
+		{"Bearer {{ token }}", "Bearer abc"},
+		{"{{user}}:{{token}}/{{user}}", "probe:abc/probe"},
+		{"[{{empty}}]", "[]"},
+		{"{{missing}} {{user}}", "{{missing}} probe"},
+		{"{{ .user }} {user} {{user-name}}", "{{ .user }} {user} {{user-name}}"},
+		{"no placeholders", "no placeholders"},
+	}
+	for _, tt := range tests {
+		if got := substitute(tt.in, vars); got != tt.want {
+			t.Errorf("substitute(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLookupPath(t *testing.T) {
+	var doc interface{}
+	if err := json.Unmarshal([]byte(`{"data":{"token":"abc","count":3,"ratio":0.5,"ok":true,"none":null,
+		"items":[{"id":7},{"id":8,"tags":["a","b"]}],"user":{"name":"probe"}}}`), &doc); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path  string
+		want  string
+		found bool
+	}{
+		{"data.token", "abc", true},
+		{"data.count", "3", true},
+		{"data.ratio", "0.5", true},
+		{"data.ok", "true", true},
+		{"data.none", "", true},
+		{"data.items.0.id", "7", true},
+		{"data.items.1.tags.1", "b", true},
+		{"data.user", `{"name":"probe"}`, true},
+		{"data.items.1.tags", `["a","b"]`, true},
+		{"data.missing", "", false},
+		{"data.items.2.id", "", false},
+		{"data.items.-1", "", false},
+		{"data.items.first", "", false},
+		{"data.token.length", "", false},
+	}
+	for _, tt := range tests {
+		v, ok := lookupPath(doc, tt.path)
+		if ok != tt.found || (ok && stringify(v) != tt.want) {
+			t.Errorf("lookupPath(%s) = %q, %v; want %q, %v", tt.path, stringify(v), ok, tt.want, tt.found)
+		}
+	}
+}
+
+// testResponse records a response with a body, headers and cookies.
+func testResponse(status int, body string, header map[string]string, cookies ...*http.Cookie) (*http.Response, []byte, func() (interface{}, error)) {
+	rec := httptest.NewRecorder()
+	for k, v := range header {
+		rec.Header().Set(k, v)
+	}
+	for _, c := range cookies {
+		http.SetCookie(rec, c)
+	}
+	rec.WriteHeader(status)
+	rec.WriteString(body)
+	jsonBody := func() (interface{}, error) {
+		var doc interface{}
+		err := json.Unmarshal([]byte(body), &doc)
+		return doc, err
+	}
+	return rec.Result(), []byte(body), jsonBody
+}
+
+func TestExtract(t *testing.T) {
+	resp, body, jsonBody := testResponse(201, `{"data":{"token":"abc"}} order #4711`,
+		map[string]string{"X-Request-Id": "req-1"}, &http.Cookie{Name: "session", Value: "s3cr3t"})
+	_, htmlBody, htmlJSON := testResponse(200, `<input name="csrf" value="tok-9">`, nil)
+
+	tests := []struct {
+		ex    Extraction
+		want  string
+		error bool
+	}{
+		{ex: Extraction{From: FromStatus}, want: "201"},
+		{ex: Extraction{From: FromHeader, Path: "x-request-id"}, want: "req-1"},
+		{ex: Extraction{From: FromHeader, Path: "X-Missing"}, error: true},
+		{ex: Extraction{From: FromCookie, Path: "session"}, want: "s3cr3t"},
+		{ex: Extraction{From: FromCookie, Path: "other"}, error: true},
+		{ex: Extraction{From: FromRegex, Path: `#(\d+)`}, want: "4711"},
+		{ex: Extraction{From: FromRegex, Path: `order #\d+`}, want: "order #4711"},
+		{ex: Extraction{From: FromRegex, Path: `invoice`}, error: true},
+		{ex: Extraction{From: FromRegex, Path: `(`}, error: true},
+		{ex: Extraction{From: FromJSON, Path: "data.token"}, error: true}, // The body isn't only JSON
+		{ex: Extraction{From: "xml", Path: "a"}, error: true},
+	}
+	for _, tt := range tests {
+		got, err := extract(tt.ex, resp, body, jsonBody)
+		if (err != nil) != tt.error || got != tt.want {
+			t.Errorf("extract(%s %s) = %q, %v", tt.ex.From, tt.ex.Path, got, err)
+		}
+	}
+
+	if got, err := extract(Extraction{From: FromRegex, Path: `value="([^"]+)"`}, resp, htmlBody, htmlJSON); got != "tok-9" || err != nil {
+		t.Errorf("regex on HTML = %q, %v", got, err)
+	}
+	resp, body, jsonBody = testResponse(200, `{"data":{"token":"abc"}}`, nil)
+	if got, err := extract(Extraction{From: FromJSON, Path: "data.token"}, resp, body, jsonBody); got != "abc" || err != nil {
+		t.Errorf("json = %q, %v", got, err)
+	}
+	if _, err := extract(Extraction{From: FromJSON, Path: "data.user"}, resp, body, jsonBody); err == nil {
+		t.Error("extracted a missing path")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	vars := map[string]string{"user": "probe", "code": "200"}
+	resp, body, jsonBody := testResponse(200, `{"user":{"name":"probe","id":7},"items":[]}`,
+		map[string]string{"Content-Type": "application/json; charset=utf-8"})
+	_, _, notJSON := testResponse(200, "<html>", nil)
+
+	tests := []struct {
+		as      Assertion
+		latency int
+		jsonDoc func() (interface{}, error)
+		pass    bool
+	}{
+		{as: Assertion{Type: AssertStatus, Value: "200"}, pass: true},
+		{as: Assertion{Type: AssertStatus, Value: "{{code}}"}, pass: true},
+		{as: Assertion{Type: AssertStatus, Value: "2xx"}, pass: true},
+		{as: Assertion{Type: AssertStatus, Value: "2XX"}, pass: true},
+		{as: Assertion{Type: AssertStatus, Value: "3xx"}},
+		{as: Assertion{Type: AssertStatus, Value: "201"}},
+		{as: Assertion{Type: AssertBodyContains, Value: `"name":"{{user}}"`}, pass: true},
+		{as: Assertion{Type: AssertBodyContains, Value: "error"}},
+		{as: Assertion{Type: AssertBodyNotContains, Value: "error"}, pass: true},
+		{as: Assertion{Type: AssertBodyNotContains, Value: "{{user}}"}},
+		{as: Assertion{Type: AssertHeader, Path: "content-type", Value: "application/json"}, pass: true},
+		{as: Assertion{Type: AssertHeader, Path: "Content-Type"}, pass: true},
+		{as: Assertion{Type: AssertHeader, Path: "Content-Type", Value: "text/html"}},
+		{as: Assertion{Type: AssertHeader, Path: "X-Missing"}},
+		{as: Assertion{Type: AssertJSON, Path: "user.name", Value: "{{user}}"}, pass: true},
+		{as: Assertion{Type: AssertJSON, Path: "user.id", Value: "7"}, pass: true},
+		{as: Assertion{Type: AssertJSON, Path: "items"}, pass: true},
+		{as: Assertion{Type: AssertJSON, Path: "user.id", Value: "8"}},
+		{as: Assertion{Type: AssertJSON, Path: "user.email"}},
+		{as: Assertion{Type: AssertJSON, Path: "user.name"}, jsonDoc: notJSON},
+		{as: Assertion{Type: AssertMaxLatency, Value: "500"}, latency: 500, pass: true},
+		{as: Assertion{Type: AssertMaxLatency, Value: "500"}, latency: 501},
+		{as: Assertion{Type: AssertMaxLatency, Value: "fast"}},
+		{as: Assertion{Type: "equals", Value: "x"}},
+	}
+	for _, tt := range tests {
+		doc := jsonBody
+		if tt.jsonDoc != nil {
+			doc = tt.jsonDoc
+		}
+		err := check(tt.as, vars, resp, body, tt.latency, doc)
+		if (err == nil) != tt.pass {
+			t.Errorf("check(%s %s %q) = %v, want pass %v", tt.as.Type, tt.as.Path, tt.as.Value, err, tt.pass)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			var creds struct{ Email string }
+			json.NewDecoder(r.Body).Decode(&creds)
+			if r.Method != http.MethodPost || creds.Email != "probe@example.com" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s1", Path: "/"})
+			fmt.Fprint(w, `{"data":{"token":"abc","id":7}}`)
+		case "/users/7":
+			if c, err := r.Cookie("session"); err != nil || c.Value != "s1" || r.Header.Get("Authorization") != "Bearer abc" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			fmt.Fprint(w, `{"email":"probe@example.com"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	def := strings.ReplaceAll(`
+variables: {user: probe@example.com}
+steps:
+  - name: login
+    method: POST
+    url: URL/login
+    body: '{"email":"{{user}}"}'
+    extract:
+      - {var: token, from: json, path: data.token}
+      - {var: id, from: json, path: data.id}
+  - name: profile
+    url: URL/users/{{id}}
+    headers: {Authorization: "Bearer {{token}}"}
+    assert:
+      - {type: json, path: email, value: "{{user}}"}
+  - name: missing
+    url: URL/missing
+  - name: after the error
+    url: URL/users/{{id}}
+    headers: {Authorization: "Bearer {{token}}"}
+`, "URL", srv.URL)
+	sc, err := Parse([]byte(def))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := Run(context.Background(), sc)
+	if res.Success || res.FailedStepIndex != 2 || res.FailedStep != "missing" || res.Error != "unexpected status code 404" {
+		t.Fatalf("Run() = %+v", res)
+	}
+	if len(res.Steps) != 3 || !res.Steps[0].Success || !res.Steps[1].Success || res.Steps[1].StatusCode != 200 {
+		t.Errorf("steps %+v", res.Steps)
+	}
+
+	// A status assertion accepts error codes
+	sc.Steps[2].Assert = []Assertion{{Type: AssertStatus, Value: "4xx"}}
+	if res := Run(context.Background(), sc); !res.Success || res.FailedStepIndex != -1 || len(res.Steps) != 4 {
+		t.Errorf("Run() with a status assertion = %+v", res)
+	}
+
+	// Variables don't leak from one run into the scenario
+	if _, ok := sc.Variables["token"]; ok {
+		t.Error("extracted variables were stored in the scenario")
+	}
+}
@@ -0,0 +1,152 @@
+package synthetic
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Scenario is a scripted sequence of HTTP requests that share variables and cookies.
+//
+// Example (YAML):
+//
+//	name: login
+//	variables:
+//	  user: probe@example.com
+//	steps:
+//	  - name: login
+//	    method: POST
+//	    url: https://app.example.com/api/login
+//	    headers: {Content-Type: application/json}
+//	    body: '{"email":"{{user}}","password":"secret"}'
+//	    extract:
+//	      - {var: token, from: json, path: data.token}
+//	    assert:
+//	      - {type: status, value: "200"}
+//	  - name: profile
+//	    url: https://app.example.com/api/me
+//	    headers: {Authorization: "Bearer {{token}}"}
+//	    assert:
+//	      - {type: json, path: email, value: "{{user}}"}
+type Scenario struct {
+	Name           string            `json:"name"`
+	Variables      map[string]string `json:"variables,omitempty"`
+	TimeoutSeconds int               `json:"timeout_seconds,omitempty"` // Per-step timeout, default 10s
+	Steps          []Step            `json:"steps"`
+}
+
+// Step is a single HTTP request of a scenario.
+type Step struct {
+	Name    string            `json:"name"`
+	Method  string            `json:"method,omitempty"` // Defaults to GET
+	URL     string            `json:"url"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+	Extract []Extraction      `json:"extract,omitempty"`
+	Assert  []Assertion       `json:"assert,omitempty"`
+}
+
+// Extraction sources.
+const (
+	FromJSON   = "json"   // Dotted path into a JSON body, e.g. data.items.0.id
+	FromHeader = "header" // Response header name
+	FromCookie = "cookie" // Cookie name set by the response
+	FromRegex  = "regex"  // Regular expression on the body; first capture group (or whole match)
+	FromStatus = "status" // Response status code
+)
+
+// Extraction stores a value from a response in a variable for later steps.
+type Extraction struct {
+	Var  string `json:"var"`
+	From string `json:"from"`
+	Path string `json:"path,omitempty"`
+}
+
+// Assertion types.
+const (
+	AssertStatus          = "status"            // Status code equals value; "2xx" style classes are accepted
+	AssertBodyContains    = "body_contains"     // Body contains value
+	AssertBodyNotContains = "body_not_contains" // Body does not contain value
+	AssertJSON            = "json"              // JSON path equals value, or exists when value is empty
+	AssertHeader          = "header"            // Header (path) contains value, or exists when value is empty
+	AssertMaxLatency      = "max_latency_ms"    // Total step latency is at most value milliseconds
+)
+
+// Assertion is a check applied to a step's response. Values may reference variables.
+type Assertion struct {
+	Type  string `json:"type"`
+	Path  string `json:"path,omitempty"`
+	Value string `json:"value,omitempty"`
+}
+
+// Parse decodes a scenario definition written in YAML or JSON and validates it.
+func Parse(definition []byte) (*Scenario, error) {
+	// YAML is a superset of JSON, so converting first handles both formats
+	data, err := yaml.YAMLToJSON(definition)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scenario definition: %w", err)
+	}
+
+	var sc Scenario
+	if err := json.Unmarshal(data, &sc); err != nil {
+		return nil, fmt.Errorf("invalid scenario definition: %w", err)
+	}
+	if err := sc.Validate(); err != nil {
+		return nil, err
+	}
+	return &sc, nil
+}
+
+// Validate checks that a scenario is complete and uses known extraction and assertion types.
+func (sc *Scenario) Validate() error {
+	if len(sc.Steps) == 0 {
+		return fmt.Errorf("scenario must have at least one step")
+	}
+
+	for i := range sc.Steps {
+		st := &sc.Steps[i]
+		if st.Name == "" {
+			st.Name = fmt.Sprintf("step %d", i+1)
+		}
+		if st.URL == "" {
+			return fmt.Errorf("step %q: url is required", st.Name)
+		}
+		st.Method = strings.ToUpper(st.Method)
+		if st.Method == "" {
+			st.Method = "GET"
+		}
+
+		for _, ex := range st.Extract {
+			if ex.Var == "" {
+				return fmt.Errorf("step %q: extract requires var", st.Name)
+			}
+			switch ex.From {
+			case FromJSON, FromHeader, FromCookie, FromRegex:
+				if ex.Path == "" {
+					return fmt.Errorf("step %q: extract %q requires path", st.Name, ex.Var)
+				}
+			case FromStatus:
+			default:
+				return fmt.Errorf("step %q: unknown extract source %q", st.Name, ex.From)
+			}
+		}
+
+		for _, as := range st.Assert {
+			switch as.Type {
+			case AssertStatus, AssertBodyContains, AssertBodyNotContains, AssertMaxLatency:
+				if as.Value == "" {
+					return fmt.Errorf("step %q: assertion %q requires value", st.Name, as.Type)
+				}
+			case AssertJSON, AssertHeader:
+				if as.Path == "" {
+					return fmt.Errorf("step %q: assertion %q requires path", st.Name, as.Type)
+				}
+			default:
+				return fmt.Errorf("step %q: unknown assertion type %q", st.Name, as.Type)
+			}
+		}
+	}
+	return nil
+}
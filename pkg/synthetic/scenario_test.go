@@ -0,0 +1,100 @@
+package synthetic
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	yamlDef := `
+name: login
+variables:
+  user: probe@example.com
+timeout_seconds: 5
+steps:
+  - name: login
+    method: post
+    url: https://app.example.com/api/login
+    headers: {Content-Type: application/json}
+    body: '{"email":"{{user}}"}'
+    extract:
+      - {var: token, from: json, path: data.token}
+      - {var: code, from: status}
+    assert:
+      - {type: status, value: "200"}
+  - url: https://app.example.com/api/me
+    assert:
+      - {type: json, path: email}
+`
+	jsonDef := `{"name":"login","variables":{"user":"probe@example.com"},"timeout_seconds":5,"steps":[
+		{"name":"login","method":"post","url":"https://app.example.com/api/login","headers":{"Content-Type":"application/json"},
+		 "body":"{\"email\":\"{{user}}\"}","extract":[{"var":"token","from":"json","path":"data.token"},{"var":"code","from":"status"}],
+		 "assert":[{"type":"status","value":"200"}]},
+		{"url":"https://app.example.com/api/me","assert":[{"type":"json","path":"email"}]}]}`
+
+	want := &Scenario{
+		Name:           "login",
+		Variables:      map[string]string{"user": "probe@example.com"},
+		TimeoutSeconds: 5,
+		Steps: []Step{
+			{
+				Name:    "login",
+				Method:  "POST",
+				URL:     "https://app.example.com/api/login",
+				Headers: map[string]string{"Content-Type": "application/json"},
+				Body:    `{"email":"{{user}}"}`,
+				Extract: []Extraction{{Var: "token", From: FromJSON, Path: "data.token"}, {Var: "code", From: FromStatus}},
+				Assert:  []Assertion{{Type: AssertStatus, Value: "200"}},
+			},
+			// Steps get a name and the GET method by default
+			{Name: "step 2", Method: "GET", URL: "https://app.example.com/api/me", Assert: []Assertion{{Type: AssertJSON, Path: "email"}}},
+		},
+	}
+	for name, def := range map[string]string{"yaml": yamlDef, "json": jsonDef} {
+		sc, err := Parse([]byte(def))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !reflect.DeepEqual(sc, want) {
+			t.Errorf("%s: got %+v\nwant %+v", name, sc, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		def  string
+		err  string
+	}{
+		{"not YAML", "steps: [", "invalid scenario definition"},
+		{"wrong types", "steps: yes", "invalid scenario definition"},
+		{"no steps", "name: empty", "at least one step"},
+		{"no url", "steps: [{name: home}]", `step "home": url is required`},
+		{"extract without var", "steps: [{url: u, extract: [{from: status}]}]", `step "step 1": extract requires var`},
+		{"extract without path", "steps: [{url: u, extract: [{var: v, from: header}]}]", `extract "v" requires path`},
+		{"unknown extract source", "steps: [{url: u, extract: [{var: v, from: xml, path: p}]}]", `unknown extract source "xml"`},
+		{"assertion without value", "steps: [{url: u, assert: [{type: body_contains}]}]", `assertion "body_contains" requires value`},
+		{"latency without value", "steps: [{url: u, assert: [{type: max_latency_ms}]}]", `assertion "max_latency_ms" requires value`},
+		{"assertion without path", "steps: [{url: u, assert: [{type: header, value: x}]}]", `assertion "header" requires path`},
+		{"unknown assertion", "steps: [{url: u, assert: [{type: equals, value: x}]}]", `unknown assertion type "equals"`},
+		{"error names the step", "steps: [{url: u}, {name: second}]", `step "second": url is required`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse([]byte(tt.def))
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("got %v, want an error containing %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestValidateInterval(t *testing.T) {
+	for seconds, valid := range map[int]bool{-30: false, 0: false, 29: false, 30: true, 300: true} {
+		if err := ValidateInterval(seconds); (err == nil) != valid {
+			t.Errorf("ValidateInterval(%d) = %v", seconds, err)
+		}
+	}
+}
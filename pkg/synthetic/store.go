@@ -0,0 +1,144 @@
+package synthetic
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"gorm.io/gorm"
+)
+
+// DefaultInterval is used for checks that don't set an interval.
+const DefaultInterval = 5 * time.Minute
+
+// MinInterval is the shortest interval a check may run at.
+const MinInterval = 30 * time.Second
+
+// ValidateInterval checks the interval of a check, in seconds.
+func ValidateInterval(seconds int) error {
+	if time.Duration(seconds)*time.Second < MinInterval {
+		return fmt.Errorf("interval_seconds must be at least %d", int(MinInterval.Seconds()))
+	}
+	return nil
+}
+
+// DueChecks returns the active checks whose interval has elapsed since their last run.
+func DueChecks(now time.Time) ([]database.SyntheticCheck, error) {
+	if database.DB == nil {
+		return nil, database.ErrDatabaseNotInitialized
+	}
+
+	var checks []database.SyntheticCheck
+	if err := database.DB.Where("is_active = ?", true).Find(&checks).Error; err != nil {
+		return nil, err
+	}
+
+	var due []database.SyntheticCheck
+	for _, chk := range checks {
+		interval := time.Duration(chk.IntervalSeconds) * time.Second
+		if interval <= 0 {
+			interval = DefaultInterval
+		}
+		if chk.LastRunAt.IsZero() || now.Sub(chk.LastRunAt) >= interval {
+			due = append(due, chk)
+		}
+	}
+	return due, nil
+}
+
+// Probe runs a stored check without saving anything and returns the unsaved run with
+// its per-step results.
+func Probe(ctx context.Context, chk database.SyntheticCheck) *database.SyntheticRun {
+	sc, err := Parse([]byte(chk.Definition))
+	var result Result
+	if err != nil {
+		result = Result{FailedStepIndex: -1, Error: err.Error()}
+	} else {
+		result = Run(ctx, sc)
+	}
+
+	now := time.Now()
+	run := &database.SyntheticRun{
+		CheckID:         chk.ID,
+		Success:         result.Success,
+		FailedStepIndex: result.FailedStepIndex,
+		FailedStep:      result.FailedStep,
+		Error:           result.Error,
+		Duration:        result.Duration,
+		CreatedAt:       now,
+	}
+	for _, sr := range result.Steps {
+		run.Steps = append(run.Steps, database.SyntheticStepResult{
+			CheckID:       chk.ID,
+			StepIndex:     sr.Index,
+			StepName:      sr.Name,
+			StatusCode:    sr.StatusCode,
+			Latency:       sr.Latency,
+			DNSLookup:     sr.DNSLookup,
+			TCPConnection: sr.TCPConnection,
+			TLSHandshake:  sr.TLSHandshake,
+			TTFB:          sr.TTFB,
+			Success:       sr.Success,
+			Error:         sr.Error,
+			CreatedAt:     now,
+		})
+	}
+	return run
+}
+
+// Execute runs a stored check and persists the run with its per-step results. The
+// previous success state is returned so callers can detect transitions; the check's
+// last-run state is updated by SaveState.
+func Execute(ctx context.Context, chk database.SyntheticCheck) (*database.SyntheticRun, bool, error) {
+	if database.DB == nil {
+		return nil, false, database.ErrDatabaseNotInitialized
+	}
+
+	wasSuccess := chk.LastSuccess || chk.LastRunAt.IsZero()
+	run := Probe(ctx, chk)
+	steps := run.Steps
+	run.Steps = nil
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(run).Error; err != nil {
+			return err
+		}
+		for _, step := range steps {
+			step.RunID = run.ID
+			if err := tx.Create(&step).Error; err != nil {
+				return err
+			}
+			run.Steps = append(run.Steps, step)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, wasSuccess, err
+	}
+	return run, wasSuccess, nil
+}
+
+// SaveState records a run as the last run of its check.
+func SaveState(tx *gorm.DB, run *database.SyntheticRun) error {
+	return tx.Model(&database.SyntheticCheck{}).Where("id = ?", run.CheckID).Updates(map[string]interface{}{
+		"last_run_at":      run.CreatedAt,
+		"last_success":     run.Success,
+		"last_failed_step": run.FailedStep,
+		"last_error":       run.Error,
+	}).Error
+}
+
+// Cleanup removes runs and step results older than the retention period.
+func Cleanup(retention time.Duration) (int64, error) {
+	if database.DB == nil {
+		return 0, database.ErrDatabaseNotInitialized
+	}
+
+	cutoff := time.Now().Add(-retention)
+	if err := database.DB.Where("created_at < ?", cutoff).Delete(&database.SyntheticStepResult{}).Error; err != nil {
+		return 0, err
+	}
+	res := database.DB.Where("created_at < ?", cutoff).Delete(&database.SyntheticRun{})
+	return res.RowsAffected, res.Error
+}
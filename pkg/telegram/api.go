@@ -0,0 +1,305 @@
+// Package telegram runs the bot commands of Telegram channels, receiving updates
+// either by long polling or through a webhook.
+package telegram
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"github.com/harveywai/zenstack/pkg/notify"
+)
+
+// Bot modes of a Telegram channel. An empty mode only sends notifications.
+const (
+	ModePolling = "polling"
+	ModeWebhook = "webhook"
+)
+
+// pollTimeout is how long a getUpdates request waits for updates, in seconds.
+const pollTimeout = 25
+
+// ErrUnauthorized is returned for webhook updates without the secret of the channel.
+var ErrUnauthorized = errors.New("invalid webhook secret")
+
+var client = &http.Client{Timeout: (pollTimeout + 10) * time.Second}
+
+// Update is an incoming update of the Bot API. Only messages are handled.
+type Update struct {
+	UpdateID int64    `json:"update_id"`
+	Message  *Message `json:"message"`
+}
+
+// Message is an incoming message.
+type Message struct {
+	Text string `json:"text"`
+	From *struct {
+		ID       int64  `json:"id"`
+		Username string `json:"username"`
+	} `json:"from"`
+	Chat struct {
+		ID int64 `json:"id"`
+	} `json:"chat"`
+}
+
+// call invokes a Bot API method and decodes its result into result, if not nil.
+func call(ctx context.Context, token, method string, params interface{}, result interface{}) error {
+	body, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s request: %w", method, err)
+	}
+	endpoint := fmt.Sprintf("%s/bot%s/%s", notify.TelegramAPIURL(), token, method)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// The error contains the URL, and with it the bot token
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("%s failed: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	var reply struct {
+		OK          bool            `json:"ok"`
+		Result      json.RawMessage `json:"result"`
+		ErrorCode   int             `json:"error_code"`
+		Description string          `json:"description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&reply); err != nil {
+		return fmt.Errorf("%s returned status code %d", method, resp.StatusCode)
+	}
+	if !reply.OK {
+		return fmt.Errorf("%s failed: %s (code: %d)", method, reply.Description, reply.ErrorCode)
+	}
+	if result != nil {
+		return json.Unmarshal(reply.Result, result)
+	}
+	return nil
+}
+
+// reply sends a command response to a chat.
+func reply(ctx context.Context, token string, chatID int64, text string) error {
+	return call(ctx, token, "sendMessage", map[string]interface{}{
+		"chat_id":                  chatID,
+		"text":                     text,
+		"disable_web_page_preview": true,
+	}, nil)
+}
+
+// Configure switches the bot mode of a Telegram channel. Webhook mode registers the
+// webhook endpoint of the channel with Telegram under a new secret; the other modes
+// remove the webhook, which Telegram requires before polling.
+func Configure(ctx context.Context, ch *database.Channel, mode string) error {
+	if database.DB == nil {
+		return database.ErrDatabaseNotInitialized
+	}
+	settings, err := botSettings(*ch)
+	if err != nil {
+		return err
+	}
+
+	switch mode {
+	case ModeWebhook:
+		secret, err := newSecret()
+		if err != nil {
+			return err
+		}
+		err = call(ctx, settings.Token, "setWebhook", map[string]interface{}{
+			"url":             WebhookURL(ch.ID),
+			"secret_token":    secret,
+			"allowed_updates": []string{"message"},
+		}, nil)
+		if err != nil {
+			return err
+		}
+		settings.BotSecret = secret
+	case ModePolling, "":
+		if err := call(ctx, settings.Token, "deleteWebhook", map[string]interface{}{}, nil); err != nil {
+			return err
+		}
+		settings.BotSecret = ""
+	default:
+		return fmt.Errorf("invalid bot mode %q (expected polling, webhook or empty)", mode)
+	}
+	settings.BotMode = mode
+
+	if err := notify.EncodeSettings(ch, settings); err != nil {
+		return err
+	}
+	if err := database.DB.Model(ch).Update("settings", ch.Settings).Error; err != nil {
+		return fmt.Errorf("failed to save bot mode: %w", err)
+	}
+	return nil
+}
+
+// botSettings returns the settings of a Telegram channel.
+func botSettings(ch database.Channel) (*notify.TelegramSettings, error) {
+	if ch.Type != notify.ChannelTelegram {
+		return nil, fmt.Errorf("channel %d is not a telegram channel", ch.ID)
+	}
+	s, err := notify.DecodeSettings(ch)
+	if err != nil {
+		return nil, err
+	}
+	return s.(*notify.TelegramSettings), nil
+}
+
+// WebhookURL returns the endpoint Telegram posts the updates of a channel to.
+func WebhookURL(channelID uint) string {
+	return notify.PublicURL() + "/v1/telegram/webhook/" + strconv.FormatUint(uint64(channelID), 10)
+}
+
+func newSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// HandleWebhook handles an update posted to the webhook endpoint of a channel, after
+// checking the secret Telegram sends in the X-Telegram-Bot-Api-Secret-Token header.
+// The command of the update runs in the background, and repeated updates are ignored.
+func HandleWebhook(ctx context.Context, channelID uint, secret string, u Update) error {
+	if database.DB == nil {
+		return database.ErrDatabaseNotInitialized
+	}
+
+	var ch database.Channel
+	err := database.DB.Where("id = ? AND type = ? AND is_active = ?", channelID, notify.ChannelTelegram, true).Limit(1).Find(&ch).Error
+	if err != nil {
+		return err
+	}
+	if ch.ID == 0 {
+		return ErrUnauthorized
+	}
+	settings, err := botSettings(ch)
+	if err != nil {
+		return err
+	}
+	if settings.BotMode != ModeWebhook || settings.BotSecret == "" ||
+		subtle.ConstantTimeCompare([]byte(secret), []byte(settings.BotSecret)) != 1 {
+		return ErrUnauthorized
+	}
+
+	if !webhookUpdates.first(channelID, u.UpdateID) {
+		return nil
+	}
+	// Commands like /scan take longer than Telegram waits for the response, after
+	// which it delivers the update again. They run once the update is acknowledged.
+	handling.Add(1)
+	go func() {
+		defer handling.Done()
+		handleUpdate(context.WithoutCancel(ctx), settings.Token, u)
+	}()
+	return nil
+}
+
+// handling tracks the webhook updates being handled.
+var handling sync.WaitGroup
+
+// maxSeenUpdates is how many update IDs of each channel webhookUpdates remembers.
+const maxSeenUpdates = 100
+
+// webhookUpdates remembers the last webhook updates of each channel, so updates
+// Telegram delivers again aren't handled twice.
+var webhookUpdates = &seenUpdates{ids: map[uint][]int64{}}
+
+type seenUpdates struct {
+	mu  sync.Mutex
+	ids map[uint][]int64
+}
+
+// first records an update of a channel and reports whether it wasn't seen before.
+func (s *seenUpdates) first(channelID uint, updateID int64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := s.ids[channelID]
+	for _, id := range ids {
+		if id == updateID {
+			return false
+		}
+	}
+	if len(ids) == maxSeenUpdates {
+		ids = append(ids[:0], ids[1:]...)
+	}
+	s.ids[channelID] = append(ids, updateID)
+	return true
+}
+
+// Poll fetches and handles the pending updates of every active Telegram channel in
+// polling mode. Each call waits up to pollTimeout seconds for new updates.
+func Poll(ctx context.Context) {
+	if database.DB == nil {
+		return
+	}
+
+	var channels []database.Channel
+	if err := database.DB.Where("type = ? AND is_active = ?", notify.ChannelTelegram, true).Find(&channels).Error; err != nil {
+		log.Printf("Error loading telegram bots: %v", err)
+		return
+	}
+
+	var wg sync.WaitGroup
+	for _, ch := range channels {
+		settings, err := botSettings(ch)
+		if err != nil {
+			log.Printf("Error loading telegram bot %d: %v", ch.ID, err)
+			continue
+		}
+		if settings.BotMode != ModePolling {
+			continue
+		}
+		wg.Add(1)
+		go func(ch database.Channel, token string) {
+			defer wg.Done()
+			if err := poll(ctx, ch, token); err != nil && ctx.Err() == nil {
+				log.Printf("Error polling telegram bot %d: %v", ch.ID, err)
+			}
+		}(ch, settings.Token)
+	}
+	wg.Wait()
+}
+
+func poll(ctx context.Context, ch database.Channel, token string) error {
+	var updates []Update
+	err := call(ctx, token, "getUpdates", map[string]interface{}{
+		"offset":          ch.Cursor,
+		"timeout":         pollTimeout,
+		"allowed_updates": []string{"message"},
+	}, &updates)
+	if err != nil {
+		return err
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+
+	offset := ch.Cursor
+	for _, u := range updates {
+		handleUpdate(ctx, token, u)
+		offset = u.UpdateID + 1
+	}
+	// Telegram confirms updates before the offset, so they aren't delivered again
+	return database.DB.Model(&database.Channel{}).Where("id = ?", ch.ID).Update("cursor", offset).Error
+}
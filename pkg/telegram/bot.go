@@ -0,0 +1,364 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"github.com/harveywai/zenstack/pkg/notify"
+	"github.com/harveywai/zenstack/pkg/oncall"
+	"gorm.io/gorm"
+)
+
+// Scanner runs the checks of a domain right away and returns a short summary. The
+// server sets it to the monitoring engine, which can't be imported here.
+var Scanner func(ctx context.Context, domain database.MonitoredDomain) (string, error)
+
+// Limits of command responses.
+const (
+	maxListed      = 30 // Items listed by /down and /expiring
+	maxExpiringDay = 365
+	scanTimeout    = time.Minute
+)
+
+// command is a bot command. Admin commands are refused for other users. Commands
+// that act on behalf of the user, like admin commands, must be sent by a linked user:
+// linking a group chat only gives its members the read-only commands.
+type command struct {
+	usage string
+	help  string
+	admin bool
+	acts  bool
+	run   func(ctx context.Context, user database.User, args []string) string
+}
+
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"/help":     {usage: "/help", help: "List the commands", run: runHelp},
+		"/status":   {usage: "/status <domain>", help: "Show the health, certificate and registration of a domain", run: runStatus},
+		"/down":     {usage: "/down", help: "List the sites failing their health check", run: runDown},
+		"/expiring": {usage: "/expiring [days]", help: "List certificates and registrations expiring within 30 or the given days", run: runExpiring},
+		"/scan":     {usage: "/scan <domain>", help: "Check a domain now", run: runScan},
+		"/ack":      {usage: "/ack <escalation>", help: "Acknowledge an on-call page and stop its escalation", acts: true, run: runAck},
+		"/silence":  {usage: "/silence <domain> <duration>", help: "Silence the notifications of a domain, e.g. for 2h or 7d", admin: true, run: runSilence},
+	}
+	commands["/start"] = commands["/help"]
+}
+
+// handleUpdate runs the command of an incoming message and replies to its chat.
+// Messages that aren't commands are ignored.
+func handleUpdate(ctx context.Context, token string, u Update) {
+	if u.Message == nil || !strings.HasPrefix(u.Message.Text, "/") {
+		return
+	}
+	chatID := u.Message.Chat.ID
+	text := Execute(ctx, u.Message, chatID)
+	if err := reply(ctx, token, chatID, text); err != nil {
+		log.Printf("Error replying to telegram chat %d: %v", chatID, err)
+	}
+}
+
+// Execute runs the command of a message on behalf of the user linked to the sender
+// or, for read-only commands, the chat, and returns the response.
+func Execute(ctx context.Context, msg *Message, chatID int64) string {
+	fields := strings.Fields(msg.Text)
+	// Commands in groups may be addressed to a bot, e.g. /status@zenstack_bot
+	name, _, _ := strings.Cut(strings.ToLower(fields[0]), "@")
+	args := fields[1:]
+
+	user, sender, ok := authorize(msg)
+	if !ok {
+		return fmt.Sprintf("This chat is not linked to a ZenStack user. Ask an admin to set your Telegram chat ID to %d.", chatID)
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		return fmt.Sprintf("Unknown command %s. Send /help for the list of commands.", name)
+	}
+	if (cmd.admin || cmd.acts) && !sender {
+		if msg.From == nil {
+			return name + " must be sent by a linked user."
+		}
+		return fmt.Sprintf("%s must be sent by a linked user. Ask an admin to set your Telegram chat ID to %d.", name, msg.From.ID)
+	}
+	if cmd.admin && user.Role != "admin" {
+		return name + " is only available to admins."
+	}
+
+	log.Printf("Telegram command %s from %s", strings.Join(fields, " "), user.Username)
+	return cmd.run(ctx, user, args)
+}
+
+// authorize returns the active user whose Telegram chat ID is the sender of a message
+// or, for chats shared by a team, the chat itself. It reports whether the user is the
+// sender's.
+func authorize(msg *Message) (user database.User, sender bool, ok bool) {
+	if database.DB == nil {
+		return database.User{}, false, false
+	}
+
+	if msg.From != nil {
+		user, err := linkedUser(msg.From.ID)
+		if err != nil {
+			return database.User{}, false, false
+		}
+		if user.ID != 0 {
+			return user, true, true
+		}
+	}
+	user, err := linkedUser(msg.Chat.ID)
+	if err != nil || user.ID == 0 {
+		return database.User{}, false, false
+	}
+	return user, false, true
+}
+
+// linkedUser returns the active user whose Telegram chat ID is id, if any.
+func linkedUser(id int64) (database.User, error) {
+	var user database.User
+	err := database.DB.Where("telegram_chat_id = ? AND status = ?", strconv.FormatInt(id, 10), "active").Limit(1).Find(&user).Error
+	if err != nil {
+		log.Printf("Error authorizing telegram chat %d: %v", id, err)
+	}
+	return user, err
+}
+
+// findDomain loads a monitored domain by name, accepting URLs as well. If there is
+// none, the response explaining why is returned instead.
+func findDomain(args []string, usage string) (database.MonitoredDomain, string) {
+	var domain database.MonitoredDomain
+	if len(args) == 0 {
+		return domain, "Usage: " + usage
+	}
+	name := strings.ToLower(args[0])
+	name = strings.TrimPrefix(strings.TrimPrefix(name, "https://"), "http://")
+	name, _, _ = strings.Cut(name, "/")
+
+	if err := database.DB.Where("domain_name = ?", name).Limit(1).Find(&domain).Error; err != nil {
+		return domain, "Failed to load domain " + name
+	}
+	if domain.ID == 0 {
+		return domain, "Domain " + name + " is not monitored"
+	}
+	return domain, ""
+}
+
+func runHelp(ctx context.Context, user database.User, args []string) string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		if name != "/start" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString("ZenStack commands:\n")
+	for _, name := range names {
+		cmd := commands[name]
+		if cmd.admin && user.Role != "admin" {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s\n  %s", cmd.usage, cmd.help)
+	}
+	return b.String()
+}
+
+func runStatus(ctx context.Context, user database.User, args []string) string {
+	domain, problem := findDomain(args, "/status <domain>")
+	if problem != "" {
+		return problem
+	}
+
+	var last database.Heartbeat
+	database.DB.Where("domain_id = ?", domain.ID).Order("created_at desc").Limit(1).Find(&last)
+
+	var b strings.Builder
+	b.WriteString(domain.DomainName)
+	switch {
+	case last.ID == 0:
+		b.WriteString("\nSite: not checked yet")
+	case domain.IsLive:
+		fmt.Fprintf(&b, "\nSite: up (HTTP %d, %d ms) at %s", last.StatusCode, last.Latency, last.CreatedAt.Format("15:04 MST"))
+	default:
+		fmt.Fprintf(&b, "\nSite: DOWN (HTTP %d) at %s", last.StatusCode, last.CreatedAt.Format("15:04 MST"))
+	}
+	if !domain.SSLExpiry.IsZero() {
+		fmt.Fprintf(&b, "\nCertificate: %s, expires %s (%s)", orDash(domain.SSLStatus), domain.SSLExpiry.Format("2006-01-02"), daysLeft(domain.SSLExpiry))
+		if domain.Issuer != "" {
+			fmt.Fprintf(&b, "\nIssuer: %s", domain.Issuer)
+		}
+	}
+	if !domain.LastExpiryDate.IsZero() {
+		fmt.Fprintf(&b, "\nRegistration: expires %s (%s)", domain.LastExpiryDate.Format("2006-01-02"), daysLeft(domain.LastExpiryDate))
+		if domain.Registrar != "" {
+			fmt.Fprintf(&b, ", %s", domain.Registrar)
+		}
+	}
+	if !domain.LastCheckTime.IsZero() {
+		fmt.Fprintf(&b, "\nLast certificate check: %s", domain.LastCheckTime.Format("2006-01-02 15:04 MST"))
+	}
+	if silence, err := notify.ActiveSilence(database.DB, "*", domain, time.Now()); err == nil && silence != nil {
+		fmt.Fprintf(&b, "\nSilenced until %s (silence #%d)", silence.EndsAt.Format("2006-01-02 15:04 MST"), silence.ID)
+	}
+	return b.String()
+}
+
+func runDown(ctx context.Context, user database.User, args []string) string {
+	// Domains without heartbeats were never checked rather than down
+	var domains []database.MonitoredDomain
+	err := database.DB.
+		Where("is_live = ? AND id IN (?)", false, database.DB.Model(&database.Heartbeat{}).Select("domain_id")).
+		Order("domain_name asc").
+		Find(&domains).Error
+	if err != nil {
+		return "Failed to load domains"
+	}
+	if len(domains) == 0 {
+		return "All sites are up."
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d site(s) down:", len(domains))
+	for i, d := range domains {
+		if i == maxListed {
+			fmt.Fprintf(&b, "\n… and %d more", len(domains)-maxListed)
+			break
+		}
+		fmt.Fprintf(&b, "\n• %s (HTTP %d)", d.DomainName, d.LastStatusCode)
+	}
+	return b.String()
+}
+
+func runExpiring(ctx context.Context, user database.User, args []string) string {
+	days := 30
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil || n < 1 || n > maxExpiringDay {
+			return fmt.Sprintf("Usage: /expiring [days], with 1 to %d days", maxExpiringDay)
+		}
+		days = n
+	}
+
+	var domains []database.MonitoredDomain
+	if err := database.DB.Find(&domains).Error; err != nil {
+		return "Failed to load domains"
+	}
+
+	type expiry struct {
+		label string
+		at    time.Time
+	}
+	var items []expiry
+	horizon := time.Now().AddDate(0, 0, days)
+	for _, d := range domains {
+		if !d.SSLExpiry.IsZero() && d.SSLExpiry.Before(horizon) {
+			items = append(items, expiry{d.DomainName + " certificate", d.SSLExpiry})
+		}
+		if !d.LastExpiryDate.IsZero() && d.LastExpiryDate.Before(horizon) {
+			items = append(items, expiry{d.DomainName + " registration", d.LastExpiryDate})
+		}
+	}
+	if len(items) == 0 {
+		return fmt.Sprintf("Nothing expires within %d days.", days)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].at.Before(items[j].at) })
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Expiring within %d days:", days)
+	for i, item := range items {
+		if i == maxListed {
+			fmt.Fprintf(&b, "\n… and %d more", len(items)-maxListed)
+			break
+		}
+		fmt.Fprintf(&b, "\n• %s: %s (%s)", item.label, item.at.Format("2006-01-02"), daysLeft(item.at))
+	}
+	return b.String()
+}
+
+func runScan(ctx context.Context, user database.User, args []string) string {
+	domain, problem := findDomain(args, "/scan <domain>")
+	if problem != "" {
+		return problem
+	}
+	if Scanner == nil {
+		return "Scanning is not available on this server"
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, scanTimeout)
+	defer cancel()
+	summary, err := Scanner(ctx, domain)
+	if err != nil {
+		return fmt.Sprintf("Scan of %s failed: %v", domain.DomainName, err)
+	}
+	return summary
+}
+
+func runAck(ctx context.Context, user database.User, args []string) string {
+	if len(args) == 0 {
+		return "Usage: /ack <escalation>"
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(args[0], "#"), 10, 64)
+	if err != nil {
+		return "Usage: /ack <escalation>, e.g. /ack 12"
+	}
+
+	esc, err := oncall.Acknowledge(uint(id), user.ID)
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return fmt.Sprintf("Escalation #%d not found", id)
+	case errors.Is(err, oncall.ErrNotOpen):
+		return fmt.Sprintf("Escalation #%d is already resolved.", id)
+	case err != nil:
+		return fmt.Sprintf("Failed to acknowledge escalation #%d", id)
+	}
+	return fmt.Sprintf("Acknowledged escalation #%d: %s", esc.ID, esc.Title)
+}
+
+func runSilence(ctx context.Context, user database.User, args []string) string {
+	const usage = "/silence <domain> <duration> [comment]"
+	domain, problem := findDomain(args, usage)
+	if problem != "" {
+		return problem
+	}
+	if len(args) < 2 {
+		return fmt.Sprintf("Usage: %s", usage)
+	}
+	d, err := notify.ParseSnoozeDuration(args[1])
+	if err != nil {
+		return fmt.Sprintf("Usage: %s, e.g. 2h or 7d", usage)
+	}
+
+	comment := strings.Join(args[2:], " ")
+	if comment == "" {
+		comment = "Silenced from Telegram"
+	}
+	silence, err := notify.Snooze(domain.ID, d, user.Username, comment)
+	if err != nil {
+		return err.Error()
+	}
+	return fmt.Sprintf("Silenced %s until %s (silence #%d).", domain.DomainName, silence.EndsAt.Format("2006-01-02 15:04 MST"), silence.ID)
+}
+
+func daysLeft(t time.Time) string {
+	days := int(time.Until(t).Hours() / 24)
+	if days < 0 {
+		return fmt.Sprintf("expired %d days ago", -days)
+	}
+	return fmt.Sprintf("%d days left", days)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
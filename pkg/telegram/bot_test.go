@@ -0,0 +1,279 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/harveywai/zenstack/pkg/database"
+	"github.com/harveywai/zenstack/pkg/notify"
+	"github.com/harveywai/zenstack/pkg/secrets"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+const (
+	testToken  = "123:token"
+	testSecret = "hook-secret"
+	groupChat  = -1001
+	adminID    = 11
+	viewerID   = 22
+	strangerID = 33
+)
+
+// botAPI stands in for the Telegram Bot API and records the messages sent.
+type botAPI struct {
+	mu   sync.Mutex
+	sent []sentMessage
+}
+
+type sentMessage struct {
+	ChatID int64  `json:"chat_id"`
+	Text   string `json:"text"`
+}
+
+func (a *botAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/bot"+testToken+"/sendMessage" {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"ok":false,"error_code":404,"description":"Not Found"}`))
+		return
+	}
+	var m sentMessage
+	json.NewDecoder(r.Body).Decode(&m)
+	a.mu.Lock()
+	a.sent = append(a.sent, m)
+	a.mu.Unlock()
+	w.Write([]byte(`{"ok":true,"result":{}}`))
+}
+
+func (a *botAPI) take() []sentMessage {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	sent := a.sent
+	a.sent = nil
+	return sent
+}
+
+// setupBot creates a Telegram channel in webhook mode, an admin and a viewer linked
+// to their own chats, the admin also linked to a group chat, and a domain.
+func setupBot(t *testing.T) (*botAPI, uint) {
+	t.Helper()
+
+	key, err := secrets.GenerateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("ZENSTACK_MASTER_KEY", key)
+	if err := secrets.Load(); err != nil {
+		t.Fatal(err)
+	}
+
+	api := &botAPI{}
+	srv := httptest.NewServer(api)
+	t.Cleanup(srv.Close)
+	t.Setenv("ZENSTACK_TELEGRAM_API_URL", srv.URL)
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&database.Channel{}, &database.User{}, &database.MonitoredDomain{}, &database.Heartbeat{}, &database.Silence{}); err != nil {
+		t.Fatal(err)
+	}
+	prev := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = prev })
+
+	ch := database.Channel{Type: notify.ChannelTelegram, IsActive: true}
+	settings := &notify.TelegramSettings{Token: testToken, ChatID: "1", BotMode: ModeWebhook, BotSecret: testSecret}
+	if err := notify.EncodeSettings(&ch, settings); err != nil {
+		t.Fatal(err)
+	}
+	users := []database.User{
+		{Username: "admin", Role: "admin", Status: "active", TelegramChatID: "11"},
+		{Username: "team", Role: "admin", Status: "active", TelegramChatID: "-1001"},
+		{Username: "viewer", Role: "user", Status: "active", TelegramChatID: "22"},
+		{Username: "pending", Role: "admin", Status: "pending", TelegramChatID: "44"},
+	}
+	if err := notify.CreateChannel(db, &ch); err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range []interface{}{&users, &database.MonitoredDomain{DomainName: "example.com"}} {
+		if err := db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return api, ch.ID
+}
+
+// lastUpdateID numbers the updates of the tests.
+var lastUpdateID int64
+
+func update(chatID, fromID int64, text string) Update {
+	var u Update
+	lastUpdateID++
+	raw, _ := json.Marshal(map[string]interface{}{
+		"update_id": lastUpdateID,
+		"message": map[string]interface{}{
+			"text": text,
+			"from": map[string]interface{}{"id": fromID},
+			"chat": map[string]interface{}{"id": chatID},
+		},
+	})
+	json.Unmarshal(raw, &u)
+	return u
+}
+
+func TestWebhookCommands(t *testing.T) {
+	api, channelID := setupBot(t)
+
+	tests := []struct {
+		name   string
+		chat   int64
+		from   int64
+		text   string
+		expect string
+	}{
+		{"admin in private chat", adminID, adminID, "/silence example.com 2h", "Silenced example.com until"},
+		{"admin in group", groupChat, adminID, "/silence@zenstack_bot example.com 2h", "Silenced example.com until"},
+		{"unlinked member reads through the group", groupChat, strangerID, "/down", "All sites are up."},
+		{"unlinked member can't use admin commands through the group", groupChat, strangerID, "/silence example.com 2h", "/silence must be sent by a linked user. Ask an admin to set your Telegram chat ID to 33."},
+		{"unlinked member can't acknowledge through the group", groupChat, strangerID, "/ack 1", "/ack must be sent by a linked user."},
+		{"viewer in group", groupChat, viewerID, "/silence example.com 2h", "/silence is only available to admins."},
+		{"viewer in private chat", viewerID, viewerID, "/silence example.com 2h", "/silence is only available to admins."},
+		{"unlinked chat", strangerID, strangerID, "/down", "This chat is not linked to a ZenStack user. Ask an admin to set your Telegram chat ID to 33."},
+		{"pending user", 44, 44, "/down", "This chat is not linked to a ZenStack user."},
+		{"unknown command", adminID, adminID, "/reboot", "Unknown command /reboot."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := HandleWebhook(context.Background(), channelID, testSecret, update(tt.chat, tt.from, tt.text)); err != nil {
+				t.Fatal(err)
+			}
+			handling.Wait()
+			sent := api.take()
+			if len(sent) != 1 {
+				t.Fatalf("sent %d messages, want 1", len(sent))
+			}
+			if sent[0].ChatID != tt.chat {
+				t.Errorf("replied to chat %d, want %d", sent[0].ChatID, tt.chat)
+			}
+			if !strings.HasPrefix(sent[0].Text, tt.expect) {
+				t.Errorf("reply = %q, want it to start with %q", sent[0].Text, tt.expect)
+			}
+		})
+	}
+
+	var silences int64
+	database.DB.Model(&database.Silence{}).Count(&silences)
+	if silences != 2 {
+		t.Errorf("created %d silences, want the 2 of the admin", silences)
+	}
+}
+
+func TestWebhookRejectsWrongSecret(t *testing.T) {
+	api, channelID := setupBot(t)
+
+	for _, secret := range []string{"", "wrong"} {
+		err := HandleWebhook(context.Background(), channelID, secret, update(adminID, adminID, "/down"))
+		if !errors.Is(err, ErrUnauthorized) {
+			t.Errorf("secret %q: got %v, want ErrUnauthorized", secret, err)
+		}
+	}
+	handling.Wait()
+	if sent := api.take(); len(sent) != 0 {
+		t.Errorf("replied %d times to unauthorized updates", len(sent))
+	}
+}
+
+func TestWebhookAcknowledgesBeforeHandling(t *testing.T) {
+	api, channelID := setupBot(t)
+
+	gate := make(chan struct{})
+	var release sync.Once
+	t.Cleanup(func() {
+		release.Do(func() { close(gate) })
+		Scanner = nil
+	})
+	Scanner = func(ctx context.Context, domain database.MonitoredDomain) (string, error) {
+		<-gate
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "Scanned " + domain.DomainName, nil
+	}
+
+	// The webhook returns while the scan runs, and ignores the update delivered again
+	u := update(adminID, adminID, "/scan example.com")
+	ctx, cancel := context.WithCancel(context.Background())
+	returned := make(chan error, 1)
+	go func() {
+		for i := 0; i < 2; i++ {
+			if err := HandleWebhook(ctx, channelID, testSecret, u); err != nil {
+				returned <- err
+				return
+			}
+		}
+		returned <- nil
+	}()
+	select {
+	case err := <-returned:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the webhook waited for the scan")
+	}
+	// The scan outlives the request
+	cancel()
+	release.Do(func() { close(gate) })
+	handling.Wait()
+
+	sent := api.take()
+	if len(sent) != 1 || sent[0].Text != "Scanned example.com" {
+		t.Errorf("sent %+v, want one scan result", sent)
+	}
+
+	// Other channels may use the same update IDs
+	if !webhookUpdates.first(channelID+1, u.UpdateID) {
+		t.Error("an update of another channel was taken for a repeat")
+	}
+}
+
+func TestSeenUpdatesForgetsOldest(t *testing.T) {
+	s := &seenUpdates{ids: map[uint][]int64{}}
+	for id := int64(1); id <= maxSeenUpdates+1; id++ {
+		if !s.first(1, id) {
+			t.Fatalf("update %d taken for a repeat", id)
+		}
+	}
+	if s.first(1, maxSeenUpdates+1) || s.first(1, 2) {
+		t.Error("a recent update was handled again")
+	}
+	if !s.first(1, 1) {
+		t.Error("the oldest update is still remembered")
+	}
+}
+
+func TestExecuteWithoutSender(t *testing.T) {
+	setupBot(t)
+
+	// Channel posts have no sender: only the chat's read-only commands are allowed
+	var msg Message
+	json.Unmarshal([]byte(`{"text":"/silence example.com 2h","chat":{"id":-1001}}`), &msg)
+	if got := Execute(context.Background(), &msg, groupChat); got != "/silence must be sent by a linked user." {
+		t.Errorf("Execute() = %q", got)
+	}
+	msg.Text = "/help"
+	if got := Execute(context.Background(), &msg, groupChat); !strings.HasPrefix(got, "ZenStack commands:") {
+		t.Errorf("Execute() = %q", got)
+	}
+}
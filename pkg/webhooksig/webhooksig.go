@@ -0,0 +1,196 @@
+// Package webhooksig signs and verifies the generic webhooks sent by ZenStack.
+//
+// Each delivery carries three headers:
+//
+//	X-ZenStack-Delivery:  ID of the notification, the same for all retries
+//	X-ZenStack-Timestamp: Unix time of the attempt, in seconds
+//	X-ZenStack-Signature: v2=<hex HMAC-SHA256 of "<timestamp>.<delivery>.<body>" keyed with the secret>
+//
+// Receivers check the signature and reject stale timestamps, which stops requests
+// from being forged or replayed later. The signature covers the delivery ID, which
+// receivers deduplicate on, so it can't be swapped to have a notification processed
+// twice; v1 signatures, which didn't cover it, are no longer accepted. The package only depends on the standard
+// library so that receivers can import it on its own:
+//
+//	v := webhooksig.NewVerifier(os.Getenv("ZENSTACK_WEBHOOK_SECRET"))
+//	http.Handle("/hooks/zenstack", v.Middleware(handler))
+package webhooksig
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Headers of signed deliveries.
+const (
+	HeaderDelivery  = "X-ZenStack-Delivery"
+	HeaderTimestamp = "X-ZenStack-Timestamp"
+	HeaderSignature = "X-ZenStack-Signature"
+)
+
+// DefaultTolerance is how far the timestamp of a delivery may be from the clock of
+// the receiver.
+const DefaultTolerance = 5 * time.Minute
+
+// maxBody is the largest body VerifyRequest reads.
+const maxBody = 1 << 20
+
+// Verification errors.
+var (
+	ErrMissingHeaders   = errors.New("webhooksig: missing delivery, timestamp or signature header")
+	ErrInvalidTimestamp = errors.New("webhooksig: invalid timestamp")
+	ErrStaleTimestamp   = errors.New("webhooksig: timestamp outside the tolerance")
+	ErrInvalidSignature = errors.New("webhooksig: signature mismatch")
+	ErrReplayed         = errors.New("webhooksig: delivery attempt was already received")
+)
+
+// Sign returns the signature header value of a delivery body sent at a time.
+func Sign(secret, deliveryID string, timestamp time.Time, body []byte) string {
+	return "v2=" + hex.EncodeToString(mac(secret, strconv.FormatInt(timestamp.Unix(), 10), deliveryID, body))
+}
+
+func mac(secret, timestamp, deliveryID string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp))
+	h.Write([]byte("."))
+	h.Write([]byte(deliveryID))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// SignRequest sets the delivery, timestamp and signature headers of a request.
+// Without a secret the request is sent unsigned, with the other two headers only.
+func SignRequest(req *http.Request, secret, deliveryID string, body []byte, now time.Time) {
+	req.Header.Set(HeaderDelivery, deliveryID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix(), 10))
+	if secret != "" {
+		req.Header.Set(HeaderSignature, Sign(secret, deliveryID, now, body))
+	}
+}
+
+// Verify checks the signature and timestamp headers of a delivery body. The signature
+// header may list several comma-separated signatures, e.g. while a secret is rotated.
+func Verify(secret string, header http.Header, body []byte, tolerance time.Duration, now time.Time) error {
+	_, err := verify(secret, header, body, tolerance, now)
+	return err
+}
+
+// verify implements Verify and returns the signature that matched.
+func verify(secret string, header http.Header, body []byte, tolerance time.Duration, now time.Time) ([]byte, error) {
+	delivery := header.Get(HeaderDelivery)
+	ts := header.Get(HeaderTimestamp)
+	sigs := header.Get(HeaderSignature)
+	if delivery == "" || ts == "" || sigs == "" {
+		return nil, ErrMissingHeaders
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidTimestamp
+	}
+	if tolerance <= 0 {
+		tolerance = DefaultTolerance
+	}
+	if d := now.Sub(time.Unix(unix, 0)); d > tolerance || d < -tolerance {
+		return nil, ErrStaleTimestamp
+	}
+
+	expected := mac(secret, ts, delivery, body)
+	for _, sig := range strings.Split(sigs, ",") {
+		value, ok := strings.CutPrefix(strings.TrimSpace(sig), "v2=")
+		if !ok {
+			continue
+		}
+		if got, err := hex.DecodeString(value); err == nil && hmac.Equal(got, expected) {
+			return expected, nil
+		}
+	}
+	return nil, ErrInvalidSignature
+}
+
+// Verifier verifies deliveries and rejects replays of attempts it has seen within the
+// tolerance. Retries of a delivery are new attempts with a fresh timestamp and keep
+// their delivery ID, which receivers use to process a notification only once.
+type Verifier struct {
+	Secret    string
+	Tolerance time.Duration // DefaultTolerance if zero
+	Now       func() time.Time
+
+	mu   sync.Mutex
+	seen map[string]time.Time // Timestamps and signatures received, until they are outside the tolerance
+}
+
+// NewVerifier returns a verifier for a secret with the default tolerance.
+func NewVerifier(secret string) *Verifier {
+	return &Verifier{Secret: secret}
+}
+
+func (v *Verifier) tolerance() time.Duration {
+	if v.Tolerance > 0 {
+		return v.Tolerance
+	}
+	return DefaultTolerance
+}
+
+// Verify checks a delivery and records it, so that the same attempt is accepted once.
+func (v *Verifier) Verify(header http.Header, body []byte) error {
+	now := time.Now()
+	if v.Now != nil {
+		now = v.Now()
+	}
+	sig, err := verify(v.Secret, header, body, v.tolerance(), now)
+	if err != nil {
+		return err
+	}
+
+	// Keyed by the signature that matched rather than the header, which an attacker
+	// could pad with further entries
+	key := header.Get(HeaderTimestamp) + "." + hex.EncodeToString(sig)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.seen == nil {
+		v.seen = make(map[string]time.Time)
+	}
+	for k, at := range v.seen {
+		if now.Sub(at) > 2*v.tolerance() {
+			delete(v.seen, k)
+		}
+	}
+	if _, ok := v.seen[key]; ok {
+		return ErrReplayed
+	}
+	v.seen[key] = now
+	return nil
+}
+
+// VerifyRequest reads and verifies the body of a request and returns it. The request
+// body can be read again afterwards.
+func (v *Verifier) VerifyRequest(r *http.Request) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBody))
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return body, v.Verify(r.Header, body)
+}
+
+// Middleware passes verified requests to next and answers others with 401.
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := v.VerifyRequest(r); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,122 @@
+package webhooksig
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func signedHeader(secret string, at time.Time, body []byte) http.Header {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	SignRequest(req, secret, "delivery-1", body, at)
+	return req.Header
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	body := []byte(`{"event":"SITE_DOWN"}`)
+
+	tests := []struct {
+		name   string
+		header func() http.Header
+		want   error
+	}{
+		{"valid", func() http.Header { return signedHeader("s3cret", now, body) }, nil},
+		{"rotated secret listed second", func() http.Header {
+			h := signedHeader("s3cret", now, body)
+			h.Set(HeaderSignature, Sign("old", "delivery-1", now, body)+","+h.Get(HeaderSignature))
+			return h
+		}, nil},
+		{"wrong secret", func() http.Header { return signedHeader("other", now, body) }, ErrInvalidSignature},
+		{"stale", func() http.Header { return signedHeader("s3cret", now.Add(-10*time.Minute), body) }, ErrStaleTimestamp},
+		{"missing signature", func() http.Header { return signedHeader("", now, body) }, ErrMissingHeaders},
+		{"changed delivery", func() http.Header {
+			h := signedHeader("s3cret", now, body)
+			h.Set(HeaderDelivery, "delivery-2")
+			return h
+		}, ErrInvalidSignature},
+		{"missing delivery", func() http.Header {
+			h := signedHeader("s3cret", now, body)
+			h.Del(HeaderDelivery)
+			return h
+		}, ErrMissingHeaders},
+		{"v1 signature", func() http.Header {
+			h := signedHeader("s3cret", now, body)
+			h.Set(HeaderSignature, "v1="+strings.TrimPrefix(h.Get(HeaderSignature), "v2="))
+			return h
+		}, ErrInvalidSignature},
+		{"invalid timestamp", func() http.Header {
+			h := signedHeader("s3cret", now, body)
+			h.Set(HeaderTimestamp, "yesterday")
+			return h
+		}, ErrInvalidTimestamp},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Verify("s3cret", tt.header(), body, 0, now)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifierRejectsReplays(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	body := []byte(`{"event":"SITE_DOWN"}`)
+	v := &Verifier{Secret: "s3cret", Now: func() time.Time { return now }}
+
+	header := signedHeader("s3cret", now, body)
+	if err := v.Verify(header, body); err != nil {
+		t.Fatalf("first delivery: %v", err)
+	}
+	if err := v.Verify(header, body); !errors.Is(err, ErrReplayed) {
+		t.Errorf("replay: got %v, want ErrReplayed", err)
+	}
+
+	// Padding the signature header with further entries doesn't make a replay new
+	for _, sig := range []string{
+		header.Get(HeaderSignature) + ",x",
+		"v1=00," + header.Get(HeaderSignature),
+		" " + header.Get(HeaderSignature) + " ",
+	} {
+		padded := header.Clone()
+		padded.Set(HeaderSignature, sig)
+		if err := v.Verify(padded, body); !errors.Is(err, ErrReplayed) {
+			t.Errorf("replay with signature header %q: got %v, want ErrReplayed", sig, err)
+		}
+	}
+
+	// A retry is a new attempt with a fresh timestamp
+	retry := signedHeader("s3cret", now.Add(time.Second), body)
+	if err := v.Verify(retry, body); err != nil {
+		t.Errorf("retry: %v", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	body := `{"event":"SITE_DOWN"}`
+	v := NewVerifier("s3cret")
+	h := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/hooks", strings.NewReader(body))
+	SignRequest(req, "s3cret", "delivery-1", []byte(body), time.Now())
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("signed request: status %d, want 204", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/hooks", strings.NewReader(body))
+	SignRequest(req, "wrong", "delivery-2", []byte(body), time.Now())
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("forged request: status %d, want 401", rec.Code)
+	}
+}